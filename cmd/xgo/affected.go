@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/xhd2015/xgo/support/affected"
+	"github.com/xhd2015/xgo/support/coverage"
+)
+
+// selectAffectedTests implements --affected-since: package patterns
+// in remainArgs are replaced with packages affected by changes since
+// ref, and run is a -run pattern if tests could be narrowed down by
+// the index. skip is true if no test is affected.
+func selectAffectedTests(projectDir string, mod string, ref string, indexFile string, remainArgs []string) (newArgs []string, run string, skip bool, err error) {
+	var index *coverage.TestIndex
+	if indexFile != "" {
+		index, err = coverage.ReadTestIndex(indexFile)
+		if err != nil {
+			return nil, "", false, fmt.Errorf("read index: %w", err)
+		}
+	}
+
+	pkgArgs := getPkgArgs(remainArgs)
+	flags := remainArgs[:len(remainArgs)-len(pkgArgs)]
+	var patterns []string
+	for len(pkgArgs) > 0 && !strings.HasPrefix(pkgArgs[0], "-") {
+		patterns = append(patterns, pkgArgs[0])
+		pkgArgs = pkgArgs[1:]
+	}
+
+	res, err := affected.Select(&affected.Options{
+		Dir:   projectDir,
+		Ref:   ref,
+		Args:  patterns,
+		Mod:   mod,
+		Index: index,
+	})
+	if err != nil {
+		return nil, "", false, err
+	}
+	if res.IndexIgnored != "" {
+		fmt.Fprintf(os.Stderr, "WARNING: --affected-index not used, %s\n", res.IndexIgnored)
+	}
+	if len(res.Packages) == 0 {
+		fmt.Fprintf(os.Stderr, "no tests affected since %s, %d files changed\n", ref, len(res.Changes))
+		return nil, "", true, nil
+	}
+	fmt.Fprintf(os.Stderr, "tests affected since %s, %d files changed:\n", ref, len(res.Changes))
+	for _, pkg := range res.Packages {
+		tests := "all tests"
+		if len(pkg.Tests) > 0 {
+			tests = strings.Join(pkg.Tests, ",")
+		}
+		fmt.Fprintf(os.Stderr, "  %s: %s (%s)\n", pkg.ImportPath, tests, pkg.Reason)
+	}
+
+	pkgs, run := res.TestArgs()
+	newArgs = make([]string, 0, len(flags)+len(pkgs)+len(pkgArgs))
+	newArgs = append(newArgs, flags...)
+	newArgs = append(newArgs, pkgs...)
+	newArgs = append(newArgs, pkgArgs...)
+	return newArgs, run, false, nil
+}
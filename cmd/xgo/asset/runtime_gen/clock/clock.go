@@ -0,0 +1,408 @@
+package clock
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xhd2015/xgo/runtime/core"
+	"github.com/xhd2015/xgo/runtime/mock"
+)
+
+// Clock is a fake clock installed on the current goroutine
+// and goroutines created by it afterwards.
+// Time only moves when Advance or Set is called, timers,
+// tickers and sleepers are fired in deadline order.
+//
+// The following functions are intercepted:
+//   - time.Now, time.Since, time.Until
+//   - time.Sleep
+//   - time.NewTimer, time.After, time.AfterFunc, (*time.Timer).Stop, (*time.Timer).Reset
+//   - time.NewTicker, time.Tick, (*time.Ticker).Stop, (*time.Ticker).Reset
+//
+// They are trapped when the program is built with `xgo test`,
+// or with the `--trap-stdlib` flag.
+type Clock struct {
+	mutex sync.Mutex
+	cond  *sync.Cond
+
+	now         time.Time
+	autoAdvance bool
+
+	waiters []*waiter
+	timers  map[*time.Timer]*waiter
+	tickers map[*time.Ticker]*waiter
+
+	cancels []func()
+}
+
+// waiter is a pending timer, ticker or sleep
+type waiter struct {
+	deadline time.Time
+	// period > 0 for tickers
+	period time.Duration
+
+	ch chan time.Time
+	// fn is set by AfterFunc
+	fn func()
+	// done is closed when a sleep finishes
+	done chan struct{}
+}
+
+// Freeze installs a fake clock stopped at current time,
+// see FreezeAt.
+func Freeze(t testing.TB) *Clock {
+	return FreezeAt(t, time.Now())
+}
+
+// FreezeAt installs a fake clock stopped at `at`.
+// If `t` is not nil, the clock is restored when the test
+// finishes. Otherwise, Restore must be called to avoid
+// affecting other tests, since methods of timers and
+// tickers are mocked globally.
+func FreezeAt(t testing.TB, at time.Time) *Clock {
+	c := &Clock{
+		now:     at,
+		timers:  make(map[*time.Timer]*waiter),
+		tickers: make(map[*time.Ticker]*waiter),
+	}
+	c.cond = sync.NewCond(&c.mutex)
+	c.install()
+	if t != nil {
+		t.Cleanup(c.Restore)
+	}
+	return c
+}
+
+// Restore uninstalls the clock, later calls
+// go to the real time package.
+// Pending sleeps are released, channels of timers
+// and tickers created by the clock are closed.
+// These timers and tickers must not be stopped or
+// reset after Restore, since they are not backed
+// by the runtime.
+func (c *Clock) Restore() {
+	cancels := c.cancels
+	c.cancels = nil
+	for i := len(cancels) - 1; i >= 0; i-- {
+		cancels[i]()
+	}
+	c.mutex.Lock()
+	for _, w := range c.waiters {
+		if w.done != nil {
+			close(w.done)
+		}
+	}
+	for _, w := range c.timers {
+		if w.ch != nil {
+			close(w.ch)
+		}
+	}
+	for _, w := range c.tickers {
+		close(w.ch)
+	}
+	c.waiters = nil
+	c.timers = make(map[*time.Timer]*waiter)
+	c.tickers = make(map[*time.Ticker]*waiter)
+	c.cond.Broadcast()
+	c.mutex.Unlock()
+}
+
+// Now returns the current time of the clock
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by `d`, firing
+// timers, tickers and sleeps whose deadline is reached
+func (c *Clock) Advance(d time.Duration) {
+	if d < 0 {
+		panic("clock: negative duration")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.advanceTo(c.now.Add(d))
+}
+
+// Set sets the clock to `t`. If `t` is after current time,
+// it is the same as Advance. Otherwise the clock goes back,
+// pending deadlines are not changed.
+func (c *Clock) Set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if t.After(c.now) {
+		c.advanceTo(t)
+		return
+	}
+	c.now = t
+}
+
+// SetAutoAdvance controls how time.Sleep behaves.
+// By default, time.Sleep blocks until another goroutine advances
+// the clock to its deadline. With auto advance enabled,
+// time.Sleep advances the clock by the given duration and
+// returns immediately, which is useful for testing retry
+// logic running in a single goroutine.
+func (c *Clock) SetAutoAdvance(enable bool) {
+	c.mutex.Lock()
+	c.autoAdvance = enable
+	c.mutex.Unlock()
+}
+
+// BlockUntil blocks until at least `n` timers, tickers
+// and sleeps are pending on the clock.
+// It is used to wait for the code under test to reach
+// its waiting point before calling Advance.
+func (c *Clock) BlockUntil(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+// advanceTo must be called with mutex held
+func (c *Clock) advanceTo(t time.Time) {
+	for {
+		w := c.nextDue(t)
+		if w == nil {
+			break
+		}
+		c.now = w.deadline
+		c.fire(w)
+	}
+	c.now = t
+}
+
+func (c *Clock) nextDue(t time.Time) *waiter {
+	var next *waiter
+	for _, w := range c.waiters {
+		if w.deadline.After(t) {
+			continue
+		}
+		if next == nil || w.deadline.Before(next.deadline) {
+			next = w
+		}
+	}
+	return next
+}
+
+func (c *Clock) fire(w *waiter) {
+	if w.period > 0 {
+		w.deadline = w.deadline.Add(w.period)
+	} else {
+		c.removeWaiter(w)
+	}
+	if w.done != nil {
+		close(w.done)
+		return
+	}
+	if w.fn != nil {
+		go w.fn()
+		return
+	}
+	// drop if the receiver falls behind,
+	// same as the real timer
+	select {
+	case w.ch <- c.now:
+	default:
+	}
+}
+
+func (c *Clock) addWaiter(w *waiter) {
+	c.waiters = append(c.waiters, w)
+	c.cond.Broadcast()
+}
+
+// removeWaiter returns true if `w` was pending
+func (c *Clock) removeWaiter(w *waiter) bool {
+	for i, x := range c.waiters {
+		if x == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func drain(ch chan time.Time) {
+	if ch == nil {
+		return
+	}
+	select {
+	case <-ch:
+	default:
+	}
+}
+
+func (c *Clock) sleep(d time.Duration) {
+	c.mutex.Lock()
+	if c.autoAdvance {
+		if d > 0 {
+			c.advanceTo(c.now.Add(d))
+		}
+		c.mutex.Unlock()
+		return
+	}
+	if d <= 0 {
+		c.mutex.Unlock()
+		return
+	}
+	w := &waiter{
+		deadline: c.now.Add(d),
+		done:     make(chan struct{}),
+	}
+	c.addWaiter(w)
+	c.mutex.Unlock()
+	<-w.done
+}
+
+func (c *Clock) newTimer(d time.Duration, fn func()) *time.Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	w := &waiter{
+		deadline: c.now.Add(d),
+		fn:       fn,
+	}
+	t := &time.Timer{}
+	if fn == nil {
+		w.ch = make(chan time.Time, 1)
+		t.C = w.ch
+	}
+	c.timers[t] = w
+	c.addWaiter(w)
+	if d <= 0 {
+		c.fire(w)
+	}
+	return t
+}
+
+func (c *Clock) newTicker(d time.Duration) *time.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	w := &waiter{
+		deadline: c.now.Add(d),
+		period:   d,
+		ch:       make(chan time.Time, 1),
+	}
+	t := &time.Ticker{C: w.ch}
+	c.tickers[t] = w
+	c.addWaiter(w)
+	return t
+}
+
+func (c *Clock) install() {
+	c.cancels = append(c.cancels,
+		mock.Patch(time.Now, c.Now),
+		mock.Patch(time.Since, func(t time.Time) time.Duration {
+			return c.Now().Sub(t)
+		}),
+		mock.Patch(time.Until, func(t time.Time) time.Duration {
+			return t.Sub(c.Now())
+		}),
+		mock.Patch(time.Sleep, c.sleep),
+		mock.Patch(time.NewTimer, func(d time.Duration) *time.Timer {
+			return c.newTimer(d, nil)
+		}),
+		mock.Patch(time.After, func(d time.Duration) <-chan time.Time {
+			return c.newTimer(d, nil).C
+		}),
+		mock.Patch(time.AfterFunc, func(d time.Duration, f func()) *time.Timer {
+			return c.newTimer(d, f)
+		}),
+		mock.Patch(time.NewTicker, c.newTicker),
+		mock.Patch(time.Tick, func(d time.Duration) <-chan time.Time {
+			if d <= 0 {
+				return nil
+			}
+			return c.newTicker(d).C
+		}),
+	)
+	// timers may be passed to goroutines not created by
+	// current goroutine, so methods are trapped globally,
+	// calling the original method on them would panic
+	global := mock.Global(nil)
+	c.cancels = append(c.cancels,
+		global.Mock((*time.Timer).Stop, c.timerStop),
+		global.Mock((*time.Timer).Reset, c.timerReset),
+		global.Mock((*time.Ticker).Stop, c.tickerStop),
+		global.Mock((*time.Ticker).Reset, c.tickerReset),
+	)
+}
+
+// interceptors on methods receive the receiver as the
+// first arg, timers not created by this clock are
+// passed to the original method
+
+func (c *Clock) timerStop(ctx context.Context, fn *core.FuncInfo, args core.Object, results core.Object) error {
+	t := args.GetFieldIndex(0).Value().(*time.Timer)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	w := c.timers[t]
+	if w == nil {
+		return mock.ErrCallOld
+	}
+	active := c.removeWaiter(w)
+	drain(w.ch)
+	results.GetFieldIndex(0).Set(active)
+	return nil
+}
+
+func (c *Clock) timerReset(ctx context.Context, fn *core.FuncInfo, args core.Object, results core.Object) error {
+	t := args.GetFieldIndex(0).Value().(*time.Timer)
+	d := args.GetFieldIndex(1).Value().(time.Duration)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	w := c.timers[t]
+	if w == nil {
+		return mock.ErrCallOld
+	}
+	active := c.removeWaiter(w)
+	drain(w.ch)
+	w.deadline = c.now.Add(d)
+	c.addWaiter(w)
+	if d <= 0 {
+		c.fire(w)
+	}
+	results.GetFieldIndex(0).Set(active)
+	return nil
+}
+
+func (c *Clock) tickerStop(ctx context.Context, fn *core.FuncInfo, args core.Object, results core.Object) error {
+	t := args.GetFieldIndex(0).Value().(*time.Ticker)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	w := c.tickers[t]
+	if w == nil {
+		return mock.ErrCallOld
+	}
+	c.removeWaiter(w)
+	drain(w.ch)
+	return nil
+}
+
+func (c *Clock) tickerReset(ctx context.Context, fn *core.FuncInfo, args core.Object, results core.Object) error {
+	t := args.GetFieldIndex(0).Value().(*time.Ticker)
+	d := args.GetFieldIndex(1).Value().(time.Duration)
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	w := c.tickers[t]
+	if w == nil {
+		return mock.ErrCallOld
+	}
+	c.removeWaiter(w)
+	drain(w.ch)
+	w.period = d
+	w.deadline = c.now.Add(d)
+	c.addWaiter(w)
+	return nil
+}
@@ -254,6 +254,9 @@ func buildMockFromInterceptor(recvPtr interface{}, interceptor Interceptor) func
 
 		err := interceptor(ctx, funcInfo, argObj, resObject)
 		if err != nil {
+			if err == ErrCallOld {
+				return false
+			}
 			if funcInfo.LastResultErr {
 				lastErr := results[len(results)-1].(*error)
 				*lastErr = err
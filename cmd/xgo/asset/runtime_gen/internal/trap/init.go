@@ -59,13 +59,17 @@ func inerhitStack(curG stack.G, newG stack.G) {
 
 	// associate trace
 	if stackData.hasStartedTracing {
-		newStackData.hasStartedTracing = true
-		newStackData.filterTrace = stackData.filterTrace
-
 		// Append under parent stack mutex so Export cannot race with Push/Top.
-		curStack.AppendGoChild(newStack.Begin.Sub(curStack.Begin).Nanoseconds(), func() *stack.Stack {
+		depth, ok := curStack.AppendGoChild(newStack.Begin.Sub(curStack.Begin).Nanoseconds(), func() *stack.Stack {
 			return newStack
 		})
+		if ok {
+			newStackData.hasStartedTracing = true
+			newStackData.filterTrace = stackData.filterTrace
+			newStackData.traceLimits = stackData.traceLimits
+			// the go node itself takes one level
+			newStack.BaseDepth = depth + 1
+		}
 	}
 }
 
@@ -75,12 +79,15 @@ func cloneFuncMocks(mock map[uintptr][]*mockHolder) map[uintptr][]*mockHolder {
 	}
 	newMock := make(map[uintptr][]*mockHolder, len(mock))
 	for pc, mocks := range mock {
-		newMocks := make([]*mockHolder, len(mocks))
-		for i, m := range mocks {
-			newMocks[i] = &mockHolder{
+		newMocks := make([]*mockHolder, 0, len(mocks))
+		for _, m := range mocks {
+			if m.goroutineOnly {
+				continue
+			}
+			newMocks = append(newMocks, &mockHolder{
 				wantRecvPtr: m.wantRecvPtr,
 				mock:        m.mock,
-			}
+			})
 		}
 		newMock[pc] = newMocks
 	}
@@ -93,11 +100,14 @@ func cloneVarMocks(mock map[uintptr][]*varMockHolder) map[uintptr][]*varMockHold
 	}
 	newMock := make(map[uintptr][]*varMockHolder, len(mock))
 	for pc, mocks := range mock {
-		newMocks := make([]*varMockHolder, len(mocks))
-		for i, m := range mocks {
-			newMocks[i] = &varMockHolder{
-				mock: m.mock,
+		newMocks := make([]*varMockHolder, 0, len(mocks))
+		for _, m := range mocks {
+			if m.goroutineOnly {
+				continue
 			}
+			newMocks = append(newMocks, &varMockHolder{
+				mock: m.mock,
+			})
 		}
 		newMock[pc] = newMocks
 	}
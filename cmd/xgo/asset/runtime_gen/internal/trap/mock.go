@@ -33,29 +33,32 @@ var ErrNotInstrumented = errors.New("not instrumented by xgo, see https://github
 type mockHolder struct {
 	wantRecvPtr interface{}
 	mock        func(fnInfo *core.FuncInfo, recvPtr interface{}, args []interface{}, results []interface{}) bool
+	// not inherited by new goroutines
+	goroutineOnly bool
 }
 
 type varMockHolder struct {
-	mock func(fnInfo *core.FuncInfo, res interface{})
+	mock          func(fnInfo *core.FuncInfo, res interface{})
+	goroutineOnly bool
 }
 
-func PushMockInterceptor(fn interface{}, interceptor Interceptor) func() {
-	return pushMockInterceptor(fn, interceptor)
+func PushMockInterceptor(scope Scope, fn interface{}, interceptor Interceptor) func() {
+	return pushMockInterceptor(scope, fn, interceptor)
 }
 
-func PushMockReplacer(fn interface{}, replacer interface{}) func() {
-	return pushMockReplacer(fn, replacer)
+func PushMockReplacer(scope Scope, fn interface{}, replacer interface{}) func() {
+	return pushMockReplacer(scope, fn, replacer)
 }
 
-func PushMockReplacerByName(pkgPath string, funcName string, replacer interface{}) func() {
-	return pushMockReplacerByName(pkgPath, funcName, replacer)
+func PushMockReplacerByName(scope Scope, pkgPath string, funcName string, replacer interface{}) func() {
+	return pushMockReplacerByName(scope, pkgPath, funcName, replacer)
 }
 
-func PushMockReplacerMethodByName(instance interface{}, method string, replacer interface{}) func() {
-	return pushMockReplacerMethodByName(instance, method, replacer)
+func PushMockReplacerMethodByName(scope Scope, instance interface{}, method string, replacer interface{}) func() {
+	return pushMockReplacerMethodByName(scope, instance, method, replacer)
 }
 
-func PushMockByName(pkgPath string, funcName string, interceptor Interceptor) func() {
+func PushMockByName(scope Scope, pkgPath string, funcName string, interceptor Interceptor) func() {
 	recvPtr, funcInfo, _, trappingPC := getFuncByName(pkgPath, funcName)
 	if funcInfo.Kind == core.Kind_Var || funcInfo.Kind == core.Kind_VarPtr || funcInfo.Kind == core.Kind_Const {
 		if strings.HasPrefix(funcName, "*") {
@@ -70,21 +73,21 @@ func PushMockByName(pkgPath string, funcName string, interceptor Interceptor) fu
 				}
 				interceptor(context.Background(), fnInfo, argObj, resObject)
 			}
-			return pushVarPtrMockHandler(reflect.ValueOf(funcInfo.Var).Pointer(), handler)
+			return pushVarPtrMockHandler(scope, reflect.ValueOf(funcInfo.Var).Pointer(), handler)
 		}
-		return pushMockInterceptor(funcInfo.Var, interceptor)
+		return pushMockInterceptor(scope, funcInfo.Var, interceptor)
 	}
 	handler := buildMockFromInterceptor(recvPtr, interceptor)
-	return pushMockHandler(trappingPC, recvPtr, handler)
+	return pushMockHandler(scope, trappingPC, recvPtr, handler)
 }
 
-func PushMockMethodByName(instance interface{}, method string, interceptor Interceptor) func() {
+func PushMockMethodByName(scope Scope, instance interface{}, method string, interceptor Interceptor) func() {
 	recvPtr, _, _, trappingPC := getMethodByName(instance, method)
 	handler := buildMockFromInterceptor(recvPtr, interceptor)
-	return pushMockHandler(trappingPC, recvPtr, handler)
+	return pushMockHandler(scope, trappingPC, recvPtr, handler)
 }
 
-func pushMockInterceptor(fn interface{}, interceptor Interceptor) func() {
+func pushMockInterceptor(scope Scope, fn interface{}, interceptor Interceptor) func() {
 	fnv := reflect.ValueOf(fn)
 	if fnv.Kind() == reflect.Ptr {
 		varPtr := fnv.Pointer()
@@ -103,13 +106,13 @@ func pushMockInterceptor(fn interface{}, interceptor Interceptor) func() {
 			}
 			err := interceptor(context.Background(), fnInfo, argObj, resObject)
 			if err != nil {
-				if err == ErrMocked {
+				if err == ErrMocked || err == ErrCallOld {
 					return
 				}
 				panic(err)
 			}
 		}
-		return pushVarMockHandler(varPtr, handler)
+		return pushVarMockHandler(scope, varPtr, handler)
 	} else if fnv.Kind() == reflect.Func {
 		// func
 	} else {
@@ -118,10 +121,10 @@ func pushMockInterceptor(fn interface{}, interceptor Interceptor) func() {
 
 	recvPtr, _, _, trappingPC := Inspect(fn)
 	handler := buildMockFromInterceptor(recvPtr, interceptor)
-	return pushMockHandler(trappingPC, recvPtr, handler)
+	return pushMockHandler(scope, trappingPC, recvPtr, handler)
 }
 
-func pushMockReplacer(fn interface{}, replacer interface{}) func() {
+func pushMockReplacer(scope Scope, fn interface{}, replacer interface{}) func() {
 	fnv := reflect.ValueOf(fn)
 	if fnv.Kind() == reflect.Ptr {
 		varPtr := fnv.Pointer()
@@ -137,9 +140,9 @@ func pushMockReplacer(fn interface{}, replacer interface{}) func() {
 			reflect.ValueOf(res).Elem().Set(mockRes[0])
 		}
 		if !isPtr {
-			return pushVarMockHandler(varPtr, handler)
+			return pushVarMockHandler(scope, varPtr, handler)
 		}
-		return pushVarPtrMockHandler(varPtr, handler)
+		return pushVarPtrMockHandler(scope, varPtr, handler)
 	} else if fnv.Kind() == reflect.Func {
 		// func
 		replacerV := reflect.ValueOf(replacer)
@@ -159,7 +162,7 @@ func pushMockReplacer(fn interface{}, replacer interface{}) func() {
 
 	recvPtr, funcInfo, _, trappingPC := Inspect(fn)
 	handler := buildMockHandler(recvPtr, funcInfo, replacer)
-	return pushMockHandler(trappingPC, recvPtr, handler)
+	return pushMockHandler(scope, trappingPC, recvPtr, handler)
 }
 
 // pushMockHandler pushes a mock handler to the stack.
@@ -167,7 +170,25 @@ func pushMockReplacer(fn interface{}, replacer interface{}) func() {
 // If the mock is not popped, it will affect even after
 // the caller returned.
 // `mock` returns `false` if the original function should be called.
-func pushMockHandler(pc uintptr, recvPtr interface{}, handler func(fnInfo *core.FuncInfo, recvPtr interface{}, args []interface{}, results []interface{}) bool) func() {
+func pushMockHandler(scope Scope, pc uintptr, recvPtr interface{}, handler func(fnInfo *core.FuncInfo, recvPtr interface{}, args []interface{}, results []interface{}) bool) func() {
+	h := &mockHolder{wantRecvPtr: recvPtr, mock: handler, goroutineOnly: scope == ScopeGoroutine}
+	if scope == ScopeGlobal && runtime.XgoInitFinished() {
+		scopedGlobal.update(func(holder *interceptorHolders) {
+			holder.mock[pc] = append(holder.mock[pc], h)
+		})
+		return func() {
+			scopedGlobal.update(func(holder *interceptorHolders) {
+				list := holder.mock[pc]
+				for i, m := range list {
+					if m == h {
+						holder.mock[pc] = append(list[:i], list[i+1:]...)
+						return
+					}
+				}
+				panic(fmt.Errorf("pop mock not found, check if the mock is already popped earlier"))
+			})
+		}
+	}
 	holder := &globalInterceptorHolder
 	if runtime.XgoInitFinished() {
 		stackData := getOrAttachStackData()
@@ -176,7 +197,6 @@ func pushMockHandler(pc uintptr, recvPtr interface{}, handler func(fnInfo *core.
 	if holder.mock == nil {
 		holder.mock = map[uintptr][]*mockHolder{}
 	}
-	h := &mockHolder{wantRecvPtr: recvPtr, mock: handler}
 	holder.mock[pc] = append(holder.mock[pc], h)
 	return func() {
 		if holder == &globalInterceptorHolder && runtime.XgoInitFinished() {
@@ -199,7 +219,25 @@ func pushMockHandler(pc uintptr, recvPtr interface{}, handler func(fnInfo *core.
 	}
 }
 
-func pushVarMockHandler(varAddr uintptr, mock func(fnInfo *core.FuncInfo, res interface{})) func() {
+func pushVarMockHandler(scope Scope, varAddr uintptr, mock func(fnInfo *core.FuncInfo, res interface{})) func() {
+	h := &varMockHolder{mock: mock, goroutineOnly: scope == ScopeGoroutine}
+	if scope == ScopeGlobal && runtime.XgoInitFinished() {
+		scopedGlobal.update(func(holder *interceptorHolders) {
+			holder.varMock[varAddr] = append(holder.varMock[varAddr], h)
+		})
+		return func() {
+			scopedGlobal.update(func(holder *interceptorHolders) {
+				list := holder.varMock[varAddr]
+				for i, m := range list {
+					if m == h {
+						holder.varMock[varAddr] = append(list[:i], list[i+1:]...)
+						return
+					}
+				}
+				panic(fmt.Errorf("pop mock not found, check if the mock is already popped earlier"))
+			})
+		}
+	}
 	holder := &globalInterceptorHolder
 	if runtime.XgoInitFinished() {
 		stackData := getOrAttachStackData()
@@ -208,7 +246,6 @@ func pushVarMockHandler(varAddr uintptr, mock func(fnInfo *core.FuncInfo, res in
 	if holder.varMock == nil {
 		holder.varMock = map[uintptr][]*varMockHolder{}
 	}
-	h := &varMockHolder{mock: mock}
 	holder.varMock[varAddr] = append(holder.varMock[varAddr], h)
 	return func() {
 		if holder == &globalInterceptorHolder && runtime.XgoInitFinished() {
@@ -231,7 +268,25 @@ func pushVarMockHandler(varAddr uintptr, mock func(fnInfo *core.FuncInfo, res in
 	}
 }
 
-func pushVarPtrMockHandler(varAddr uintptr, mock func(fnInfo *core.FuncInfo, res interface{})) func() {
+func pushVarPtrMockHandler(scope Scope, varAddr uintptr, mock func(fnInfo *core.FuncInfo, res interface{})) func() {
+	h := &varMockHolder{mock: mock, goroutineOnly: scope == ScopeGoroutine}
+	if scope == ScopeGlobal && runtime.XgoInitFinished() {
+		scopedGlobal.update(func(holder *interceptorHolders) {
+			holder.varPtrMock[varAddr] = append(holder.varPtrMock[varAddr], h)
+		})
+		return func() {
+			scopedGlobal.update(func(holder *interceptorHolders) {
+				list := holder.varPtrMock[varAddr]
+				for i, m := range list {
+					if m == h {
+						holder.varPtrMock[varAddr] = append(list[:i], list[i+1:]...)
+						return
+					}
+				}
+				panic(fmt.Errorf("pop mock not found, check if the mock is already popped earlier"))
+			})
+		}
+	}
 	holder := &globalInterceptorHolder
 	if runtime.XgoInitFinished() {
 		stackData := getOrAttachStackData()
@@ -240,7 +295,6 @@ func pushVarPtrMockHandler(varAddr uintptr, mock func(fnInfo *core.FuncInfo, res
 	if holder.varPtrMock == nil {
 		holder.varPtrMock = map[uintptr][]*varMockHolder{}
 	}
-	h := &varMockHolder{mock: mock}
 	holder.varPtrMock[varAddr] = append(holder.varPtrMock[varAddr], h)
 	return func() {
 		if holder == &globalInterceptorHolder && runtime.XgoInitFinished() {
@@ -263,7 +317,7 @@ func pushVarPtrMockHandler(varAddr uintptr, mock func(fnInfo *core.FuncInfo, res
 	}
 }
 
-func pushMockReplacerByName(pkgPath string, funcName string, replacer interface{}) func() {
+func pushMockReplacerByName(scope Scope, pkgPath string, funcName string, replacer interface{}) func() {
 	if replacer == nil {
 		panic("replacer cannot be nil")
 	}
@@ -294,18 +348,18 @@ func pushMockReplacerByName(pkgPath string, funcName string, replacer interface{
 				fnRes := reflect.ValueOf(replacer).Call([]reflect.Value{})
 				reflect.ValueOf(res).Elem().Set(fnRes[0])
 			}
-			return pushVarPtrMockHandler(reflect.ValueOf(vr).Pointer(), handler)
+			return pushVarPtrMockHandler(scope, reflect.ValueOf(vr).Pointer(), handler)
 		}
-		return pushMockReplacer(funcInfo.Var, replacer)
+		return pushMockReplacer(scope, funcInfo.Var, replacer)
 	} else {
 		panic(fmt.Errorf("unrecognized func type: %s", funcInfo.Kind.String()))
 	}
 
 	handler := buildMockHandler(recvPtr, funcInfo, replacer)
-	return pushMockHandler(trappingPC, recvPtr, handler)
+	return pushMockHandler(scope, trappingPC, recvPtr, handler)
 }
 
-func pushMockReplacerMethodByName(instance interface{}, method string, replacer interface{}) func() {
+func pushMockReplacerMethodByName(scope Scope, instance interface{}, method string, replacer interface{}) func() {
 	if replacer == nil {
 		panic("replacer cannot be nil")
 	}
@@ -323,7 +377,7 @@ func pushMockReplacerMethodByName(instance interface{}, method string, replacer
 		}
 	}
 	handler := buildMockHandler(recvPtr, funcInfo, replacer)
-	return pushMockHandler(trappingPC, recvPtr, handler)
+	return pushMockHandler(scope, trappingPC, recvPtr, handler)
 }
 
 func getFuncByName(pkgPath string, funcName string) (recvPtr interface{}, fn *core.FuncInfo, funcPC uintptr, trappingPC uintptr) {
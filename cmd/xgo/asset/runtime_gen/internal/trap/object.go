@@ -13,6 +13,10 @@ import (
 // default size to shrink 1M
 const DEFAULT_SIZE_LIMIT = 1 * 1024 * 1024
 
+// TRUNCATED_MARKER is appended to values exceeding
+// the size limit, followed by the original size
+const TRUNCATED_MARKER = "...(truncated, total "
+
 type object []field
 
 type field struct {
@@ -94,6 +98,12 @@ func trySplitLastError(resultNames []string, results []interface{}) ([]string, [
 type structValue struct {
 	Names  []string
 	Values []interface{}
+
+	// SizeLimit is the max bytes of each marshaled
+	// value, default DEFAULT_SIZE_LIMIT
+	SizeLimit int
+	// Truncated is set if any value exceeds SizeLimit
+	Truncated bool
 }
 
 func newStructValue(names []string, values []interface{}) *structValue {
@@ -111,8 +121,13 @@ func (c *structValue) MarshalJSON() ([]byte, error) {
 			fieldName = fmt.Sprintf("__field_%d", i)
 		}
 		res := runtime.MarshalNoError(c.Values[i])
-		if len(res) > DEFAULT_SIZE_LIMIT {
-			fields[i] = fmt.Sprintf("%q: %q", fieldName, string(res[:DEFAULT_SIZE_LIMIT]))
+		limit := c.SizeLimit
+		if limit <= 0 {
+			limit = DEFAULT_SIZE_LIMIT
+		}
+		if len(res) > limit {
+			c.Truncated = true
+			fields[i] = fmt.Sprintf("%q: %q", fieldName, truncateJSON(res, limit))
 		} else {
 			fields[i] = fmt.Sprintf("%q: %s", fieldName, res)
 		}
@@ -120,6 +135,12 @@ func (c *structValue) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf("{%s}", strings.Join(fields, ","))), nil
 }
 
+// truncateJSON keeps the first limit bytes of
+// the marshaled value, followed by a marker
+func truncateJSON(res []byte, limit int) string {
+	return fmt.Sprintf("%s%s%d bytes)", res[:limit], TRUNCATED_MARKER, len(res))
+}
+
 type ptrType int
 
 const (
@@ -168,7 +189,7 @@ func checkFuncTypeMatch(a reflect.Type, b reflect.Type, skipAFirst bool) (atype
 		return formatFuncType(a, skipAFirst), formatFuncType(b, false), false
 	}
 
-	for i := 0; i < na; i++ {
+	for i := 0; i < nb; i++ {
 		ta := a.In(i + base)
 		tb := b.In(i)
 		if ta != tb {
@@ -0,0 +1,405 @@
+package trap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/xhd2015/xgo/runtime/core"
+	"github.com/xhd2015/xgo/runtime/internal/flags"
+	xgo_runtime "github.com/xhd2015/xgo/runtime/internal/runtime"
+)
+
+// replay mocks functions with results recorded
+// by a previous `xgo test --strace` run, see --strace-replay
+
+// keep the same with cmd/xgo/rule.go
+type replayRule struct {
+	Any        bool    `json:"any"`
+	Kind       *string `json:"kind"`
+	Pkg        *string `json:"pkg"`
+	Name       *string `json:"name"`
+	Stdlib     *bool   `json:"stdlib"`
+	MainModule *bool   `json:"main_module"`
+	Generic    *bool   `json:"generic"`
+	Exported   *bool   `json:"exported"`
+	Closure    *bool   `json:"closure"`
+	Action     string  `json:"action"` // include,exclude or empty
+}
+
+// replayEntry is the subset of stack_model.StackEntry
+// needed by replay
+type replayEntry struct {
+	FuncInfo *struct {
+		Pkg      string
+		Name     string
+		RecvType string
+		RecvName string
+	}
+	Args      json.RawMessage
+	Results   json.RawMessage
+	Panic     bool
+	Error     string
+	Truncated bool
+	Children  []*replayEntry
+}
+
+type replayRecord struct {
+	results json.RawMessage
+	panic   bool
+	err     string
+}
+
+type replayData struct {
+	mutex sync.Mutex
+	// func key -> args key -> records in call order
+	records map[string]map[string][]*replayRecord
+	// number of records consumed
+	consumed map[string]int
+	// funcs with truncated records, which
+	// cannot be replayed
+	truncated []string
+}
+
+var replayRulesOnce sync.Once
+var replayRules []*replayRule
+
+var mainModuleOnce sync.Once
+var mainModule string
+
+// loadReplayData reads <dir>/<testName>.json,
+// returns nil if the test was not recorded
+func loadReplayData(dir string, testName string) *replayData {
+	file := filepath.Join(dir, testName+".json")
+	content, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "WARNING: replay %s: %v\n", file, err)
+		}
+		return nil
+	}
+	var stk struct {
+		Children []*replayEntry
+	}
+	err = json.Unmarshal(content, &stk)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: replay %s: %v\n", file, err)
+		return nil
+	}
+	data := &replayData{
+		records:  make(map[string]map[string][]*replayRecord),
+		consumed: make(map[string]int),
+	}
+	// top level entries are the tests themselves
+	for _, entry := range stk.Children {
+		data.addEntries(entry.Children)
+	}
+	for _, funcKey := range data.truncated {
+		fmt.Fprintf(os.Stderr, "WARNING: replay %s: %s has truncated args or results, not replayed, record again with larger --strace-max-arg-bytes\n", file, funcKey)
+	}
+	return data
+}
+
+func (c *replayData) addEntries(entries []*replayEntry) {
+	for _, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		if entry.FuncInfo != nil && entry.Truncated {
+			funcKey := entry.FuncInfo.Pkg + "." + entry.FuncInfo.Name
+			if !listContains(c.truncated, funcKey) {
+				c.truncated = append(c.truncated, funcKey)
+			}
+		} else if entry.FuncInfo != nil {
+			var recvField string
+			if entry.FuncInfo.RecvType != "" {
+				// receiver is prepended to args
+				recvField = structFieldName(entry.FuncInfo.RecvName, 0)
+			}
+			argsKey, err := replayArgsKey(entry.Args, recvField)
+			if err == nil {
+				funcKey := entry.FuncInfo.Pkg + "." + entry.FuncInfo.Name
+				byArgs := c.records[funcKey]
+				if byArgs == nil {
+					byArgs = make(map[string][]*replayRecord)
+					c.records[funcKey] = byArgs
+				}
+				byArgs[argsKey] = append(byArgs[argsKey], &replayRecord{
+					results: entry.Results,
+					panic:   entry.Panic,
+					err:     entry.Error,
+				})
+			}
+		}
+		c.addEntries(entry.Children)
+	}
+}
+
+// getMock returns a mock replaying recorded results of
+// the function, or nil if the function is not selected
+// by rules or not recorded
+func (c *replayData) getMock(funcInfo *core.FuncInfo) func(fnInfo *core.FuncInfo, recvPtr interface{}, args []interface{}, results []interface{}) bool {
+	if funcInfo.Kind != core.Kind_Func {
+		return nil
+	}
+	byArgs := c.records[funcInfo.Pkg+"."+funcInfo.IdentityName]
+	if byArgs == nil {
+		return nil
+	}
+	if !matchReplayRules(funcInfo) {
+		return nil
+	}
+	return func(fnInfo *core.FuncInfo, recvPtr interface{}, args []interface{}, results []interface{}) bool {
+		argNames, argsNoCtx := tryRemoveFirstCtx(fnInfo.ArgNames, args)
+		var recvField string
+		if fnInfo.RecvType != "" {
+			// same layout as recorded, so unnamed args
+			// have the same __field_<i>, the receiver
+			// itself is not compared
+			recvField = structFieldName(fnInfo.RecvName, 0)
+			argNames = append([]string{fnInfo.RecvName}, argNames...)
+			argsNoCtx = append([]interface{}{nil}, argsNoCtx...)
+		}
+		argsJSON := xgo_runtime.MarshalNoError(newStructValue(argNames, argsNoCtx))
+		argsKey, err := replayArgsKey(argsJSON, recvField)
+		if err != nil {
+			return false
+		}
+		record := c.next(fnInfo.Pkg+"."+fnInfo.IdentityName, argsKey, byArgs[argsKey])
+		if record == nil {
+			// not recorded with these args, call the real function
+			return false
+		}
+		if record.panic {
+			panic(record.err)
+		}
+		err = record.apply(fnInfo, results)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: replay %s.%s: %v\n", fnInfo.Pkg, fnInfo.IdentityName, err)
+			return false
+		}
+		return true
+	}
+}
+
+// next returns records in call order, the last
+// record repeats once all records are consumed
+func (c *replayData) next(funcKey string, argsKey string, records []*replayRecord) *replayRecord {
+	if len(records) == 0 {
+		return nil
+	}
+	key := funcKey + "\x00" + argsKey
+	c.mutex.Lock()
+	i := c.consumed[key]
+	if i < len(records)-1 {
+		c.consumed[key] = i + 1
+	}
+	c.mutex.Unlock()
+	return records[i]
+}
+
+func (c *replayRecord) apply(fnInfo *core.FuncInfo, results []interface{}) error {
+	resultNames, resultsNoErr, _ := trySplitLastError(fnInfo.ResNames, results)
+	hasErr := len(resultsNoErr) < len(results)
+	if len(resultsNoErr) > 0 {
+		var fields map[string]json.RawMessage
+		err := json.Unmarshal(c.results, &fields)
+		if err != nil {
+			return err
+		}
+		for i, res := range resultsNoErr {
+			value, ok := fields[structFieldName(resultNames[i], i)]
+			if !ok {
+				return fmt.Errorf("missing result %s", structFieldName(resultNames[i], i))
+			}
+			err := json.Unmarshal(value, res)
+			if err != nil {
+				return fmt.Errorf("result %s: %w", structFieldName(resultNames[i], i), err)
+			}
+		}
+	}
+	if hasErr && c.err != "" {
+		*(results[len(results)-1].(*error)) = errors.New(c.err)
+	}
+	return nil
+}
+
+// replayArgsKey normalizes args JSON so that recorded
+// and actual args can be compared, the receiver is
+// excluded because it usually carries unrelated state
+func replayArgsKey(argsJSON json.RawMessage, recvField string) (string, error) {
+	var fields map[string]interface{}
+	if len(argsJSON) > 0 {
+		err := json.Unmarshal(argsJSON, &fields)
+		if err != nil {
+			return "", err
+		}
+	}
+	if recvField != "" {
+		delete(fields, recvField)
+	}
+	// map keys are sorted when marshaling
+	key, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	return string(key), nil
+}
+
+func structFieldName(name string, i int) string {
+	if name == "" {
+		return fmt.Sprintf("__field_%d", i)
+	}
+	return name
+}
+
+func matchReplayRules(funcInfo *core.FuncInfo) bool {
+	replayRulesOnce.Do(func() {
+		if flags.STRACE_REPLAY_RULES == "" {
+			return
+		}
+		err := json.Unmarshal([]byte(flags.STRACE_REPLAY_RULES), &replayRules)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: parse replay rules: %v\n", err)
+		}
+	})
+	for _, rule := range replayRules {
+		if rule.match(funcInfo) {
+			return rule.Action == "" || rule.Action == "include"
+		}
+	}
+	return false
+}
+
+func (c *replayRule) match(funcInfo *core.FuncInfo) bool {
+	if c.Any {
+		return true
+	}
+	var hasAnyCondition bool
+	if c.Kind != nil {
+		hasAnyCondition = true
+		if !listContains(splitList(*c.Kind), funcInfo.Kind.String()) {
+			return false
+		}
+	}
+	if c.Pkg != nil {
+		hasAnyCondition = true
+		if !matchAnyPattern(splitList(*c.Pkg), funcInfo.Pkg) {
+			return false
+		}
+	}
+	if c.Name != nil {
+		hasAnyCondition = true
+		if !matchAnyPattern(splitList(*c.Name), funcInfo.IdentityName) {
+			return false
+		}
+	}
+	if c.MainModule != nil {
+		hasAnyCondition = true
+		if *c.MainModule != isMainModulePkg(funcInfo.Pkg) {
+			return false
+		}
+	}
+	if c.Stdlib != nil {
+		hasAnyCondition = true
+		if *c.Stdlib != funcInfo.Stdlib {
+			return false
+		}
+	}
+	if c.Generic != nil {
+		hasAnyCondition = true
+		if *c.Generic != funcInfo.Generic {
+			return false
+		}
+	}
+	if c.Exported != nil {
+		hasAnyCondition = true
+		name := funcInfo.Name
+		if *c.Exported != (name != "" && name[0] >= 'A' && name[0] <= 'Z') {
+			return false
+		}
+	}
+	if c.Closure != nil {
+		hasAnyCondition = true
+		if *c.Closure != funcInfo.Closure {
+			return false
+		}
+	}
+	return hasAnyCondition
+}
+
+func isMainModulePkg(pkg string) bool {
+	mainModuleOnce.Do(func() {
+		buildInfo, ok := debug.ReadBuildInfo()
+		if ok {
+			mainModule = buildInfo.Main.Path
+		}
+	})
+	if mainModule == "" {
+		return false
+	}
+	return pkg == mainModule || strings.HasPrefix(pkg, mainModule+"/")
+}
+
+func splitList(s string) []string {
+	list := strings.Split(s, ",")
+	i := 0
+	for _, e := range list {
+		e = strings.TrimSpace(e)
+		if e != "" {
+			list[i] = e
+			i++
+		}
+	}
+	return list[:i]
+}
+
+func listContains(list []string, e string) bool {
+	for _, x := range list {
+		if x == e {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyPattern matches slash separated segments,
+// `*` matches within a segment and `**` matches
+// any number of segments
+func matchAnyPattern(patterns []string, s string) bool {
+	segs := strings.Split(s, "/")
+	for _, pattern := range patterns {
+		if matchSegments(strings.Split(pattern, "/"), segs) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(patterns []string, segs []string) bool {
+	if len(patterns) == 0 {
+		return len(segs) == 0
+	}
+	if patterns[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchSegments(patterns[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	ok, _ := path.Match(patterns[0], segs[0])
+	if !ok {
+		return false
+	}
+	return matchSegments(patterns[1:], segs[1:])
+}
@@ -0,0 +1,71 @@
+package trap
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Scope controls which goroutines can see a mock.
+//
+// Mocks set up during init are always visible to
+// all goroutines, regardless of scope.
+//
+// When mocks of different scopes are set up on the same
+// function, the goroutine's own mocks take precedence
+// over global mocks, which in turn take precedence over
+// mocks set up during init. Within the same level, the
+// most recently pushed mock wins.
+type Scope int
+
+const (
+	// ScopeDefault is ScopeGoroutineTree
+	ScopeDefault Scope = iota
+	// ScopeGoroutineTree makes the mock visible to current
+	// goroutine and goroutines created by it afterwards,
+	// new goroutines take a snapshot of mocks when created
+	ScopeGoroutineTree
+	// ScopeGoroutine makes the mock visible to
+	// current goroutine only
+	ScopeGoroutine
+	// ScopeGlobal makes the mock visible to all goroutines,
+	// including those created before the mock
+	ScopeGlobal
+)
+
+// scopedGlobal holds mocks with ScopeGlobal set up
+// after init, it is copy-on-write so that trap can
+// read it without lock
+var scopedGlobal scopedGlobalHolder
+
+type scopedGlobalHolder struct {
+	mutex   sync.Mutex
+	holders atomic.Value // *interceptorHolders
+}
+
+func (c *scopedGlobalHolder) get() *interceptorHolders {
+	holders, _ := c.holders.Load().(*interceptorHolders)
+	return holders
+}
+
+func (c *scopedGlobalHolder) update(f func(holders *interceptorHolders)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	newHolders := &interceptorHolders{
+		mock:       map[uintptr][]*mockHolder{},
+		varMock:    map[uintptr][]*varMockHolder{},
+		varPtrMock: map[uintptr][]*varMockHolder{},
+	}
+	if holders := c.get(); holders != nil {
+		for pc, list := range holders.mock {
+			newHolders.mock[pc] = append([]*mockHolder(nil), list...)
+		}
+		for addr, list := range holders.varMock {
+			newHolders.varMock[addr] = append([]*varMockHolder(nil), list...)
+		}
+		for addr, list := range holders.varPtrMock {
+			newHolders.varPtrMock[addr] = append([]*varMockHolder(nil), list...)
+		}
+	}
+	f(newHolders)
+	c.holders.Store(newHolders)
+}
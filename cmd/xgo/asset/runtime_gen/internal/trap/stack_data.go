@@ -15,9 +15,14 @@ var globalInterceptorHolder interceptorHolders
 type StackData struct {
 	hasStartedTracing bool
 
-	filterTrace     func(funcInfo *core.FuncInfo) bool
-	onFinish        func(stack stack_model.IStack)
-	stackOutputFile string
+	filterTrace       func(funcInfo *core.FuncInfo) bool
+	onFinish          func(stack stack_model.IStack)
+	stackOutputFile   string
+	stackOutputFormat stack_model.ExportFormat
+	traceLimits       *traceLimits
+
+	// see --strace-replay
+	replay *replayData
 
 	inspecting func(pc uintptr, funcInfo *core.FuncInfo, recvPtr interface{}, args []interface{}, results []interface{})
 
@@ -83,6 +88,11 @@ func (c *StackData) getLastMock(pc uintptr) (recvPtr interface{}, mock func(fnIn
 	if c != nil {
 		mockList = c.interceptors.mock[pc]
 	}
+	if len(mockList) == 0 {
+		if scoped := scopedGlobal.get(); scoped != nil {
+			mockList = scoped.mock[pc]
+		}
+	}
 	if len(mockList) == 0 {
 		mockList = globalInterceptorHolder.mock[pc]
 		if len(mockList) == 0 {
@@ -98,6 +108,11 @@ func (c *StackData) getLastVarMock(varAddr uintptr) (mock func(fnInfo *core.Func
 	if c != nil {
 		mockList = c.interceptors.varMock[varAddr]
 	}
+	if len(mockList) == 0 {
+		if scoped := scopedGlobal.get(); scoped != nil {
+			mockList = scoped.varMock[varAddr]
+		}
+	}
 	if len(mockList) == 0 {
 		mockList = globalInterceptorHolder.varMock[varAddr]
 		if len(mockList) == 0 {
@@ -113,6 +128,11 @@ func (c *StackData) getLastVarPtrMock(varAddr uintptr) (mock func(fnInfo *core.F
 	if c != nil {
 		mockList = c.interceptors.varPtrMock[varAddr]
 	}
+	if len(mockList) == 0 {
+		if scoped := scopedGlobal.get(); scoped != nil {
+			mockList = scoped.varPtrMock[varAddr]
+		}
+	}
 	if len(mockList) == 0 {
 		mockList = globalInterceptorHolder.varPtrMock[varAddr]
 		if len(mockList) == 0 {
@@ -1,6 +1,7 @@
 package trap
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -79,6 +80,8 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 	}
 
 	var stackAttached bool
+	var replay *replayData
+	var isStartReplay bool
 	if depth <= 1 && !isTracing {
 		// detect if we need to start tracing
 		if pkg == constants.TRACE_PKG && name == constants.TRACE_FUNC {
@@ -86,7 +89,7 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 			isTracing = true
 		} else if stackData == nil {
 			// try detect testing
-			if flags.COLLECT_TEST_TRACE {
+			if flags.COLLECT_TEST_TRACE || flags.STRACE_REPLAY_DIR != "" {
 				if recvPtr == nil && len(args) == 1 && len(results) == 0 {
 					t, ok := args[0].(**testing.T)
 					if ok {
@@ -98,15 +101,21 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 
 						if funcInfo != nil && funcInfo.Name() == constants.TESTING_RUNNER {
 							isTesting = true
-							isStartTracing = true
-							isTracing = true
 							testName = (*t).Name()
+							if flags.COLLECT_TEST_TRACE {
+								isStartTracing = true
+								isTracing = true
+							}
+							if flags.STRACE_REPLAY_DIR != "" {
+								replay = loadReplayData(flags.STRACE_REPLAY_DIR, testName)
+								isStartReplay = replay != nil && !isStartTracing
+							}
 						}
 					}
 				}
 			}
 		}
-		if isStartTracing {
+		if isStartTracing || isStartReplay {
 			if stackData == nil {
 				// trace starting cannot happen on empty stack
 				// stk might be InitGStack
@@ -115,7 +124,7 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 					panic("stackData is nil while stk is not nil!")
 				}
 				stackData = &StackData{
-					hasStartedTracing: true,
+					hasStartedTracing: isStartTracing,
 				}
 				begin = xgo_runtime.XgoRealTimeNow()
 				stk = &stack.Stack{
@@ -129,6 +138,12 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 			} else {
 				stackData.hasStartedTracing = true
 			}
+			if isStartTracing && isTesting {
+				stackData.traceLimits = getTestTraceLimits()
+			}
+		}
+		if replay != nil {
+			stackData.replay = replay
 		}
 	}
 	// === end detect trapping and tracing ===
@@ -150,6 +165,8 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 	}
 	if mockFn != nil && (wantPtr == nil || (recvPtr != nil && sameReceiver(recvPtr, wantPtr))) {
 		mock = mockFn
+	} else if depth <= 1 && !isTesting && stackData != nil && stackData.replay != nil {
+		mock = stackData.replay.getMock(funcInfo)
 	}
 
 	var postRecordersAndInterceptors []func()
@@ -222,7 +239,28 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 			postRecorder()
 		}
 	}
+	if allowTracingThisEntry && !isStartTracing && depth <= 1 && stackData.traceLimits.omit(stk, funcInfo) {
+		// omitted because of trace limits
+		allowTracingThisEntry = false
+		callRecorder := callRecorderWithDepth
+		callRecorderWithDepth = func() {
+			if callRecorder != nil {
+				callRecorder()
+			}
+			stk.EndOmit()
+		}
+	}
 	// === end check mock and interceptors ===
+	if isStartReplay {
+		// replaying without tracing, detach
+		// the stack when test finishes
+		return func() {
+			if callRecorderWithDepth != nil {
+				callRecorderWithDepth()
+			}
+			stack.Detach()
+		}, false
+	}
 	if !mocked {
 		if depth > 1 {
 			// when stack is trapping, only allow pc-related
@@ -260,6 +298,7 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 	if isStartTracing && !isTesting {
 		var onFinish func(stack stack_model.IStack)
 		var outputFile string
+		var outputFormat stack_model.ExportFormat
 		var filterTrace func(funcInfo *core.FuncInfo) bool
 		var config interface{}
 		for i, arg := range args {
@@ -280,6 +319,15 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 						outputFile = f
 					}
 				}
+				outputFormatField := rvalue.FieldByName("OutputFormat")
+				if outputFormatField.IsValid() && outputFormatField.Kind() == reflect.String {
+					format, err := stack_model.ParseExportFormat(outputFormatField.String())
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "WARNING: %v, fallback to json\n", err)
+					} else {
+						outputFormat = format
+					}
+				}
 				onFinishField := rvalue.FieldByName("OnFinish")
 				if onFinishField.IsValid() {
 					f, ok := onFinishField.Interface().(func(stack stack_model.IStack))
@@ -297,6 +345,10 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 			}
 		}
 		stackData.filterTrace = filterTrace
+		if config != nil {
+			// MaxDepth and other limits
+			stackData.traceLimits = parseTraceLimits(xgo_runtime.MarshalNoError(config))
+		}
 		if outputFile == "" && onFinish == nil {
 			if stackAttached {
 				stack.Detach()
@@ -304,6 +356,7 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 			return postRecorder, false
 		}
 		stackData.stackOutputFile = outputFile
+		stackData.stackOutputFormat = outputFormat
 		stackData.onFinish = onFinish
 	}
 
@@ -319,7 +372,9 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 		copy(marshalNames[1:], argNamesNoCtx)
 		copy(marshalArgs[1:], argsNoCtx)
 	}
-	argsJSON := json.RawMessage(xgo_runtime.MarshalNoError(newStructValue(marshalNames, marshalArgs)))
+	argsValue := newStructValue(marshalNames, marshalArgs)
+	argsValue.SizeLimit = stackData.traceLimits.maxArgBytes()
+	argsJSON := json.RawMessage(xgo_runtime.MarshalNoError(argsValue))
 
 	// Push under stack mutex so Export cannot observe a half-built entry.
 	cur, oldTop := stk.PushNew(begin, name, func(cur *stack.Entry) {
@@ -327,6 +382,7 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 		cur.Line = callLine
 		cur.FuncInfo = funcInfo
 		cur.Args = argsJSON
+		cur.Truncated = argsValue.Truncated
 	})
 
 	var hitMock bool
@@ -397,7 +453,9 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 		}
 
 		resultNamesNoErr, resultsNoErr, resErr := trySplitLastError(resultNames, results)
-		resultsJSON := json.RawMessage(xgo_runtime.MarshalNoError(newStructValue(resultNamesNoErr, resultsNoErr)))
+		resultsValue := newStructValue(resultNamesNoErr, resultsNoErr)
+		resultsValue.SizeLimit = stackData.traceLimits.maxArgBytes()
+		resultsJSON := json.RawMessage(xgo_runtime.MarshalNoError(resultsValue))
 		if !hasPanic && resErr != nil {
 			errStr = resErr.Error()
 		}
@@ -414,10 +472,16 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 				cur.PanicLine = panicLine
 			}
 			cur.Results = resultsJSON
+			if resultsValue.Truncated {
+				cur.Truncated = true
+			}
 			if errStr != "" {
 				cur.Error = errStr
 			}
 		})
+		if !isStartTracing && stackData.traceLimits.collapseRepeated() {
+			stk.CollapseRepeated(cur, oldTop)
+		}
 		if isStartTracing {
 			exportedStack := stack.Export(stk, 0)
 			if isTesting {
@@ -436,8 +500,7 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 					})
 				}
 				if stackData.stackOutputFile != "" {
-					exportedStackJSON := xgo_runtime.MarshalNoError(exportedStack)
-					err := os.WriteFile(stackData.stackOutputFile, exportedStackJSON, 0644)
+					err := writeStackFile(stackData.stackOutputFile, stackData.stackOutputFormat, exportedStack)
 					if err != nil {
 						fmt.Fprintf(os.Stderr, "error writing stack: %v\n", err)
 					}
@@ -456,6 +519,23 @@ func trap(infoPtr unsafe.Pointer, recvPtr interface{}, args []interface{}, resul
 	return post, false
 }
 
+func writeStackFile(file string, format stack_model.ExportFormat, exportedStack *stack_model.Stack) error {
+	if format == "" || format == stack_model.ExportFormat_JSON {
+		return os.WriteFile(file, xgo_runtime.MarshalNoError(exportedStack), 0644)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	err = stack_model.Export(exportedStack, format, w)
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
 type StackDataExportImpl struct {
 	data *stack_model.Stack
 
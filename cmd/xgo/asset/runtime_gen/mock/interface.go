@@ -0,0 +1,47 @@
+package mock
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/xhd2015/xgo/runtime/functab"
+	"github.com/xhd2015/xgo/runtime/internal/trap"
+)
+
+// NewInterfaceOf creates a value implementing the interface
+// pointed by `intfPtr`, which is typically `(*SomeInterface)(nil)`.
+// The returned value can be asserted to that interface.
+//
+// The implementation is generated by xgo at compile time,
+// its methods return zero values unless mocked, and they
+// can be mocked, patched and traced like ordinary methods:
+//
+//	svc := mock.NewInterfaceOf((*Service)(nil)).(Service)
+//	mock.Patch(svc.Get, func(id int) string {
+//		return "mock"
+//	})
+//
+// Only interfaces referenced directly by NewInterface or
+// NewInterfaceOf are implemented, they can be declared in
+// the main module, dependencies or the standard library.
+// Unexported methods promoted from interfaces of other packages
+// are not implemented, calling them panics.
+//
+// For go1.22 and above, see NewInterface.
+func NewInterfaceOf(intfPtr interface{}) interface{} {
+	t := reflect.TypeOf(intfPtr)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Interface {
+		panic(fmt.Errorf("requires pointer to interface, given: %T", intfPtr))
+	}
+	return newInterface(t.Elem())
+}
+
+func newInterface(intfType reflect.Type) interface{} {
+	intfInfo := functab.GetInterfaceByType(intfType)
+	if intfInfo == nil {
+		panic(fmt.Errorf("interface %w: %s", trap.ErrNotInstrumented, intfType.String()))
+	}
+	// Func: func() Interface
+	res := reflect.ValueOf(intfInfo.Func).Call(nil)
+	return res[0].Interface()
+}
@@ -22,3 +22,6 @@ package mock
 //  implicit function instantiation requires go1.18 or later (-lang was set to go1.16; check go.mod)
 //     mock.Patch(...)
 //   because mock.Patch was defined as generic
+//
+// so the generic versions are added with different
+// names(PatchT, PatchVarT...), see patch_go1.22.go
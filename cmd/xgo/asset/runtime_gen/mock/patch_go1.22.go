@@ -0,0 +1,53 @@
+//go:build go1.22
+// +build go1.22
+
+package mock
+
+import (
+	"testing"
+
+	"github.com/xhd2015/xgo/runtime/internal/trap"
+)
+
+// PatchT is the type-safe version of Patch, `replacer` must
+// have the same type as `fn`, so a mismatched signature
+// fails at compile time instead of panicking at runtime.
+//
+// Example:
+//
+//	mock.PatchT(greet, func(s string) string {
+//		return "mock " + s
+//	})
+//
+// NOTE: runtime/go.mod declares go1.14, a file can only
+// upgrade its language version to use generics since go1.22.
+// The non-generic Patch is kept for older go versions,
+// see patch_go1.18.go.
+func PatchT[F any](fn F, replacer F) func() {
+	return trap.PushMockReplacer(trap.ScopeDefault, fn, replacer)
+}
+
+// PatchVarT is the type-safe version of Patch for variables,
+// reading `*v` returns what `replacer` returns.
+//
+// Example:
+//
+//	mock.PatchVarT(&timeout, func() time.Duration {
+//		return time.Second
+//	})
+func PatchVarT[T any](v *T, replacer func() T) func() {
+	return trap.PushMockReplacer(trap.ScopeDefault, v, replacer)
+}
+
+// PatchScopeT is like PatchT, but with the scope applied,
+// see Global, GoroutineTree and Goroutine.
+func PatchScopeT[F any](scope *Scope, fn F, replacer F) func() {
+	return scope.cleanup(trap.PushMockReplacer(scope.scope, fn, replacer))
+}
+
+// PatchVerifyT is the type-safe version of PatchVerify
+func PatchVerifyT[F any](t testing.TB, fn F, replacer F) *Verifier {
+	v := Verify(t, fn)
+	v.cancels = append(v.cancels, trap.PushMockReplacer(trap.ScopeDefault, fn, replacer))
+	return v
+}
@@ -0,0 +1,289 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/xhd2015/xgo/runtime/core"
+	"github.com/xhd2015/xgo/runtime/internal/trap"
+)
+
+// ArgMatcher reports whether a call should be handled by a rule.
+// `args` has the same layout as Call.Args: a leading
+// context.Context is not included.
+type ArgMatcher interface {
+	Match(args core.Object) bool
+}
+
+// ArgMatcherFunc adapts a function to ArgMatcher
+type ArgMatcherFunc func(args core.Object) bool
+
+// argNamesChecker is implemented by matchers referring
+// args by name, checked when the rule is added
+type argNamesChecker interface {
+	checkArgNames(names []string) error
+}
+
+type argsMatcher []interface{}
+
+type argMatcher struct {
+	name  string
+	value interface{}
+}
+
+var _ argNamesChecker = (*argMatcher)(nil)
+
+func (c ArgMatcherFunc) Match(args core.Object) bool {
+	return c(args)
+}
+
+// Args matches calls whose args equal to `args`,
+// with the same rules as Verifier.CalledWith
+func Args(args ...interface{}) ArgMatcher {
+	return argsMatcher(args)
+}
+
+// Arg matches calls whose arg named `name` equals to `value`
+func Arg(name string, value interface{}) ArgMatcher {
+	return &argMatcher{name: name, value: value}
+}
+
+func (c argsMatcher) Match(actual core.Object) bool {
+	return argsMatch(actual, c)
+}
+
+func (c *argMatcher) Match(actual core.Object) bool {
+	return valueMatch(actual.GetField(c.name).Value(), c.value)
+}
+
+func (c *argMatcher) checkArgNames(names []string) error {
+	for _, name := range names {
+		if name == c.name {
+			return nil
+		}
+	}
+	return fmt.Errorf("no arg named %q, available: %s", c.name, strings.Join(names, ", "))
+}
+
+// Stubber replaces a function with declared outcomes.
+// Rules are checked in the order they are declared,
+// the first rule that matches and still has an outcome
+// handles the call.
+// If no rule handles the call, the original function
+// is called.
+//
+// Example:
+//
+//	s := mock.Stub(GetUser)
+//	s.When(mock.Args("admin")).Return(&User{Admin: true}, nil)
+//	s.When(mock.Arg("id", "404")).Error(ErrNotFound)
+//	s.When(nil).ReturnOnce(nil, ErrTimeout).Return(&User{}, nil)
+type Stubber struct {
+	funcInfo *core.FuncInfo
+	// names of args passed to matchers
+	argNames []string
+
+	mutex sync.Mutex
+	rules []*StubRule
+
+	cancel func()
+}
+
+// StubRule declares outcomes for calls matched by an ArgMatcher.
+// One-time outcomes are consumed in declaring order,
+// after that the outcome declared by Return, Error or Panic
+// is used for every matched call.
+type StubRule struct {
+	stubber *Stubber
+	matcher ArgMatcher
+
+	once   []*stubOutcome
+	repeat *stubOutcome
+}
+
+type stubOutcome struct {
+	results  []interface{}
+	err      error
+	panicVal interface{}
+	isPanic  bool
+}
+
+// Stub installs a mock on `fn` whose behavior is
+// declared by rules.
+// `fn` can be a function or a method, if `fn` is a method,
+// only the bound instance is stubbed.
+func Stub(fn interface{}) *Stubber {
+	recvPtr, funcInfo, _, _ := trap.Inspect(fn)
+	s := &Stubber{
+		funcInfo: funcInfo,
+		argNames: stubArgNames(funcInfo, recvPtr != nil),
+	}
+	s.cancel = Mock(fn, s.intercept)
+	return s
+}
+
+// When adds a rule matching calls with `matcher`,
+// a nil matcher matches any call.
+// It panics if `matcher` refers to an arg that `fn` does not have.
+func (c *Stubber) When(matcher ArgMatcher) *StubRule {
+	if checker, ok := matcher.(argNamesChecker); ok {
+		if err := checker.checkArgNames(c.argNames); err != nil {
+			panic(fmt.Errorf("stub %s: %w", c.funcInfo.DisplayName(), err))
+		}
+	}
+	rule := &StubRule{
+		stubber: c,
+		matcher: matcher,
+	}
+	c.mutex.Lock()
+	c.rules = append(c.rules, rule)
+	c.mutex.Unlock()
+	return rule
+}
+
+// Cancel removes the stub, later calls
+// go to the original function.
+func (c *Stubber) Cancel() {
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+}
+
+// Return sets results returned by every matched call,
+// `results` must match the function's results.
+func (c *StubRule) Return(results ...interface{}) *Stubber {
+	c.setRepeat(&stubOutcome{results: c.stubber.checkResults(results)})
+	return c.stubber
+}
+
+// Error sets the last error result returned by every
+// matched call, other results are zero values.
+// The function must have an error as its last result.
+func (c *StubRule) Error(err error) *Stubber {
+	c.setRepeat(&stubOutcome{err: c.stubber.checkErr(err)})
+	return c.stubber
+}
+
+// Panic makes every matched call panic with `v`
+func (c *StubRule) Panic(v interface{}) *Stubber {
+	c.setRepeat(&stubOutcome{panicVal: v, isPanic: true})
+	return c.stubber
+}
+
+// ReturnOnce adds results returned by the next matched call
+func (c *StubRule) ReturnOnce(results ...interface{}) *StubRule {
+	c.addOnce(&stubOutcome{results: c.stubber.checkResults(results)})
+	return c
+}
+
+// ReturnSequence adds results returned by the next matched calls,
+// one call for each element of `sequence`.
+func (c *StubRule) ReturnSequence(sequence ...[]interface{}) *StubRule {
+	for _, results := range sequence {
+		c.ReturnOnce(results...)
+	}
+	return c
+}
+
+// ErrorOnce makes the next matched call return `err`
+func (c *StubRule) ErrorOnce(err error) *StubRule {
+	c.addOnce(&stubOutcome{err: c.stubber.checkErr(err)})
+	return c
+}
+
+// PanicOnce makes the next matched call panic with `v`
+func (c *StubRule) PanicOnce(v interface{}) *StubRule {
+	c.addOnce(&stubOutcome{panicVal: v, isPanic: true})
+	return c
+}
+
+func (c *StubRule) addOnce(outcome *stubOutcome) {
+	c.stubber.mutex.Lock()
+	c.once = append(c.once, outcome)
+	c.stubber.mutex.Unlock()
+}
+
+func (c *StubRule) setRepeat(outcome *stubOutcome) {
+	c.stubber.mutex.Lock()
+	c.repeat = outcome
+	c.stubber.mutex.Unlock()
+}
+
+func (c *Stubber) checkResults(results []interface{}) []interface{} {
+	numResults := len(c.funcInfo.ResNames)
+	if c.funcInfo.Kind != core.Kind_Func {
+		// variable or const
+		numResults = 1
+	}
+	if len(results) != numResults {
+		panic(fmt.Errorf("stub %s: expect %d results, actual: %d", c.funcInfo.DisplayName(), numResults, len(results)))
+	}
+	return results
+}
+
+func (c *Stubber) checkErr(err error) error {
+	if !c.funcInfo.LastResultErr {
+		panic(fmt.Errorf("stub %s: last result is not error", c.funcInfo.DisplayName()))
+	}
+	if err == nil {
+		panic(fmt.Errorf("stub %s: err cannot be nil", c.funcInfo.DisplayName()))
+	}
+	return err
+}
+
+// next finds the outcome for a call,
+// returns nil if no rule handles it
+func (c *Stubber) next(args core.Object) *stubOutcome {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, rule := range c.rules {
+		if len(rule.once) == 0 && rule.repeat == nil {
+			continue
+		}
+		if rule.matcher != nil && !rule.matcher.Match(args) {
+			continue
+		}
+		if len(rule.once) > 0 {
+			outcome := rule.once[0]
+			rule.once = rule.once[1:]
+			return outcome
+		}
+		return rule.repeat
+	}
+	return nil
+}
+
+// stubArgNames returns names of args in the same layout as
+// snapshotArgs: the receiver of an unbound method comes first,
+// a leading context.Context is not included
+func stubArgNames(funcInfo *core.FuncInfo, bound bool) []string {
+	var names []string
+	if !bound && funcInfo.RecvName != "" {
+		names = append(names, funcInfo.RecvName)
+	}
+	argNames := funcInfo.ArgNames
+	if funcInfo.FirstArgCtx && len(argNames) > 0 {
+		argNames = argNames[1:]
+	}
+	return append(names, argNames...)
+}
+
+func (c *Stubber) intercept(ctx context.Context, fn *core.FuncInfo, args core.Object, results core.Object) error {
+	outcome := c.next(snapshotArgs(fn, args))
+	if outcome == nil {
+		return ErrCallOld
+	}
+	if outcome.isPanic {
+		panic(outcome.panicVal)
+	}
+	if outcome.err != nil {
+		return outcome.err
+	}
+	for i, res := range outcome.results {
+		results.GetFieldIndex(i).Set(res)
+	}
+	return nil
+}
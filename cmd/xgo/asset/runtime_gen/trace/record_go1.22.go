@@ -0,0 +1,58 @@
+//go:build go1.22
+// +build go1.22
+
+package trace
+
+import (
+	"reflect"
+
+	"github.com/xhd2015/xgo/runtime/internal/trap"
+)
+
+// RecordT is the type-safe version of Record, `pre` must have
+// the same type as `fn`, so a mismatched signature fails at
+// compile time.
+// `pre` is called with the arguments before `fn` runs, its
+// return values are ignored.
+// `post` is called after `fn` returns, with the arguments
+// followed by the results of `fn`, i.e. for `func(string) string`,
+// `post` should be `func(string, string)`. Its type is checked
+// when RecordT is called.
+//
+// Example:
+//
+//	trace.RecordT(greet, func(s string) string {
+//		t.Logf("greet %s", s)
+//		return ""
+//	}, func(s string, res string) {
+//		t.Logf("greet %s: %s", s, res)
+//	})
+func RecordT[F any, P any](fn F, pre F, post P) func() {
+	return trap.PushRecorder(fn, argsRecorder(fn, pre), funcOrNil(post))
+}
+
+// RecordCallT is like RecordT, but with only the pre-hook
+func RecordCallT[F any](fn F, pre F) func() {
+	return trap.PushRecorder(fn, argsRecorder(fn, pre), nil)
+}
+
+// RecordResultT is like RecordT, but with only the post-hook,
+// which sees the arguments and results of `fn`.
+//
+// Example:
+//
+//	trace.RecordResultT(greet, func(s string, res string) {
+//		t.Logf("greet %s: %s", s, res)
+//	})
+func RecordResultT[F any, P any](fn F, post P) func() {
+	return trap.PushRecorder(fn, nil, funcOrNil(post))
+}
+
+// funcOrNil unwraps a nil func of type P to an untyped nil
+func funcOrNil[P any](hook P) interface{} {
+	v := reflect.ValueOf(hook)
+	if !v.IsValid() || (v.Kind() == reflect.Func && v.IsNil()) {
+		return nil
+	}
+	return hook
+}
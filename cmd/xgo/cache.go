@@ -0,0 +1,535 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/xhd2015/xgo/cmd/xgo/pathsum"
+	"github.com/xhd2015/xgo/support/flag"
+)
+
+const cacheHelp = `
+Xgo caches the instrumented GOROOT under ~/.xgo/go-instrument, keyed by
+go version, content of GOROOT, xgo revision, patches and build flags.
+An exported entry can be imported on another machine with the same
+GOOS/GOARCH, i.e. a CI runner, to skip the first run instrumentation.
+
+Usage:
+    xgo cache <command> [arguments]
+
+The commands are:
+    list       list instrumented GOROOTs
+    export     export instrumented GOROOTs to a .tar.gz file
+    import     import instrumented GOROOTs from .tar.gz files
+    prune      remove instrumented GOROOTs not used recently
+    help       show help
+
+Flags:
+    --with-goroot GOROOT    export: GOROOT whose instrumentation to export when no NAME is given
+    -o FILE                 export: output file, default NAME.tar.gz
+    --older-than DURATION   prune: remove entries not used within DURATION, default 720h
+    --all                   prune: remove all entries
+    --dry-run               prune: only print entries to be removed
+
+Examples:
+    xgo cache list                              list instrumented GOROOTs
+    xgo setup && xgo cache export -o xgo.tar.gz export instrumentation of current GOROOT
+    xgo cache import xgo.tar.gz                 import on a CI runner
+    xgo cache prune --older-than 168h           remove entries not used in a week
+
+See https://github.com/xhd2015/xgo for documentation.
+
+`
+
+func handleCache(args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Print(strings.TrimPrefix(cacheHelp, "\n"))
+		return nil
+	}
+	command := args[0]
+	args = args[1:]
+
+	var xgoHome string
+	var withGoroot string
+	var output string
+	var olderThan string
+	var all bool
+	var dryRun bool
+	var remainArgs []string
+	n := len(args)
+	for i := 0; i < n; i++ {
+		arg := args[i]
+		if arg == "--" {
+			remainArgs = append(remainArgs, args[i+1:]...)
+			break
+		}
+		if arg == "-h" || arg == "--help" {
+			fmt.Print(strings.TrimPrefix(cacheHelp, "\n"))
+			return nil
+		}
+		if arg == "--all" {
+			all = true
+			continue
+		}
+		if arg == "--dry-run" {
+			dryRun = true
+			continue
+		}
+		flagValues := []struct {
+			name  string
+			value *string
+		}{
+			{"--with-goroot", &withGoroot},
+			{"-o", &output},
+			{"--older-than", &olderThan},
+		}
+		if isDevelopment {
+			flagValues = append(flagValues, struct {
+				name  string
+				value *string
+			}{"--xgo-home", &xgoHome})
+		}
+		var matched bool
+		for _, f := range flagValues {
+			ok, err := flag.TryParseFlagValue(f.name, f.value, nil, &i, args)
+			if err != nil {
+				return err
+			}
+			if ok {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") {
+			remainArgs = append(remainArgs, arg)
+			continue
+		}
+		return fmt.Errorf("unrecognized flag: %s", arg)
+	}
+
+	xgoDir, err := getOrMakeAbsXgoHome(xgoHome)
+	if err != nil {
+		return err
+	}
+	cacheRoot := getInstrumentCacheRoot(xgoDir)
+	switch command {
+	case "list":
+		if len(remainArgs) > 0 {
+			return fmt.Errorf("list: unrecognized args: %s", strings.Join(remainArgs, " "))
+		}
+		return listCache(os.Stdout, cacheRoot)
+	case "export":
+		names := remainArgs
+		if len(names) == 0 {
+			name, err := getCurrentCacheName(xgoDir, withGoroot)
+			if err != nil {
+				return err
+			}
+			names = []string{name}
+		}
+		if output == "" {
+			output = names[0] + ".tar.gz"
+		}
+		err := exportCache(cacheRoot, names, output)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "exported %s to %s\n", strings.Join(names, ", "), output)
+		return nil
+	case "import":
+		if len(remainArgs) == 0 {
+			return fmt.Errorf("import: requires file")
+		}
+		for _, file := range remainArgs {
+			err := importCache(cacheRoot, file)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "prune":
+		if len(remainArgs) > 0 {
+			return fmt.Errorf("prune: unrecognized args: %s", strings.Join(remainArgs, " "))
+		}
+		olderThanDuration := 30 * 24 * time.Hour
+		if olderThan != "" {
+			if all {
+				return fmt.Errorf("prune: --older-than and --all cannot be used together")
+			}
+			olderThanDuration, err = time.ParseDuration(olderThan)
+			if err != nil {
+				return fmt.Errorf("prune: --older-than: %w", err)
+			}
+		}
+		if all {
+			olderThanDuration = 0
+		}
+		return pruneCache(cacheRoot, olderThanDuration, dryRun)
+	default:
+		return fmt.Errorf("xgo cache %s: unknown command\nRun 'xgo cache help' for usage.", command)
+	}
+}
+
+// getInstrumentCacheRoot returns ~/.xgo/go-instrument
+func getInstrumentCacheRoot(xgoDir string) string {
+	instrumentSuffix := ""
+	if isDevelopment {
+		instrumentSuffix = "-dev"
+	}
+	return filepath.Join(xgoDir, "go-instrument"+instrumentSuffix)
+}
+
+// getCurrentCacheName returns the entry name of the
+// instrumented GOROOT used by `xgo build` with default flags
+func getCurrentCacheName(xgoDir string, withGoroot string) (string, error) {
+	goroot, err := checkGoroot("", withGoroot)
+	if err != nil {
+		return "", err
+	}
+	goroot, err = filepath.Abs(goroot)
+	if err != nil {
+		return "", err
+	}
+	goVersion, err := checkGoVersion(goroot, true)
+	if err != nil {
+		return "", err
+	}
+	useFilePatches, _, err := resolveUseFilePatches(nil, goVersion)
+	if err != nil {
+		return "", err
+	}
+	goVersionName := fmt.Sprintf("go%d.%d.%d", goVersion.Major, goVersion.Minor, goVersion.Patch)
+	key, err := getInstrumentCacheKey(xgoDir, goroot, goVersion, goVersionName, useFilePatches, false, false)
+	if err != nil {
+		return "", err
+	}
+	return key.DirName(), nil
+}
+
+func listCache(w io.Writer, cacheRoot string) error {
+	entries, err := listInstrumentCache(cacheRoot)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(w, "no instrumented GOROOT under %s\n", cacheRoot)
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "NAME\tGO\tSIZE\tLAST USED\tGOROOT\n")
+	for _, entry := range entries {
+		size, err := dirSize(entry.Dir)
+		if err != nil {
+			return err
+		}
+		goroot := "(legacy)"
+		if entry.Manifest != nil {
+			goroot = entry.Manifest.Goroot
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", entry.Name, entry.GoVersion(), formatCacheSize(size), entry.LastUsed.Format("2006-01-02 15:04"), goroot)
+	}
+	return tw.Flush()
+}
+
+// exportCache writes entries to a .tar.gz file, each
+// entry is stored under its name
+func exportCache(cacheRoot string, names []string, output string) (err error) {
+	var entries []*instrumentCacheEntry
+	for _, name := range names {
+		if err := checkCacheName(name); err != nil {
+			return err
+		}
+		dir := filepath.Join(cacheRoot, name)
+		if _, err := os.Stat(dir); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("instrumented GOROOT not found: %s, run `xgo setup` first", name)
+			}
+			return err
+		}
+		entry, err := readInstrumentCacheEntry(dir)
+		if err != nil {
+			return err
+		}
+		if entry.Manifest == nil {
+			return fmt.Errorf("%s is incomplete or created by an older xgo, run `xgo setup --reset-instrument` first", name)
+		}
+		entries = append(entries, entry)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := f.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(output)
+		}
+	}()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for _, entry := range entries {
+		err = addDirToTar(tw, entry.Dir, entry.Name)
+		if err != nil {
+			return err
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func addDirToTar(tw *tar.Writer, dir string, name string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("cannot export symlink %s", path)
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(name, relPath))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		err = tw.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+}
+
+// importCache extracts the file into a temporary dir under cacheRoot,
+// then moves each complete entry into place. Existing entries are kept.
+func importCache(cacheRoot string, file string) error {
+	err := os.MkdirAll(cacheRoot, 0755)
+	if err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(cacheRoot, ".import-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	err = extractCacheFile(file, tmpDir)
+	if err != nil {
+		return fmt.Errorf("import %s: %w", file, err)
+	}
+	dirEntries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		return err
+	}
+	if len(dirEntries) == 0 {
+		return fmt.Errorf("import %s: no instrumented GOROOT found", file)
+	}
+	coreRevision := getCoreRevision()
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		entry, err := readInstrumentCacheEntry(filepath.Join(tmpDir, name))
+		if err != nil {
+			return err
+		}
+		if entry.Manifest == nil {
+			return fmt.Errorf("import %s: %s is not an instrumented GOROOT exported by `xgo cache export`", file, name)
+		}
+		target := filepath.Join(cacheRoot, name)
+		if _, err := os.Stat(target); err == nil {
+			fmt.Fprintf(os.Stderr, "%s already exists, skipped\n", name)
+			continue
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if entry.Manifest.XgoRevision != coreRevision {
+			fmt.Fprintf(os.Stderr, "WARNING: %s is prepared by xgo %s, current: %s, it will not be used until xgo is upgraded to the same revision\n", name, entry.Manifest.XgoRevision, coreRevision)
+		}
+		err = os.Rename(entry.Dir, target)
+		if err != nil {
+			return err
+		}
+		// imported entries are just used
+		err = touchInstrumentCache(target)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "imported %s\n", name)
+	}
+	return nil
+}
+
+func extractCacheFile(file string, targetDir string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("gzip: %w", err)
+	}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("tar read: %w", err)
+		}
+		name := filepath.FromSlash(strings.TrimSuffix(header.Name, "/"))
+		if name == "" || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) || filepath.Clean(name) != name {
+			return fmt.Errorf("tar: invalid file name %s", header.Name)
+		}
+		if err := checkCacheName(strings.SplitN(filepath.ToSlash(name), "/", 2)[0]); err != nil {
+			return fmt.Errorf("tar: %w", err)
+		}
+		target := filepath.Join(targetDir, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+			if err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			err = os.MkdirAll(filepath.Dir(target), 0755)
+			if err != nil {
+				return err
+			}
+			err = writeTarFile(tr, target, header.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			// keep mtime as the go command checks staleness of GOROOT by it
+			err = os.Chtimes(target, header.ModTime, header.ModTime)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("tar: unrecognized type %v: %s", header.Typeflag, header.Name)
+		}
+	}
+}
+
+func writeTarFile(r io.Reader, file string, perm os.FileMode) error {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// pruneCache removes entries not used within olderThan,
+// together with their build cache under the tmp dir
+func pruneCache(cacheRoot string, olderThan time.Duration, dryRun bool) error {
+	entries, err := listInstrumentCache(cacheRoot)
+	if err != nil {
+		return err
+	}
+	tmpRoot, err := getStableTmpDir()
+	if err != nil {
+		return err
+	}
+	buildCacheRoot := filepath.Join(tmpRoot, "xgo", filepath.Base(cacheRoot))
+
+	now := time.Now()
+	var removed int
+	for _, entry := range entries {
+		if olderThan > 0 && now.Sub(entry.LastUsed) < olderThan {
+			continue
+		}
+		removed++
+		if dryRun {
+			fmt.Fprintf(os.Stdout, "would remove %s\n", entry.Dir)
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "remove %s\n", entry.Dir)
+		err := os.RemoveAll(entry.Dir)
+		if err != nil {
+			return err
+		}
+		// see instrumentCacheDir in handleBuild
+		buildCacheName, err := pathsum.PathSum(entry.GoVersion()+"_", entry.Dir)
+		if err != nil {
+			return err
+		}
+		err = os.RemoveAll(filepath.Join(buildCacheRoot, buildCacheName))
+		if err != nil {
+			return err
+		}
+	}
+	if removed == 0 {
+		fmt.Fprintf(os.Stdout, "nothing to prune\n")
+	}
+	return nil
+}
+
+// checkCacheName rejects names that are not a direct child of the cache root
+func checkCacheName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid instrumented GOROOT name: %q", name)
+	}
+	return nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+func formatCacheSize(size int64) string {
+	const MB = 1 << 20
+	if size < MB {
+		return fmt.Sprintf("%dK", size>>10)
+	}
+	return fmt.Sprintf("%.1fM", float64(size)/MB)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xhd2015/xgo/support/goinfo"
+)
+
+func TestInstrumentCacheKey(t *testing.T) {
+	xgoDir := t.TempDir()
+	goroot := t.TempDir()
+	write := func(file string, content string) {
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(goroot, "bin", "go"), "go")
+	write(filepath.Join(goroot, "VERSION"), "go1.24.2\n")
+	write(filepath.Join(goroot, "src", "runtime", "proc.go"), "package runtime\n")
+
+	goVersion := &goinfo.GoVersion{Major: 1, Minor: 24, Patch: 2}
+	getKey := func(useFilePatches bool, skipRebuild bool, rehashGoroot bool) *instrumentCacheKey {
+		key, err := getInstrumentCacheKey(xgoDir, goroot, goVersion, "go1.24.2", useFilePatches, skipRebuild, rehashGoroot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	key := getKey(true, false, false)
+	if !strings.HasPrefix(key.DirName(), "go1.24.2_") || len(key.DirName()) != len("go1.24.2_")+16 {
+		t.Fatalf("unexpected dir name: %s", key.DirName())
+	}
+	if getKey(true, false, false).Sum() != key.Sum() {
+		t.Fatalf("expect stable key")
+	}
+	if getKey(false, false, false).Sum() == key.Sum() || getKey(true, true, false).Sum() == key.Sum() {
+		t.Fatalf("expect flags to change key")
+	}
+
+	// the remembered sum is used until GOROOT is reinstalled or rehashed
+	write(filepath.Join(goroot, "src", "runtime", "proc.go"), "package runtime\n\nfunc f() {}\n")
+	if getKey(true, false, false).GorootSum != key.GorootSum {
+		t.Fatalf("expect remembered GOROOT sum")
+	}
+	if getKey(true, false, true).GorootSum == key.GorootSum {
+		t.Fatalf("expect GOROOT content to change key")
+	}
+
+	// same content at another path shares the key
+	otherGoroot := t.TempDir()
+	for _, file := range []string{"bin/go", "VERSION", "src/runtime/proc.go"} {
+		content, err := os.ReadFile(filepath.Join(goroot, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(filepath.Join(otherGoroot, file), string(content))
+	}
+	otherSum, err := getGorootSum(xgoDir, otherGoroot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherSum != getKey(true, false, false).GorootSum {
+		t.Fatalf("expect same GOROOT sum for same content")
+	}
+}
+
+func TestCacheExportImportPrune(t *testing.T) {
+	cacheRoot := filepath.Join(t.TempDir(), "go-instrument")
+	key := &instrumentCacheKey{GoVersion: "go1.24.2", XgoRevision: getCoreRevision()}
+	name := key.DirName()
+	dir := filepath.Join(cacheRoot, name)
+	if err := os.MkdirAll(filepath.Join(dir, "go1.24.2", "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go1.24.2", "bin", "go"), []byte("go"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(cacheRoot, "go1.24.2_legacy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(t.TempDir(), "cache.tar.gz")
+	err := exportCache(cacheRoot, []string{name}, output)
+	if err == nil || !strings.Contains(err.Error(), "incomplete") {
+		t.Fatalf("expect incomplete error, actual: %v", err)
+	}
+	if err := writeInstrumentCacheManifest(dir, key, "/usr/local/go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := exportCache(cacheRoot, []string{name}, output); err != nil {
+		t.Fatal(err)
+	}
+
+	importRoot := filepath.Join(t.TempDir(), "go-instrument")
+	if err := importCache(importRoot, output); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := listInstrumentCache(importRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != name || entries[0].Manifest == nil || entries[0].Manifest.Sum != key.Sum() {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	stat, err := os.Stat(filepath.Join(importRoot, name, "go1.24.2", "bin", "go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm()&0o100 == 0 {
+		t.Fatalf("expect executable, actual: %v", stat.Mode())
+	}
+
+	// the legacy entry is old, the exported one is just used
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(cacheRoot, "go1.24.2_legacy"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := pruneCache(cacheRoot, 24*time.Hour, false); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = listInstrumentCache(cacheRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != name {
+		t.Fatalf("unexpected entries after prune: %+v", entries)
+	}
+}
+
+func TestCheckCacheName(t *testing.T) {
+	for _, name := range []string{"", ".", "..", "../x", "a/b", `a\b`, ".import-1"} {
+		if checkCacheName(name) == nil {
+			t.Errorf("expect %q to be invalid", name)
+		}
+	}
+	if err := checkCacheName("go1.24.2_0123456789abcdef"); err != nil {
+		t.Error(err)
+	}
+}
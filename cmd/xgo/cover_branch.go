@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xhd2015/xgo/support/coverage"
+)
+
+// XGO_COVER_BRANCH_DIR keep the same with xgo/runtime/coverage
+const XGO_COVER_BRANCH_DIR = "XGO_COVER_BRANCH_DIR"
+
+// mergeBranchProfiles merges profiles written by each
+// test binary in dir into outFile
+func mergeBranchProfiles(dir string, outFile string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var list [][]*coverage.Branch
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".branch") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		branches, err := coverage.ParseBranchProfile(string(content))
+		if err != nil {
+			return fmt.Errorf("--cover-branch: %s: %w", entry.Name(), err)
+		}
+		list = append(list, branches)
+	}
+	return os.WriteFile(outFile, []byte(coverage.FormatBranchProfile(coverage.MergeBranches(list...))), 0644)
+}
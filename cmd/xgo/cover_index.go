@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/xhd2015/xgo/support/coverage"
+	"github.com/xhd2015/xgo/support/git"
+)
+
+type topTest struct {
+	Pkg  string
+	Name string
+}
+
+// see `go help test2json`
+type goTestEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+}
+
+// benchmarks are not listed since they
+// are not run without -bench
+var topTestNameRegex = regexp.MustCompile(`^(Test|Example|Fuzz)\S*$`)
+
+// coverPerTestRun implements --cover-per-test: it lists top-level
+// tests, runs each of them separately with -coverprofile, and
+// writes which blocks each test covers to indexFile.
+// Each test is a separate `go test` process, the test binary is
+// cached by go after the first run, but the per process cost of
+// N tests remains.
+// Failed tests are recorded and reported after all tests run.
+func coverPerTestRun(goBin string, dir string, env []string, baseArgs []string, run string, pkgs []string, testArgs []string, indexFile string) error {
+	if run == "" {
+		run = "."
+	}
+	tests, err := listTopTests(goBin, dir, env, baseArgs, run, pkgs)
+	if err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp("", "xgo-cover-per-test")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	fmt.Fprintf(os.Stderr, "xgo: --cover-per-test runs %d tests one by one, each in a separate process\n", len(tests))
+
+	index := &coverage.TestIndex{}
+	var failed int
+	for i, test := range tests {
+		profile := filepath.Join(tmpDir, fmt.Sprintf("%d.out", i))
+		args := append(baseArgs[:len(baseArgs):len(baseArgs)], "-run", "^"+regexp.QuoteMeta(test.Name)+"$", "-coverprofile", profile, test.Pkg)
+		if len(testArgs) > 0 {
+			args = append(args, "-args")
+			args = append(args, testArgs...)
+		}
+		logDebug("cover per test: %s %s", goBin, __DEBUG_CMD_ARGS(args))
+		cmd := exec.Command(goBin, args...)
+		cmd.Dir = dir
+		cmd.Env = env
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		runErr := cmd.Run()
+		if runErr != nil {
+			failed++
+		}
+		content, readErr := os.ReadFile(profile)
+		if readErr != nil {
+			if runErr == nil {
+				return fmt.Errorf("%s.%s: read coverage profile: %w", test.Pkg, test.Name, readErr)
+			}
+			// failed before writing profile, e.g. build error
+			index.Add(test.Pkg, test.Name, true, nil)
+			continue
+		}
+		mode, lines := coverage.Parse(string(content))
+		if index.Mode == "" {
+			index.Mode = mode
+		}
+		index.Add(test.Pkg, test.Name, runErr != nil, coverage.Compact(lines))
+	}
+	if index.Mode == "" {
+		index.Mode = "set"
+	}
+	index.Revision = getIndexRevision(dir)
+	err = coverage.WriteTestIndex(indexFile, index)
+	if err != nil {
+		return fmt.Errorf("write coverage index: %w", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tests failed", failed, len(tests))
+	}
+	return nil
+}
+
+// getIndexRevision returns HEAD if no .go file has
+// uncommitted changes, otherwise line numbers of the
+// index do not match any commit
+func getIndexRevision(dir string) string {
+	commit, err := git.GetHeadCommit(dir)
+	if err != nil {
+		logDebug("cover per test: get HEAD: %v", err)
+		return ""
+	}
+	changes, err := git.ListChangedLines(dir, commit)
+	if err != nil {
+		logDebug("cover per test: list changes: %v", err)
+		return ""
+	}
+	for _, change := range changes {
+		if strings.HasSuffix(change.File, ".go") {
+			logDebug("cover per test: %s has uncommitted changes, revision not recorded", change.File)
+			return ""
+		}
+	}
+	return commit
+}
+
+func listTopTests(goBin string, dir string, env []string, baseArgs []string, run string, pkgs []string) ([]*topTest, error) {
+	args := append(baseArgs[:len(baseArgs):len(baseArgs)], "-json", "-list", run)
+	args = append(args, pkgs...)
+	logDebug("list tests: %s %s", goBin, __DEBUG_CMD_ARGS(args))
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(goBin, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		os.Stderr.Write(stdout.Bytes())
+		return nil, fmt.Errorf("list tests: %w", err)
+	}
+	return parseTestList(stdout.Bytes())
+}
+
+func parseTestList(output []byte) ([]*topTest, error) {
+	var tests []*topTest
+	seen := make(map[topTest]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(nil, 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var event goTestEvent
+		err := json.Unmarshal(line, &event)
+		if err != nil {
+			return nil, fmt.Errorf("parse test list: %w", err)
+		}
+		if event.Action != "output" || event.Test != "" {
+			continue
+		}
+		name := strings.TrimSpace(event.Output)
+		if !topTestNameRegex.MatchString(name) {
+			continue
+		}
+		test := topTest{Pkg: event.Package, Name: name}
+		if seen[test] {
+			continue
+		}
+		seen[test] = true
+		tests = append(tests, &test)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tests, nil
+}
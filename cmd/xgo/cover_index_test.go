@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestParseTestList(t *testing.T) {
+	output := `{"Action":"start","Package":"example.com/demo"}
+{"Action":"output","Package":"example.com/demo","Output":"TestAdd\n"}
+{"Action":"output","Package":"example.com/demo","Output":"BenchmarkAdd\n"}
+{"Action":"output","Package":"example.com/demo","Output":"ExampleAdd\n"}
+{"Action":"output","Package":"example.com/demo","Output":"FuzzAdd\n"}
+{"Action":"output","Package":"example.com/demo","Output":"ok  \texample.com/demo\t0.01s\n"}
+{"Action":"pass","Package":"example.com/demo"}
+{"Action":"output","Package":"example.com/demo/sub","Output":"TestAdd\n"}
+{"Action":"output","Package":"example.com/demo/sub","Test":"TestSub","Output":"TestSub\n"}
+`
+	tests, err := parseTestList([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []topTest{
+		{Pkg: "example.com/demo", Name: "TestAdd"},
+		{Pkg: "example.com/demo", Name: "ExampleAdd"},
+		{Pkg: "example.com/demo", Name: "FuzzAdd"},
+		{Pkg: "example.com/demo/sub", Name: "TestAdd"},
+	}
+	if len(tests) != len(expect) {
+		t.Fatalf("expect %d tests, actual: %d", len(expect), len(tests))
+	}
+	for i, test := range tests {
+		if *test != expect[i] {
+			t.Fatalf("tests[%d]: expect %+v, actual: %+v", i, expect[i], *test)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/xhd2015/xgo/support/coverage"
+)
+
+// handleBranches lists lines with partially covered branches
+// of profiles generated by `xgo test --cover-branch`
+func handleBranches(args []string) error {
+	var remain []string
+	var all bool
+	var projectDir string
+	n := len(args)
+	for i := 0; i < n; i++ {
+		arg := args[i]
+		if arg == "--" {
+			remain = append(remain, args[i+1:]...)
+			break
+		}
+		if arg == "--all" {
+			all = true
+			continue
+		}
+		if arg == "--project-dir" {
+			if i+1 >= n {
+				return fmt.Errorf("%s requires arg", arg)
+			}
+			projectDir = args[i+1]
+			i++
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") {
+			remain = append(remain, arg)
+			continue
+		}
+		return fmt.Errorf("unrecognized flag: %s", arg)
+	}
+	if len(remain) == 0 {
+		return fmt.Errorf("requires branch profile")
+	}
+	lines, err := loadBranchLines(remain, projectDir)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(os.Stdout)
+	err = writeBranchLines(w, lines, all)
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// loadBranchLines merges branch profiles, file names are
+// made relative to module root of projectDir
+func loadBranchLines(files []string, projectDir string) ([]*coverage.BranchLine, error) {
+	list := make([][]*coverage.Branch, 0, len(files))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		branches, err := coverage.ParseBranchProfile(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		list = append(list, branches)
+	}
+	mapFile, err := moduleFileMapper(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	lines := coverage.GroupBranchesByLine(coverage.MergeBranches(list...))
+	for _, line := range lines {
+		line.File = mapFile(line.File)
+	}
+	return lines, nil
+}
+
+func writeBranchLines(w io.Writer, lines []*coverage.BranchLine, all bool) error {
+	var total, covered int
+	for _, line := range lines {
+		total += line.Total
+		covered += line.Covered
+		if !all && !line.Partial() {
+			continue
+		}
+		var missed []string
+		for _, branch := range line.Branches {
+			if desc := describeMissed(branch); desc != "" {
+				missed = append(missed, desc)
+			}
+		}
+		text := fmt.Sprintf("%s:%d\t%d/%d", line.File, line.Line, line.Covered, line.Total)
+		if len(missed) > 0 {
+			text += "\t" + strings.Join(missed, ", ")
+		}
+		_, err := fmt.Fprintln(w, text)
+		if err != nil {
+			return err
+		}
+	}
+	var percent float64
+	if total > 0 {
+		percent = math.Round(float64(covered)*10000/float64(total)) / 100
+	}
+	_, err := fmt.Fprintf(w, "branch coverage: %v%% (%d/%d outcomes)\n", percent, covered, total)
+	return err
+}
+
+// describeMissed describes outcomes that are never taken
+func describeMissed(branch *coverage.Branch) string {
+	pos := fmt.Sprintf("%s@%d.%d", branch.Kind, branch.StartLine, branch.StartCol)
+	switch branch.Kind {
+	case coverage.BranchKindCase, coverage.BranchKindDefault:
+		if branch.Taken == 0 {
+			return pos + " never taken"
+		}
+		return ""
+	}
+	if branch.Taken == 0 && branch.NotTaken == 0 {
+		return pos + " never evaluated"
+	}
+	if branch.Taken == 0 {
+		return pos + " never true"
+	}
+	if branch.NotTaken == 0 {
+		return pos + " never false"
+	}
+	return ""
+}
@@ -0,0 +1,414 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xhd2015/xgo/cmd/xgo/internal/vendir/github.com/xhd2015/gitops/git"
+	ccov "github.com/xhd2015/xgo/cmd/xgo/internal/vendir/github.com/xhd2015/lines-annotation/compute/coverage"
+	"github.com/xhd2015/xgo/cmd/xgo/internal/vendir/github.com/xhd2015/lines-annotation/load/loadcov"
+	"github.com/xhd2015/xgo/cmd/xgo/internal/vendir/github.com/xhd2015/lines-annotation/model"
+	"github.com/xhd2015/xgo/support/coverage"
+	"github.com/xhd2015/xgo/support/flag"
+	"github.com/xhd2015/xgo/support/testconfig"
+)
+
+type checkOptions struct {
+	// thresholds in percentage, 0 means no threshold
+	MinTotal   float64
+	MinDiff    float64
+	PerPackage bool
+}
+
+// lineCoverage is a coverable line
+type lineCoverage struct {
+	File    string
+	Line    int
+	Covered bool
+	Changed bool
+}
+
+type coverageCount struct {
+	Total   int
+	Covered int
+}
+
+type packageCoverage struct {
+	Pkg   string
+	Total coverageCount
+	Diff  coverageCount
+}
+
+type checkResult struct {
+	Total    coverageCount
+	Diff     coverageCount
+	Packages []*packageCoverage
+	// UncoveredChanged are changed lines not covered,
+	// sorted by file and line
+	UncoveredChanged []*lineCoverage
+	Failures         []string
+}
+
+// handleCheck returns true if all thresholds are met
+func handleCheck(args []string) (bool, error) {
+	var profiles []string
+	var projectDir string
+	var diffWith string
+	var configFile string
+	var buildArgs []string
+	var include []string
+	var exclude []string
+	var minTotal string
+	var minDiff string
+	var perPackage bool
+
+	n := len(args)
+	for i := 0; i < n; i++ {
+		arg := args[i]
+		if arg == "--" {
+			profiles = append(profiles, args[i+1:]...)
+			break
+		}
+		if arg == "--per-package" {
+			perPackage = true
+			continue
+		}
+		var matched bool
+		for _, f := range []struct {
+			flags []string
+			value *string
+			list  *[]string
+		}{
+			{flags: []string{"--project-dir"}, value: &projectDir},
+			{flags: []string{"--diff-with"}, value: &diffWith},
+			{flags: []string{"--config"}, value: &configFile},
+			{flags: []string{"--min-total"}, value: &minTotal},
+			{flags: []string{"--min-diff"}, value: &minDiff},
+			{flags: []string{"--build-arg", "--build-args"}, list: &buildArgs},
+			{flags: []string{"--include"}, list: &include},
+			{flags: []string{"--exclude"}, list: &exclude},
+		} {
+			var set func(v string)
+			if f.list != nil {
+				list := f.list
+				set = func(v string) {
+					*list = append(*list, v)
+				}
+			}
+			ok, err := flag.TryParseFlagsValue(f.flags, f.value, set, &i, args)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") {
+			profiles = append(profiles, arg)
+			continue
+		}
+		return false, fmt.Errorf("unrecognized flag: %s", arg)
+	}
+
+	if configFile == "" {
+		configFile = filepath.Join(projectDir, testconfig.DefaultFileName)
+	}
+	conf, err := testconfig.Load(configFile)
+	if err != nil {
+		return false, fmt.Errorf("load %s: %w", configFile, err)
+	}
+	opts := checkOptions{PerPackage: perPackage}
+	if conf != nil && conf.Coverage != nil {
+		covConf := conf.Coverage
+		opts.MinTotal = covConf.MinTotal
+		opts.MinDiff = covConf.MinDiff
+		opts.PerPackage = opts.PerPackage || covConf.PerPackage
+		if diffWith == "" {
+			diffWith = covConf.DiffWith
+		}
+		if len(profiles) == 0 && covConf.Profile != "" {
+			profiles = append(profiles, filepath.Join(projectDir, covConf.Profile))
+		}
+		if len(include) == 0 && len(exclude) == 0 {
+			include = covConf.Include
+			exclude = covConf.Exclude
+		}
+	}
+	if minTotal != "" {
+		opts.MinTotal, err = parsePercent("--min-total", minTotal)
+		if err != nil {
+			return false, err
+		}
+	}
+	if minDiff != "" {
+		opts.MinDiff, err = parsePercent("--min-diff", minDiff)
+		if err != nil {
+			return false, err
+		}
+	}
+	if len(profiles) == 0 {
+		return false, fmt.Errorf("requires files")
+	}
+	if diffWith == "" {
+		diffWith = "origin/master"
+	}
+
+	project, err := loadcov.LoadAll(loadcov.LoadAllOptions{
+		Dir:      projectDir,
+		Args:     buildArgs,
+		Profiles: profiles,
+		Ref:      git.COMMIT_WORKING,
+		DiffBase: diffWith,
+		Include:  include,
+		Exclude:  exclude,
+	})
+	if err != nil {
+		return false, err
+	}
+	covFiles, err := loadProfileFiles(projectDir, profiles)
+	if err != nil {
+		return false, err
+	}
+	res := checkCoverage(collectLineCoverage(project, covFiles), &opts)
+
+	w := bufio.NewWriter(os.Stdout)
+	err = writeCheckReport(w, res, &opts, diffWith)
+	if err != nil {
+		return false, err
+	}
+	err = w.Flush()
+	if err != nil {
+		return false, err
+	}
+	return len(res.Failures) == 0, nil
+}
+
+func parsePercent(flag string, s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || v < 0 || v > 100 {
+		return 0, fmt.Errorf("%s: expects a percentage between 0 and 100, got %q", flag, s)
+	}
+	return v, nil
+}
+
+func loadProfileFiles(projectDir string, profiles []string) ([]*coverage.FileCoverage, error) {
+	covs := make([][]*coverage.CovLine, 0, len(profiles))
+	for _, file := range profiles {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		_, cov := coverage.Parse(string(content))
+		covs = append(covs, cov)
+	}
+	mapFile, err := moduleFileMapper(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	return coverage.GroupByFile(coverage.Merge(covs...), mapFile), nil
+}
+
+// collectLineCoverage takes coverable lines from the profile, and
+// whether a line is changed from the project loaded by loadcov,
+// files not in the project are filtered by --include, --exclude
+// or --build-arg.
+//
+// NOTE: loadcov also computes line coverage, but it attributes
+// lines to AST blocks, which do not match block positions in
+// profiles generated by newer go versions
+func collectLineCoverage(project *model.ProjectAnnotation, files []*coverage.FileCoverage) []*lineCoverage {
+	var lines []*lineCoverage
+	for _, file := range files {
+		fileData := project.Files[model.RelativeFile(file.File)]
+		if fileData == nil {
+			continue
+		}
+		for _, line := range file.Lines {
+			lineData := fileData.Lines[model.LineNum(line.Line)]
+			if lineData != nil && ccov.LineUncoverableOrExcluded(lineData) {
+				continue
+			}
+			lines = append(lines, &lineCoverage{
+				File:    file.File,
+				Line:    line.Line,
+				Covered: line.Count > 0,
+				Changed: ccov.LineChanged(fileData, lineData),
+			})
+		}
+	}
+	return lines
+}
+
+func checkCoverage(lines []*lineCoverage, opts *checkOptions) *checkResult {
+	res := &checkResult{}
+	pkgMapping := make(map[string]*packageCoverage)
+	for _, line := range lines {
+		pkg := path.Dir(line.File)
+		pkgCov := pkgMapping[pkg]
+		if pkgCov == nil {
+			pkgCov = &packageCoverage{Pkg: pkg}
+			pkgMapping[pkg] = pkgCov
+			res.Packages = append(res.Packages, pkgCov)
+		}
+		res.Total.add(line.Covered)
+		pkgCov.Total.add(line.Covered)
+		if line.Changed {
+			res.Diff.add(line.Covered)
+			pkgCov.Diff.add(line.Covered)
+			if !line.Covered {
+				res.UncoveredChanged = append(res.UncoveredChanged, line)
+			}
+		}
+	}
+	sort.Slice(res.Packages, func(i, j int) bool {
+		return res.Packages[i].Pkg < res.Packages[j].Pkg
+	})
+	sort.Slice(res.UncoveredChanged, func(i, j int) bool {
+		a, b := res.UncoveredChanged[i], res.UncoveredChanged[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+
+	checkMin := func(name string, count coverageCount, min float64) {
+		if !count.meets(min) {
+			res.Failures = append(res.Failures, fmt.Sprintf("%s coverage %s is below %s", name, count.percent(), formatPercent(min)))
+		}
+	}
+	checkMin("total", res.Total, opts.MinTotal)
+	checkMin("diff", res.Diff, opts.MinDiff)
+	if opts.PerPackage {
+		for _, pkg := range res.Packages {
+			checkMin(pkg.Pkg+" total", pkg.Total, opts.MinTotal)
+			checkMin(pkg.Pkg+" diff", pkg.Diff, opts.MinDiff)
+		}
+	}
+	return res
+}
+
+func (c *coverageCount) add(covered bool) {
+	c.Total++
+	if covered {
+		c.Covered++
+	}
+}
+
+// meets treats no lines as 100%
+func (c coverageCount) meets(min float64) bool {
+	if min <= 0 || c.Total == 0 {
+		return true
+	}
+	return float64(c.Covered)*100 >= min*float64(c.Total)
+}
+
+func (c coverageCount) percent() string {
+	if c.Total == 0 {
+		return "100%"
+	}
+	return formatPercent(float64(c.Covered) * 100 / float64(c.Total))
+}
+
+func (c coverageCount) String() string {
+	return fmt.Sprintf("%s (%d/%d lines)", c.percent(), c.Covered, c.Total)
+}
+
+func formatPercent(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64) + "%"
+}
+
+func writeCheckReport(w io.Writer, res *checkResult, opts *checkOptions, diffWith string) error {
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(w, format, args...)
+	}
+	status := func(count coverageCount, min float64) string {
+		if min <= 0 {
+			return ""
+		}
+		if count.meets(min) {
+			return fmt.Sprintf(", min %s: ok", formatPercent(min))
+		}
+		return fmt.Sprintf(", min %s: FAIL", formatPercent(min))
+	}
+	printf("total coverage: %v%s\n", res.Total, status(res.Total, opts.MinTotal))
+	printf("diff coverage against %s: %v%s\n", diffWith, res.Diff, status(res.Diff, opts.MinDiff))
+	if opts.PerPackage {
+		printf("\npackages:\n")
+		for _, pkg := range res.Packages {
+			printf("  %s\n", pkg.Pkg)
+			printf("    total: %v%s\n", pkg.Total, status(pkg.Total, opts.MinTotal))
+			if pkg.Diff.Total > 0 {
+				printf("    diff: %v%s\n", pkg.Diff, status(pkg.Diff, opts.MinDiff))
+			}
+		}
+	}
+	if len(res.UncoveredChanged) > 0 {
+		printf("\nuncovered changed lines:\n")
+		for _, fileRanges := range formatLineRanges(res.UncoveredChanged) {
+			printf("  %s\n", fileRanges)
+		}
+	}
+	if len(res.Failures) > 0 {
+		printf("\nFAIL:\n")
+		for _, failure := range res.Failures {
+			printf("  %s\n", failure)
+		}
+	}
+	return err
+}
+
+// formatLineRanges formats sorted lines as file:1-3,5
+func formatLineRanges(lines []*lineCoverage) []string {
+	var result []string
+	var file string
+	var ranges []string
+	start, end := -1, -1
+	flushRange := func() {
+		if start < 0 {
+			return
+		}
+		if start == end {
+			ranges = append(ranges, strconv.Itoa(start))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", start, end))
+		}
+		start, end = -1, -1
+	}
+	flushFile := func() {
+		flushRange()
+		if len(ranges) > 0 {
+			result = append(result, file+":"+strings.Join(ranges, ","))
+		}
+		ranges = nil
+	}
+	for _, line := range lines {
+		if line.File != file {
+			flushFile()
+			file = line.File
+		}
+		if start >= 0 && line.Line == end+1 {
+			end = line.Line
+			continue
+		}
+		flushRange()
+		start, end = line.Line, line.Line
+	}
+	flushFile()
+	return result
+}
@@ -0,0 +1,76 @@
+package coverage
+
+import (
+	"strings"
+	"testing"
+)
+
+func testCheckLines() []*lineCoverage {
+	return []*lineCoverage{
+		{File: "a/a.go", Line: 3, Covered: true},
+		{File: "a/a.go", Line: 4, Covered: true, Changed: true},
+		{File: "a/a.go", Line: 5, Changed: true},
+		{File: "a/a.go", Line: 6, Changed: true},
+		{File: "a/a.go", Line: 8, Changed: true},
+		{File: "b/b.go", Line: 3, Covered: true},
+		{File: "b/b.go", Line: 4, Covered: true},
+		{File: "b/b.go", Line: 5, Covered: true},
+		{File: "b/b.go", Line: 6},
+		{File: "main.go", Line: 10, Covered: true, Changed: true},
+	}
+}
+
+func TestCheckCoverage(t *testing.T) {
+	res := checkCoverage(testCheckLines(), &checkOptions{MinTotal: 60, MinDiff: 40, PerPackage: true})
+	if res.Total != (coverageCount{Total: 10, Covered: 6}) {
+		t.Fatalf("Total: %+v", res.Total)
+	}
+	if res.Diff != (coverageCount{Total: 5, Covered: 2}) {
+		t.Fatalf("Diff: %+v", res.Diff)
+	}
+	expectFailures := "a total coverage 40% is below 60%,a diff coverage 25% is below 40%"
+	if got := strings.Join(res.Failures, ","); got != expectFailures {
+		t.Fatalf("expect failures %q, actual: %q", expectFailures, got)
+	}
+
+	res = checkCoverage(testCheckLines(), &checkOptions{MinTotal: 60, MinDiff: 40})
+	if len(res.Failures) != 0 {
+		t.Fatalf("expect no failures without --per-package, actual: %v", res.Failures)
+	}
+	res = checkCoverage(testCheckLines(), &checkOptions{MinDiff: 40.1})
+	if len(res.Failures) != 1 {
+		t.Fatalf("expect diff failure, actual: %v", res.Failures)
+	}
+}
+
+func TestWriteCheckReport(t *testing.T) {
+	opts := &checkOptions{MinTotal: 70, MinDiff: 40}
+	res := checkCoverage(testCheckLines(), opts)
+	var b strings.Builder
+	err := writeCheckReport(&b, res, opts, "origin/master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `total coverage: 60% (6/10 lines), min 70%: FAIL
+diff coverage against origin/master: 40% (2/5 lines), min 40%: ok
+
+uncovered changed lines:
+  a/a.go:5-6,8
+
+FAIL:
+  total coverage 60% is below 70%
+`
+	if got := b.String(); got != expect {
+		t.Fatalf("expect report:\n%s\nactual:\n%s", expect, got)
+	}
+}
+
+func TestParsePercent(t *testing.T) {
+	v, err := parsePercent("--min-total", "80.5%")
+	if err != nil || v != 80.5 {
+		t.Fatalf("expect 80.5, actual: %v %v", v, err)
+	}
+	if _, err := parsePercent("--min-total", "101"); err == nil {
+		t.Fatalf("expect error for 101")
+	}
+}
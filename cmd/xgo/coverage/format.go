@@ -0,0 +1,63 @@
+package coverage
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/xhd2015/xgo/support/coverage"
+	"github.com/xhd2015/xgo/support/goinfo"
+)
+
+const (
+	FormatGo        = "go"
+	FormatLCOV      = "lcov"
+	FormatCobertura = "cobertura"
+	FormatJSON      = "json"
+)
+
+func checkFormat(format string) error {
+	switch format {
+	case "", FormatGo, FormatLCOV, FormatCobertura, FormatJSON:
+		return nil
+	}
+	return fmt.Errorf("unrecognized format: %s, expect one of go,lcov,cobertura,json", format)
+}
+
+// formatProfile formats coverage lines, file names of
+// non-go formats are made relative to module root of projectDir
+func formatProfile(format string, mode string, lines []*coverage.CovLine, projectDir string) (string, error) {
+	if format == "" || format == FormatGo {
+		return coverage.Format(mode, lines), nil
+	}
+	mapFile, err := moduleFileMapper(projectDir)
+	if err != nil {
+		return "", err
+	}
+	files := coverage.GroupByFile(lines, mapFile)
+	switch format {
+	case FormatLCOV:
+		return coverage.FormatLCOV(files), nil
+	case FormatCobertura:
+		source, err := filepath.Abs(projectDir)
+		if err != nil {
+			return "", err
+		}
+		return coverage.FormatCobertura(files, &coverage.CoberturaOptions{
+			Source:    source,
+			Timestamp: time.Now().UnixMilli(),
+		})
+	case FormatJSON:
+		return coverage.FormatJSON(mode, files)
+	default:
+		return "", checkFormat(format)
+	}
+}
+
+func moduleFileMapper(projectDir string) (func(file string) string, error) {
+	modPath, err := goinfo.GetModPath(projectDir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve module path to map files: %w", err)
+	}
+	return coverage.ModuleFileMapper(modPath), nil
+}
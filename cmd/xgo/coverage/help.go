@@ -11,14 +11,20 @@ The commands are:
     load        load profiles
     merge       merge coverage profiles
     compact     compact profile
+    check       check coverage thresholds, exits with 1 if missed
+    tests       list tests in an index generated by xgo test --cover-per-test
+    covers      print coverage profile of a single test in the index
+    branches    list lines with partially covered branches in profiles generated by xgo test --cover-branch
     help        show help message
 
 Global options:
   --project-dir DIR         the project dir
 
-Options for merge:
+Options for merge & compact:
     -o <file>               output to file instead of stdout
     --exclude-prefix <pkg>  exclude coverage of a specific package and sub packages
+    --format FORMAT         output format: go(default), lcov, cobertura or json,
+                            file names of non-go formats are relative to the module root
 
 Options for serve & load:
     --diff-with REF         the base branch to diff with when displaying coverage
@@ -26,12 +32,50 @@ Options for serve & load:
     --port PORT             listening port  
     --exclude FILE          exclude FILE
     --include FILE          include FILE
+    --format FORMAT         for load only, output merged profiles as go, lcov, cobertura
+                            or json instead of annotations, --diff-with is ignored
+    --per-test FILE         index generated by xgo test --cover-per-test, enables
+                            /testsOfLine?file=&line= and /coverageOfTest?name=
+    --branch FILE           branch profile generated by xgo test --cover-branch, lines with
+                            branches carry lineData and the BRANCH coverage label, also enables
+                            /branches?file=&partial=true
+
+Options for check:
+    --min-total N           minimal total coverage in percentage
+    --min-diff N            minimal coverage of lines changed since --diff-with
+    --per-package           also apply the thresholds to each package
+    --config FILE           defaults to test.config.json in project dir, thresholds are read from
+                            coverage.min_total, coverage.min_diff and coverage.per_package
+    --diff-with, --build-arg, --include and --exclude are the same as serve
+
+Options for tests:
+    --dead                  only list tests that cover no block uniquely,
+                            they can be removed one at a time without losing coverage
+
+Options for covers:
+    -o <file>               output to file instead of stdout
+
+Options for branches:
+    --all                   list all lines with branches, not only partially covered ones
 
 Examples:
     # merge multiple files into one
     $ xgo tool coverage merge -o cover.a cover-a.out cover-b.out
+    # convert to LCOV and Cobertura
+    $ xgo tool coverage merge --format lcov -o lcov.info cover.out
+    $ xgo tool coverage compact --format cobertura -o coverage.xml cover.out
     # load all
     $ xgo tool coverage load
+    # fail if total coverage < 70% or changed lines coverage < 80%
+    $ xgo tool coverage check --min-total=70 --min-diff=80 --per-package cover.out
+    # record coverage per test, then find tests hitting a line
+    $ xgo test --cover-per-test cover-index.json ./...
+    $ xgo tool coverage tests cover-index.json service/user.go:42
+    # print what TestX covers
+    $ xgo tool coverage covers cover-index.json TestX
+    # find untested branches, e.g. the false side of err != nil
+    $ xgo test --cover-branch cover.branch ./...
+    $ xgo tool coverage branches cover.branch
 
 See https://github.com/xhd2015/xgo for documentation.
 
@@ -20,7 +20,7 @@ func Main(args []string) {
 		fmt.Print(strings.TrimPrefix(help, "\n"))
 		return
 	}
-	if cmd != "merge" && cmd != "compact" && cmd != "serve" && cmd != "load" {
+	if cmd != "merge" && cmd != "compact" && cmd != "serve" && cmd != "load" && cmd != "tests" && cmd != "covers" && cmd != "check" && cmd != "branches" {
 		fmt.Fprintf(os.Stderr, "unrecognized cmd: %s\n", cmd)
 		return
 	}
@@ -33,6 +33,32 @@ func Main(args []string) {
 		}
 		return
 	}
+	if cmd == "check" {
+		pass, err := handleCheck(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if !pass {
+			os.Exit(1)
+		}
+		return
+	}
+	if cmd == "tests" || cmd == "covers" || cmd == "branches" {
+		var err error
+		if cmd == "tests" {
+			err = handleTests(args)
+		} else if cmd == "covers" {
+			err = handleCovers(args)
+		} else {
+			err = handleBranches(args)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	var remainArgs []string
 	var outFile string
@@ -40,6 +66,8 @@ func Main(args []string) {
 
 	var flagHelp bool
 	var excludePrefix []string
+	var format string
+	var projectDir string
 	for i := 0; i < n; i++ {
 		arg := args[i]
 		if arg == "--" {
@@ -68,6 +96,23 @@ func Main(args []string) {
 			i++
 			continue
 		}
+		if arg == "--format" || arg == "--project-dir" {
+			if i+1 >= n {
+				fmt.Fprintf(os.Stderr, "%s requires argument\n", arg)
+				os.Exit(1)
+			}
+			if arg == "--format" {
+				format = args[i+1]
+			} else {
+				projectDir = args[i+1]
+			}
+			i++
+			continue
+		}
+		if strings.HasPrefix(arg, "--format=") {
+			format = strings.TrimPrefix(arg, "--format=")
+			continue
+		}
 		if arg == "--help" || arg == "-h" {
 			flagHelp = true
 			continue
@@ -83,6 +128,10 @@ func Main(args []string) {
 		fmt.Print(strings.TrimPrefix(help, "\n"))
 		return
 	}
+	if err := checkFormat(format); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 
 	switch cmd {
 	case "merge":
@@ -90,7 +139,7 @@ func Main(args []string) {
 			fmt.Fprintf(os.Stderr, "requires files\n")
 			os.Exit(1)
 		}
-		err := mergeCover(remainArgs, outFile, excludePrefix)
+		err := mergeCover(remainArgs, outFile, excludePrefix, format, projectDir)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(1)
@@ -105,7 +154,7 @@ func Main(args []string) {
 			os.Exit(1)
 		}
 		// compact is a special case of merge
-		err := mergeCover(remainArgs, outFile, excludePrefix)
+		err := mergeCover(remainArgs, outFile, excludePrefix, format, projectDir)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(1)
@@ -116,7 +165,7 @@ func Main(args []string) {
 	}
 }
 
-func mergeCover(files []string, outFile string, excludePrefix []string) error {
+func mergeCover(files []string, outFile string, excludePrefix []string, format string, projectDir string) error {
 	// var mode string
 	covs := make([][]*coverage.CovLine, 0, len(files))
 	for _, file := range files {
@@ -139,7 +188,10 @@ func mergeCover(files []string, outFile string, excludePrefix []string) error {
 	// 	mode = "set"
 	// }
 	// always set mode to count
-	mergedCov := coverage.Format("count", res)
+	mergedCov, err := formatProfile(format, "count", res, projectDir)
+	if err != nil {
+		return err
+	}
 
 	var out io.Writer = os.Stdout
 	if outFile != "" {
@@ -150,7 +202,7 @@ func mergeCover(files []string, outFile string, excludePrefix []string) error {
 		defer file.Close()
 		out = file
 	}
-	_, err := io.WriteString(out, mergedCov)
+	_, err = io.WriteString(out, mergedCov)
 	return err
 }
 
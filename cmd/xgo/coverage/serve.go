@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"runtime"
 	"time"
 
@@ -13,7 +14,9 @@ import (
 	"github.com/xhd2015/xgo/cmd/xgo/coverage/serve"
 	"github.com/xhd2015/xgo/cmd/xgo/internal/vendir/github.com/xhd2015/gitops/git"
 	"github.com/xhd2015/xgo/cmd/xgo/internal/vendir/github.com/xhd2015/lines-annotation/load/loadcov"
+	"github.com/xhd2015/xgo/cmd/xgo/internal/vendir/github.com/xhd2015/lines-annotation/path/filter"
 	"github.com/xhd2015/xgo/support/cmd"
+	"github.com/xhd2015/xgo/support/coverage"
 	"github.com/xhd2015/xgo/support/netutil"
 )
 
@@ -29,6 +32,9 @@ func handleServe(cmd string, args []string) error {
 	var include []string
 	var exclude []string
 	var full bool
+	var perTestFile string
+	var branchFiles []string
+	var format string
 	for i := 0; i < n; i++ {
 		arg := args[i]
 		if arg == "--" {
@@ -95,6 +101,34 @@ func handleServe(cmd string, args []string) error {
 			i++
 			continue
 		}
+		if arg == "--per-test" {
+			if i+1 >= n {
+				return fmt.Errorf("%s requires arg", arg)
+			}
+			perTestFile = args[i+1]
+			i++
+			continue
+		}
+		if arg == "--branch" {
+			if i+1 >= n {
+				return fmt.Errorf("%s requires arg", arg)
+			}
+			branchFiles = append(branchFiles, args[i+1])
+			i++
+			continue
+		}
+		if arg == "--format" {
+			if i+1 >= n {
+				return fmt.Errorf("%s requires arg", arg)
+			}
+			format = args[i+1]
+			i++
+			continue
+		}
+		if strings.HasPrefix(arg, "--format=") {
+			format = strings.TrimPrefix(arg, "--format=")
+			continue
+		}
 		if arg == "--full" {
 			full = true
 			continue
@@ -106,10 +140,37 @@ func handleServe(cmd string, args []string) error {
 		return fmt.Errorf("unknown flag: %s", arg)
 	}
 
+	var perTestIndex *coverage.TestIndex
+	if perTestFile != "" {
+		var err error
+		perTestIndex, err = coverage.ReadTestIndex(perTestFile)
+		if err != nil {
+			return err
+		}
+		if len(remain) == 0 {
+			// use coverage of all tests as profile
+			profile, err := writeTempProfile(perTestIndex)
+			if err != nil {
+				return err
+			}
+			defer os.Remove(profile)
+			remain = append(remain, profile)
+		}
+	}
+
 	if len(remain) == 0 {
 		return fmt.Errorf("requires files")
 	}
 
+	var branchLines []*coverage.BranchLine
+	if len(branchFiles) > 0 {
+		var err error
+		branchLines, err = loadBranchLines(branchFiles, projectDir)
+		if err != nil {
+			return err
+		}
+	}
+
 	ref := git.COMMIT_WORKING
 	if diffWith == "" {
 		diffWith = "origin/master"
@@ -125,11 +186,29 @@ func handleServe(cmd string, args []string) error {
 		Exclude:          exclude,
 		OnlyChangedFiles: !full,
 	}
+	if format != "" {
+		if len(branchFiles) > 0 {
+			return fmt.Errorf("--branch cannot be used with --format")
+		}
+		if cmd != "load" {
+			return fmt.Errorf("--format is only supported by load")
+		}
+		if err := checkFormat(format); err != nil {
+			return err
+		}
+		content, err := loadFormatted(format, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Print(content)
+		return nil
+	}
 	if cmd == "load" {
 		data, err := loadcov.LoadAll(opts)
 		if err != nil {
 			return err
 		}
+		serve.AnnotateBranches(data.Files, branchLines)
 		dataJSON, err := json.Marshal(data)
 		if err != nil {
 			return err
@@ -142,7 +221,13 @@ func handleServe(cmd string, args []string) error {
 	server := &http.ServeMux{}
 	serve.RouteServer(server, "", func() int {
 		return actualPort
-	}, opts)
+	}, opts, branchLines)
+	if perTestIndex != nil {
+		serve.RoutePerTest(server, "", perTestIndex)
+	}
+	if len(branchFiles) > 0 {
+		serve.RouteBranches(server, "", branchLines)
+	}
 
 	autoIncrPort := true
 	h, p := netutil.GetHostAndIP(bind, port)
@@ -154,6 +239,68 @@ func handleServe(cmd string, args []string) error {
 	})
 }
 
+// loadFormatted merges profiles and filters them by
+// --include and --exclude, all files are kept regardless
+// of --diff-with since the formats carry no diff info
+func loadFormatted(format string, opts loadcov.LoadAllOptions) (string, error) {
+	var mode string
+	var modeFile string
+	covs := make([][]*coverage.CovLine, 0, len(opts.Profiles))
+	for _, file := range opts.Profiles {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		covMode, cov := coverage.Parse(string(content))
+		if covMode != "" {
+			if mode != "" && covMode != mode {
+				return "", fmt.Errorf("mode of %s is %s, differs from %s of %s", file, covMode, mode, modeFile)
+			}
+			mode = covMode
+			modeFile = file
+		}
+		covs = append(covs, cov)
+	}
+	if mode == "" {
+		mode = "count"
+	}
+	lines := coverage.Merge(covs...)
+	if mode == "set" {
+		// merged counts are summed
+		for _, line := range lines {
+			if line.Count > 1 {
+				line.Count = 1
+			}
+		}
+	}
+	if len(opts.Include) > 0 || len(opts.Exclude) > 0 {
+		mapFile, err := moduleFileMapper(opts.Dir)
+		if err != nil {
+			return "", err
+		}
+		fileFilter := filter.NewFileFilter(opts.Include, opts.Exclude)
+		lines = coverage.Filter(lines, func(line *coverage.CovLine) bool {
+			block, ok := coverage.ParseBlock(line.Prefix)
+			return ok && fileFilter.MatchFile(mapFile(block.File))
+		})
+	}
+	return formatProfile(format, mode, lines, opts.Dir)
+}
+
+func writeTempProfile(index *coverage.TestIndex) (string, error) {
+	file, err := os.CreateTemp("", "xgo-cover-per-test-*.out")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	_, err = file.WriteString(coverage.Format("count", index.AllCovLines()))
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
 func openURL(url string) {
 	openCmd := "open"
 	if runtime.GOOS == "windows" {
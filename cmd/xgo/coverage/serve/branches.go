@@ -0,0 +1,106 @@
+package serve
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/xhd2015/xgo/cmd/xgo/internal/vendir/github.com/xhd2015/lines-annotation/model"
+	"github.com/xhd2015/xgo/support/coverage"
+	"github.com/xhd2015/xgo/support/netutil"
+)
+
+// LineDataType_Branch is the LineDataType of lines
+// having branch points, LineData is *BranchLineInfo
+const LineDataType_Branch = "branch"
+
+// CoverageLabel_Branch is false if some
+// branch outcomes of the line are not covered
+const CoverageLabel_Branch = "BRANCH"
+
+type BranchInfo struct {
+	Kind      string `json:"kind"`
+	StartLine int    `json:"startLine"`
+	StartCol  int    `json:"startCol"`
+	EndLine   int    `json:"endLine"`
+	EndCol    int    `json:"endCol"`
+	Taken     int64  `json:"taken"`
+	NotTaken  int64  `json:"notTaken"`
+}
+
+type BranchLineInfo struct {
+	File     string        `json:"file"`
+	Line     int           `json:"line"`
+	Total    int           `json:"total"`
+	Covered  int           `json:"covered"`
+	Partial  bool          `json:"partial,omitempty"`
+	Branches []*BranchInfo `json:"branches"`
+}
+
+// RouteBranches install these endpoints:
+// /branches         ->    branch lines, filtered by ?file=&partial=true
+func RouteBranches(server *http.ServeMux, prefix string, lines []*coverage.BranchLine) {
+	server.HandleFunc(prefix+"/branches", func(w http.ResponseWriter, r *http.Request) {
+		netutil.SetCORSHeaders(w)
+		netutil.HandleJSON(w, r, func(ctx context.Context, r *http.Request) (interface{}, error) {
+			q := r.URL.Query()
+			file := q.Get("file")
+			onlyPartial := q.Get("partial") == "true"
+			infos := make([]*BranchLineInfo, 0, len(lines))
+			for _, line := range lines {
+				if file != "" && line.File != file {
+					continue
+				}
+				if onlyPartial && !line.Partial() {
+					continue
+				}
+				infos = append(infos, toBranchLineInfo(line))
+			}
+			return infos, nil
+		})
+	})
+}
+
+// AnnotateBranches attaches branch info to lines of files,
+// so partially covered lines can be told apart from fully
+// covered ones
+func AnnotateBranches(files model.FileAnnotationMapping, lines []*coverage.BranchLine) {
+	for _, line := range lines {
+		file := files[model.RelativeFile(line.File)]
+		if file == nil {
+			continue
+		}
+		lineAnnotation := file.Lines[model.LineNum(line.Line)]
+		if lineAnnotation == nil {
+			continue
+		}
+		lineAnnotation.LineDataType = LineDataType_Branch
+		lineAnnotation.LineData = toBranchLineInfo(line)
+		if lineAnnotation.CoverageLabels == nil {
+			lineAnnotation.CoverageLabels = make(map[string]bool, 1)
+		}
+		lineAnnotation.CoverageLabels[CoverageLabel_Branch] = !line.Partial()
+	}
+}
+
+func toBranchLineInfo(line *coverage.BranchLine) *BranchLineInfo {
+	branches := make([]*BranchInfo, 0, len(line.Branches))
+	for _, branch := range line.Branches {
+		branches = append(branches, &BranchInfo{
+			Kind:      branch.Kind,
+			StartLine: branch.StartLine,
+			StartCol:  branch.StartCol,
+			EndLine:   branch.EndLine,
+			EndCol:    branch.EndCol,
+			Taken:     branch.Taken,
+			NotTaken:  branch.NotTaken,
+		})
+	}
+	return &BranchLineInfo{
+		File:     line.File,
+		Line:     line.Line,
+		Total:    line.Total,
+		Covered:  line.Covered,
+		Partial:  line.Partial(),
+		Branches: branches,
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/xhd2015/xgo/cmd/xgo/internal/vendir/github.com/xhd2015/gitops/git"
 	"github.com/xhd2015/xgo/cmd/xgo/internal/vendir/github.com/xhd2015/lines-annotation/load/loadcov"
 	"github.com/xhd2015/xgo/cmd/xgo/internal/vendir/github.com/xhd2015/lines-annotation/model/coverage"
+	xgocov "github.com/xhd2015/xgo/support/coverage"
 	"github.com/xhd2015/xgo/support/netutil"
 )
 
@@ -29,7 +30,8 @@ func getHost(port int) string {
 // /fileAnnotations  ->    dynamic coverage
 // /fileDetail       ->    get file content
 // /diffFileDetail   ->    get diff file content
-func RouteServer(server *http.ServeMux, prefix string, getPort func() int, opts loadcov.LoadAllOptions) {
+// branchLines are optional, see AnnotateBranches
+func RouteServer(server *http.ServeMux, prefix string, getPort func() int, opts loadcov.LoadAllOptions, branchLines []*xgocov.BranchLine) {
 	dir := opts.Dir
 	diffBase := opts.DiffBase
 	server.HandleFunc(prefix+"/", func(w http.ResponseWriter, r *http.Request) {
@@ -56,6 +58,7 @@ func RouteServer(server *http.ServeMux, prefix string, getPort func() int, opts
 			if err != nil {
 				return nil, err
 			}
+			AnnotateBranches(project.Files, branchLines)
 			// NOTE: return files instead of project
 			return project.Files, nil
 		})
@@ -0,0 +1,97 @@
+package serve
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/xhd2015/xgo/support/coverage"
+	"github.com/xhd2015/xgo/support/netutil"
+)
+
+type TestInfo struct {
+	Pkg    string `json:"pkg"`
+	Name   string `json:"name"`
+	Failed bool   `json:"failed,omitempty"`
+	// Blocks is the number of covered blocks
+	Blocks int `json:"blocks"`
+	// Unique is the number of blocks covered only by this test,
+	// a test with 0 unique blocks can be removed without
+	// losing coverage
+	Unique int `json:"unique"`
+}
+
+type TestCoverageResp struct {
+	*TestInfo
+	Mode    string `json:"mode"`
+	Profile string `json:"profile"`
+}
+
+// RoutePerTest install these endpoints:
+// /tests            ->    list all tests
+// /testsOfLine      ->    tests covering ?file=&line=
+// /coverageOfTest   ->    coverage profile of ?name=
+func RoutePerTest(server *http.ServeMux, prefix string, index *coverage.TestIndex) {
+	server.HandleFunc(prefix+"/tests", func(w http.ResponseWriter, r *http.Request) {
+		netutil.SetCORSHeaders(w)
+		netutil.HandleJSON(w, r, func(ctx context.Context, r *http.Request) (interface{}, error) {
+			return toTestInfos(index, index.Tests), nil
+		})
+	})
+	server.HandleFunc(prefix+"/testsOfLine", func(w http.ResponseWriter, r *http.Request) {
+		netutil.SetCORSHeaders(w)
+		netutil.HandleJSON(w, r, func(ctx context.Context, r *http.Request) (interface{}, error) {
+			q := r.URL.Query()
+			file := q.Get("file")
+			if file == "" {
+				return nil, netutil.ParamErrorf("requires file")
+			}
+			line, err := strconv.Atoi(q.Get("line"))
+			if err != nil || line <= 0 {
+				return nil, netutil.ParamErrorf("invalid line: %s", q.Get("line"))
+			}
+			return toTestInfos(index, index.TestsCoveringLine(file, line)), nil
+		})
+	})
+	server.HandleFunc(prefix+"/coverageOfTest", func(w http.ResponseWriter, r *http.Request) {
+		netutil.SetCORSHeaders(w)
+		netutil.HandleJSON(w, r, func(ctx context.Context, r *http.Request) (interface{}, error) {
+			name := r.URL.Query().Get("name")
+			if name == "" {
+				return nil, netutil.ParamErrorf("requires name")
+			}
+			tests := index.FindTest(name)
+			if len(tests) == 0 {
+				return nil, fmt.Errorf("test not found: %s", name)
+			}
+			if len(tests) > 1 {
+				return nil, fmt.Errorf("ambiguous test %s, use pkg.%s instead", name, name)
+			}
+			test := tests[0]
+			return &TestCoverageResp{
+				TestInfo: toTestInfo(index, test),
+				Mode:     index.Mode,
+				Profile:  coverage.Format(index.Mode, index.CovLines(test)),
+			}, nil
+		})
+	})
+}
+
+func toTestInfos(index *coverage.TestIndex, tests []*coverage.TestCoverage) []*TestInfo {
+	infos := make([]*TestInfo, 0, len(tests))
+	for _, test := range tests {
+		infos = append(infos, toTestInfo(index, test))
+	}
+	return infos
+}
+
+func toTestInfo(index *coverage.TestIndex, test *coverage.TestCoverage) *TestInfo {
+	return &TestInfo{
+		Pkg:    test.Pkg,
+		Name:   test.Name,
+		Failed: test.Failed,
+		Blocks: len(test.Blocks),
+		Unique: index.UniqueBlocks(test),
+	}
+}
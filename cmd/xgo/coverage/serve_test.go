@@ -0,0 +1,38 @@
+package coverage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/xgo/cmd/xgo/internal/vendir/github.com/xhd2015/lines-annotation/load/loadcov"
+)
+
+func TestLoadFormattedKeepsMode(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	a := write("a.out", "mode: set\nexample.com/a/a.go:3.10,5.2 1 1\n")
+	b := write("b.out", "mode: set\nexample.com/a/a.go:3.10,5.2 1 1\nexample.com/a/a.go:6.10,7.2 1 0\n")
+	c := write("c.out", "mode: atomic\nexample.com/a/a.go:3.10,5.2 1 3\n")
+
+	res, err := loadFormatted(FormatGo, loadcov.LoadAllOptions{Dir: dir, Profiles: []string{a, b}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "mode: set\nexample.com/a/a.go:3.10,5.2 1 1\nexample.com/a/a.go:6.10,7.2 1 0"
+	if res != expect {
+		t.Fatalf("expect:\n%s\nactual:\n%s", expect, res)
+	}
+
+	_, err = loadFormatted(FormatGo, loadcov.LoadAllOptions{Dir: dir, Profiles: []string{a, c}})
+	if err == nil || !strings.Contains(err.Error(), "mode of "+c+" is atomic, differs from set") {
+		t.Fatalf("expect mode mismatch, actual: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xhd2015/xgo/support/coverage"
+)
+
+// handleTests lists tests of a per-test index generated by
+// `xgo test --cover-per-test`, if locations like file:line
+// are given, only tests covering any of them are listed.
+func handleTests(args []string) error {
+	var remain []string
+	var onlyDead bool
+	n := len(args)
+	for i := 0; i < n; i++ {
+		arg := args[i]
+		if arg == "--" {
+			remain = append(remain, args[i+1:]...)
+			break
+		}
+		if arg == "--dead" {
+			onlyDead = true
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") {
+			remain = append(remain, arg)
+			continue
+		}
+		return fmt.Errorf("unrecognized flag: %s", arg)
+	}
+	if len(remain) == 0 {
+		return fmt.Errorf("requires index file")
+	}
+	index, err := coverage.ReadTestIndex(remain[0])
+	if err != nil {
+		return err
+	}
+	locations := remain[1:]
+
+	w := bufio.NewWriter(os.Stdout)
+	if len(locations) == 0 {
+		err = writeTestList(w, index, onlyDead)
+	} else {
+		if onlyDead {
+			return fmt.Errorf("--dead cannot be used with locations")
+		}
+		err = writeTestsOfLocations(w, index, locations)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// handleCovers prints the coverage profile of a single test
+func handleCovers(args []string) error {
+	var remain []string
+	var outFile string
+	n := len(args)
+	for i := 0; i < n; i++ {
+		arg := args[i]
+		if arg == "--" {
+			remain = append(remain, args[i+1:]...)
+			break
+		}
+		if arg == "-o" {
+			if i+1 >= n {
+				return fmt.Errorf("%s requires file", arg)
+			}
+			outFile = args[i+1]
+			i++
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") {
+			remain = append(remain, arg)
+			continue
+		}
+		return fmt.Errorf("unrecognized flag: %s", arg)
+	}
+	if len(remain) != 2 {
+		return fmt.Errorf("requires index file and test name, given: %v", remain)
+	}
+	index, err := coverage.ReadTestIndex(remain[0])
+	if err != nil {
+		return err
+	}
+	tests := index.FindTest(remain[1])
+	if len(tests) == 0 {
+		return fmt.Errorf("test not found: %s", remain[1])
+	}
+	if len(tests) > 1 {
+		return fmt.Errorf("ambiguous test %s, found in packages: %s, use pkg.%s instead", remain[1], strings.Join(testPkgs(tests), ","), remain[1])
+	}
+	profile := coverage.Format(index.Mode, index.CovLines(tests[0]))
+
+	var out io.Writer = os.Stdout
+	if outFile != "" {
+		file, err := os.Create(outFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	_, err = io.WriteString(out, profile+"\n")
+	return err
+}
+
+func writeTestList(w io.Writer, index *coverage.TestIndex, onlyDead bool) error {
+	for _, test := range index.Tests {
+		unique := index.UniqueBlocks(test)
+		dead := unique == 0
+		if onlyDead && !dead {
+			continue
+		}
+		line := fmt.Sprintf("%s.%s\tblocks=%d\tunique=%d", test.Pkg, test.Name, len(test.Blocks), unique)
+		if test.Failed {
+			line += "\tfailed"
+		}
+		if dead {
+			line += "\tdead"
+		}
+		_, err := fmt.Fprintln(w, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTestsOfLocations(w io.Writer, index *coverage.TestIndex, locations []string) error {
+	seen := make(map[*coverage.TestCoverage]bool)
+	for _, location := range locations {
+		file, line, err := parseLocation(location)
+		if err != nil {
+			return err
+		}
+		for _, test := range index.TestsCoveringLine(file, line) {
+			if seen[test] {
+				continue
+			}
+			seen[test] = true
+			_, err := fmt.Fprintf(w, "%s.%s\n", test.Pkg, test.Name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseLocation parses file:line
+func parseLocation(location string) (string, int, error) {
+	idx := strings.LastIndex(location, ":")
+	if idx < 0 {
+		return "", 0, fmt.Errorf("invalid location %q, expect file:line", location)
+	}
+	line, err := strconv.Atoi(location[idx+1:])
+	if err != nil || line <= 0 {
+		return "", 0, fmt.Errorf("invalid location %q, expect file:line", location)
+	}
+	return location[:idx], line, nil
+}
+
+func testPkgs(tests []*coverage.TestCoverage) []string {
+	pkgs := make([]string, 0, len(tests))
+	for _, test := range tests {
+		pkgs = append(pkgs, test.Pkg)
+	}
+	return pkgs
+}
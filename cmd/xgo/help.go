@@ -18,18 +18,40 @@ The commands are:
     revision    print xgo revision
     upgrade     upgrade to latest version of xgo
     tool        invoke xgo tools   
+    cache       list, export, import and prune instrumented GOROOTs
 
 Examples:
     xgo build -o main ./                         build current module
     xgo build -o main -gcflags="all=-N -l" ./    build current module with debug flags
     xgo run ./                                   run current module
     xgo test ./...                               test all test cases of current module
+    xgo test --patch-dir ./xgo-patches ./...     test with .xgo.patch files applied to GOROOT and dependencies
     xgo exec go version                          print instrumented go version
     xgo tool help                                print help for xgo tools
+    xgo cache export -o xgo.tar.gz               export instrumented GOROOT, see 'xgo cache help'
 
 Examples of Trace:
     xgo test -run TestSomething --strace ./      test and collect stack trace
     xgo tool trace TestSomething.json            view collected stack trace
+    xgo test --strace --strace-max-depth=10 --strace-max-arg-bytes=4096 ./
+                                                 collect stack trace with limited depth and arg size
+    xgo tool trace export --format=otlp TestSomething.json
+                                                 export collected stack trace as OpenTelemetry spans
+    xgo test --strace-replay=./ --strace-replay-rule '{"pkg":"example.com/db"}' ./
+                                                 mock functions in example.com/db with results in collected stack trace
+
+Example of Coverage:
+    xgo test --cover-per-test cover-index.json ./...
+                                                 run each test separately and record which blocks it covers,
+                                                 one go test process per test, much slower than a normal run
+    xgo tool coverage tests cover-index.json service/user.go:42
+                                                 list tests covering the line
+    xgo test --cover-branch cover.branch ./...   record each if/switch outcome and &&/|| operand
+    xgo tool coverage branches cover.branch      list lines with partially covered branches
+    xgo test --affected-since origin/master ./...
+                                                 only test packages affected by changes since origin/master
+    xgo test --affected-since HEAD --affected-index cover-index.json ./...
+                                                 only run tests covering lines changed since HEAD
 
 Example of Test Explorer:
     xgo e                                        open test explorer, alias for xgo tool test-explorer
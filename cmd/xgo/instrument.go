@@ -19,6 +19,7 @@ import (
 	"github.com/xhd2015/xgo/instrument/config"
 	"github.com/xhd2015/xgo/instrument/constants"
 	"github.com/xhd2015/xgo/instrument/edit"
+	"github.com/xhd2015/xgo/instrument/instrument_branch"
 	"github.com/xhd2015/xgo/instrument/instrument_func"
 	"github.com/xhd2015/xgo/instrument/instrument_go"
 	"github.com/xhd2015/xgo/instrument/instrument_intf"
@@ -50,7 +51,7 @@ type instrumentResult struct {
 // goroot is critical for stdlib
 // includeAsMainModules: extra module paths treated as main for mock/trap (option B:
 // reclassify packages already on the load graph; do not bulk-load module/...).
-func instrumentUserCode(goroot string, projectDir string, projectRoot string, goVersion *goinfo.GoVersion, xgoSrc string, mod string, modfile string, mainModule string, includeAsMainModules []string, xgoRuntimeModuleDir string, mayHaveCover bool, overlayFS overlay.Overlay, includeTest bool, rules []Rule, trapPkgs []string, trapAll string, collectTestTrace bool, collectTestTraceDir string, xgoRaceSafe bool, goFlag bool, triedUpgrade bool) (*instrumentResult, error) {
+func instrumentUserCode(goroot string, projectDir string, projectRoot string, goVersion *goinfo.GoVersion, xgoSrc string, mod string, modfile string, mainModule string, includeAsMainModules []string, xgoRuntimeModuleDir string, mayHaveCover bool, overlayFS overlay.Overlay, includeTest bool, rules []Rule, trapPkgs []string, trapAll string, collectTestTrace bool, collectTestTraceDir string, straceLimits string, straceReplayDir string, straceReplayRules string, xgoRaceSafe bool, coverBranch bool, goFlag bool, triedUpgrade bool) (*instrumentResult, error) {
 	logDebug("instrumentUserSpace: mod=%s, modfile=%s, xgoRuntimeModuleDir=%s, includeTest=%v, collectTestTrace=%v, includeAsMainModules=%v", mod, modfile, xgoRuntimeModuleDir, includeTest, collectTestTrace, includeAsMainModules)
 	if mod == "" {
 		// check vendor dir
@@ -85,6 +86,9 @@ func instrumentUserCode(goroot string, projectDir string, projectRoot string, go
 		XgoNumber:           NUMBER,
 		CollectTestTrace:    collectTestTrace,
 		CollectTestTraceDir: collectTestTraceDir,
+		StraceLimits:        straceLimits,
+		StraceReplayDir:     straceReplayDir,
+		StraceReplayRules:   straceReplayRules,
 		XgoRaceSafe:         xgoRaceSafe,
 		ReadRuntimeGenFile: func(path []string) ([]byte, error) {
 			return readRuntimeGenFile(xgoSrc, path)
@@ -265,9 +269,13 @@ func instrumentUserCode(goroot string, projectDir string, projectRoot string, go
 		initial := pkg.Initial
 		pkgRecorder := recorder.GetOrInit(pkgPath)
 
-		var hasVarTrap bool
+		// the compiler declares package stubs with index 0
+		// for extra funcs, unless they are already declared
+		// in source, by var traps or implementations of
+		// interfaces in the first file
+		var pkgStubsDeclared bool
 		if pkgRecorder != nil && pkgRecorder.NumVars > 0 {
-			hasVarTrap = true
+			pkgStubsDeclared = true
 		}
 
 		mode := config.CheckInstrumentMode(stdlib, main, initial, needTrapAll)
@@ -290,12 +298,25 @@ func instrumentUserCode(goroot string, projectDir string, projectRoot string, go
 
 			// interface types
 			var extraInterfaces []*compiler_extra.Interface
-			if mode != config.InstrumentMode_None {
+			if mode != config.InstrumentMode_None || pkgRecorder.HasInterfaceRef() {
 				intfTypes := instrument_intf.CollectInterfaces(file)
+				implFuncs := instrument_intf.GenerateImpls(reg, pkg, file, intfTypes, pkgRecorder)
+				file.TrapFuncs = append(file.TrapFuncs, implFuncs...)
+				if len(implFuncs) > 0 && file.Index == 0 {
+					pkgStubsDeclared = true
+				}
 				if main {
 					file.InterfaceTypes = append(file.InterfaceTypes, intfTypes...)
 				} else {
 					for _, intf := range intfTypes {
+						if intf.Impl != "" {
+							// registered along with the implementation
+							file.InterfaceTypes = append(file.InterfaceTypes, intf)
+							continue
+						}
+						if mode == config.InstrumentMode_None {
+							continue
+						}
 						if mode == config.InstrumentMode_All {
 							// pass
 						} else if mode == config.InstrumentMode_Exported {
@@ -324,17 +345,22 @@ func instrumentUserCode(goroot string, projectDir string, projectRoot string, go
 			}
 		}
 		if len(extraFiles) > 0 {
-			md5sum := md5sumTraps(extraFiles, hasVarTrap)
+			md5sum := md5sumTraps(extraFiles, pkgStubsDeclared)
 			extraPkgs = append(extraPkgs, &compiler_extra.Package{
 				Path:       pkg.LoadPackage.GoPackage.ImportPath,
 				Files:      extraFiles,
-				HasVarTrap: hasVarTrap,
+				HasVarTrap: pkgStubsDeclared,
 				TrapMD5Sum: md5sum,
 			})
 		}
 	}
 	logDebug("extraPkgs: %d", len(extraPkgs))
 
+	if coverBranch {
+		logDebug("instrument branch coverage: len(mainPkgs)=%d", len(mainPkgs))
+		instrumentBranches(fset, mainPkgs)
+	}
+
 	logDebug("generate functab register")
 	registerFuncTab(pkgs)
 
@@ -355,7 +381,7 @@ func instrumentUserCode(goroot string, projectDir string, projectRoot string, go
 	}, nil
 }
 
-func md5sumTraps(files []*compiler_extra.File, hasVarTrap bool) string {
+func md5sumTraps(files []*compiler_extra.File, pkgStubsDeclared bool) string {
 	h := md5.New()
 	for _, file := range files {
 		// assume files are stable
@@ -368,7 +394,7 @@ func md5sumTraps(files []*compiler_extra.File, hasVarTrap bool) string {
 		}
 	}
 	var v string = "v:0"
-	if hasVarTrap {
+	if pkgStubsDeclared {
 		v = "v:1"
 	}
 	io.WriteString(h, v)
@@ -376,6 +402,30 @@ func md5sumTraps(files []*compiler_extra.File, hasVarTrap bool) string {
 	return hex.EncodeToString(md5[:])
 }
 
+// instrumentBranches records branch outcomes of non-test files,
+// and flushes them when each test finishes
+func instrumentBranches(fset *token.FileSet, mainPkgs []*edit.Package) {
+	for _, pkg := range mainPkgs {
+		pkgPath := pkg.LoadPackage.GoPackage.ImportPath
+		var hasTestFile bool
+		var hasFlush bool
+		for _, file := range pkg.Files {
+			if strings.HasSuffix(file.File.Name, "_test.go") {
+				hasTestFile = true
+				if instrument_branch.AddFlush(file) {
+					hasFlush = true
+				}
+				continue
+			}
+			instrument_branch.Instrument(fset, file, pkgPath)
+		}
+		if hasTestFile && !hasFlush {
+			// e.g. only TestMain, or tests with unusual signatures
+			fmt.Fprintf(os.Stderr, "WARNING: --cover-branch: no test found in %s to flush branch counters, its run is not recorded\n", pkgPath)
+		}
+	}
+}
+
 func registerFuncTab(packages *edit.Packages) {
 	fset := packages.Fset
 	for _, pkg := range packages.Packages {
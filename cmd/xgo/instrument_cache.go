@@ -0,0 +1,289 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xhd2015/xgo/cmd/xgo/asset"
+	"github.com/xhd2015/xgo/cmd/xgo/pathsum"
+	"github.com/xhd2015/xgo/support/goinfo"
+	"github.com/xhd2015/xgo/support/osinfo"
+)
+
+const INSTRUMENT_XGO_REVISION_FILE = "xgo-revision.txt"
+
+// the manifest of an instrumented GOROOT, written after instrumentation
+// finished, so an entry without it is incomplete or created by xgo
+// before the cache was content addressed
+const INSTRUMENT_CACHE_MANIFEST_FILE = "xgo-cache.json"
+
+// instrumentCacheKey identifies the content of an instrumented GOROOT.
+// Two GOROOTs with same key are interchangeable, regardless of the
+// machine or the path they are prepared from.
+type instrumentCacheKey struct {
+	GoVersion   string   `json:"go_version"`
+	GOOS        string   `json:"goos"`
+	GOARCH      string   `json:"goarch"`
+	GorootSum   string   `json:"goroot_sum"`
+	XgoRevision string   `json:"xgo_revision"`
+	PatchesSum  string   `json:"patches_sum,omitempty"`
+	Flags       []string `json:"flags,omitempty"`
+}
+
+type instrumentCacheManifest struct {
+	instrumentCacheKey
+	Sum     string    `json:"sum"`
+	Goroot  string    `json:"goroot"`
+	Created time.Time `json:"created"`
+}
+
+// Sum is the sha256 of the key
+func (c *instrumentCacheKey) Sum() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		// only strings
+		panic(err)
+	}
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// DirName is the name of the instrument dir under
+// ~/.xgo/go-instrument, i.e. go1.24.2_0123456789abcdef
+func (c *instrumentCacheKey) DirName() string {
+	return c.GoVersion + "_" + c.Sum()[:16]
+}
+
+func getInstrumentCacheKey(xgoDir string, goroot string, goVersion *goinfo.GoVersion, goVersionName string, useFilePatches bool, skipRebuildCompilerAndGo bool, rehashGoroot bool) (*instrumentCacheKey, error) {
+	gorootSum, err := getGorootSum(xgoDir, goroot, rehashGoroot)
+	if err != nil {
+		return nil, err
+	}
+	var patchesSum string
+	// in development mode, patches are read from
+	// the xgo source and re-applied on every run
+	if useFilePatches && goVersion.Minor >= 24 && !isDevelopment {
+		patchesSum, err = hashFS(asset.PatchesFS, path.Join(asset.Patches, getPatchVersionDir(goVersion)))
+		if err != nil {
+			return nil, fmt.Errorf("hash patches: %w", err)
+		}
+	}
+	flags := []string{fmt.Sprintf("use-file-patches=%v", useFilePatches)}
+	if skipRebuildCompilerAndGo {
+		flags = append(flags, "skip-rebuild-compiler-and-go")
+	}
+	return &instrumentCacheKey{
+		GoVersion:   goVersionName,
+		GOOS:        runtime.GOOS,
+		GOARCH:      runtime.GOARCH,
+		GorootSum:   gorootSum,
+		XgoRevision: getCoreRevision(),
+		PatchesSum:  patchesSum,
+		Flags:       flags,
+	}, nil
+}
+
+type gorootSumRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Sum         string `json:"sum"`
+}
+
+// getGorootSum hashes the content of $GOROOT/src, VERSION and go.env.
+// Hashing takes about a second, so the result is remembered under
+// ~/.xgo/goroot-sum until the GOROOT is reinstalled, or rehashGoroot
+// is set.
+func getGorootSum(xgoDir string, goroot string, rehashGoroot bool) (string, error) {
+	fingerprint, err := getGorootFingerprint(goroot)
+	if err != nil {
+		return "", err
+	}
+	name, err := pathsum.PathSum("", goroot)
+	if err != nil {
+		return "", err
+	}
+	recordFile := filepath.Join(xgoDir, "goroot-sum", name+".json")
+	if !rehashGoroot {
+		var record gorootSumRecord
+		data, readErr := os.ReadFile(recordFile)
+		if readErr == nil && json.Unmarshal(data, &record) == nil && record.Fingerprint == fingerprint && record.Sum != "" {
+			return record.Sum, nil
+		}
+	}
+	sum, err := hashGoroot(goroot)
+	if err != nil {
+		return "", fmt.Errorf("hash GOROOT %s: %w", goroot, err)
+	}
+	data, err := json.Marshal(&gorootSumRecord{Fingerprint: fingerprint, Sum: sum})
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(filepath.Dir(recordFile), 0755)
+	if err != nil {
+		return "", err
+	}
+	err = os.WriteFile(recordFile, data, 0644)
+	if err != nil {
+		return "", err
+	}
+	return sum, nil
+}
+
+// getGorootFingerprint changes whenever the GOROOT is reinstalled
+func getGorootFingerprint(goroot string) (string, error) {
+	goBin := filepath.Join(goroot, "bin", "go"+osinfo.EXE_SUFFIX)
+	stat, err := os.Stat(goBin)
+	if err != nil {
+		return "", err
+	}
+	version, err := readOrEmpty(filepath.Join(goroot, "VERSION"))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s\n%s\n%d %d", goroot, version, stat.Size(), stat.ModTime().UnixNano()), nil
+}
+
+func hashGoroot(goroot string) (string, error) {
+	return hashFS(os.DirFS(goroot), ".", "VERSION", "go.env", "src")
+}
+
+// hashFS hashes relative paths, modes and contents of regular files
+// under the given roots, missing roots are skipped
+func hashFS(fsys fs.FS, roots ...string) (string, error) {
+	h := sha256.New()
+	for _, root := range roots {
+		err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if name == root && errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			f, err := fsys.Open(name)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			fmt.Fprintf(h, "%s %o %d\n", name, info.Mode().Perm()&0111, info.Size())
+			_, err = io.Copy(h, f)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeInstrumentCacheManifest(instrumentDir string, key *instrumentCacheKey, goroot string) error {
+	data, err := json.MarshalIndent(&instrumentCacheManifest{
+		instrumentCacheKey: *key,
+		Sum:                key.Sum(),
+		Goroot:             goroot,
+		Created:            time.Now(),
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(instrumentDir, INSTRUMENT_CACHE_MANIFEST_FILE), data, 0644)
+}
+
+// touchInstrumentCache records the last use of an instrumented
+// GOROOT as the mtime of its manifest, used by `xgo cache prune`
+func touchInstrumentCache(instrumentDir string) error {
+	now := time.Now()
+	err := os.Chtimes(filepath.Join(instrumentDir, INSTRUMENT_CACHE_MANIFEST_FILE), now, now)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+type instrumentCacheEntry struct {
+	Name string
+	Dir  string
+	// nil if the entry is legacy or incomplete
+	Manifest *instrumentCacheManifest
+	LastUsed time.Time
+}
+
+// GoVersion is the prefix of the entry name, i.e. go1.24.2
+func (c *instrumentCacheEntry) GoVersion() string {
+	idx := strings.Index(c.Name, "_")
+	if idx < 0 {
+		return c.Name
+	}
+	return c.Name[:idx]
+}
+
+func listInstrumentCache(cacheRoot string) ([]*instrumentCacheEntry, error) {
+	dirEntries, err := os.ReadDir(cacheRoot)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []*instrumentCacheEntry
+	for _, dirEntry := range dirEntries {
+		// .import-* are imports in progress
+		if !dirEntry.IsDir() || strings.HasPrefix(dirEntry.Name(), ".") {
+			continue
+		}
+		entry, err := readInstrumentCacheEntry(filepath.Join(cacheRoot, dirEntry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+func readInstrumentCacheEntry(dir string) (*instrumentCacheEntry, error) {
+	entry := &instrumentCacheEntry{
+		Name: filepath.Base(dir),
+		Dir:  dir,
+	}
+	manifestFile := filepath.Join(dir, INSTRUMENT_CACHE_MANIFEST_FILE)
+	data, err := os.ReadFile(manifestFile)
+	if err == nil {
+		var manifest instrumentCacheManifest
+		err = json.Unmarshal(data, &manifest)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", manifestFile, err)
+		}
+		entry.Manifest = &manifest
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	// legacy entries are last written by the revision file
+	for _, file := range []string{manifestFile, filepath.Join(dir, INSTRUMENT_XGO_REVISION_FILE), dir} {
+		stat, err := os.Stat(file)
+		if err == nil {
+			entry.LastUsed = stat.ModTime()
+			break
+		}
+	}
+	return entry, nil
+}
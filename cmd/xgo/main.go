@@ -97,6 +97,11 @@ func main() {
 		consumeErrAndExit(err)
 		return
 	}
+	if cmd == "cache" {
+		err := handleCache(args)
+		consumeErrAndExit(err)
+		return
+	}
 	if cmd == "shadow" {
 		consumeErrAndExit(fmt.Errorf("shadow is deprecated, use `xgo setup` instead"))
 		return
@@ -222,9 +227,11 @@ func handleBuild(cmd string, args []string) error {
 	stackTrace := opts.stackTrace
 	stackTraceDir := opts.stackTraceDir
 	straceSnapshotMainModuleDefault := opts.straceSnapshotMainModuleDefault
+	straceReplay := opts.straceReplay
 	trapStdlib := opts.trapStdlib
 	trapAll := opts.trapAll
 	trapPkgs := opts.trap
+	patchDirs := opts.patchDirs
 	unified := opts.unified
 	noLineDirective := opts.noLineDirective
 	deleteFlag := opts.deleteFlag
@@ -234,6 +241,9 @@ func handleBuild(cmd string, args []string) error {
 	if cmdExec && len(remainArgs) == 0 {
 		return fmt.Errorf("exec requires command")
 	}
+	if cmdExec && len(patchDirs) > 0 {
+		return fmt.Errorf("--patch-dir is not supported by exec")
+	}
 
 	closeDebug, err = setupDebugLog(logDebugOption)
 	if err != nil {
@@ -249,6 +259,27 @@ func handleBuild(cmd string, args []string) error {
 		logDebug("current working dir: %s", wd)
 	}
 
+	// select tests before setup, which may take a while
+	if opts.affectedSince != "" {
+		if !cmdTest || flagC || (debug != nil && *debug != "false") {
+			return fmt.Errorf("--affected-since is only supported by xgo test")
+		}
+		var affectedRun string
+		var skip bool
+		remainArgs, affectedRun, skip, err = selectAffectedTests(projectDir, mod, opts.affectedSince, opts.affectedIndex, remainArgs)
+		if err != nil {
+			return fmt.Errorf("--affected-since: %w", err)
+		}
+		if skip {
+			return nil
+		}
+		if flagRun == "" {
+			flagRun = affectedRun
+		}
+	} else if opts.affectedIndex != "" {
+		return fmt.Errorf("--affected-index requires --affected-since")
+	}
+
 	goroot, err := checkGoroot(projectDir, withGoroot)
 	if err != nil {
 		return err
@@ -365,8 +396,6 @@ func handleBuild(cmd string, args []string) error {
 		instrumentSuffix = "-dev"
 	}
 
-	const INSTRUMENT_XGO_REVISION_FILE = "xgo-revision.txt"
-
 	var instrumentDir string
 	var instrumentCacheDir string
 
@@ -387,14 +416,24 @@ func handleBuild(cmd string, args []string) error {
 		}
 	}
 
-	if !instrumented {
-		// cache map by orig goroot
+	var cacheKey *instrumentCacheKey
+	if !instrumented && opts.patchGorootInPlace {
+		// the content of GOROOT changes after patching,
+		// so it is mapped by path
 		mappedGorootName, err := pathsum.PathSum(goVersionName+"_", goroot)
 		if err != nil {
 			return err
 		}
-		// ~/.xgo/go-instrument/go1.21.0
 		instrumentDir = filepath.Join(xgoDir, "go-instrument"+instrumentSuffix, mappedGorootName)
+	} else if !instrumented {
+		// cache map by content of goroot, xgo revision, patches and flags,
+		// so the instrumented GOROOT can be shared by `xgo cache export`
+		cacheKey, err = getInstrumentCacheKey(xgoDir, goroot, goVersion, goVersionName, *opts.useFilePatches, opts.skipRebuildCompilerAndGo, resetInstrument)
+		if err != nil {
+			return err
+		}
+		// ~/.xgo/go-instrument/go1.21.0_0123456789abcdef
+		instrumentDir = filepath.Join(xgoDir, "go-instrument"+instrumentSuffix, cacheKey.DirName())
 	}
 
 	// cache maps to instrument dir
@@ -446,8 +485,26 @@ func handleBuild(cmd string, args []string) error {
 		instrumentedGorootNeedRecreate = false
 	}
 
+	mockRules := opts.mockRules
+	enableStraceReplay := cmdTest && straceReplay != ""
+	var straceReplayRules string
+	if enableStraceReplay {
+		var replayIncludeRules []string
+		straceReplayRules, replayIncludeRules, err = parseStraceReplayRules(opts.straceReplayRules)
+		if err != nil {
+			return err
+		}
+		// ensure replayed functions are instrumented
+		mockRules = append(mockRules[:len(mockRules):len(mockRules)], replayIncludeRules...)
+	}
+
+	straceLimits, err := parseStraceLimits(opts.straceMaxDepth, opts.straceMaxChildren, opts.straceMaxArgBytes, opts.straceSamples, opts.straceCollapseRepeated)
+	if err != nil {
+		return err
+	}
+
 	includeAsMainModules := parseModuleList(opts.mockRuleIncludeAsMainModule)
-	optionsFromFile, optionsFromFileContent, err := mergeOptionFiles(sessionTmpDir, opts.optionsFromFile, opts.mockRules, includeAsMainModules)
+	optionsFromFile, optionsFromFileContent, err := mergeOptionFiles(sessionTmpDir, opts.optionsFromFile, mockRules, includeAsMainModules)
 	if err != nil {
 		return err
 	}
@@ -573,6 +630,18 @@ func handleBuild(cmd string, args []string) error {
 			if err != nil {
 				return err
 			}
+			if cacheKey != nil {
+				err = writeInstrumentCacheManifest(instrumentDir, cacheKey, goroot)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if cacheKey != nil {
+			err = touchInstrumentCache(instrumentDir)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -651,10 +720,43 @@ func handleBuild(cmd string, args []string) error {
 	debugMode := runDebug || testDebug || buildDebug
 	var finalBuildOutput string
 
+	coverPerTest := opts.coverPerTest
+	if coverPerTest != "" {
+		if !cmdTest || flagC || debugMode {
+			return fmt.Errorf("--cover-per-test is only supported by xgo test")
+		}
+		coverPerTest, err = filepath.Abs(coverPerTest)
+		if err != nil {
+			return fmt.Errorf("--cover-per-test: %w", err)
+		}
+	}
+
+	coverBranch := opts.coverBranch
+	var coverBranchDir string
+	if coverBranch != "" {
+		if !cmdTest || flagC || debugMode {
+			return fmt.Errorf("--cover-branch is only supported by xgo test")
+		}
+		coverBranch, err = filepath.Abs(coverBranch)
+		if err != nil {
+			return fmt.Errorf("--cover-branch: %w", err)
+		}
+		// each test binary writes its counters into this dir
+		coverBranchDir, err = os.MkdirTemp("", "xgo-cover-branch")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(coverBranchDir)
+	}
+
 	execCmdEnv := build.MakeGorootEnv(os.Environ(), instrumentGoroot)
+	if coverBranchDir != "" {
+		execCmdEnv = append(execCmdEnv, XGO_COVER_BRANCH_DIR+"="+coverBranchDir)
+	}
 
 	var execCmd *exec.Cmd
 	var logCmdExec func()
+	var runCoverPerTest func(env []string) error
 
 	var instrumentUserCodeResult *instrumentResult
 	if !cmdExec {
@@ -727,15 +829,21 @@ xgo will try best to compile with newer xgo/runtime v%s, it's recommended to upg
 		// Always load runtime into a local dir so xgo writes modifications
 		// there instead of creating GOMODCACHE overlays.
 		needLocalRuntime := goVersion.Minor >= 25
-		if enableStackTrace || needUpgrade || needLocalRuntime {
+		// --cover-branch imports xgo/runtime/coverage
+		needRuntimeCoverage := coverBranch != ""
+		if enableStackTrace || enableStraceReplay || needUpgrade || needLocalRuntime || needRuntimeCoverage {
 			// check if xgo/runtime ready
-			impResult, impRuntimeErr := importRuntimeDepGenOverlay(cmdTest, instrumentGoroot, instrumentGo, goVersion, modfile, realXgoSrc, projectDir, projectRoot, localXgoGenDir, mainModule, mod, resetInstrument || flagA, needUpgrade || needLocalRuntime, remainArgs)
+			impResult, impRuntimeErr := importRuntimeDepGenOverlay(cmdTest, instrumentGoroot, instrumentGo, goVersion, modfile, realXgoSrc, projectDir, projectRoot, localXgoGenDir, mainModule, mod, resetInstrument || flagA, needUpgrade || needLocalRuntime || needRuntimeCoverage, remainArgs)
 			if impRuntimeErr != nil {
 				// can be silently ignored
 				if enableStackTrace {
 					fmt.Fprintf(os.Stderr, "WARNING: --strace requires: import _ %q\n   failed to auto import %s: %v\n", constants.RUNTIME_TRACE_PKG, constants.RUNTIME_TRACE_PKG, impRuntimeErr)
+				} else if enableStraceReplay {
+					fmt.Fprintf(os.Stderr, "WARNING: --strace-replay requires: import _ %q\n   failed to auto import %s: %v\n", constants.RUNTIME_TRACE_PKG, constants.RUNTIME_TRACE_PKG, impRuntimeErr)
 				} else if needUpgrade {
 					fmt.Fprintf(os.Stderr, "WARNING: auto upgrade fails: %v\n", impRuntimeErr)
+				} else if needRuntimeCoverage {
+					return fmt.Errorf("--cover-branch requires %s: %w", constants.RUNTIME_COVERAGE_PKG, impRuntimeErr)
 				} else if needLocalRuntime {
 					// Go 1.25+: loading runtime locally failed, fall back to overlay
 					// (may fail if runtime is in GOMODCACHE)
@@ -768,6 +876,14 @@ xgo will try best to compile with newer xgo/runtime v%s, it's recommended to upg
 				xgoRuntimeModuleDir = impResult.runtimeModuleDir
 			}
 		}
+		if len(patchDirs) > 0 {
+			// applied before instrumenting, so that
+			// instrumentation sees the patched source
+			modfile, err = applyUserPatches(patchDirs, overlayFS, instrumentGoroot, instrumentGo, goVersion, projectDir, projectRoot, localXgoGenDir, mod, modfile)
+			if err != nil {
+				return err
+			}
+		}
 
 		var opts FileOptions
 		if len(optionsFromFileContent) > 0 {
@@ -779,7 +895,8 @@ xgo will try best to compile with newer xgo/runtime v%s, it's recommended to upg
 		// coverage and instrument have conflicts,
 		// see https://github.com/xhd2015/xgo/issues/301
 		// TODO: enhance this to be extract -coverpkg pkg1,pkg2,...
-		var mayHaveCover bool
+		// --cover-per-test always runs with -coverprofile
+		mayHaveCover := coverPerTest != ""
 		// example:
 		//    -cover -coverpkg github.com/xhd2015/xgo/... -coverprofile cover.out
 		for _, arg := range args {
@@ -791,16 +908,22 @@ xgo will try best to compile with newer xgo/runtime v%s, it's recommended to upg
 
 		var collectTestTrace bool
 		var collectTestTraceDir string
+		var collectTestTraceLimits string
 		if cmdTest && enableStackTrace {
 			collectTestTrace = true
 			collectTestTraceDir = stackTraceDir
+			collectTestTraceLimits = straceLimits
+		}
+		var straceReplayDir string
+		if enableStraceReplay {
+			straceReplayDir = straceReplay
 		}
 		// Prefer CLI/env effective list; fall back to list already in options file if any.
 		instrumentIncludeAsMain := includeAsMainModules
 		if len(instrumentIncludeAsMain) == 0 {
 			instrumentIncludeAsMain = opts.MockRuleIncludeAsMainModule
 		}
-		instrumentUserCodeResult, err = instrumentUserCode(instrumentGoroot, projectDir, projectRoot, goVersion, realXgoSrc, modForLoad, modfileForLoad, mainModule, instrumentIncludeAsMain, xgoRuntimeModuleDir, mayHaveCover, overlayFS, cmdTest, opts.FilterRules, trapPkgs, trapAll, collectTestTrace, collectTestTraceDir, xgoRaceSafe, goFlag, needUpgrade)
+		instrumentUserCodeResult, err = instrumentUserCode(instrumentGoroot, projectDir, projectRoot, goVersion, realXgoSrc, modForLoad, modfileForLoad, mainModule, instrumentIncludeAsMain, xgoRuntimeModuleDir, mayHaveCover, overlayFS, cmdTest, opts.FilterRules, trapPkgs, trapAll, collectTestTrace, collectTestTraceDir, collectTestTraceLimits, straceReplayDir, straceReplayRules, xgoRaceSafe, coverBranch != "", goFlag, needUpgrade)
 		if err != nil {
 			return err
 		}
@@ -883,7 +1006,8 @@ xgo will try best to compile with newer xgo/runtime v%s, it's recommended to upg
 		if flagC && !testDebug {
 			buildCmdArgs = append(buildCmdArgs, "-c")
 		}
-		if flagRun != "" {
+		// --cover-per-test uses -run to select each test
+		if flagRun != "" && coverPerTest == "" {
 			if !testDebug {
 				buildCmdArgs = append(buildCmdArgs, "-run", flagRun)
 			} else {
@@ -951,6 +1075,11 @@ xgo will try best to compile with newer xgo/runtime v%s, it's recommended to upg
 			buildCmdArgs = append(buildCmdArgs, buildFlags...)
 		}
 		buildCmdArgs = append(buildCmdArgs, build.ExternalLinkerFlags(goVersion)...)
+		if coverPerTest != "" {
+			runCoverPerTest = func(env []string) error {
+				return coverPerTestRun(instrumentGo, projectDir, env, buildCmdArgs[:len(buildCmdArgs):len(buildCmdArgs)], flagRun, remainArgs, testArgs, coverPerTest)
+			}
+		}
 		if len(progFlags) > 0 {
 			runFlagsAfterBuild = append(runFlagsAfterBuild, progFlags...)
 		}
@@ -1085,7 +1214,19 @@ xgo will try best to compile with newer xgo/runtime v%s, it's recommended to upg
 	if logCmdExec != nil {
 		logCmdExec()
 	}
-	err = execCmd.Run()
+	if runCoverPerTest != nil {
+		err = runCoverPerTest(execCmdEnv)
+	} else {
+		err = execCmd.Run()
+	}
+	if coverBranchDir != "" {
+		// branch profile is written even if tests fail,
+		// same as -coverprofile
+		mergeErr := mergeBranchProfiles(coverBranchDir, coverBranch)
+		if err == nil {
+			err = mergeErr
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -93,6 +93,41 @@ type options struct {
 	stackTraceDir string
 	// --strace-snapshot-main-module-default
 	straceSnapshotMainModuleDefault string
+	// --strace-max-depth, --strace-max-children,
+	// --strace-max-arg-bytes, --strace-sample(repeatable)
+	// and --strace-collapse-repeated
+	straceMaxDepth         string
+	straceMaxChildren      string
+	straceMaxArgBytes      string
+	straceSamples          []string
+	straceCollapseRepeated bool
+	// --strace-replay
+	straceReplay string
+	// --strace-replay-rule, same format as --mock-rule
+	straceReplayRules []string
+
+	// --cover-per-test <file>
+	// run each top-level test separately with -coverprofile,
+	// and write a test->blocks index to file.
+	// each test is a separate `go test` process
+	coverPerTest string
+
+	// --cover-branch <file>
+	// record each if/switch outcome and each &&/|| operand
+	// of the main module, and write a branch profile to file
+	coverBranch string
+
+	// --affected-since <ref>
+	// only run tests affected by changes since ref,
+	// narrowed by --affected-index if given
+	affectedSince string
+	// --affected-index <file>, generated by --cover-per-test
+	affectedIndex string
+
+	// --patch-dir DIR, can repeat
+	// .xgo.patch files applied to GOROOT packages
+	// and dependencies, see user_patch.go
+	patchDirs []string
 
 	// --delete
 	deleteFlag bool
@@ -181,9 +216,21 @@ func parseOptions(cmd string, args []string) (*options, error) {
 	var stackTrace string
 	var stackTraceDir string
 	var straceSnapshotMainModuleDefault string
+	var straceMaxDepth string
+	var straceMaxChildren string
+	var straceMaxArgBytes string
+	var straceSamples []string
+	var straceCollapseRepeated bool
+	var straceReplay string
+	var straceReplayRules []string
+	var coverPerTest string
+	var coverBranch string
+	var affectedSince string
+	var affectedIndex string
 	var trapStdlib bool
 	var trapAll string
 	var trap []string
+	var patchDirs []string
 
 	var unified bool
 
@@ -250,6 +297,56 @@ func parseOptions(cmd string, args []string) (*options, error) {
 			Flags: []string{"--mock-rule-include-as-main-module"},
 			Value: &mockRuleIncludeAsMainModule,
 		},
+		{
+			Flags: []string{"--strace-max-depth"},
+			Value: &straceMaxDepth,
+		},
+		{
+			Flags: []string{"--strace-max-children"},
+			Value: &straceMaxChildren,
+		},
+		{
+			Flags: []string{"--strace-max-arg-bytes"},
+			Value: &straceMaxArgBytes,
+		},
+		{
+			Flags: []string{"--strace-sample"},
+			Set: func(v string) {
+				straceSamples = append(straceSamples, v)
+			},
+		},
+		{
+			Flags: []string{"--strace-replay"},
+			Value: &straceReplay,
+		},
+		{
+			Flags: []string{"--strace-replay-rule"},
+			Set: func(v string) {
+				straceReplayRules = append(straceReplayRules, v)
+			},
+		},
+		{
+			Flags: []string{"--cover-per-test"},
+			Value: &coverPerTest,
+		},
+		{
+			Flags: []string{"--patch-dir"},
+			Set: func(v string) {
+				patchDirs = append(patchDirs, v)
+			},
+		},
+		{
+			Flags: []string{"--cover-branch"},
+			Value: &coverBranch,
+		},
+		{
+			Flags: []string{"--affected-since"},
+			Value: &affectedSince,
+		},
+		{
+			Flags: []string{"--affected-index"},
+			Value: &affectedIndex,
+		},
 		{
 			Flags: []string{"--dump-ir"},
 			Value: &dumpIR,
@@ -520,6 +617,12 @@ func parseOptions(cmd string, args []string) (*options, error) {
 			straceSnapshotMainModuleDefault = val
 			continue
 		}
+		// supported flag: --strace-collapse-repeated, --strace-collapse-repeated=false
+		collapseRepeatedFlag, collapseRepeatedVal := flag.TrySingleFlag([]string{"--strace-collapse-repeated"}, arg)
+		if collapseRepeatedFlag != "" {
+			straceCollapseRepeated = collapseRepeatedVal == "" || collapseRepeatedVal == "true"
+			continue
+		}
 
 		// supported flag: --trap-stdlib, --trap-stdlib=false, --trap-stdlib=true
 		trapStdlibFlag, trapStdlibVal := flag.TrySingleFlag([]string{"--trap-stdlib"}, arg)
@@ -642,9 +745,21 @@ func parseOptions(cmd string, args []string) (*options, error) {
 		stackTrace:                      stackTrace,
 		stackTraceDir:                   stackTraceDir,
 		straceSnapshotMainModuleDefault: straceSnapshotMainModuleDefault,
+		straceMaxDepth:                  straceMaxDepth,
+		straceMaxChildren:               straceMaxChildren,
+		straceMaxArgBytes:               straceMaxArgBytes,
+		straceSamples:                   straceSamples,
+		straceCollapseRepeated:          straceCollapseRepeated,
+		straceReplay:                    straceReplay,
+		straceReplayRules:               straceReplayRules,
+		coverPerTest:                    coverPerTest,
+		coverBranch:                     coverBranch,
+		affectedSince:                   affectedSince,
+		affectedIndex:                   affectedIndex,
 		trapStdlib:                      trapStdlib,
 		trapAll:                         trapAll,
 		trap:                            trap,
+		patchDirs:                       patchDirs,
 
 		unified: unified,
 
@@ -35,6 +35,39 @@ import (
 // so can be cleared by newer xgo
 type _FilePath = patch.FilePath
 
+// extractPatches extracts the embedded file-based patches into
+// tmpDir, which should be removed by the caller, and returns
+// the patch dir of goVersion under it
+func extractPatches(goVersion *goinfo.GoVersion) (tmpDir string, patchDir string, err error) {
+	if _, err := fs.ReadDir(asset.PatchesFS, "."); err != nil {
+		return "", "", fmt.Errorf("file-based patches not embedded (binary built with Go < 1.24)")
+	}
+	tmpDir, err = os.MkdirTemp("", "xgo-patches-*")
+	if err != nil {
+		return "", "", err
+	}
+	if err := embedutil.CopyDir(asset.PatchesFS, asset.Patches, tmpDir, embedutil.CopyOptions{}); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", "", fmt.Errorf("extract patches: %w", err)
+	}
+	patchDir = filepath.Join(tmpDir, getPatchVersionDir(goVersion))
+	filepath.WalkDir(patchDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".go.txt") {
+			return os.Rename(path, strings.TrimSuffix(path, ".txt"))
+		}
+		return nil
+	})
+	return tmpDir, patchDir, nil
+}
+
+// getPatchVersionDir returns the dir of goVersion under patches/
+func getPatchVersionDir(goVersion *goinfo.GoVersion) string {
+	return fmt.Sprintf("go%d.%d", goVersion.Major, goVersion.Minor)
+}
+
 // assume go 1.20
 // the patch should be idempotent
 // the origGoroot is used to generate runtime defs, see https://github.com/xhd2015/xgo/issues/4#issuecomment-2017880791
@@ -52,30 +85,14 @@ func patchRuntime(origGoroot string, goroot string, xgoSrc string, goVersion *go
 	if useFilePatches && goVersion.Minor >= 24 {
 		var patchDir string
 		if isDevelopment {
-			patchDir = filepath.Join(xgoSrc, "patches", fmt.Sprintf("go%d.%d", goVersion.Major, goVersion.Minor))
+			patchDir = filepath.Join(xgoSrc, "patches", getPatchVersionDir(goVersion))
 		} else {
-			var err error
-			patchDir, err = os.MkdirTemp("", "xgo-patches-*")
+			tmpDir, versionPatchDir, err := extractPatches(goVersion)
 			if err != nil {
 				return err
 			}
-			defer os.RemoveAll(patchDir)
-			if _, err := fs.ReadDir(asset.PatchesFS, "."); err != nil {
-				return fmt.Errorf("file-based patches not embedded (binary built with Go < 1.24)")
-			}
-			if err := embedutil.CopyDir(asset.PatchesFS, asset.Patches, patchDir, embedutil.CopyOptions{}); err != nil {
-				return fmt.Errorf("extract patches: %w", err)
-			}
-			patchDir = filepath.Join(patchDir, fmt.Sprintf("go%d.%d", goVersion.Major, goVersion.Minor))
-			filepath.WalkDir(patchDir, func(path string, d os.DirEntry, err error) error {
-				if err != nil {
-					return err
-				}
-				if !d.IsDir() && strings.HasSuffix(path, ".go.txt") {
-					return os.Rename(path, strings.TrimSuffix(path, ".txt"))
-				}
-				return nil
-			})
+			defer os.RemoveAll(tmpDir)
+			patchDir = versionPatchDir
 			xgoSrc, err = os.MkdirTemp("", "xgo-src-*")
 			if err != nil {
 				return err
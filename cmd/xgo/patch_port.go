@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xhd2015/xgo/instrument/patch"
+	"github.com/xhd2015/xgo/support/filecopy"
+	"github.com/xhd2015/xgo/support/flag"
+	"github.com/xhd2015/xgo/support/goinfo"
+)
+
+// handlePatchPort seeds patches of a new go release from
+// the previous one, see `xgo tool patch help`
+func handlePatchPort(args []string) error {
+	var from string
+	var to string
+	var goroot string
+	var patchDir string
+	var xgoSrc string
+	var outDir string
+	var remainArgs []string
+	n := len(args)
+	for i := 0; i < n; i++ {
+		arg := args[i]
+		if arg == "--" {
+			remainArgs = append(remainArgs, args[i+1:]...)
+			break
+		}
+		if arg == "-h" || arg == "--help" {
+			fmt.Print(strings.TrimPrefix(patchToolHelp, "\n"))
+			return nil
+		}
+		var matched bool
+		for _, f := range []struct {
+			name  string
+			value *string
+		}{
+			{"--from", &from},
+			{"--to", &to},
+			{"--goroot", &goroot},
+			{"--patch-dir", &patchDir},
+			{"--xgo-src", &xgoSrc},
+			{"--out", &outDir},
+		} {
+			ok, err := flag.TryParseFlagValue(f.name, f.value, nil, &i, args)
+			if err != nil {
+				return err
+			}
+			if ok {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") {
+			remainArgs = append(remainArgs, arg)
+			continue
+		}
+		return fmt.Errorf("unrecognized flag: %s", arg)
+	}
+	if goroot == "" && len(remainArgs) == 1 {
+		goroot = remainArgs[0]
+		remainArgs = nil
+	}
+	if goroot == "" || len(remainArgs) > 0 {
+		return fmt.Errorf("requires --goroot, see xgo tool patch help")
+	}
+	if patchDir != "" && xgoSrc != "" {
+		return fmt.Errorf("--patch-dir and --xgo-src cannot be used together")
+	}
+	goroot, err := filepath.Abs(goroot)
+	if err != nil {
+		return err
+	}
+	gorootVersion, err := goinfo.GetGorootVersion(goroot)
+	if err != nil {
+		return fmt.Errorf("get version of %s: %w", goroot, err)
+	}
+
+	toVersion := gorootVersion
+	if to != "" {
+		toVersion, err = parsePatchVersion("--to", to)
+		if err != nil {
+			return err
+		}
+		if toVersion.Major != gorootVersion.Major || toVersion.Minor != gorootVersion.Minor {
+			return fmt.Errorf("--to %s does not match goroot version go%d.%d", to, gorootVersion.Major, gorootVersion.Minor)
+		}
+	}
+	fromVersion := &goinfo.GoVersion{Major: toVersion.Major, Minor: toVersion.Minor - 1}
+	if from != "" {
+		fromVersion, err = parsePatchVersion("--from", from)
+		if err != nil {
+			return err
+		}
+	}
+	fromDir := getPatchVersionDir(fromVersion)
+	toDir := getPatchVersionDir(toVersion)
+	if fromDir == toDir {
+		return fmt.Errorf("--from and --to are both %s", fromDir)
+	}
+
+	// the tree to port from, printed as patches/go1.xx
+	// if it is the embedded one
+	srcDir := patchDir
+	displayDir := patchDir
+	if patchDir == "" {
+		displayDir = filepath.Join("patches", fromDir)
+		if xgoSrc != "" {
+			srcDir = filepath.Join(xgoSrc, "patches", fromDir)
+			displayDir = srcDir
+		} else {
+			tmpDir, versionPatchDir, err := extractPatches(fromVersion)
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(tmpDir)
+			srcDir = versionPatchDir
+		}
+	}
+	if _, err := os.Stat(srcDir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("patch dir not found: %s", displayDir)
+		}
+		return err
+	}
+	if outDir == "" {
+		if patchDir == "" && xgoSrc == "" {
+			return fmt.Errorf("requires --out when porting the embedded patches")
+		}
+		outDir = filepath.Join(filepath.Dir(srcDir), toDir)
+	}
+	if _, err := os.Stat(outDir); err == nil {
+		return fmt.Errorf("output dir already exists: %s", outDir)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	ports, err := patch.PortPatches(srcDir, goroot)
+	if err != nil {
+		return err
+	}
+	gorootVersionName := fmt.Sprintf("go%d.%d.%d", gorootVersion.Major, gorootVersion.Minor, gorootVersion.Patch)
+	err = writePortedPatches(srcDir, outDir, fromDir, toDir, ports)
+	if err != nil {
+		return err
+	}
+	changelog := formatPortChangelog(displayDir, fromDir, toDir, gorootVersionName, ports)
+	err = os.WriteFile(filepath.Join(outDir, "CHANGELOG"), []byte(changelog), 0644)
+	if err != nil {
+		return err
+	}
+
+	var blocks int
+	var relocated int
+	var failed int
+	for _, port := range ports {
+		pos := filepath.Join(outDir, filepath.FromSlash(port.PatchFile))
+		if port.Err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", pos, port.Err)
+			continue
+		}
+		blocks += len(port.Blocks)
+		for _, block := range port.Blocks {
+			switch block.Status {
+			case patch.PortFailed:
+				failed++
+				fmt.Fprintf(os.Stderr, "%s:%d: patch %q: %v\n", pos, block.Line, block.Block, block.Err)
+			case patch.PortRelocated:
+				relocated++
+			}
+			for _, note := range block.Notes {
+				fmt.Fprintf(os.Stderr, "%s: patch %q: %s\n", pos, block.Block, note)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "ported %d blocks of %d files to %s, %d relocated, %d failed, see %s\n", blocks, len(ports), outDir, relocated, failed, filepath.Join(outDir, "CHANGELOG"))
+	if failed > 0 {
+		return fmt.Errorf("%d blocks need to be ported by hand", failed)
+	}
+	return nil
+}
+
+func parsePatchVersion(flagName string, version string) (*goinfo.GoVersion, error) {
+	if !strings.HasPrefix(version, "go") {
+		return nil, fmt.Errorf("%s: expect go1.xx, actual: %s", flagName, version)
+	}
+	goVersion, err := goinfo.ParseGoVersionNumber(strings.TrimPrefix(version, "go"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", flagName, err)
+	}
+	return goVersion, nil
+}
+
+// writePortedPatches writes the patch tree of srcDir to outDir,
+// with .xgo.patch files replaced by the ported ones. The CHANGELOG
+// of the old version is not copied.
+func writePortedPatches(srcDir string, outDir string, fromDir string, toDir string, ports []*patch.FilePort) error {
+	contents := make(map[string]string, len(ports))
+	for _, port := range ports {
+		contents[port.PatchFile] = port.Content
+	}
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(outDir, relPath)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		switch relPath {
+		case "CHANGELOG":
+			return nil
+		case "__config__.json":
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			// "version": "go1.26+"
+			content = []byte(strings.Replace(string(content), `"`+fromDir+`+"`, `"`+toDir+`+"`, 1))
+			return os.WriteFile(target, content, 0644)
+		}
+		if content, ok := contents[filepath.ToSlash(relPath)]; ok {
+			return os.WriteFile(target, []byte(content), 0644)
+		}
+		return filecopy.CopyFile(path, target)
+	})
+}
+
+// formatPortChangelog formats the port result in the
+// same layout as the CHANGELOG of each patch version
+func formatPortChangelog(displayDir string, fromDir string, toDir string, gorootVersion string, ports []*patch.FilePort) string {
+	var failed []string
+	var review []string
+	var unchanged []string
+	for _, port := range ports {
+		if port.Err != nil {
+			failed = append(failed, fmt.Sprintf("- `%s`: %v", port.PatchFile, port.Err))
+			continue
+		}
+		fileUnchanged := true
+		for _, block := range port.Blocks {
+			if !block.NeedsAttention() {
+				continue
+			}
+			fileUnchanged = false
+			item := fmt.Sprintf("- `%s` (`%s`):", port.PatchFile, block.Block)
+			if block.Status == patch.PortFailed {
+				item += fmt.Sprintf("\n  line %d: %v", block.Line, block.Err)
+			}
+			for _, note := range block.Notes {
+				item += "\n  " + note
+			}
+			if block.Status == patch.PortFailed {
+				failed = append(failed, item)
+			} else {
+				review = append(review, item)
+			}
+		}
+		if fileUnchanged {
+			unchanged = append(unchanged, fmt.Sprintf("- `%s`", port.TargetFile))
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s Patch CHANGELOG\n\n", toDir)
+	fmt.Fprintf(&b, "## Status\n\n")
+	fmt.Fprintf(&b, "Seeded from `%s` by `xgo tool patch port`. Compared anchors against %s.\n\n", filepath.ToSlash(displayDir), gorootVersion)
+	fmt.Fprintf(&b, "## Patch adjustments from %s to %s\n", fromDir, toDir)
+	sections := []struct {
+		title string
+		items []string
+	}{
+		{"Patches failed (port by hand, kept as is)", failed},
+		{"Patches to review (anchors relocated or edit text may not compile)", review},
+		{fmt.Sprintf("Patches unchanged (anchors still match %s)", gorootVersion), unchanged},
+	}
+	for _, section := range sections {
+		if len(section.items) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n%s\n", section.title, strings.Join(section.items, "\n"))
+	}
+	return b.String()
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/xhd2015/xgo/instrument/patch"
+	"github.com/xhd2015/xgo/support/cmd"
+	"github.com/xhd2015/xgo/support/fileutil"
+	"github.com/xhd2015/xgo/support/flag"
+	"github.com/xhd2015/xgo/support/goinfo"
+)
+
+const patchToolHelp = `
+Xgo tool patch validates .xgo.patch files against a GOROOT
+without instrumenting it.
+
+Usage:
+    xgo tool patch check [options] <goroot>
+    xgo tool patch diff [options] <goroot>
+    xgo tool patch apply [options] <goroot>
+    xgo tool patch port [options] --goroot <goroot>
+
+The commands are:
+    check      apply every patch in memory, report blocks whose goto,
+               match or find_for_replace anchors cannot be resolved
+    diff       print a unified diff of each patched file, same as apply --dry-run
+    apply      write patched files into goroot, nothing is written if any block fails
+    port       seed the patch tree of a new go release from the previous one,
+               relocating anchors that moved, and write a CHANGELOG listing
+               blocks that need human attention
+
+Options:
+    --patch-dir DIR  the patch dir containing src/, e.g. patches/go1.27,
+                     default is the one embedded in xgo matching goroot's version
+    --xgo-src DIR    use DIR/patches/go1.xx instead of the embedded patches
+    --dry-run        for apply, print the diff instead of writing
+
+Options for port:
+    --goroot DIR     the GOROOT of the new release
+    --from VERSION   the patch version to port from, default is the previous
+                     one of goroot's version, e.g. go1.26
+    --to VERSION     the patch version to create, default is goroot's version
+    --xgo-src DIR    port DIR/patches/<from> into DIR/patches/<to>
+    --patch-dir DIR  port DIR into <dir of DIR>/<to>
+    --out DIR        the dir to write, must not exist, required when
+                     porting the embedded patches
+
+Only .xgo.patch files are processed, other files and __config__.json are ignored.
+check and diff exit with 1 if any block fails.
+
+port copies other files as is, bumps the version of __config__.json and replaces
+CHANGELOG. A match or find_for_replace anchor that is not found is relocated to the
+only code in scope that is the same except for formatting and one renamed identifier.
+Failed blocks are kept as is, and port exits with 1 after writing the tree.
+Run go run ./script/generate cmd/xgo/asset/patches to embed the new tree.
+
+Examples:
+    xgo tool patch check /usr/local/go
+    xgo tool patch diff --patch-dir ./patches/go1.27 ~/go-fork
+    xgo tool patch port --xgo-src . --from go1.26 --to go1.27 --goroot ~/sdk/go1.27rc2
+`
+
+func handlePatchTool(args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Print(strings.TrimPrefix(patchToolHelp, "\n"))
+		return nil
+	}
+	command := args[0]
+	args = args[1:]
+	if command == "port" {
+		return handlePatchPort(args)
+	}
+	if command != "check" && command != "diff" && command != "apply" {
+		return fmt.Errorf("unrecognized command: %s, see xgo tool patch help", command)
+	}
+
+	var patchDir string
+	var xgoSrc string
+	var dryRun bool
+	var remainArgs []string
+	n := len(args)
+	for i := 0; i < n; i++ {
+		arg := args[i]
+		if arg == "--" {
+			remainArgs = append(remainArgs, args[i+1:]...)
+			break
+		}
+		if arg == "-h" || arg == "--help" {
+			fmt.Print(strings.TrimPrefix(patchToolHelp, "\n"))
+			return nil
+		}
+		if arg == "--dry-run" {
+			dryRun = true
+			continue
+		}
+		ok, err := flag.TryParseFlagValue("--patch-dir", &patchDir, nil, &i, args)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		ok, err = flag.TryParseFlagValue("--xgo-src", &xgoSrc, nil, &i, args)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") {
+			remainArgs = append(remainArgs, arg)
+			continue
+		}
+		return fmt.Errorf("unrecognized flag: %s", arg)
+	}
+	if len(remainArgs) != 1 {
+		return fmt.Errorf("requires goroot, see xgo tool patch help")
+	}
+	if dryRun && command != "apply" {
+		return fmt.Errorf("--dry-run is only for apply")
+	}
+	if patchDir != "" && xgoSrc != "" {
+		return fmt.Errorf("--patch-dir and --xgo-src cannot be used together")
+	}
+	goroot, err := filepath.Abs(remainArgs[0])
+	if err != nil {
+		return err
+	}
+
+	// patch files are printed relative to displayDir
+	displayDir := patchDir
+	if patchDir == "" {
+		goVersion, err := goinfo.GetGorootVersion(goroot)
+		if err != nil {
+			return fmt.Errorf("get version of %s: %w, specify patches with --patch-dir", goroot, err)
+		}
+		versionDir := getPatchVersionDir(goVersion)
+		displayDir = filepath.Join("patches", versionDir)
+		if xgoSrc != "" {
+			patchDir = filepath.Join(xgoSrc, "patches", versionDir)
+			displayDir = patchDir
+		} else {
+			tmpDir, versionPatchDir, err := extractPatches(goVersion)
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(tmpDir)
+			patchDir = versionPatchDir
+		}
+	}
+	if _, err := os.Stat(patchDir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("patch dir not found: %s", displayDir)
+		}
+		return err
+	}
+
+	checks, err := patch.CheckPatches(patchDir, goroot)
+	if err != nil {
+		return err
+	}
+	var blocks int
+	var failed int
+	for _, check := range checks {
+		blocks += check.Blocks
+		if len(check.Errors) > 0 {
+			failed++
+		}
+		for _, blockErr := range check.Errors {
+			pos := filepath.Join(displayDir, filepath.FromSlash(check.PatchFile))
+			if blockErr.Line > 0 {
+				pos += fmt.Sprintf(":%d", blockErr.Line)
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", pos, formatBlockError(blockErr))
+		}
+	}
+
+	switch {
+	case command == "diff" || (command == "apply" && dryRun):
+		for _, check := range checks {
+			if check.Patched == check.Original {
+				continue
+			}
+			diff, err := diffPatched(check.TargetFile, check.Original, check.Patched)
+			if err != nil {
+				return err
+			}
+			fmt.Print(diff)
+		}
+	case command == "apply":
+		if failed > 0 {
+			return fmt.Errorf("%d of %d patch files failed, nothing written", failed, len(checks))
+		}
+		for _, check := range checks {
+			if check.Patched == check.Original {
+				continue
+			}
+			err := fileutil.WriteFile(filepath.Join(goroot, filepath.FromSlash(check.TargetFile)), []byte(check.Patched))
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(os.Stderr, "applied %d blocks to %d files\n", blocks, len(checks))
+		return nil
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d patch files failed", failed, len(checks))
+	}
+	if command == "check" {
+		fmt.Fprintf(os.Stderr, "%d blocks of %d files ok\n", blocks, len(checks))
+	}
+	return nil
+}
+
+// formatBlockError formats err without the line,
+// which is printed as part of the file position
+func formatBlockError(err *patch.BlockError) string {
+	if err.Block == "" {
+		return err.Err.Error()
+	}
+	return fmt.Sprintf("patch %q: %v", err.Block, err.Err)
+}
+
+// diffPatched returns a unified diff between original
+// and patched content of relFile, as a/relFile and b/relFile
+func diffPatched(relFile string, original string, patched string) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "xgo-patch-diff")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	a := filepath.Join("a", filepath.FromSlash(relFile))
+	b := filepath.Join("b", filepath.FromSlash(relFile))
+	for file, content := range map[string]string{a: original, b: patched} {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(tmpDir, file)), 0755)
+		if err != nil {
+			return "", err
+		}
+		err = os.WriteFile(filepath.Join(tmpDir, file), []byte(content), 0644)
+		if err != nil {
+			return "", err
+		}
+	}
+	diff, err := cmd.Dir(tmpDir).Output("git", "diff", "--no-index", "--no-color", "--no-prefix", "--", a, b)
+	if err != nil {
+		// exits with 1 if there are differences
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			err = nil
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return diff + "\n", nil
+}
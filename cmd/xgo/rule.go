@@ -103,3 +103,33 @@ func mergeOptionFiles(tmpDir string, optionFromFile string, mockRules []string,
 	err = fileutil.WriteFile(newFile, newOptionFile)
 	return newFile, newOptionFile, err
 }
+
+// parseStraceReplayRules validates --strace-replay-rule values,
+// returns the rules as a JSON array to be injected into
+// the runtime, and the include rules which are also needed
+// for instrumenting the selected functions
+func parseStraceReplayRules(replayRules []string) (rulesJSON string, includeRules []string, err error) {
+	rules := make([]Rule, 0, len(replayRules))
+	for _, replayRule := range replayRules {
+		if replayRule == "" {
+			continue
+		}
+		var rule Rule
+		err := json.Unmarshal([]byte(replayRule), &rule)
+		if err != nil {
+			return "", nil, fmt.Errorf("parse strace replay rule: %s %w", replayRule, err)
+		}
+		rules = append(rules, rule)
+		if rule.Action == "" || rule.Action == "include" {
+			includeRules = append(includeRules, replayRule)
+		}
+	}
+	if len(rules) == 0 {
+		return "", nil, fmt.Errorf("--strace-replay requires at least one --strace-replay-rule")
+	}
+	content, err := json.Marshal(rules)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(content), includeRules, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// straceLimits is injected into the runtime as JSON,
+// keep the same with runtime/internal/trap/trace_limit.go
+type straceLimits struct {
+	MaxDepth         int                `json:"MaxDepth,omitempty"`
+	MaxChildren      int                `json:"MaxChildren,omitempty"`
+	MaxArgBytes      int                `json:"MaxArgBytes,omitempty"`
+	Sampling         map[string]float64 `json:"Sampling,omitempty"`
+	CollapseRepeated bool               `json:"CollapseRepeated,omitempty"`
+}
+
+// parseStraceLimits parses --strace-max-depth, --strace-max-children,
+// --strace-max-arg-bytes, --strace-sample and --strace-collapse-repeated,
+// returns empty string if no limit is set
+func parseStraceLimits(maxDepth string, maxChildren string, maxArgBytes string, samples []string, collapseRepeated bool) (string, error) {
+	var limits straceLimits
+	var err error
+	limits.MaxDepth, err = parseStraceLimitInt("--strace-max-depth", maxDepth)
+	if err != nil {
+		return "", err
+	}
+	limits.MaxChildren, err = parseStraceLimitInt("--strace-max-children", maxChildren)
+	if err != nil {
+		return "", err
+	}
+	limits.MaxArgBytes, err = parseStraceLimitInt("--strace-max-arg-bytes", maxArgBytes)
+	if err != nil {
+		return "", err
+	}
+	for _, sample := range samples {
+		// pattern=rate, pattern may contain '='
+		idx := strings.LastIndex(sample, "=")
+		if idx <= 0 {
+			return "", fmt.Errorf("--strace-sample: expects <func pattern>=<rate>, got %q", sample)
+		}
+		pattern := sample[:idx]
+		rate, err := strconv.ParseFloat(sample[idx+1:], 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return "", fmt.Errorf("--strace-sample: expects rate between 0 and 1, got %q", sample)
+		}
+		if limits.Sampling == nil {
+			limits.Sampling = make(map[string]float64, len(samples))
+		}
+		limits.Sampling[pattern] = rate
+	}
+	limits.CollapseRepeated = collapseRepeated
+	if limits.MaxDepth == 0 && limits.MaxChildren == 0 && limits.MaxArgBytes == 0 && len(limits.Sampling) == 0 && !limits.CollapseRepeated {
+		return "", nil
+	}
+	data, err := json.Marshal(limits)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func parseStraceLimitInt(flag string, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s: expects a non-negative integer, got %q", flag, value)
+	}
+	return n, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseStraceLimits(t *testing.T) {
+	tests := []struct {
+		name             string
+		maxDepth         string
+		maxChildren      string
+		maxArgBytes      string
+		samples          []string
+		collapseRepeated bool
+		want             string
+		wantErr          bool
+	}{
+		{
+			name: "no limits",
+			want: "",
+		},
+		{
+			name:        "depth and children",
+			maxDepth:    "10",
+			maxChildren: "100",
+			want:        `{"MaxDepth":10,"MaxChildren":100}`,
+		},
+		{
+			name:             "arg bytes and collapse",
+			maxArgBytes:      "4096",
+			collapseRepeated: true,
+			want:             `{"MaxArgBytes":4096,"CollapseRepeated":true}`,
+		},
+		{
+			name:    "samples",
+			samples: []string{"example.com/db.*=0.1", "example.com/a=b.F=1"},
+			want:    `{"Sampling":{"example.com/a=b.F":1,"example.com/db.*":0.1}}`,
+		},
+		{
+			name:     "negative depth",
+			maxDepth: "-1",
+			wantErr:  true,
+		},
+		{
+			name:        "invalid children",
+			maxChildren: "many",
+			wantErr:     true,
+		},
+		{
+			name:    "sample without rate",
+			samples: []string{"example.com/db.*"},
+			wantErr: true,
+		},
+		{
+			name:    "sample rate out of range",
+			samples: []string{"example.com/db.*=2"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStraceLimits(tt.maxDepth, tt.maxChildren, tt.maxArgBytes, tt.samples, tt.collapseRepeated)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStraceLimits() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseStraceLimits() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package test_explorer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/xhd2015/xgo/support/affected"
+	"github.com/xhd2015/xgo/support/coverage"
+	"github.com/xhd2015/xgo/support/netutil"
+)
+
+type AffectedRequest struct {
+	// Ref defaults to coverage.diff_with, or origin/master
+	Ref string `json:"ref"`
+}
+
+type AffectedResult struct {
+	Ref string `json:"ref"`
+	*affected.Result
+}
+
+// selectAffected selects tests affected by changes since ref among
+// packages matched by args, narrowed by coverage.test_index if exists
+func selectAffected(projectDir string, conf *TestConfig, ref string, args []string) (*AffectedResult, error) {
+	var indexFile string
+	if conf.Coverage != nil {
+		if ref == "" {
+			ref = conf.Coverage.DiffWith
+		}
+		indexFile = conf.Coverage.TestIndex
+	}
+	if ref == "" {
+		ref = "origin/master"
+	}
+	var index *coverage.TestIndex
+	if indexFile != "" {
+		if !filepath.IsAbs(indexFile) {
+			indexFile = filepath.Join(projectDir, indexFile)
+		}
+		var err error
+		index, err = coverage.ReadTestIndex(indexFile)
+		if err != nil {
+			return nil, fmt.Errorf("read test index: %w", err)
+		}
+	}
+	res, err := affected.Select(&affected.Options{
+		Dir:   projectDir,
+		Ref:   ref,
+		Args:  args,
+		Index: index,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &AffectedResult{Ref: ref, Result: res}, nil
+}
+
+// affectedTestPaths converts selected packages to
+// paths relative to absDir, and names to run
+func affectedTestPaths(absDir string, res *affected.Result) (paths []string, names []string, err error) {
+	for _, pkg := range res.Packages {
+		relPath, err := filepath.Rel(absDir, pkg.Dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		paths = append(paths, relPath)
+	}
+	return paths, res.Tests(), nil
+}
+
+func formatAffectedMsg(res *AffectedResult) string {
+	var b strings.Builder
+	if res.IndexIgnored != "" {
+		fmt.Fprintf(&b, "test index not used, %s\n", res.IndexIgnored)
+	}
+	if len(res.Packages) == 0 {
+		fmt.Fprintf(&b, "no tests affected since %s, %d files changed", res.Ref, len(res.Changes))
+		return b.String()
+	}
+	fmt.Fprintf(&b, "tests affected since %s, %d files changed:", res.Ref, len(res.Changes))
+	for _, pkg := range res.Packages {
+		tests := "all tests"
+		if len(pkg.Tests) > 0 {
+			tests = strings.Join(pkg.Tests, ",")
+		}
+		fmt.Fprintf(&b, "\n  %s: %s (%s)", pkg.ImportPath, tests, pkg.Reason)
+	}
+	return b.String()
+}
+
+// setupAffectedHandler install these endpoints:
+// /affected     ->    tests affected by changes since ?ref=
+func setupAffectedHandler(server *http.ServeMux, projectDir string, getTestConfig func() (*TestConfig, error)) {
+	server.HandleFunc("/affected", func(w http.ResponseWriter, r *http.Request) {
+		netutil.SetCORSHeaders(w)
+		netutil.HandleJSON(w, r, func(ctx context.Context, r *http.Request) (interface{}, error) {
+			conf, err := getTestConfig()
+			if err != nil {
+				return nil, err
+			}
+			return selectAffected(projectDir, conf, r.URL.Query().Get("ref"), nil)
+		})
+	})
+}
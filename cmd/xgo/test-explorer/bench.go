@@ -0,0 +1,412 @@
+package test_explorer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xhd2015/xgo/support/git"
+	"github.com/xhd2015/xgo/support/netutil"
+)
+
+// benchmark results are appended to .xgo/test-explorer/bench.jsonl
+// of the project root along with the commit they ran at, so that
+// runs across commits can be compared
+const benchFileName = "bench.jsonl"
+
+// by default the last 10 commits of each benchmark are compared
+const defaultBenchLimit = 10
+
+// BenchResult is a row of benchmark output, i.e.:
+//
+//	BenchmarkItoa-8   	 1000000	        25.3 ns/op	       8 B/op	       1 allocs/op
+type BenchResult struct {
+	Time   time.Time `json:"time"`
+	Commit string    `json:"commit"`
+	// Dirty is true if the worktree had uncommitted changes
+	Dirty bool   `json:"dirty"`
+	Pkg   string `json:"pkg"`
+	// Name does not include the -GOMAXPROCS suffix
+	Name  string `json:"name"`
+	Procs int    `json:"procs"`
+	N     int64  `json:"n"`
+
+	NsPerOp     float64 `json:"nsPerOp"`
+	BytesPerOp  float64 `json:"bytesPerOp"`
+	AllocsPerOp float64 `json:"allocsPerOp"`
+	// other metrics keyed by unit, e.g. MB/s
+	// or those reported by b.ReportMetric
+	Extra map[string]float64 `json:"extra,omitempty"`
+}
+
+// BenchCommit averages runs of a benchmark at a commit
+type BenchCommit struct {
+	Commit      string    `json:"commit"`
+	Dirty       bool      `json:"dirty"`
+	LastRun     time.Time `json:"lastRun"`
+	Runs        int       `json:"runs"`
+	NsPerOp     float64   `json:"nsPerOp"`
+	BytesPerOp  float64   `json:"bytesPerOp"`
+	AllocsPerOp float64   `json:"allocsPerOp"`
+}
+
+// BenchHistory compares a benchmark across commits
+type BenchHistory struct {
+	Pkg   string `json:"pkg"`
+	Name  string `json:"name"`
+	Procs int    `json:"procs"`
+	// oldest first
+	Commits []*BenchCommit `json:"commits"`
+}
+
+type BenchRequest struct {
+	// max number of commits of each benchmark
+	Limit int `json:"limit"`
+
+	// filter a single benchmark
+	Pkg  string `json:"pkg"`
+	Name string `json:"name"`
+}
+
+func getBenchFile(projectRoot string) string {
+	return filepath.Join(projectRoot, ".xgo", "test-explorer", benchFileName)
+}
+
+// getBenchCommit returns HEAD of dir, empty if not a git repo,
+// files written by the explorer itself do not make it dirty
+func getBenchCommit(dir string, benchFile string) (commit string, dirty bool) {
+	commit, err := git.GetHeadCommit(dir)
+	if err != nil {
+		return "", false
+	}
+	dirty, _ = git.HasChanges(dir, filepath.Dir(benchFile))
+	return commit, dirty
+}
+
+// parseBenchLine parses a result line of test, returns
+// nil if the line is not a result, e.g. logs
+func parseBenchLine(test string, line string) *BenchResult {
+	fields := strings.Fields(line)
+	name := test
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "Benchmark") {
+		name = fields[0]
+		fields = fields[1:]
+	}
+	// N, then value-unit pairs
+	if len(fields) < 3 || len(fields)%2 != 1 {
+		return nil
+	}
+	n, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil
+	}
+	res := &BenchResult{N: n}
+	var hasNs bool
+	for i := 1; i < len(fields); i += 2 {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return nil
+		}
+		switch unit := fields[i+1]; unit {
+		case "ns/op":
+			res.NsPerOp = value
+			hasNs = true
+		case "B/op":
+			res.BytesPerOp = value
+		case "allocs/op":
+			res.AllocsPerOp = value
+		default:
+			if res.Extra == nil {
+				res.Extra = make(map[string]float64)
+			}
+			res.Extra[unit] = value
+		}
+	}
+	if !hasNs {
+		return nil
+	}
+	res.Name, res.Procs = splitBenchProcs(name)
+	return res
+}
+
+// splitBenchProcs splits BenchmarkItoa-8 into BenchmarkItoa and 8
+func splitBenchProcs(name string) (string, int) {
+	idx := strings.LastIndex(name, "-")
+	if idx < 0 {
+		return name, 0
+	}
+	procs, err := strconv.Atoi(name[idx+1:])
+	if err != nil || procs <= 0 {
+		return name, 0
+	}
+	return name[:idx], procs
+}
+
+func appendBenchResults(file string, results []*BenchResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, res := range results {
+		data, err := json.Marshal(res)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	err := os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(buf.Bytes())
+	return err
+}
+
+// readBenchResults returns nil if file does not exist,
+// malformed lines are skipped
+func readBenchResults(file string) ([]*BenchResult, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var results []*BenchResult
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var res *BenchResult
+		if json.Unmarshal(line, &res) != nil || res == nil {
+			continue
+		}
+		results = append(results, res)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// summarizeBench groups results by benchmark, then by commit,
+// keeping the last limit commits of each benchmark. uncommitted
+// runs are grouped separately from the commit they are based on.
+func summarizeBench(results []*BenchResult, req *BenchRequest) []*BenchHistory {
+	if req == nil {
+		req = &BenchRequest{}
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultBenchLimit
+	}
+	type benchKey struct {
+		pkg   string
+		name  string
+		procs int
+	}
+	type commitKey struct {
+		commit string
+		dirty  bool
+	}
+	mapping := make(map[benchKey]*BenchHistory)
+	commitMapping := make(map[benchKey]map[commitKey]*BenchCommit)
+	var list []*BenchHistory
+	for _, res := range results {
+		if req.Pkg != "" && res.Pkg != req.Pkg {
+			continue
+		}
+		if req.Name != "" && res.Name != req.Name {
+			continue
+		}
+		k := benchKey{pkg: res.Pkg, name: res.Name, procs: res.Procs}
+		h := mapping[k]
+		if h == nil {
+			h = &BenchHistory{Pkg: res.Pkg, Name: res.Name, Procs: res.Procs}
+			mapping[k] = h
+			commitMapping[k] = make(map[commitKey]*BenchCommit)
+			list = append(list, h)
+		}
+		ck := commitKey{commit: res.Commit, dirty: res.Dirty}
+		c := commitMapping[k][ck]
+		if c == nil {
+			c = &BenchCommit{Commit: res.Commit, Dirty: res.Dirty}
+			commitMapping[k][ck] = c
+			h.Commits = append(h.Commits, c)
+		}
+		// sums are averaged below
+		c.Runs++
+		c.NsPerOp += res.NsPerOp
+		c.BytesPerOp += res.BytesPerOp
+		c.AllocsPerOp += res.AllocsPerOp
+		if res.Time.After(c.LastRun) {
+			c.LastRun = res.Time
+		}
+	}
+	for _, h := range list {
+		for _, c := range h.Commits {
+			c.NsPerOp /= float64(c.Runs)
+			c.BytesPerOp /= float64(c.Runs)
+			c.AllocsPerOp /= float64(c.Runs)
+		}
+		sort.SliceStable(h.Commits, func(i, j int) bool {
+			return h.Commits[i].LastRun.Before(h.Commits[j].LastRun)
+		})
+		if len(h.Commits) > limit {
+			h.Commits = h.Commits[len(h.Commits)-limit:]
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Pkg != list[j].Pkg {
+			return list[i].Pkg < list[j].Pkg
+		}
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Procs < list[j].Procs
+	})
+	return list
+}
+
+func loadBench(file string, req *BenchRequest) ([]*BenchHistory, error) {
+	results, err := readBenchResults(file)
+	if err != nil {
+		return nil, fmt.Errorf("read benchmark results: %w", err)
+	}
+	return summarizeBench(results, req), nil
+}
+
+// setupBenchHandler install these endpoints:
+// /bench        ->    benchmarks compared across commits, filtered by ?limit=N&pkg=&name=
+func setupBenchHandler(server *http.ServeMux, benchFile string) {
+	server.HandleFunc("/bench", func(w http.ResponseWriter, r *http.Request) {
+		netutil.SetCORSHeaders(w)
+		netutil.HandleJSON(w, r, func(ctx context.Context, r *http.Request) (interface{}, error) {
+			q := r.URL.Query()
+			req := &BenchRequest{
+				Pkg:  q.Get("pkg"),
+				Name: q.Get("name"),
+			}
+			if limit := q.Get("limit"); limit != "" {
+				n, err := strconv.Atoi(limit)
+				if err != nil {
+					return nil, netutil.ParamErrorf("limit: %v", err)
+				}
+				req.Limit = n
+			}
+			return loadBench(benchFile, req)
+		})
+	})
+}
+
+// printBench implements `xgo e bench`
+func printBench(benchFile string, args []string) error {
+	req := &BenchRequest{}
+	var jsonOutput bool
+	n := len(args)
+	for i := 0; i < n; i++ {
+		arg := args[i]
+		if arg == "--json" {
+			jsonOutput = true
+			continue
+		}
+		if arg == "--limit" {
+			if i+1 >= n {
+				return fmt.Errorf("%s requires value", arg)
+			}
+			limit, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return fmt.Errorf("%s: %w", arg, err)
+			}
+			req.Limit = limit
+			i++
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") && req.Name == "" {
+			req.Name = arg
+			continue
+		}
+		return fmt.Errorf("unrecognized arg: %s", arg)
+	}
+	list, err := loadBench(benchFile, req)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		data, err := json.MarshalIndent(list, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	if len(list) == 0 {
+		fmt.Fprintf(os.Stderr, "no benchmark results found in %s\n", benchFile)
+		return nil
+	}
+	for _, h := range list {
+		name := h.Name
+		if h.Procs > 0 {
+			name = fmt.Sprintf("%s-%d", name, h.Procs)
+		}
+		fmt.Printf("%s %s\n", h.Pkg, name)
+		prev := &BenchCommit{}
+		for _, c := range h.Commits {
+			fmt.Printf("  %s\t%s\t%s\t%s\t%d runs\n", formatBenchCommit(c),
+				formatBenchValue(c.NsPerOp, prev.NsPerOp, "ns/op"),
+				formatBenchValue(c.BytesPerOp, prev.BytesPerOp, "B/op"),
+				formatBenchValue(c.AllocsPerOp, prev.AllocsPerOp, "allocs/op"),
+				c.Runs,
+			)
+			prev = c
+		}
+	}
+	return nil
+}
+
+func formatBenchCommit(c *BenchCommit) string {
+	commit := c.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	if commit == "" {
+		commit = "-"
+	}
+	if c.Dirty {
+		commit += "+dirty"
+	}
+	return commit
+}
+
+// formatBenchValue shows the change relative
+// to prev, if prev is not 0
+func formatBenchValue(value float64, prev float64, unit string) string {
+	s := strconv.FormatFloat(value, 'f', 2, 64)
+	if value >= 100 || value == float64(int64(value)) {
+		s = strconv.FormatFloat(value, 'f', 0, 64)
+	}
+	s += " " + unit
+	if prev == 0 {
+		return s
+	}
+	return fmt.Sprintf("%s (%+.1f%%)", s, (value-prev)/prev*100)
+}
@@ -0,0 +1,165 @@
+package test_explorer
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBenchLine(t *testing.T) {
+	tests := []struct {
+		test   string
+		line   string
+		expect *BenchResult
+	}{
+		{
+			test:   "BenchmarkItoa",
+			line:   "BenchmarkItoa-8   \t 1000000\t        25.30 ns/op\t       8 B/op\t       1 allocs/op\n",
+			expect: &BenchResult{Name: "BenchmarkItoa", Procs: 8, N: 1000000, NsPerOp: 25.3, BytesPerOp: 8, AllocsPerOp: 1},
+		},
+		{
+			test:   "BenchmarkSub/small-x",
+			line:   "BenchmarkSub/small-x \t     100\t         3.020 ns/op\t  12.5 MB/s\n",
+			expect: &BenchResult{Name: "BenchmarkSub/small-x", N: 100, NsPerOp: 3.02, Extra: map[string]float64{"MB/s": 12.5}},
+		},
+		{
+			// result printed without name after logs
+			test:   "BenchmarkLog",
+			line:   "     200\t  10 ns/op\n",
+			expect: &BenchResult{Name: "BenchmarkLog", N: 200, NsPerOp: 10},
+		},
+		{test: "BenchmarkItoa", line: "BenchmarkItoa\n"},
+		{test: "BenchmarkItoa", line: "    bench_test.go:10: some log\n"},
+		{test: "BenchmarkItoa", line: "--- FAIL: BenchmarkItoa\n"},
+	}
+	for _, tt := range tests {
+		res := parseBenchLine(tt.test, tt.line)
+		if tt.expect == nil {
+			if res != nil {
+				t.Errorf("%q: expect nil, actual: %+v", tt.line, res)
+			}
+			continue
+		}
+		if res == nil {
+			t.Errorf("%q: expect result", tt.line)
+			continue
+		}
+		if res.Name != tt.expect.Name || res.Procs != tt.expect.Procs || res.N != tt.expect.N ||
+			res.NsPerOp != tt.expect.NsPerOp || res.BytesPerOp != tt.expect.BytesPerOp || res.AllocsPerOp != tt.expect.AllocsPerOp ||
+			len(res.Extra) != len(tt.expect.Extra) || res.Extra["MB/s"] != tt.expect.Extra["MB/s"] {
+			t.Errorf("%q: expect %+v, actual: %+v", tt.line, tt.expect, res)
+		}
+	}
+}
+
+func TestSummarizeBench(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var results []*BenchResult
+	add := func(commit string, dirty bool, ns float64) {
+		results = append(results, &BenchResult{
+			Time:    base.Add(time.Duration(len(results)) * time.Second),
+			Commit:  commit,
+			Dirty:   dirty,
+			Pkg:     "example.com/demo",
+			Name:    "BenchmarkA",
+			Procs:   8,
+			NsPerOp: ns,
+		})
+	}
+	add("c1", false, 10)
+	add("c1", false, 20)
+	add("c2", false, 30)
+	add("c2", true, 40)
+
+	list := summarizeBench(results, nil)
+	if len(list) != 1 || len(list[0].Commits) != 3 {
+		t.Fatalf("unexpected: %+v", list)
+	}
+	c1, c2, dirty := list[0].Commits[0], list[0].Commits[1], list[0].Commits[2]
+	if c1.Commit != "c1" || c1.Runs != 2 || c1.NsPerOp != 15 {
+		t.Fatalf("unexpected c1: %+v", c1)
+	}
+	if c2.Commit != "c2" || c2.Dirty || c2.NsPerOp != 30 {
+		t.Fatalf("unexpected c2: %+v", c2)
+	}
+	if dirty.Commit != "c2" || !dirty.Dirty || dirty.NsPerOp != 40 {
+		t.Fatalf("unexpected dirty: %+v", dirty)
+	}
+
+	list = summarizeBench(results, &BenchRequest{Limit: 1})
+	if len(list[0].Commits) != 1 || !list[0].Commits[0].Dirty {
+		t.Fatalf("unexpected limited: %+v", list[0].Commits)
+	}
+	if list := summarizeBench(results, &BenchRequest{Name: "BenchmarkB"}); len(list) != 0 {
+		t.Fatalf("expect no result, actual: %+v", list)
+	}
+}
+
+func TestAppendAndReadBenchResults(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sub", benchFileName)
+	results, err := readBenchResults(file)
+	if err != nil || results != nil {
+		t.Fatalf("expect empty, actual: %v %v", results, err)
+	}
+	for i := 0; i < 2; i++ {
+		err = appendBenchResults(file, []*BenchResult{{Name: "BenchmarkA", NsPerOp: float64(i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, err = readBenchResults(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[1].NsPerOp != 1 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestBuildBenchEvents(t *testing.T) {
+	resolver := &testResolver{dirPkgPath: "example.com/demo"}
+	resolver.pkgTests.Store("", []*TestingItem{{Name: "BenchmarkA", RelPath: "a_test.go", Kind: TestingItemKind_Benchmark}})
+	var results []*BenchResult
+	builder := &jsonTestEventBuilder{
+		pathPrefix:   []string{"demo"},
+		dirPkgPath:   "example.com/demo",
+		testResolver: resolver,
+		pm:           &pathMapping{},
+		onBenchResult: func(res *BenchResult) {
+			results = append(results, res)
+		},
+	}
+	// go test -json leaves Test empty, and splits the result line
+	for _, output := range []string{
+		"BenchmarkA\n",
+		"BenchmarkA-8 \t",
+		"  100\t  12.5 ns/op\t  16 B/op\t  1 allocs/op\n",
+		"PASS\n",
+	} {
+		line, err := json.Marshal(&TestEvent{Action: TestEventAction_Output, Package: "example.com/demo", Output: output})
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, err := builder.build(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, event := range events {
+			if event.Event != Event_BenchResult {
+				continue
+			}
+			if strings.Join(event.Path, "/") != "demo/a_test.go/BenchmarkA" {
+				t.Fatalf("unexpected path: %v", event.Path)
+			}
+		}
+	}
+	if len(results) != 1 {
+		t.Fatalf("expect 1 result, actual: %d", len(results))
+	}
+	res := results[0]
+	if res.Pkg != "example.com/demo" || res.Name != "BenchmarkA" || res.Procs != 8 || res.N != 100 || res.NsPerOp != 12.5 || res.BytesPerOp != 16 || res.AllocsPerOp != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -70,6 +71,14 @@ func parseConfigAndMergeOptions(configFile string, opts *Options, configFileRequ
 			conf.Flags = append(conf.Flags, "--mock-rule", mockRule)
 		}
 	}
+	if goCmd == "xgo" {
+		for _, patchDir := range conf.Patches {
+			if configFile != "" && !filepath.IsAbs(patchDir) {
+				patchDir = filepath.Join(filepath.Dir(configFile), patchDir)
+			}
+			conf.Flags = append(conf.Flags, "--patch-dir", patchDir)
+		}
+	}
 	conf.Args = append(conf.Args, opts.Args...)
 
 	if opts.Coverage == "false" {
@@ -11,5 +11,5 @@ import (
 // see https://github.com/xhd2015/xgo/issues/215
 func setupCoverageHandler(server *http.ServeMux, covController icov.Controller, opts loadcov.LoadAllOptions, getPort func() int) {
 	// install /coverage, /coverage/fileAnnotations, /coverage/fileDetail, /coverage/diffFileDetail
-	serve.RouteServer(server, "/coverage", getPort, opts)
+	serve.RouteServer(server, "/coverage", getPort, opts, nil)
 }
@@ -0,0 +1,130 @@
+package test_explorer
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xhd2015/xgo/support/fileutil"
+	"github.com/xhd2015/xgo/support/netutil"
+)
+
+// fuzzing without -fuzztime never ends,
+// so it is limited by default
+const defaultFuzzTime = "10s"
+
+// FuzzCorpusRequest lists the seed corpus of a fuzz target,
+// that is testdata/fuzz/<Name> beside File, where failing
+// inputs are also written to
+type FuzzCorpusRequest struct {
+	// the test file declaring the target
+	File string `json:"file"`
+	Name string `json:"name"`
+
+	// if set, only Entry is returned with its content
+	Entry string `json:"entry"`
+}
+
+type FuzzCorpus struct {
+	Dir     string             `json:"dir"`
+	Entries []*FuzzCorpusEntry `json:"entries"`
+}
+
+type FuzzCorpusEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	// only set if requested
+	Content string `json:"content"`
+}
+
+func getFuzzCorpusDir(file string, name string) string {
+	return filepath.Join(filepath.Dir(file), "testdata", "fuzz", name)
+}
+
+// isBaseName tells if name is a plain file name,
+// so that joining it does not escape the dir
+func isBaseName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+func listFuzzCorpus(req *FuzzCorpusRequest) (*FuzzCorpus, error) {
+	if req == nil || req.File == "" {
+		return nil, netutil.ParamErrorf("requires file")
+	}
+	if !strings.HasPrefix(req.Name, "Fuzz") || !isBaseName(req.Name) {
+		return nil, netutil.ParamErrorf("invalid fuzz target: %s", req.Name)
+	}
+	dir := getFuzzCorpusDir(req.File, req.Name)
+	corpus := &FuzzCorpus{Dir: dir, Entries: []*FuzzCorpusEntry{}}
+	if req.Entry != "" {
+		if !isBaseName(req.Entry) {
+			return nil, netutil.ParamErrorf("invalid entry: %s", req.Entry)
+		}
+		file := filepath.Join(dir, req.Entry)
+		stat, err := os.Stat(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, netutil.ParamErrorf("entry not found: %s", req.Entry)
+			}
+			return nil, err
+		}
+		content, err := fileutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		corpus.Entries = append(corpus.Entries, &FuzzCorpusEntry{
+			Name:    req.Entry,
+			Size:    stat.Size(),
+			ModTime: stat.ModTime(),
+			Content: string(content),
+		})
+		return corpus, nil
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return corpus, nil
+		}
+		return nil, err
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			return nil, err
+		}
+		corpus.Entries = append(corpus.Entries, &FuzzCorpusEntry{
+			Name:    f.Name(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+	// newest first, recent failing inputs are the interesting ones
+	sort.SliceStable(corpus.Entries, func(i, j int) bool {
+		return corpus.Entries[i].ModTime.After(corpus.Entries[j].ModTime)
+	})
+	return corpus, nil
+}
+
+// setupFuzzHandler install these endpoints:
+// /fuzz/corpus  ->    seed corpus of ?file=&name=, content of a single one if ?entry=
+func setupFuzzHandler(server *http.ServeMux) {
+	server.HandleFunc("/fuzz/corpus", func(w http.ResponseWriter, r *http.Request) {
+		netutil.SetCORSHeaders(w)
+		netutil.HandleJSON(w, r, func(ctx context.Context, r *http.Request) (interface{}, error) {
+			q := r.URL.Query()
+			return listFuzzCorpus(&FuzzCorpusRequest{
+				File:  q.Get("file"),
+				Name:  q.Get("name"),
+				Entry: q.Get("entry"),
+			})
+		})
+	})
+}
@@ -0,0 +1,50 @@
+package test_explorer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListFuzzCorpus(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "x_test.go")
+	corpus, err := listFuzzCorpus(&FuzzCorpusRequest{File: file, Name: "FuzzA"})
+	if err != nil || len(corpus.Entries) != 0 {
+		t.Fatalf("expect empty, actual: %+v %v", corpus, err)
+	}
+
+	corpusDir := getFuzzCorpusDir(file, "FuzzA")
+	if err := os.MkdirAll(corpusDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "go test fuzz v1\nstring(\"0\")\n"
+	if err := os.WriteFile(filepath.Join(corpusDir, "a1b2"), []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	corpus, err = listFuzzCorpus(&FuzzCorpusRequest{File: file, Name: "FuzzA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corpus.Entries) != 1 || corpus.Entries[0].Name != "a1b2" || corpus.Entries[0].Content != "" {
+		t.Fatalf("unexpected entries: %+v", corpus.Entries)
+	}
+	corpus, err = listFuzzCorpus(&FuzzCorpusRequest{File: file, Name: "FuzzA", Entry: "a1b2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if corpus.Entries[0].Content != content {
+		t.Fatalf("unexpected content: %q", corpus.Entries[0].Content)
+	}
+
+	for _, req := range []*FuzzCorpusRequest{
+		{File: file, Name: "TestA"},
+		{File: file, Name: "FuzzA/../x"},
+		{File: file, Name: "FuzzA", Entry: "../a1b2"},
+		{File: file, Name: "FuzzA", Entry: "missing"},
+	} {
+		if _, err := listFuzzCorpus(req); err == nil {
+			t.Errorf("expect error for %+v", req)
+		}
+	}
+}
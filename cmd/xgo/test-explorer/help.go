@@ -6,6 +6,8 @@ xgo tool test-explorer is a tool used to test and debug go code easily.
 Usage:
    xgo e [options]       
    xgo e [options] test
+   xgo e [options] rpc
+   xgo e [options] history [--flaky] [--limit N] [--json] [TEST]
 
 Alias:
    xgo e
@@ -16,6 +18,17 @@ By default, test-explorer opens a web UI to show all tests to be run.
 
 If invoked with 'xgo e test', all tests are automatically executed without opening the web UI.
 
+If invoked with 'xgo e rpc', the explorer is served as JSON-RPC over stdio for editor integrations,
+see the documentation for available methods.
+
+Results of tests run from the explorer are appended to .xgo/test-explorer/history.jsonl,
+'xgo e history' summarizes the last N(default: 20) runs of each test, a test is flaky
+if it both passed and failed.
+
+Tests can be run selectively by the git diff: only packages depending on changed
+packages are run, and if coverage.test_index is configured, only tests covering
+changed lines.
+
 Options:
      --project-dir DIR         directory to project dir
      --go-command CMD          the command to execute test, default is 'xgo' when invoked via xgo, and 'go' otherwise
@@ -32,6 +45,8 @@ Options:
 Examples:
   xgo e                  open the test explorer in browser
   xgo e test             run all tests without opening the test explorer(used in CI)
+  xgo e rpc              serve the test explorer to an editor plugin via stdio
+  xgo e history --flaky  list tests that both passed and failed in the last 20 runs
 
 See https://github.com/xhd2015/xgo/blob/master/doc/test-explorer/README.md for documentation.
 
@@ -0,0 +1,385 @@
+package test_explorer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xhd2015/xgo/support/netutil"
+)
+
+// run history is appended to .xgo/test-explorer/history.jsonl
+// of the project root, one record per test per run, so that
+// tests whose outcome flips between runs can be found
+const historyFileName = "history.jsonl"
+
+// by default flakiness is judged by the last 20 runs
+const defaultHistoryLimit = 20
+
+// at most 100 runs of each test are kept in the history file,
+// older runs are dropped when appending
+const maxHistoryRuns = 100
+
+type HistoryRecord struct {
+	Time    time.Time `json:"time"`
+	Pkg     string    `json:"pkg"`
+	Test    string    `json:"test"`
+	Status  RunStatus `json:"status"`
+	Elapsed float64   `json:"elapsed"` // seconds
+}
+
+// TestHistory summarizes the most recent runs of a test
+type TestHistory struct {
+	Pkg    string `json:"pkg"`
+	Test   string `json:"test"`
+	Runs   int    `json:"runs"`
+	Passes int    `json:"passes"`
+	Fails  int    `json:"fails"`
+	// Flaky is true if the test both passed and failed
+	Flaky      bool      `json:"flaky"`
+	LastStatus RunStatus `json:"lastStatus"`
+	LastRun    time.Time `json:"lastRun"`
+	AvgElapsed float64   `json:"avgElapsed"`
+
+	// oldest first
+	Records []*HistoryRecord `json:"records"`
+}
+
+type HistoryRequest struct {
+	// only return flaky tests
+	Flaky bool `json:"flaky"`
+	// max number of runs of each test to consider
+	Limit int `json:"limit"`
+
+	// filter a single test
+	Pkg  string `json:"pkg"`
+	Test string `json:"test"`
+}
+
+func getHistoryFile(projectRoot string) string {
+	return filepath.Join(projectRoot, ".xgo", "test-explorer", historyFileName)
+}
+
+func appendHistory(file string, records []*HistoryRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	existing, err := readHistory(file)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+	all := append(existing, records...)
+	kept := compactHistory(all, maxHistoryRuns)
+	if len(kept) < len(all) {
+		// rewrite, renaming makes readers see
+		// either the old or the new file
+		data, err := marshalHistory(kept)
+		if err != nil {
+			return err
+		}
+		tmpFile := file + ".tmp"
+		err = os.WriteFile(tmpFile, data, 0644)
+		if err != nil {
+			return err
+		}
+		return os.Rename(tmpFile, file)
+	}
+	data, err := marshalHistory(records)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	return err
+}
+
+func marshalHistory(records []*HistoryRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// compactHistory keeps the last max records of each
+// test, in their original order
+func compactHistory(records []*HistoryRecord, max int) []*HistoryRecord {
+	type key struct {
+		pkg  string
+		test string
+	}
+	counts := make(map[key]int)
+	keep := make([]bool, len(records))
+	var n int
+	for i := len(records) - 1; i >= 0; i-- {
+		k := key{pkg: records[i].Pkg, test: records[i].Test}
+		if counts[k] >= max {
+			continue
+		}
+		counts[k]++
+		keep[i] = true
+		n++
+	}
+	if n == len(records) {
+		return records
+	}
+	kept := make([]*HistoryRecord, 0, n)
+	for i, record := range records {
+		if keep[i] {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// readHistory returns nil if file does not exist,
+// malformed lines are skipped
+func readHistory(file string) ([]*HistoryRecord, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []*HistoryRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var record *HistoryRecord
+		if json.Unmarshal(line, &record) != nil || record == nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// summarizeHistory groups records by test, keeping the last
+// limit runs of each test. skipped runs are ignored.
+func summarizeHistory(records []*HistoryRecord, req *HistoryRequest) []*TestHistory {
+	if req == nil {
+		req = &HistoryRequest{}
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	type key struct {
+		pkg  string
+		test string
+	}
+	mapping := make(map[key]*TestHistory)
+	var list []*TestHistory
+	for _, record := range records {
+		if record.Status != RunStatus_Success && record.Status != RunStatus_Fail {
+			continue
+		}
+		if req.Pkg != "" && record.Pkg != req.Pkg {
+			continue
+		}
+		if req.Test != "" && record.Test != req.Test {
+			continue
+		}
+		k := key{pkg: record.Pkg, test: record.Test}
+		h := mapping[k]
+		if h == nil {
+			h = &TestHistory{Pkg: record.Pkg, Test: record.Test}
+			mapping[k] = h
+			list = append(list, h)
+		}
+		h.Records = append(h.Records, record)
+	}
+
+	result := make([]*TestHistory, 0, len(list))
+	for _, h := range list {
+		sort.SliceStable(h.Records, func(i, j int) bool {
+			return h.Records[i].Time.Before(h.Records[j].Time)
+		})
+		if len(h.Records) > limit {
+			h.Records = h.Records[len(h.Records)-limit:]
+		}
+		var elapsed float64
+		for _, record := range h.Records {
+			if record.Status == RunStatus_Success {
+				h.Passes++
+			} else {
+				h.Fails++
+			}
+			elapsed += record.Elapsed
+		}
+		last := h.Records[len(h.Records)-1]
+		h.Runs = len(h.Records)
+		h.Flaky = h.Passes > 0 && h.Fails > 0
+		h.LastStatus = last.Status
+		h.LastRun = last.Time
+		h.AvgElapsed = elapsed / float64(h.Runs)
+		if req.Flaky && !h.Flaky {
+			continue
+		}
+		result = append(result, h)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Pkg != result[j].Pkg {
+			return result[i].Pkg < result[j].Pkg
+		}
+		return result[i].Test < result[j].Test
+	})
+	return result
+}
+
+func loadHistory(file string, req *HistoryRequest) ([]*TestHistory, error) {
+	records, err := readHistory(file)
+	if err != nil {
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+	return summarizeHistory(records, req), nil
+}
+
+// historyRecordOf converts a test2json result event,
+// returns nil if it is not the result of a test
+func historyRecordOf(event *TestEvent) *HistoryRecord {
+	if event == nil || event.Test == "" {
+		return nil
+	}
+	var status RunStatus
+	switch event.Action {
+	case TestEventAction_Pass:
+		status = RunStatus_Success
+	case TestEventAction_Fail:
+		status = RunStatus_Fail
+	case TestEventAction_Skip:
+		status = RunStatus_Skip
+	default:
+		return nil
+	}
+	t := event.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return &HistoryRecord{
+		Time:    t,
+		Pkg:     event.Package,
+		Test:    event.Test,
+		Status:  status,
+		Elapsed: event.Elapsed,
+	}
+}
+
+// setupHistoryHandler install these endpoints:
+// /history      ->    summary of each test, filtered by ?flaky=true&limit=N&pkg=&test=
+func setupHistoryHandler(server *http.ServeMux, historyFile string) {
+	server.HandleFunc("/history", func(w http.ResponseWriter, r *http.Request) {
+		netutil.SetCORSHeaders(w)
+		netutil.HandleJSON(w, r, func(ctx context.Context, r *http.Request) (interface{}, error) {
+			q := r.URL.Query()
+			req := &HistoryRequest{
+				Flaky: q.Get("flaky") == "true",
+				Pkg:   q.Get("pkg"),
+				Test:  q.Get("test"),
+			}
+			if limit := q.Get("limit"); limit != "" {
+				n, err := strconv.Atoi(limit)
+				if err != nil {
+					return nil, netutil.ParamErrorf("limit: %v", err)
+				}
+				req.Limit = n
+			}
+			return loadHistory(historyFile, req)
+		})
+	})
+}
+
+// printHistory implements `xgo e history`
+func printHistory(historyFile string, args []string) error {
+	req := &HistoryRequest{}
+	var jsonOutput bool
+	n := len(args)
+	for i := 0; i < n; i++ {
+		arg := args[i]
+		if arg == "--flaky" {
+			req.Flaky = true
+			continue
+		}
+		if arg == "--json" {
+			jsonOutput = true
+			continue
+		}
+		if arg == "--limit" {
+			if i+1 >= n {
+				return fmt.Errorf("%s requires value", arg)
+			}
+			limit, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return fmt.Errorf("%s: %w", arg, err)
+			}
+			req.Limit = limit
+			i++
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") && req.Test == "" {
+			req.Test = arg
+			continue
+		}
+		return fmt.Errorf("unrecognized arg: %s", arg)
+	}
+	list, err := loadHistory(historyFile, req)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		data, err := json.MarshalIndent(list, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	if len(list) == 0 {
+		fmt.Fprintf(os.Stderr, "no history found in %s\n", historyFile)
+		return nil
+	}
+	var flaky int
+	for _, h := range list {
+		mark := "ok"
+		if h.Flaky {
+			mark = "flaky"
+			flaky++
+		} else if h.Fails > 0 {
+			mark = "fail"
+		}
+		fmt.Printf("%s\t%s %s\tfailed %d of last %d runs\tavg %.2fs\n", mark, h.Pkg, h.Test, h.Fails, h.Runs, h.AvgElapsed)
+	}
+	fmt.Printf("%d tests, %d flaky\n", len(list), flaky)
+	return nil
+}
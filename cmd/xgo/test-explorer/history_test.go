@@ -0,0 +1,128 @@
+package test_explorer
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSummarizeHistory(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var records []*HistoryRecord
+	add := func(test string, status RunStatus) {
+		records = append(records, &HistoryRecord{
+			Time:    base.Add(time.Duration(len(records)) * time.Second),
+			Pkg:     "example.com/demo",
+			Test:    test,
+			Status:  status,
+			Elapsed: 1,
+		})
+	}
+	add("TestA", RunStatus_Fail)
+	add("TestB", RunStatus_Success)
+	add("TestA", RunStatus_Success)
+	add("TestB", RunStatus_Skip)
+	add("TestA", RunStatus_Success)
+	add("TestC", RunStatus_Fail)
+	add("TestB", RunStatus_Success)
+
+	list := summarizeHistory(records, nil)
+	if len(list) != 3 {
+		t.Fatalf("expect 3 tests, actual: %d", len(list))
+	}
+	a, b, c := list[0], list[1], list[2]
+	if a.Test != "TestA" || a.Runs != 3 || a.Fails != 1 || !a.Flaky || a.LastStatus != RunStatus_Success {
+		t.Fatalf("unexpected TestA: %+v", a)
+	}
+	// skip is ignored
+	if b.Test != "TestB" || b.Runs != 2 || b.Flaky {
+		t.Fatalf("unexpected TestB: %+v", b)
+	}
+	if c.Test != "TestC" || c.Flaky || c.Fails != 1 {
+		t.Fatalf("unexpected TestC: %+v", c)
+	}
+
+	// the failure of TestA falls out of the last 2 runs
+	list = summarizeHistory(records, &HistoryRequest{Limit: 2, Test: "TestA"})
+	if len(list) != 1 || list[0].Runs != 2 || list[0].Flaky {
+		t.Fatalf("unexpected limited: %+v", list)
+	}
+
+	list = summarizeHistory(records, &HistoryRequest{Flaky: true})
+	if len(list) != 1 || list[0].Test != "TestA" {
+		t.Fatalf("unexpected flaky: %+v", list)
+	}
+}
+
+func TestAppendAndReadHistory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sub", historyFileName)
+	records, err := readHistory(file)
+	if err != nil || records != nil {
+		t.Fatalf("expect no history, actual: %v %v", records, err)
+	}
+	if historyRecordOf(&TestEvent{Action: TestEventAction_Output, Package: "p", Test: "TestX"}) != nil {
+		t.Fatalf("output should not be recorded")
+	}
+	if historyRecordOf(&TestEvent{Action: TestEventAction_Fail, Package: "p"}) != nil {
+		t.Fatalf("package result should not be recorded")
+	}
+	for i := 0; i < 2; i++ {
+		record := historyRecordOf(&TestEvent{Action: TestEventAction_Pass, Package: "p", Test: "TestX", Elapsed: 0.5})
+		err := appendHistory(file, []*HistoryRecord{record})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err = readHistory(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].Test != "TestX" || records[1].Status != RunStatus_Success || records[1].Elapsed != 0.5 {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
+
+func TestAppendHistoryKeepsLastRuns(t *testing.T) {
+	file := filepath.Join(t.TempDir(), historyFileName)
+	for i := 0; i < maxHistoryRuns+10; i++ {
+		err := appendHistory(file, []*HistoryRecord{
+			{Pkg: "p", Test: "TestX", Status: RunStatus_Success, Elapsed: float64(i)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := appendHistory(file, []*HistoryRecord{{Pkg: "p", Test: "TestY", Status: RunStatus_Fail}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := readHistory(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != maxHistoryRuns+1 {
+		t.Fatalf("expect %d records, actual: %d", maxHistoryRuns+1, len(records))
+	}
+	if records[0].Elapsed != 10 || records[maxHistoryRuns-1].Elapsed != maxHistoryRuns+9 || records[maxHistoryRuns].Test != "TestY" {
+		t.Fatalf("expect oldest runs dropped, actual first: %+v, last: %+v", records[0], records[maxHistoryRuns])
+	}
+}
+
+func TestAppendHistoryFirstRunOverLimit(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".xgo", "test-explorer", historyFileName)
+	var runs []*HistoryRecord
+	for i := 0; i < maxHistoryRuns+1; i++ {
+		runs = append(runs, &HistoryRecord{Pkg: "p", Test: "TestX", Status: RunStatus_Success, Elapsed: float64(i)})
+	}
+	err := appendHistory(file, runs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := readHistory(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != maxHistoryRuns || records[0].Elapsed != 1 {
+		t.Fatalf("expect last %d runs kept, actual: %d records", maxHistoryRuns, len(records))
+	}
+}
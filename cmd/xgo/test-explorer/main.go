@@ -72,6 +72,11 @@ func Main(args []string, opts *Options) error {
 			flagHelp = true
 			continue
 		}
+		if (arg == "history" || arg == "bench") && len(remainArgs) == 0 {
+			// flags after history or bench are their own
+			remainArgs = append(remainArgs, args[i:]...)
+			break
+		}
 		if arg == "--go-command" {
 			if i+1 >= n {
 				return fmt.Errorf("%s requires value", arg)
@@ -196,9 +201,11 @@ func Main(args []string, opts *Options) error {
 type TestingItemKind string
 
 const (
-	TestingItemKind_Dir  = "dir"
-	TestingItemKind_File = "file"
-	TestingItemKind_Case = "case"
+	TestingItemKind_Dir       = "dir"
+	TestingItemKind_File      = "file"
+	TestingItemKind_Case      = "case"
+	TestingItemKind_Benchmark = "benchmark"
+	TestingItemKind_Fuzz      = "fuzz"
 )
 
 func (c TestingItemKind) Order() int {
@@ -209,11 +216,21 @@ func (c TestingItemKind) Order() int {
 		return 1
 	case TestingItemKind_Case:
 		return 2
+	case TestingItemKind_Benchmark:
+		return 3
+	case TestingItemKind_Fuzz:
+		return 4
 	default:
 		return -1
 	}
 }
 
+// IsFunc tells if c is a test, benchmark or fuzz
+// target, or a sub test of them
+func (c TestingItemKind) IsFunc() bool {
+	return c == TestingItemKind_Case || c == TestingItemKind_Benchmark || c == TestingItemKind_Fuzz
+}
+
 type RunStatus string
 
 const (
@@ -343,6 +360,15 @@ func handle(opts *Options, args []string) error {
 	if err != nil {
 		return err
 	}
+	historyFile := getHistoryFile(projectRoot)
+	if len(args) > 0 && args[0] == "history" {
+		return printHistory(historyFile, args[1:])
+	}
+	benchFile := getBenchFile(projectRoot)
+	if len(args) > 0 && args[0] == "bench" {
+		return printBench(benchFile, args[1:])
+	}
+
 	var configFile string
 	configFileName := opts.Config
 	var configFileRequired bool
@@ -489,6 +515,17 @@ func handle(opts *Options, args []string) error {
 		}
 	}
 
+	if len(args) > 0 && args[0] == "rpc" {
+		// headless mode for editors, stdout is reserved
+		// for the protocol, so test outputs are redirected
+		// to stderr
+		stdout := os.Stdout
+		os.Stdout = os.Stderr
+		rpcServer := newRPCServer(stdout)
+		setupRPCHandler(rpcServer, projectRoot, subPath, projectDir, opts.LogConsole, getTestConfig, covController, coverageFlags, covOpts, historyFile, benchFile)
+		return rpcServer.Serve(os.Stdin)
+	}
+
 	server.HandleFunc("/detail", func(w http.ResponseWriter, r *http.Request) {
 		netutil.SetCORSHeaders(w)
 		netutil.HandleJSON(w, r, func(ctx context.Context, r *http.Request) (interface{}, error) {
@@ -524,7 +561,12 @@ func handle(opts *Options, args []string) error {
 		})
 	})
 
-	setupSessionHandler(server, projectDir, opts.LogConsole, getTestConfig, covController, coverageFlags)
+	setupSessionHandler(server, projectDir, opts.LogConsole, getTestConfig, covController, coverageFlags, historyFile, benchFile)
+	setupHistoryHandler(server, historyFile)
+	setupBenchHandler(server, benchFile)
+	setupFuzzHandler(server)
+	setupAffectedHandler(server, projectDir, getTestConfig)
+	setupTraceHandler(server, projectRoot)
 	setupCoverageHandler(server, covController, covOpts, func() int {
 		return actualPort
 	})
@@ -736,7 +778,7 @@ func sortByKindAndName(item *TestingItem) {
 		if a.Kind != b.Kind {
 			return a.Kind.Order() < b.Kind.Order()
 		}
-		if a.Kind == TestingItemKind_Case {
+		if a.Kind.IsFunc() {
 			// case does sort by index
 			return i < j
 		}
@@ -13,12 +13,7 @@ import (
 
 func setupOpenHandler(server *http.ServeMux) {
 	server.HandleFunc("/openVscode", func(w http.ResponseWriter, r *http.Request) {
-		handleOpenFile(w, r, func(file string, line int) error {
-			if line > 0 {
-				return cmd.Debug().Run("code", "--goto", fmt.Sprintf("%s:%d", file, line))
-			}
-			return cmd.Debug().Run("code", file)
-		})
+		handleOpenFile(w, r, openVscode)
 	})
 	server.HandleFunc("/openGoland", func(w http.ResponseWriter, r *http.Request) {
 		handleOpenFile(w, r, func(file string, line int) error {
@@ -44,6 +39,13 @@ func setupOpenHandler(server *http.ServeMux) {
 	})
 }
 
+func openVscode(file string, line int) error {
+	if line > 0 {
+		return cmd.Debug().Run("code", "--goto", fmt.Sprintf("%s:%d", file, line))
+	}
+	return cmd.Debug().Run("code", file)
+}
+
 func handleOpenFile(w http.ResponseWriter, r *http.Request, callback func(file string, line int) error) {
 	netutil.SetCORSHeaders(w)
 	netutil.HandleJSON(w, r, func(ctx context.Context, r *http.Request) (interface{}, error) {
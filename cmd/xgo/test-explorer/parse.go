@@ -52,7 +52,7 @@ func filterItem(item *TestingItem, withCases bool) *TestingItem {
 			}
 		}
 		item.Children = children[:i]
-		if i == 0 && item.Kind != TestingItemKind_Case {
+		if i == 0 && !item.Kind.IsFunc() {
 			return nil
 		}
 	} else {
@@ -65,7 +65,11 @@ func filterItem(item *TestingItem, withCases bool) *TestingItem {
 }
 
 func parseTests(absDir string, absFile string) ([]*TestingItem, error) {
-	fset, decls, err := parseTestFuncs(absFile)
+	return parseTestsCode(absDir, absFile, nil)
+}
+
+func parseTestsCode(absDir string, absFile string, code io.Reader) ([]*TestingItem, error) {
+	fset, astFile, decls, err := parseTestFileCode(absFile, code)
 	if err != nil {
 		return nil, err
 	}
@@ -76,7 +80,7 @@ func parseTests(absDir string, absFile string) ([]*TestingItem, error) {
 	items := make([]*TestingItem, 0, len(decls))
 	for _, fnDecl := range decls {
 		name := fnDecl.Name.Name
-		items = append(items, &TestingItem{
+		item := &TestingItem{
 			Key:          name,
 			Name:         name,
 			BaseCaseName: name,
@@ -84,8 +88,12 @@ func parseTests(absDir string, absFile string) ([]*TestingItem, error) {
 			RelPath:      relPath,
 			File:         absFile,
 			Line:         fset.Position(fnDecl.Pos()).Line,
-			Kind:         TestingItemKind_Case,
-		})
+			Kind:         getTestFuncKind(fnDecl),
+		}
+		if item.Kind == TestingItemKind_Case {
+			item.Children = parseSubTests(fset, astFile, item, fnDecl.Type, fnDecl.Body)
+		}
+		items = append(items, item)
 	}
 	return items, nil
 }
@@ -95,10 +103,15 @@ func parseTestFuncs(file string) (*token.FileSet, []*ast.FuncDecl, error) {
 }
 
 func parseTestFuncsCode(file string, code io.Reader) (*token.FileSet, []*ast.FuncDecl, error) {
+	fset, _, decls, err := parseTestFileCode(file, code)
+	return fset, decls, err
+}
+
+func parseTestFileCode(file string, code io.Reader) (*token.FileSet, *ast.File, []*ast.FuncDecl, error) {
 	fset := token.NewFileSet()
 	astFile, err := parser.ParseFile(fset, file, code, parser.ParseComments)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	var results []*ast.FuncDecl
 	for _, decl := range astFile.Decls {
@@ -106,21 +119,33 @@ func parseTestFuncsCode(file string, code io.Reader) (*token.FileSet, []*ast.Fun
 		if !ok {
 			continue
 		}
-		if fnDecl.Name == nil {
-			continue
-		}
-		if !strings.HasPrefix(fnDecl.Name.Name, "Test") {
-			continue
-		}
-		if fnDecl.Body == nil {
-			continue
-		}
-		if fnDecl.Type.Params == nil || len(fnDecl.Type.Params.List) != 1 {
+		if getTestFuncKind(fnDecl) == "" {
 			continue
 		}
 		results = append(results, fnDecl)
 	}
-	return fset, results, nil
+	return fset, astFile, results, nil
+}
+
+// getTestFuncKind returns "" if fnDecl is not a
+// test, benchmark or fuzz target
+func getTestFuncKind(fnDecl *ast.FuncDecl) TestingItemKind {
+	if fnDecl.Name == nil || fnDecl.Body == nil {
+		return ""
+	}
+	if fnDecl.Type.Params == nil || len(fnDecl.Type.Params.List) != 1 {
+		return ""
+	}
+	name := fnDecl.Name.Name
+	switch {
+	case strings.HasPrefix(name, "Test"):
+		return TestingItemKind_Case
+	case strings.HasPrefix(name, "Benchmark"):
+		return TestingItemKind_Benchmark
+	case strings.HasPrefix(name, "Fuzz"):
+		return TestingItemKind_Fuzz
+	}
+	return ""
 }
 
 func getFuncDecl(funcs []*ast.FuncDecl, name string) (*ast.FuncDecl, error) {
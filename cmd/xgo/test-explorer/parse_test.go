@@ -1,6 +1,7 @@
 package test_explorer
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -65,3 +66,143 @@ func TestParseTestFuncsCode(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSubTests(t *testing.T) {
+	code := `package test
+import (
+	"fmt"
+	"testing"
+)
+
+var pkgTests = []struct {
+	name string
+}{
+	{name: "pkg case"},
+}
+
+func TestLiteral(t *testing.T) {
+	t.Run("a b", func(t *testing.T) {
+		t.Run("nested", func(t *testing.T) {})
+	})
+	t.Run("a b", func(t *testing.T) {})
+	t.Run("", func(t *testing.T) {})
+	t.Run("x/y", func(t *testing.T) {})
+	t.Run(fmt.Sprintf("%d", 1), func(t *testing.T) {})
+}
+
+func TestTable(t *testing.T) {
+	tests := []struct {
+		name string
+		want int
+	}{
+		{name: "add (1+2)", want: 3},
+		{"positional", 1},
+		{want: 2},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {})
+	}
+	for _, tt := range pkgTests {
+		t.Run(tt.name, func(t *testing.T) {})
+	}
+}
+
+func TestMap(t *testing.T) {
+	for name, c := range map[string]*struct{ desc string }{
+		"k1": {desc: "d1"},
+	} {
+		t.Run(name, func(t *testing.T) {})
+		t.Run(c.desc, func(t *testing.T) {})
+	}
+	for _, s := range []string{"s1"} {
+		t.Run(s, func(t *testing.T) {})
+	}
+}
+
+func TestNoParam(t *testing.T) {
+	helper := func(t *testing.T) {}
+	helper(t)
+}
+`
+	items, err := parseTestsCode("/", "/x_test.go", strings.NewReader(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	var walk func(items []*TestingItem, depth int)
+	walk = func(items []*TestingItem, depth int) {
+		for _, item := range items {
+			lines = append(lines, fmt.Sprintf("%s%s %s %s:%d", strings.Repeat("  ", depth), item.Name, item.NameUnderPkg, item.BaseCaseName, item.Line))
+			walk(item.Children, depth+1)
+		}
+	}
+	walk(items, 0)
+	expect := []string{
+		"TestLiteral TestLiteral TestLiteral:13",
+		"  a_b TestLiteral/a_b TestLiteral:14",
+		"    nested TestLiteral/a_b/nested TestLiteral:15",
+		"  a_b#01 TestLiteral/a_b#01 TestLiteral:17",
+		"  #00 TestLiteral/#00 TestLiteral:18",
+		"TestTable TestTable TestTable:23",
+		"  add_(1+2) TestTable/add_(1+2) TestTable:28",
+		"  positional TestTable/positional TestTable:29",
+		"  pkg_case TestTable/pkg_case TestTable:10",
+		"TestMap TestMap TestMap:41",
+		"  k1 TestMap/k1 TestMap:43",
+		"  d1 TestMap/d1 TestMap:43",
+		"  s1 TestMap/s1 TestMap:48",
+		"TestNoParam TestNoParam TestNoParam:53",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expect, "\n") {
+		t.Fatalf("expect:\n%s\nactual:\n%s", strings.Join(expect, "\n"), strings.Join(lines, "\n"))
+	}
+
+	run := formatRunNames([]string{items[1].Children[0].NameUnderPkg, "TestMap"})
+	if run != `^TestTable/add_\(1\+2\)$|^TestMap$` {
+		t.Fatalf("unexpected run: %s", run)
+	}
+}
+
+func TestParseBenchmarkAndFuzz(t *testing.T) {
+	code := `package test
+import "testing"
+
+func TestA(t *testing.T) {}
+
+func BenchmarkB(b *testing.B) {
+	b.Run("sub", func(b *testing.B) {})
+}
+
+func FuzzC(f *testing.F) {}
+
+func helper() {}
+`
+	items, err := parseTestsCode("/", "/x_test.go", strings.NewReader(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for _, item := range items {
+		actual = append(actual, fmt.Sprintf("%s %s %d", item.Name, item.Kind, len(item.Children)))
+	}
+	expect := []string{
+		"TestA case 0",
+		"BenchmarkB benchmark 0",
+		"FuzzC fuzz 0",
+	}
+	if strings.Join(actual, "\n") != strings.Join(expect, "\n") {
+		t.Fatalf("expect:\n%s\nactual:\n%s", strings.Join(expect, "\n"), strings.Join(actual, "\n"))
+	}
+
+	// benchmarks are not run without -bench
+	file := &TestingItem{Kind: TestingItemKind_File, RelPath: "x_test.go", Children: items}
+	_, itemPaths := getAllSubRelPaths(file, []string{"root"})
+	if len(itemPaths) != 3 || strings.Join(itemPaths[2], "/") != "root/x_test.go/FuzzC" {
+		t.Fatalf("unexpected item paths: %v", itemPaths)
+	}
+	_, cases := getFileSubCases(file)
+	if strings.Join(cases, ",") != "TestA,FuzzC" {
+		t.Fatalf("unexpected cases: %v", cases)
+	}
+}
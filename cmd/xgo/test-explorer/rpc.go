@@ -0,0 +1,374 @@
+package test_explorer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/xhd2015/xgo/cmd/xgo/internal/vendir/github.com/xhd2015/lines-annotation/load/loadcov"
+	"github.com/xhd2015/xgo/cmd/xgo/test-explorer/icov"
+	"github.com/xhd2015/xgo/support/netutil"
+	"github.com/xhd2015/xgo/support/session"
+)
+
+// the headless protocol used by `xgo e rpc`, it is JSON-RPC 2.0
+// over stdio, each message is framed with a Content-Length header
+// just like LSP. see doc/test-explorer/README.md for methods.
+const (
+	RPCMethod_List           = "list"
+	RPCMethod_Detail         = "detail"
+	RPCMethod_SessionStart   = "session/start"
+	RPCMethod_SessionDestroy = "session/destroy"
+	RPCMethod_Coverage       = "coverage"
+	RPCMethod_History        = "history"
+	RPCMethod_Affected       = "affected"
+	RPCMethod_Trace          = "trace"
+	RPCMethod_Bench          = "bench"
+	RPCMethod_FuzzCorpus     = "fuzz/corpus"
+
+	// notification sent from server
+	RPCMethod_SessionEvent = "session/event"
+)
+
+// standard JSON-RPC error codes
+const (
+	rpcCodeParseError     = -32700
+	rpcCodeInvalidRequest = -32600
+	rpcCodeMethodNotFound = -32601
+	rpcCodeInvalidParams  = -32602
+	rpcCodeInternalError  = -32603
+)
+
+type rpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+// rpcResponse always has id, which is null
+// if the request id cannot be determined
+type rpcResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type SessionEventParams struct {
+	ID    string            `json:"id"`
+	Event *TestingItemEvent `json:"event"`
+}
+
+type CoverageRequest struct {
+	// if false, only changed files are returned
+	Full bool `json:"full"`
+}
+
+type rpcHandler func(params json.RawMessage) (interface{}, error)
+
+type rpcServer struct {
+	handlers map[string]rpcHandler
+
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func newRPCServer(w io.Writer) *rpcServer {
+	return &rpcServer{
+		handlers: make(map[string]rpcHandler),
+		w:        w,
+	}
+}
+
+func (c *rpcServer) Handle(method string, h rpcHandler) {
+	c.handlers[method] = h
+}
+
+// Notify sends a message without id, safe to be
+// called concurrently
+func (c *rpcServer) Notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&rpcMessage{JSONRPC: "2.0", Method: method, Params: data})
+}
+
+// Serve reads requests from r until EOF, each request
+// is handled in its own goroutine
+func (c *rpcServer) Serve(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		data, err := readRPCFrame(reader)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var msg rpcMessage
+		err = json.Unmarshal(data, &msg)
+		if err != nil {
+			c.write(&rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: rpcCodeParseError, Message: err.Error()}})
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.handle(&msg)
+		}()
+	}
+}
+
+func (c *rpcServer) handle(msg *rpcMessage) {
+	result, rpcErr := c.call(msg)
+	if msg.ID == nil {
+		// notification from client
+		return
+	}
+	resp := &rpcResponse{JSONRPC: "2.0", ID: msg.ID, Error: rpcErr}
+	if rpcErr == nil {
+		// result is required on success
+		if result == nil {
+			result = json.RawMessage("null")
+		}
+		resp.Result = result
+	}
+	c.write(resp)
+}
+
+func (c *rpcServer) call(msg *rpcMessage) (result interface{}, rpcErr *rpcError) {
+	if msg.Method == "" {
+		return nil, &rpcError{Code: rpcCodeInvalidRequest, Message: "requires method"}
+	}
+	h := c.handlers[msg.Method]
+	if h == nil {
+		return nil, &rpcError{Code: rpcCodeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			result = nil
+			rpcErr = &rpcError{Code: rpcCodeInternalError, Message: fmt.Sprintf("panic: %v", e)}
+		}
+	}()
+	result, err := h(msg.Params)
+	if err != nil {
+		code := rpcCodeInternalError
+		var httpErr netutil.HttpStatusErr
+		if errors.As(err, &httpErr) && httpErr.HttpStatusCode() == 400 {
+			code = rpcCodeInvalidParams
+		}
+		return nil, &rpcError{Code: code, Message: err.Error()}
+	}
+	return result, nil
+}
+
+func (c *rpcServer) write(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, err = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+func readRPCFrame(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	lengthStr := header.Get("Content-Length")
+	if lengthStr == "" {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(lengthStr))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %s", lengthStr)
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	return data, nil
+}
+
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	err := json.Unmarshal(params, v)
+	if err != nil {
+		return netutil.ParamErrorf("params: %v", err)
+	}
+	return nil
+}
+
+// setupRPCHandler registers the same functionalities
+// as the http server, except that session events are
+// pushed as session/event notifications instead of
+// being polled
+func setupRPCHandler(server *rpcServer, projectRoot string, subPath []string, projectDir string, logConsole bool, getTestConfig func() (*TestConfig, error), covController icov.Controller, coverageFlags []string, covOpts loadcov.LoadAllOptions, historyFile string, benchFile string) {
+	sessionManager := session.NewSessionManager()
+
+	server.Handle(RPCMethod_List, func(params json.RawMessage) (interface{}, error) {
+		conf, err := getTestConfig()
+		if err != nil {
+			return nil, err
+		}
+		return scanTests(projectRoot, subPath, true, conf.Exclude)
+	})
+	server.Handle(RPCMethod_Detail, func(params json.RawMessage) (interface{}, error) {
+		req := &DetailRequest{BaseRequest: &BaseRequest{}}
+		err := decodeParams(params, req)
+		if err != nil {
+			return nil, err
+		}
+		return getDetail(req)
+	})
+	server.Handle(RPCMethod_SessionStart, func(params json.RawMessage) (interface{}, error) {
+		var req *StartSessionRequest
+		err := decodeParams(params, &req)
+		if err != nil {
+			return nil, err
+		}
+		res, err := startSession(sessionManager, req, projectDir, logConsole, getTestConfig, covController, coverageFlags, historyFile, benchFile)
+		if err != nil {
+			return nil, err
+		}
+		ses, err := sessionManager.Get(res.ID)
+		if err != nil {
+			return nil, err
+		}
+		go forwardSessionEvents(server, sessionManager, res.ID, ses)
+		return res, nil
+	})
+	server.Handle(RPCMethod_SessionDestroy, func(params json.RawMessage) (interface{}, error) {
+		var req *DestroySessionRequest
+		err := decodeParams(params, &req)
+		if err != nil {
+			return nil, err
+		}
+		if req == nil || req.ID == "" {
+			return nil, netutil.ParamErrorf("requires id")
+		}
+		return nil, sessionManager.Destroy(req.ID)
+	})
+	server.Handle(RPCMethod_History, func(params json.RawMessage) (interface{}, error) {
+		var req HistoryRequest
+		err := decodeParams(params, &req)
+		if err != nil {
+			return nil, err
+		}
+		return loadHistory(historyFile, &req)
+	})
+	server.Handle(RPCMethod_Affected, func(params json.RawMessage) (interface{}, error) {
+		var req AffectedRequest
+		err := decodeParams(params, &req)
+		if err != nil {
+			return nil, err
+		}
+		conf, err := getTestConfig()
+		if err != nil {
+			return nil, err
+		}
+		return selectAffected(projectDir, conf, req.Ref, nil)
+	})
+	server.Handle(RPCMethod_Bench, func(params json.RawMessage) (interface{}, error) {
+		var req BenchRequest
+		err := decodeParams(params, &req)
+		if err != nil {
+			return nil, err
+		}
+		return loadBench(benchFile, &req)
+	})
+	server.Handle(RPCMethod_FuzzCorpus, func(params json.RawMessage) (interface{}, error) {
+		var req FuzzCorpusRequest
+		err := decodeParams(params, &req)
+		if err != nil {
+			return nil, err
+		}
+		return listFuzzCorpus(&req)
+	})
+	server.Handle(RPCMethod_Trace, func(params json.RawMessage) (interface{}, error) {
+		var req TraceRequest
+		err := decodeParams(params, &req)
+		if err != nil {
+			return nil, err
+		}
+		traceRoot, err := getTraceRoot(projectRoot)
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		err = renderTrace(traceRoot, &req, &b)
+		if err != nil {
+			return nil, err
+		}
+		return &TraceResult{HTML: b.String()}, nil
+	})
+	server.Handle(RPCMethod_Coverage, func(params json.RawMessage) (interface{}, error) {
+		if covController == nil {
+			return nil, fmt.Errorf("coverage is disabled")
+		}
+		var req CoverageRequest
+		err := decodeParams(params, &req)
+		if err != nil {
+			return nil, err
+		}
+		opts := covOpts
+		opts.OnlyChangedFiles = !req.Full
+		project, err := loadcov.LoadAll(opts)
+		if err != nil {
+			return nil, err
+		}
+		return project.Files, nil
+	})
+}
+
+// forwardSessionEvents notifies events of a session until
+// the test ends, then the session is destroyed
+func forwardSessionEvents(server *rpcServer, sessionManager session.SessionManager, id string, ses session.Session) {
+	defer sessionManager.Destroy(id)
+	for {
+		_, err := sessionManager.Get(id)
+		if err != nil {
+			// destroyed by client
+			return
+		}
+		events, err := ses.PollEvents()
+		if err != nil {
+			return
+		}
+		for _, event := range convTestingEvents(events) {
+			err := server.Notify(RPCMethod_SessionEvent, &SessionEventParams{ID: id, Event: event})
+			if err != nil {
+				return
+			}
+			if event.Event == Event_TestEnd {
+				return
+			}
+		}
+	}
+}
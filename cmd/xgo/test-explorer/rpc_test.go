@@ -0,0 +1,110 @@
+package test_explorer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/xgo/support/netutil"
+)
+
+func frameRPC(msgs ...string) string {
+	var b strings.Builder
+	for _, msg := range msgs {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+	return b.String()
+}
+
+func TestRPCServe(t *testing.T) {
+	var out bytes.Buffer
+	server := newRPCServer(&out)
+	server.Handle("echo", func(params json.RawMessage) (interface{}, error) {
+		var req struct {
+			Name string `json:"name"`
+		}
+		err := decodeParams(params, &req)
+		if err != nil {
+			return nil, err
+		}
+		if req.Name == "" {
+			return nil, netutil.ParamErrorf("requires name")
+		}
+		return map[string]string{"hello": req.Name}, nil
+	})
+	server.Handle("nothing", func(params json.RawMessage) (interface{}, error) {
+		return nil, nil
+	})
+	in := frameRPC(
+		`{"jsonrpc":"2.0","id":1,"method":"echo","params":{"name":"xgo"}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"echo","params":{}}`,
+		`{"jsonrpc":"2.0","id":"3","method":"missing"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"nothing"}`,
+		// notification, no response
+		`{"jsonrpc":"2.0","method":"echo","params":{"name":"x"}}`,
+		`{"jsonrpc":"2.0","id":5,`,
+	)
+	err := server.Serve(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// requests are handled concurrently, sort to compare
+	var responses []string
+	reader := bufio.NewReader(&out)
+	for {
+		data, err := readRPCFrame(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		responses = append(responses, string(data))
+	}
+	sort.Strings(responses)
+	expect := []string{
+		`{"jsonrpc":"2.0","id":"3","error":{"code":-32601,"message":"method not found: missing"}}`,
+		`{"jsonrpc":"2.0","id":1,"result":{"hello":"xgo"}}`,
+		`{"jsonrpc":"2.0","id":2,"error":{"code":-32602,"message":"requires name"}}`,
+		`{"jsonrpc":"2.0","id":4,"result":null}`,
+		`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"unexpected end of JSON input"}}`,
+	}
+	if strings.Join(responses, "\n") != strings.Join(expect, "\n") {
+		t.Fatalf("expect responses:\n%s\nactual:\n%s", strings.Join(expect, "\n"), strings.Join(responses, "\n"))
+	}
+}
+
+func TestRPCNotify(t *testing.T) {
+	var out bytes.Buffer
+	server := newRPCServer(&out)
+	err := server.Notify(RPCMethod_SessionEvent, &SessionEventParams{ID: "s1", Event: &TestingItemEvent{Event: Event_TestEnd}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := readRPCFrame(bufio.NewReader(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg struct {
+		Method string `json:"method"`
+		Params struct {
+			ID    string `json:"id"`
+			Event struct {
+				Event string `json:"event"`
+			} `json:"event"`
+		} `json:"params"`
+	}
+	err = json.Unmarshal(data, &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Method != "session/event" || msg.Params.ID != "s1" || msg.Params.Event.Event != "test_end" {
+		t.Fatalf("unexpected notification: %s", data)
+	}
+}
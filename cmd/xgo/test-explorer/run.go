@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -31,6 +32,7 @@ const (
 	Event_TestStart   Event = "test_start"
 	Event_TestEnd     Event = "test_end"
 	Event_UpdateTrace Event = "update_trace"
+	Event_BenchResult Event = "bench_result"
 )
 
 type TestingItemEvent struct {
@@ -41,6 +43,8 @@ type TestingItemEvent struct {
 	Msg          string        `json:"msg"`
 	LogConsole   bool          `json:"logConsole"`
 	TraceRecords []*CallRecord `json:"traceRecords"`
+	Trace        *TraceLink    `json:"trace"`
+	Bench        *BenchResult  `json:"bench"`
 }
 
 type PollSessionRequest struct {
@@ -70,6 +74,19 @@ type runSession struct {
 	path  []string
 	debug bool
 	trace bool // xgo stack trace
+	// run the item count times, used to detect flaky tests
+	count int
+	// if not nil, only affected tests are run
+	affected *AffectedResult
+
+	// fuzz with -fuzz for fuzzTime
+	fuzz     bool
+	fuzzTime string
+
+	// if not empty, results are appended to it
+	historyFile string
+	// if not empty, benchmark results are appended to it
+	benchFile string
 
 	logConsole bool
 
@@ -91,7 +108,9 @@ func formatRunNames(names []string) string {
 	if names == nil {
 		return ""
 	}
-	return fmt.Sprintf("^%s$", strings.Join(escapeRegexNames(names), "|"))
+	// go test splits -run by top level '|', so each
+	// alternative is anchored
+	return fmt.Sprintf("^%s$", strings.Join(escapeRegexNames(names), "$|^"))
 }
 
 func escapeRegexNames(names []string) []string {
@@ -102,17 +121,9 @@ func escapeRegexNames(names []string) []string {
 	return replacedNames
 }
 
-var replacer = strings.NewReplacer(
-	// ".", "\\.",
-	"{", "\\{",
-	"}", "\\}",
-	"[", "\\[",
-	"]", "\\]",
-	"|", "\\|",
-)
-
+// '/' is not escaped, go test splits -run by it to match subtests
 func escapeRegexName(name string) string {
-	return replacer.Replace(name)
+	return regexp.QuoteMeta(name)
 }
 
 func joinTestArgs(pathArgs []string, runNames string) []string {
@@ -126,7 +137,7 @@ func joinTestArgs(pathArgs []string, runNames string) []string {
 
 func getTestPaths(item *TestingItem, pathPrefix []string) (paths []string, itemPaths [][]string, names []string) {
 	switch item.Kind {
-	case TestingItemKind_Case:
+	case TestingItemKind_Case, TestingItemKind_Benchmark, TestingItemKind_Fuzz:
 		testName := item.NameUnderPkg
 		if testName == "" {
 			testName = item.Name
@@ -185,7 +196,11 @@ func getCaseItemPath(prefix []string, relPath string, name string, nameUnderPkg
 
 // emulate the ./pkg/... behavior
 func getAllSubRelPaths(t *TestingItem, pathPrefix []string) (relPaths []string, itemPaths [][]string) {
-	if t.Kind == TestingItemKind_Case {
+	if t.Kind == TestingItemKind_Benchmark {
+		// benchmarks are not run by go test without -bench
+		return
+	}
+	if t.Kind.IsFunc() {
 		itemPaths = append(itemPaths, getCaseItemPath(pathPrefix, t.RelPath, t.Name, t.NameUnderPkg))
 	} else {
 		itemPaths = append(itemPaths, getFileItemPath(pathPrefix, t.RelPath))
@@ -205,7 +220,8 @@ func getAllSubRelPaths(t *TestingItem, pathPrefix []string) (relPaths []string,
 func getFileSubCases(t *TestingItem) (arg string, cases []string) {
 	arg = filepath.Dir(t.RelPath)
 	for _, child := range t.Children {
-		if child.Kind != TestingItemKind_Case {
+		// seed corpus of fuzz targets are run as tests
+		if child.Kind != TestingItemKind_Case && child.Kind != TestingItemKind_Fuzz {
 			continue
 		}
 		cases = append(cases, child.Name)
@@ -260,7 +276,7 @@ func makeMergeTree(subPath []string, prefixName string, item *TestingItem) *Test
 		RelPath:        item.RelPath,
 		File:           item.File,
 		Line:           item.Line,
-		Kind:           TestingItemKind_Case,
+		Kind:           item.Kind,
 		HasTestGoFiles: item.HasTestGoFiles,
 		HasTestCases:   item.HasTestCases,
 		State: &TestingItemState{
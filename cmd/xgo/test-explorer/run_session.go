@@ -10,9 +10,11 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -32,10 +34,21 @@ type StartSessionRequest struct {
 	Path  []string     `json:"path"`
 	Debug bool         `json:"debug"`
 	Trace bool         `json:"trace"`
+	// Count > 1 runs the item repeatedly via -count
+	Count int `json:"count"`
+	// Affected only runs tests under the item that are
+	// affected by changes since AffectedSince, which
+	// defaults to coverage.diff_with
+	Affected      bool   `json:"affected"`
+	AffectedSince string `json:"affectedSince"`
+	// Fuzz runs a fuzz target with -fuzz for FuzzTime,
+	// otherwise only its seed corpus is run as a test
+	Fuzz     bool   `json:"fuzz"`
+	FuzzTime string `json:"fuzzTime"`
 }
 
 // TODO: make FE call /session/destroy
-func setupSessionHandler(server *http.ServeMux, projectDir string, logConsole bool, getTestConfig func() (*TestConfig, error), covController icov.Controller, coverageFlags []string) {
+func setupSessionHandler(server *http.ServeMux, projectDir string, logConsole bool, getTestConfig func() (*TestConfig, error), covController icov.Controller, coverageFlags []string, historyFile string, benchFile string) {
 	sessionManager := session.NewSessionManager()
 
 	server.HandleFunc("/session/start", func(w http.ResponseWriter, r *http.Request) {
@@ -46,60 +59,7 @@ func setupSessionHandler(server *http.ServeMux, projectDir string, logConsole bo
 			if err != nil {
 				return nil, err
 			}
-			if req == nil || req.Item == nil || req.Item.File == "" {
-				return nil, netutil.ParamErrorf("requires file")
-			}
-
-			if req.Debug && req.Item.Kind != TestingItemKind_Case {
-				return nil, netutil.ParamErrorf("debug not supported: %s", req.Item.Kind)
-			}
-
-			trace := req.Trace
-			if trace && req.Item.Kind != TestingItemKind_Case {
-				trace = false
-				// return nil, netutil.ParamErrorf("trace not supported: %s", req.Item.Kind)
-			}
-
-			config, err := getTestConfig()
-			if err != nil {
-				return nil, err
-			}
-
-			id, ses, err := sessionManager.Start()
-			if err != nil {
-				return nil, err
-			}
-
-			absDir, err := filepath.Abs(projectDir)
-			if err != nil {
-				return nil, err
-			}
-
-			runSess := &runSession{
-				dir:           projectDir,
-				absDir:        absDir,
-				goCmd:         config.GoCmd,
-				env:           config.CmdEnv(),
-				testFlags:     appendCopy(config.Flags, coverageFlags...),
-				bypassGoFlags: config.BypassGoFlags,
-				progArgs:      config.Args,
-
-				pathPrefix: []string{getRootName(absDir)},
-
-				item:  req.Item,
-				path:  req.Path,
-				debug: req.Debug,
-				trace: trace,
-
-				logConsole:    logConsole,
-				session:       ses,
-				covController: covController,
-			}
-			err = runSess.Start()
-			if err != nil {
-				return nil, err
-			}
-			return &StartSessionResult{ID: id}, nil
+			return startSession(sessionManager, req, projectDir, logConsole, getTestConfig, covController, coverageFlags, historyFile, benchFile)
 		})
 	})
 
@@ -150,6 +110,93 @@ func setupSessionHandler(server *http.ServeMux, projectDir string, logConsole bo
 	})
 }
 
+// startSession starts running req.Item in background, events
+// are sent to the returned session
+func startSession(sessionManager session.SessionManager, req *StartSessionRequest, projectDir string, logConsole bool, getTestConfig func() (*TestConfig, error), covController icov.Controller, coverageFlags []string, historyFile string, benchFile string) (*StartSessionResult, error) {
+	if req == nil || req.Item == nil || req.Item.File == "" {
+		return nil, netutil.ParamErrorf("requires file")
+	}
+
+	if req.Debug && req.Item.Kind != TestingItemKind_Case {
+		return nil, netutil.ParamErrorf("debug not supported: %s", req.Item.Kind)
+	}
+
+	if req.Count < 0 {
+		return nil, netutil.ParamErrorf("invalid count: %d", req.Count)
+	}
+	if req.Debug && req.Count > 1 {
+		return nil, netutil.ParamErrorf("debug cannot run multiple times")
+	}
+	if req.Affected && (req.Debug || req.Item.Kind.IsFunc()) {
+		return nil, netutil.ParamErrorf("affected not supported: %s", req.Item.Kind)
+	}
+	if req.Fuzz && req.Item.Kind != TestingItemKind_Fuzz {
+		return nil, netutil.ParamErrorf("fuzz not supported: %s", req.Item.Kind)
+	}
+	fuzzTime := req.FuzzTime
+	if req.Fuzz && fuzzTime == "" {
+		fuzzTime = defaultFuzzTime
+	}
+
+	config, err := getTestConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var affectedRes *AffectedResult
+	if req.Affected {
+		paths, _, _ := getTestPaths(req.Item, nil)
+		affectedRes, err = selectAffected(projectDir, config, req.AffectedSince, formatPathArgs(paths))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	id, ses, err := sessionManager.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	absDir, err := filepath.Abs(projectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	runSess := &runSession{
+		dir:           projectDir,
+		absDir:        absDir,
+		goCmd:         config.GoCmd,
+		env:           config.CmdEnv(),
+		testFlags:     appendCopy(config.Flags, coverageFlags...),
+		bypassGoFlags: config.BypassGoFlags,
+		progArgs:      config.Args,
+
+		pathPrefix: []string{getRootName(absDir)},
+
+		item:  req.Item,
+		path:  req.Path,
+		debug: req.Debug,
+		trace: req.Trace,
+		count: req.Count,
+
+		fuzz:     req.Fuzz,
+		fuzzTime: fuzzTime,
+
+		affected: affectedRes,
+
+		logConsole:    logConsole,
+		session:       ses,
+		covController: covController,
+		historyFile:   historyFile,
+		benchFile:     benchFile,
+	}
+	err = runSess.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &StartSessionResult{ID: id}, nil
+}
+
 type testResolver struct {
 	absDir     string
 	dirPkgPath string
@@ -214,7 +261,6 @@ func (c *runSession) Start() error {
 	trace := c.trace
 
 	begin := time.Now()
-	_ = begin
 
 	dirPkgPath, err := resolveDirPkgPath(absDir)
 	if err != nil {
@@ -226,6 +272,12 @@ func (c *runSession) Start() error {
 	sendEvent := func(event *TestingItemEvent) {
 		if event.Event == Event_ItemStatus {
 			if event.Status != "" {
+				if c.count > 1 && event.Status != RunStatus_Fail {
+					// any failed run fails the item
+					if ok, prev := pm.Get(event.Path); ok && prev == RunStatus_Fail {
+						event.Status = RunStatus_Fail
+					}
+				}
 				pm.Set(event.Path, event.Status)
 			}
 		}
@@ -239,6 +291,26 @@ func (c *runSession) Start() error {
 	})
 
 	paths, itemPaths, names := getTestPaths(item, pathPrefix)
+	if c.affected != nil {
+		sendEvent(&TestingItemEvent{
+			Event: Event_ItemStatus,
+			Path:  pathPrefix,
+			Msg:   formatAffectedMsg(c.affected),
+		})
+		if len(c.affected.Packages) == 0 {
+			sendEvent(&TestingItemEvent{
+				Event: Event_TestEnd,
+			})
+			return nil
+		}
+		paths, names, err = affectedTestPaths(absDir, c.affected.Result)
+		if err != nil {
+			return err
+		}
+		// not all items run, statuses are reported
+		// only for tests actually run
+		itemPaths = nil
+	}
 
 	// set initial state
 	for _, itemPath := range itemPaths {
@@ -273,9 +345,25 @@ func (c *runSession) Start() error {
 		}}, nil
 	}
 
+	// results of this session, appended to history when finished
+	recordHistory := !debug && c.historyFile != ""
+	var records []*HistoryRecord
+
+	// results of tests, linked to their traces when finished
+	var traceResults []*traceResult
+
+	// benchmark results, appended to benchFile when finished
+	var benchResults []*BenchResult
+	var benchCommit string
+	var benchDirty bool
+	if item.Kind == TestingItemKind_Benchmark {
+		benchCommit, benchDirty = getBenchCommit(absDir, c.benchFile)
+	}
+
+	// repeated runs need json output to tell result of each run
 	var singleCase bool
 	var eventBuilder func(line []byte) ([]*TestingItemEvent, error)
-	if item.Kind == TestingItemKind_Case {
+	if item.Kind == TestingItemKind_Case && c.count <= 1 {
 		singleCase = true
 		eventBuilder = plainMsgBuilder
 	} else {
@@ -289,6 +377,21 @@ func (c *runSession) Start() error {
 			testResolver: tResolver,
 			pm:           pm,
 		}
+		if recordHistory {
+			jsonTestEventBuilder.onResult = func(record *HistoryRecord) {
+				records = append(records, record)
+			}
+		}
+		if trace {
+			jsonTestEventBuilder.onTraceResult = func(res *traceResult) {
+				traceResults = append(traceResults, res)
+			}
+		}
+		jsonTestEventBuilder.onBenchResult = func(res *BenchResult) {
+			res.Commit = benchCommit
+			res.Dirty = benchDirty
+			benchResults = append(benchResults, res)
+		}
 		eventBuilder = jsonTestEventBuilder.build
 	}
 
@@ -303,6 +406,7 @@ func (c *runSession) Start() error {
 	}
 
 	// trace
+	var traceRoot string
 	var traceDir string
 
 	// in go, file is ignored under a package
@@ -312,21 +416,40 @@ func (c *runSession) Start() error {
 	//  case = pkg/some_test.go/TestSomething/sub
 	//  traceDir = ROOT/pkg
 	//  caseSubPath = TestSomething/sub
+	// for a dir, tests of all packages under
+	// it write to the same traceDir
 	var caseSubPath string
-	if singleCase && trace {
+	if trace {
 		subPath, projectRoot, err := goinfo.FindGoModDirSubPath(absDir)
 		if err != nil {
 			return err
 		}
-		itemDir := filepath.Dir(c.item.RelPath)
+		itemDir := c.item.RelPath
+		if c.item.Kind != TestingItemKind_Dir {
+			itemDir = filepath.Dir(itemDir)
+		}
 		if len(subPath) > 0 {
 			itemDir = filepath.Join(filepath.Join(subPath...), itemDir)
 		}
+		traceRoot, err = getTraceRoot(projectRoot)
+		if err != nil {
+			return err
+		}
 		traceDir, err = getConsistentTraceDir(projectRoot, itemDir)
 		if err != nil {
 			return err
 		}
-		caseSubPath = item.NameUnderPkg
+		// remove traces of previous runs, so that
+		// tests not run this time are not linked
+		if singleCase {
+			caseSubPath = item.NameUnderPkg
+			err = removeTrace(traceDir, caseSubPath)
+		} else {
+			err = removeTrace(traceDir, "")
+		}
+		if err != nil {
+			return err
+		}
 		debugF("absDir=%s,itemRelPath=%s, traceDir=%s\n", absDir, c.item.RelPath, traceDir)
 	}
 
@@ -353,9 +476,21 @@ func (c *runSession) Start() error {
 		if traceDir != "" {
 			testFlags = append(testFlags, "--strace", "--strace-dir", traceDir)
 		}
+		if c.count > 1 {
+			testFlags = append(testFlags, "-count", strconv.Itoa(c.count))
+		}
 
 		if !debug {
-			testArgs := joinTestArgs(pathArgs, runNames)
+			var testArgs []string
+			switch {
+			case item.Kind == TestingItemKind_Benchmark:
+				// -run ^$ skips tests
+				testArgs = append([]string{"-run", "^$", "-bench", runNames, "-benchmem"}, pathArgs...)
+			case item.Kind == TestingItemKind_Fuzz && c.fuzz:
+				testArgs = append([]string{"-run", "^$", "-fuzz", runNames, "-fuzztime", c.fuzzTime}, pathArgs...)
+			default:
+				testArgs = joinTestArgs(pathArgs, runNames)
+			}
 			err = runTest(c.goCmd, c.dir, testFlags, testArgs, c.bypassGoFlags, c.progArgs, c.env, stdout, stderr)
 		} else {
 			err = debugTest(c.goCmd, c.dir, item.File, testFlags, pathArgs, c.bypassGoFlags, runNames, stdout, stderr, c.progArgs, c.env)
@@ -387,6 +522,40 @@ func (c *runSession) Start() error {
 			if err != nil {
 				sendEvent(&TestingItemEvent{Event: Event_ItemStatus, Path: rootPath, Msg: err.Error(), Status: RunStatus_Fail})
 			}
+			if recordHistory && singleCase {
+				status := RunStatus_Success
+				if err != nil {
+					status = RunStatus_Fail
+				}
+				records = append(records, &HistoryRecord{
+					Time:    time.Now(),
+					Pkg:     path.Join(dirPkgPath, filepath.ToSlash(filepath.Dir(item.RelPath))),
+					Test:    item.NameUnderPkg,
+					Status:  status,
+					Elapsed: time.Since(begin).Seconds(),
+				})
+			}
+			if recordHistory {
+				err := appendHistory(c.historyFile, records)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "WARNING: record test history: %v\n", err)
+				}
+			}
+			if c.benchFile != "" {
+				err := appendBenchResults(c.benchFile, benchResults)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "WARNING: record benchmark results: %v\n", err)
+				}
+			}
+			if c.count > 1 {
+				for _, h := range summarizeHistory(records, &HistoryRequest{Flaky: true, Limit: c.count}) {
+					sendEvent(&TestingItemEvent{
+						Event: Event_ItemStatus,
+						Path:  rootPath,
+						Msg:   fmt.Sprintf("flaky: %s %s failed %d of %d runs", h.Pkg, h.Test, h.Fails, h.Runs),
+					})
+				}
+			}
 
 			// set all sub cases as success
 			pm.Range(func(path []string, status RunStatus) bool {
@@ -398,21 +567,29 @@ func (c *runSession) Start() error {
 					})
 				}
 				// read trace
-				if traceDir != "" {
+				if traceDir != "" && singleCase {
 					debugF("path: %v, baseTraceCase: %v, rootPath: %v", path, caseSubPath, rootPath)
 					if suffix, ok := trimPrefix(path, rootPath); ok {
-						records := readTrace(traceDir, caseSubPath, suffix)
-						if records != nil {
+						traceFile := filepath.Join(traceDir, caseSubPath, filepath.Join(suffix...)) + ".json"
+						records := readTrace(traceFile)
+						link := newTraceLink(traceRoot, traceFile, status == RunStatus_Fail)
+						if records != nil || link != nil {
 							sendEvent(&TestingItemEvent{
 								Event:        Event_UpdateTrace,
 								Path:         path,
 								TraceRecords: records,
+								Trace:        link,
 							})
 						}
 					}
 				}
 				return true
 			})
+			if traceDir != "" && !singleCase {
+				for _, event := range linkTraces(traceRoot, traceDir, traceResults) {
+					sendEvent(event)
+				}
+			}
 
 			sendEvent(&TestingItemEvent{
 				Event: Event_TestEnd,
@@ -438,8 +615,25 @@ func trimPrefix(path []string, root []string) ([]string, bool) {
 	return path[len(root):], true
 }
 
-func readTrace(traceDir string, baseCaseName string, subPath []string) []*CallRecord {
-	traceFile := filepath.Join(traceDir, baseCaseName, filepath.Join(subPath...)) + ".json"
+// removeTrace removes trace files of a test and its
+// sub tests, or all traces in traceDir if name is empty
+func removeTrace(traceDir string, name string) error {
+	if name == "" {
+		err := os.RemoveAll(traceDir)
+		if err != nil {
+			return err
+		}
+		return os.MkdirAll(traceDir, 0755)
+	}
+	file := filepath.Join(traceDir, filepath.FromSlash(name))
+	err := os.RemoveAll(file + ".json")
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(file)
+}
+
+func readTrace(traceFile string) []*CallRecord {
 	debugF("traceFile: %s", traceFile)
 	records, err := readTraceFromFile(traceFile)
 	if err != nil {
@@ -610,6 +804,16 @@ type jsonTestEventBuilder struct {
 
 	pm *pathMapping
 
+	// called with result of each test
+	onResult func(record *HistoryRecord)
+	// called with result of each test when tracing
+	onTraceResult func(res *traceResult)
+	// called with each row of benchmark output
+	onBenchResult func(res *BenchResult)
+
+	// benchmark output not ended with newline, by package
+	benchPending map[string]string
+
 	// parser
 	prefix []string
 }
@@ -619,7 +823,102 @@ func (c *jsonTestEventBuilder) build(line []byte) ([]*TestingItemEvent, error) {
 	if err != nil {
 		return nil, err
 	}
-	return buildEvent(event, c.pathPrefix, c.dirPkgPath, c.pm, c.testResolver)
+	if c.onResult != nil {
+		if record := historyRecordOf(event); record != nil {
+			c.onResult(record)
+		}
+	}
+	benchLine := c.attributeBenchOutput(event)
+	events, err := buildEvent(event, c.pathPrefix, c.dirPkgPath, c.pm, c.testResolver)
+	if err != nil {
+		return nil, err
+	}
+	if c.onTraceResult != nil && event != nil && event.Test != "" && (event.Action == TestEventAction_Pass || event.Action == TestEventAction_Fail) {
+		failed := event.Action == TestEventAction_Fail
+		status := RunStatus_Success
+		if failed {
+			status = RunStatus_Fail
+		}
+		for _, e := range events {
+			if e.Event == Event_ItemStatus && e.Status == status && len(e.Path) > 0 {
+				c.onTraceResult(&traceResult{
+					pkg:    event.Package,
+					test:   event.Test,
+					path:   appendCopy(e.Path),
+					failed: failed,
+				})
+				break
+			}
+		}
+	}
+	if benchLine != "" {
+		if res := parseBenchLine(event.Test, benchLine); res != nil {
+			res.Time = event.Time
+			res.Pkg = event.Package
+			var path []string
+			for _, e := range events {
+				// the output of the benchmark itself
+				if e.Event == Event_ItemStatus && e.Msg == event.Output && len(e.Path) > 0 {
+					path = e.Path
+					break
+				}
+			}
+			events = append(events, &TestingItemEvent{
+				Event: Event_BenchResult,
+				Path:  path,
+				Bench: res,
+			})
+			if c.onBenchResult != nil {
+				c.onBenchResult(res)
+			}
+		}
+	}
+	return events, nil
+}
+
+// attributeBenchOutput sets Test of benchmark output, which
+// go test -json may leave empty, and returns the complete
+// output line of the benchmark, or "" if not yet complete.
+// The result is printed in two parts:
+//
+//	BenchmarkItoa-8 \t
+//	 1000000\t        25.30 ns/op\t       8 B/op\t       1 allocs/op\n
+func (c *jsonTestEventBuilder) attributeBenchOutput(event *TestEvent) string {
+	if event == nil || event.Action != TestEventAction_Output || event.Package == "" {
+		return ""
+	}
+	line := event.Output
+	pending, hasPending := c.benchPending[event.Package]
+	if hasPending {
+		delete(c.benchPending, event.Package)
+		line = pending + line
+	}
+	if event.Test == "" {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "Benchmark") {
+			return ""
+		}
+		name, _ := splitBenchProcs(fields[0])
+		baseName := name
+		if idx := strings.Index(name, "/"); idx >= 0 {
+			baseName = name[:idx]
+		}
+		item, _ := c.testResolver.resolveTestingItem(event.Package, baseName)
+		if item == nil {
+			return ""
+		}
+		event.Test = name
+	} else if !hasPending && !strings.HasPrefix(event.Test, "Benchmark") {
+		return ""
+	}
+	if !strings.HasSuffix(line, "\n") {
+		if c.benchPending == nil {
+			c.benchPending = make(map[string]string, 1)
+		}
+		c.benchPending[event.Package] = line
+		return ""
+	}
+	return line
 }
 
 var failRegex = regexp.MustCompile(`^FAIL\s+([^\s]+)\s+.*$`)
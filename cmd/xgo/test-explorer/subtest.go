@@ -0,0 +1,386 @@
+package test_explorer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// parseSubTests statically discovers subtests of a test,
+// names must be known from source, i.e.:
+//
+//	t.Run("name", func(t *testing.T) {...})
+//	for _, tt := range tests { t.Run(tt.name, ...) }
+//	for name, tt := range map[string]T{...} { t.Run(name, ...) }
+//
+// where tests is a composite literal, or a variable initialized
+// with one. Other subtests still appear after run.
+func parseSubTests(fset *token.FileSet, file *ast.File, parent *TestingItem, fnType *ast.FuncType, body *ast.BlockStmt) []*TestingItem {
+	p := &subTestParser{
+		fset:  fset,
+		lits:  make(map[string]*ast.CompositeLit),
+		names: make(map[string]int),
+	}
+	if file != nil {
+		p.collectCompositeLits(file)
+	}
+	return p.parse(parent, fnType, body, nil)
+}
+
+type subTestParser struct {
+	fset *token.FileSet
+	// variables initialized with composite literals
+	lits map[string]*ast.CompositeLit
+	// used names, see testing.(*matcher).unique
+	names map[string]int
+}
+
+// rangeScope is a range statement over a composite literal
+type rangeScope struct {
+	key   string
+	value string
+	lit   *ast.CompositeLit
+}
+
+// subTestName is a possible name of a t.Run call
+type subTestName struct {
+	name string
+	pos  token.Pos
+}
+
+func (c *subTestParser) parse(parent *TestingItem, fnType *ast.FuncType, body *ast.BlockStmt, scopes []*rangeScope) []*TestingItem {
+	t := testingTParam(fnType)
+	if t == "" || body == nil {
+		return nil
+	}
+	var children []*TestingItem
+	var walk func(node ast.Node, scopes []*rangeScope)
+	walk = func(node ast.Node, scopes []*rangeScope) {
+		ast.Inspect(node, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				c.addAssign(n)
+			case *ast.ValueSpec:
+				c.addValueSpec(n)
+			case *ast.RangeStmt:
+				if scope := c.rangeScopeOf(n); scope != nil {
+					walk(n.Body, append(scopes[:len(scopes):len(scopes)], scope))
+					return false
+				}
+			case *ast.CallExpr:
+				if !isRunCall(n, t) {
+					return true
+				}
+				fn, _ := n.Args[1].(*ast.FuncLit)
+				for _, name := range c.resolveNames(n.Args[0], n.Pos(), scopes) {
+					child := c.newItem(parent, name)
+					if child == nil {
+						continue
+					}
+					if fn != nil {
+						nestedScopes := scopes
+						if _, ok := n.Args[0].(*ast.BasicLit); !ok {
+							// nested names depending on the table
+							// case cannot be told apart
+							nestedScopes = nil
+						}
+						child.Children = c.parse(child, fn.Type, fn.Body, nestedScopes)
+					}
+					children = append(children, child)
+				}
+				return false
+			}
+			return true
+		})
+	}
+	walk(body, scopes)
+	return children
+}
+
+func (c *subTestParser) newItem(parent *TestingItem, name subTestName) *TestingItem {
+	subName := rewriteSubTestName(name.name)
+	if strings.Contains(subName, "/") {
+		// split into multiple levels by go test
+		return nil
+	}
+	fullName := c.unique(parent.NameUnderPkg, subName)
+	return &TestingItem{
+		Key:          fullName[len(parent.NameUnderPkg)+1:],
+		Name:         fullName[len(parent.NameUnderPkg)+1:],
+		BaseCaseName: parent.BaseCaseName,
+		NameUnderPkg: fullName,
+		RelPath:      parent.RelPath,
+		File:         parent.File,
+		Line:         c.fset.Position(name.pos).Line,
+		Kind:         TestingItemKind_Case,
+	}
+}
+
+// unique mirrors testing.(*matcher).unique, duplicated
+// names are suffixed with #01, #02...
+func (c *subTestParser) unique(parent string, subName string) string {
+	name := parent + "/" + subName
+	empty := subName == ""
+	for {
+		next, exists := c.names[name]
+		if !empty && !exists {
+			c.names[name] = 1
+			return name
+		}
+		c.names[name] = next + 1
+		name = fmt.Sprintf("%s#%02d", name, next)
+		empty = false
+	}
+}
+
+func (c *subTestParser) addAssign(assign *ast.AssignStmt) {
+	if len(assign.Lhs) != len(assign.Rhs) {
+		return
+	}
+	for i, lhs := range assign.Lhs {
+		ident, ok := lhs.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		if lit, ok := assign.Rhs[i].(*ast.CompositeLit); ok {
+			c.lits[ident.Name] = lit
+		}
+	}
+}
+
+func (c *subTestParser) addValueSpec(spec *ast.ValueSpec) {
+	if len(spec.Names) != len(spec.Values) {
+		return
+	}
+	for i, name := range spec.Names {
+		if lit, ok := spec.Values[i].(*ast.CompositeLit); ok {
+			c.lits[name.Name] = lit
+		}
+	}
+}
+
+func (c *subTestParser) rangeScopeOf(n *ast.RangeStmt) *rangeScope {
+	var lit *ast.CompositeLit
+	switch x := n.X.(type) {
+	case *ast.CompositeLit:
+		lit = x
+	case *ast.Ident:
+		lit = c.lits[x.Name]
+	}
+	if lit == nil {
+		return nil
+	}
+	scope := &rangeScope{lit: lit}
+	if key, ok := n.Key.(*ast.Ident); ok {
+		scope.key = key.Name
+	}
+	if value, ok := n.Value.(*ast.Ident); ok {
+		scope.value = value.Name
+	}
+	return scope
+}
+
+func (c *subTestParser) resolveNames(expr ast.Expr, pos token.Pos, scopes []*rangeScope) []subTestName {
+	if s, ok := stringLit(expr); ok {
+		return []subTestName{{name: s, pos: pos}}
+	}
+	var ident, field string
+	switch x := expr.(type) {
+	case *ast.Ident:
+		ident = x.Name
+	case *ast.SelectorExpr:
+		xIdent, ok := x.X.(*ast.Ident)
+		if !ok {
+			return nil
+		}
+		ident, field = xIdent.Name, x.Sel.Name
+	default:
+		return nil
+	}
+	// innermost first
+	for i := len(scopes) - 1; i >= 0; i-- {
+		scope := scopes[i]
+		if ident != scope.key && ident != scope.value {
+			continue
+		}
+		_, isMap := scope.lit.Type.(*ast.MapType)
+		var names []subTestName
+		for _, elt := range scope.lit.Elts {
+			elemKey, elemValue := elt, elt
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				elemKey, elemValue = kv.Key, kv.Value
+			}
+			var name string
+			var ok bool
+			switch {
+			case ident == scope.key && isMap && field == "":
+				name, ok = stringLit(elemKey)
+			case ident == scope.value && field == "":
+				name, ok = stringLit(elemValue)
+			case ident == scope.value:
+				name, ok = fieldOf(scope.lit, elemValue, field)
+			}
+			if ok {
+				names = append(names, subTestName{name: name, pos: elt.Pos()})
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// fieldOf returns value of a string field
+// of a struct literal element
+func fieldOf(lit *ast.CompositeLit, elem ast.Expr, field string) (string, bool) {
+	if unary, ok := elem.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+		elem = unary.X
+	}
+	elemLit, ok := elem.(*ast.CompositeLit)
+	if !ok || len(elemLit.Elts) == 0 {
+		return "", false
+	}
+	if _, ok := elemLit.Elts[0].(*ast.KeyValueExpr); ok {
+		for _, elt := range elemLit.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				continue
+			}
+			if key, ok := kv.Key.(*ast.Ident); ok && key.Name == field {
+				return stringLit(kv.Value)
+			}
+		}
+		return "", false
+	}
+	// positional fields, only works with inline struct type
+	var elemType ast.Expr
+	switch t := lit.Type.(type) {
+	case *ast.ArrayType:
+		elemType = t.Elt
+	case *ast.MapType:
+		elemType = t.Value
+	}
+	if star, ok := elemType.(*ast.StarExpr); ok {
+		elemType = star.X
+	}
+	structType, ok := elemType.(*ast.StructType)
+	if !ok {
+		return "", false
+	}
+	idx := 0
+	for _, f := range structType.Fields.List {
+		if len(f.Names) == 0 {
+			idx++
+			continue
+		}
+		for _, name := range f.Names {
+			if name.Name == field {
+				if idx >= len(elemLit.Elts) {
+					return "", false
+				}
+				return stringLit(elemLit.Elts[idx])
+			}
+			idx++
+		}
+	}
+	return "", false
+}
+
+func stringLit(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// isRunCall checks t.Run(name, fn)
+func isRunCall(call *ast.CallExpr, t string) bool {
+	if len(call.Args) != 2 {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Run" {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == t
+}
+
+// testingTParam returns name of the *testing.T param
+func testingTParam(fnType *ast.FuncType) string {
+	if fnType == nil || fnType.Params == nil || len(fnType.Params.List) != 1 {
+		return ""
+	}
+	param := fnType.Params.List[0]
+	if len(param.Names) != 1 || param.Names[0].Name == "_" {
+		return ""
+	}
+	star, ok := param.Type.(*ast.StarExpr)
+	if !ok {
+		return ""
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "T" {
+		return ""
+	}
+	return param.Names[0].Name
+}
+
+// collectCompositeLits collects package level
+// variables initialized with composite literals
+func (c *subTestParser) collectCompositeLits(file *ast.File) {
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			if valueSpec, ok := spec.(*ast.ValueSpec); ok {
+				c.addValueSpec(valueSpec)
+			}
+		}
+	}
+}
+
+// rewriteSubTestName mirrors testing.rewrite, which
+// replaces spaces with '_' and escapes non-printable runes
+func rewriteSubTestName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case isSpace(r):
+			b.WriteByte('_')
+		case !strconv.IsPrint(r):
+			q := strconv.QuoteRune(r)
+			b.WriteString(q[1 : len(q)-1])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isSpace(r rune) bool {
+	if r < 0x2000 {
+		switch r {
+		// Note: not the same as Unicode Z class.
+		case '\t', '\n', '\v', '\f', '\r', ' ', 0x85, 0xA0, 0x1680:
+			return true
+		}
+		return false
+	}
+	if r <= 0x200a {
+		return true
+	}
+	switch r {
+	case 0x2028, 0x2029, 0x202f, 0x205f, 0x3000:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package test_explorer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xhd2015/xgo/cmd/xgo/trace/render"
+	"github.com/xhd2015/xgo/cmd/xgo/trace/render/stack_model"
+	"github.com/xhd2015/xgo/support/netutil"
+)
+
+// TraceLink links a test result to the trace
+// recorded by --strace, which is rendered by /trace
+type TraceLink struct {
+	// File is relative to the trace root of the project
+	File string `json:"file"`
+	URL  string `json:"url"`
+	// ErrorURL selects the deepest errored call, only
+	// set if the test failed with an error recorded
+	ErrorURL string `json:"errorURL"`
+}
+
+type TraceRequest struct {
+	File string `json:"file"`
+	// Focus: "error" selects the deepest errored call
+	Focus string `json:"focus"`
+}
+
+type TraceResult struct {
+	HTML string `json:"html"`
+}
+
+const traceFocusError = "error"
+
+// traceResult is the result of a test that may have a trace
+type traceResult struct {
+	pkg    string
+	test   string
+	path   []string
+	failed bool
+}
+
+// getTraceRoot returns the dir holding all
+// traces of tests under projectRoot
+func getTraceRoot(projectRoot string) (string, error) {
+	return getConsistentTraceDir(projectRoot, "")
+}
+
+// newTraceLink returns nil if file does not exist
+func newTraceLink(traceRoot string, file string, failed bool) *TraceLink {
+	_, err := os.Stat(file)
+	if err != nil {
+		return nil
+	}
+	relFile, err := filepath.Rel(traceRoot, file)
+	if err != nil {
+		return nil
+	}
+	relFile = filepath.ToSlash(relFile)
+	link := &TraceLink{
+		File: relFile,
+		URL:  "/trace?" + url.Values{"file": []string{relFile}}.Encode(),
+	}
+	if failed {
+		stacks, _, err := render.ReadStacks(file)
+		if err == nil && stack_model.DeepestError(stacks) != nil {
+			link.ErrorURL = link.URL + "&focus=" + traceFocusError
+		}
+	}
+	return link
+}
+
+// linkTraces links each test to traceDir/<test>.json, tests
+// of the same name from different packages are not linked
+// because they write to the same file
+func linkTraces(traceRoot string, traceDir string, results []*traceResult) []*TestingItemEvent {
+	pkgOf := make(map[string]string, len(results))
+	ambiguous := make(map[string]bool)
+	// repeated runs report a test multiple times,
+	// any failed run fails the test
+	failed := make(map[string]bool, len(results))
+	for _, res := range results {
+		baseTest := getBaseTestName(res.test)
+		if pkg, ok := pkgOf[baseTest]; ok && pkg != res.pkg {
+			ambiguous[baseTest] = true
+		}
+		pkgOf[baseTest] = res.pkg
+		key := res.pkg + "." + res.test
+		failed[key] = failed[key] || res.failed
+	}
+	var events []*TestingItemEvent
+	linked := make(map[string]bool, len(results))
+	for _, res := range results {
+		key := res.pkg + "." + res.test
+		baseTest := getBaseTestName(res.test)
+		if ambiguous[baseTest] || linked[key] {
+			continue
+		}
+		linked[key] = true
+		link := newTraceLink(traceRoot, getTraceFile(traceDir, res.test), failed[key])
+		if link == nil && baseTest != res.test {
+			// sub tests are usually recorded
+			// in the trace of the top level test
+			link = newTraceLink(traceRoot, getTraceFile(traceDir, baseTest), failed[key])
+		}
+		if link == nil {
+			continue
+		}
+		events = append(events, &TestingItemEvent{
+			Event: Event_UpdateTrace,
+			Path:  res.path,
+			Trace: link,
+		})
+	}
+	return events
+}
+
+func getTraceFile(traceDir string, test string) string {
+	return filepath.Join(traceDir, filepath.FromSlash(test)) + ".json"
+}
+
+func getBaseTestName(test string) string {
+	if idx := strings.Index(test, "/"); idx >= 0 {
+		return test[:idx]
+	}
+	return test
+}
+
+// resolveTraceFile resolves file relative to traceRoot,
+// files outside traceRoot are rejected
+func resolveTraceFile(traceRoot string, file string) (string, error) {
+	if file == "" {
+		return "", netutil.ParamErrorf("requires file")
+	}
+	relFile := filepath.Clean(filepath.FromSlash(file))
+	if filepath.IsAbs(relFile) || relFile == ".." || strings.HasPrefix(relFile, ".."+string(filepath.Separator)) || filepath.Ext(relFile) != ".json" {
+		return "", netutil.ParamErrorf("invalid trace file: %s", file)
+	}
+	return filepath.Join(traceRoot, relFile), nil
+}
+
+func renderTrace(traceRoot string, req *TraceRequest, w io.Writer) error {
+	if req == nil {
+		return netutil.ParamErrorf("requires file")
+	}
+	if req.Focus != "" && req.Focus != traceFocusError {
+		return netutil.ParamErrorf("unrecognized focus: %s", req.Focus)
+	}
+	file, err := resolveTraceFile(traceRoot, req.File)
+	if err != nil {
+		return err
+	}
+	stacks, ok, err := render.ReadStacks(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return netutil.ParamErrorf("trace not found: %s", req.File)
+		}
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("not a stack trace: %s", req.File)
+	}
+	var opts *render.Options
+	if req.Focus == traceFocusError {
+		opts = &render.Options{Select: stack_model.DeepestError(stacks)}
+	}
+	return render.RenderStacksWithOptions(stacks, req.File, w, opts)
+}
+
+// setupTraceHandler install these endpoints:
+// /trace            ->    render trace ?file=, with ?focus=error selecting the deepest errored call
+// /openVscodeFile   ->    open ?file= and ?line= from the rendered trace
+func setupTraceHandler(server *http.ServeMux, projectRoot string) {
+	server.HandleFunc("/trace", func(w http.ResponseWriter, r *http.Request) {
+		netutil.SetCORSHeaders(w)
+		traceRoot, err := getTraceRoot(projectRoot)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		q := r.URL.Query()
+		var buf bytes.Buffer
+		err = renderTrace(traceRoot, &TraceRequest{File: q.Get("file"), Focus: q.Get("focus")}, &buf)
+		if err != nil {
+			code := http.StatusInternalServerError
+			if httpErr, ok := err.(netutil.HttpStatusErr); ok {
+				code = httpErr.HttpStatusCode()
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(buf.Bytes())
+	})
+	server.HandleFunc("/openVscodeFile", func(w http.ResponseWriter, r *http.Request) {
+		handleOpenFile(w, r, openVscode)
+	})
+}
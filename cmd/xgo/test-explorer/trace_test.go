@@ -0,0 +1,68 @@
+package test_explorer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLinkTraces(t *testing.T) {
+	traceRoot := t.TempDir()
+	traceDir := filepath.Join(traceRoot, "pkg")
+	write := func(name string, content string) {
+		file := filepath.Join(traceDir, filepath.FromSlash(name)) + ".json"
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("TestA", `{"Format":"stack","Children":[{"FuncInfo":{"Name":"TestA"}}]}`)
+	write("TestA/sub", `{"Format":"stack","Children":[{"FuncInfo":{"Name":"get"},"Error":"not found"}]}`)
+	write("TestB", `{"Format":"stack"}`)
+
+	events := linkTraces(traceRoot, traceDir, []*traceResult{
+		{pkg: "x/a", test: "TestA", path: []string{"a", "TestA"}},
+		{pkg: "x/a", test: "TestA/sub", path: []string{"a", "TestA", "sub"}, failed: true},
+		{pkg: "x/a", test: "TestA/sub", path: []string{"a", "TestA", "sub"}},
+		// recorded in the trace of TestA
+		{pkg: "x/a", test: "TestA/other", path: []string{"a", "TestA", "other"}, failed: true},
+		// ambiguous, written by two packages
+		{pkg: "x/a", test: "TestB", path: []string{"a", "TestB"}},
+		{pkg: "x/b", test: "TestB/sub", path: []string{"b", "TestB", "sub"}},
+		// no trace recorded
+		{pkg: "x/a", test: "TestC", path: []string{"a", "TestC"}},
+	})
+	var actual []string
+	for _, event := range events {
+		actual = append(actual, strings.Join(event.Path, "/")+" "+event.Trace.URL+" "+event.Trace.ErrorURL)
+	}
+	expect := []string{
+		"a/TestA /trace?file=pkg%2FTestA.json ",
+		"a/TestA/sub /trace?file=pkg%2FTestA%2Fsub.json /trace?file=pkg%2FTestA%2Fsub.json&focus=error",
+		"a/TestA/other /trace?file=pkg%2FTestA.json ",
+	}
+	if strings.Join(actual, "\n") != strings.Join(expect, "\n") {
+		t.Fatalf("expect:\n%s\nactual:\n%s", strings.Join(expect, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestResolveTraceFile(t *testing.T) {
+	root := filepath.FromSlash("/tmp/trace")
+	for file, ok := range map[string]bool{
+		"pkg/TestA.json":     true,
+		"TestA/sub.json":     true,
+		"":                   false,
+		"../TestA.json":      false,
+		"pkg/../../x.json":   false,
+		"/etc/TestA.json":    false,
+		"pkg/TestA.json.bak": false,
+	} {
+		_, err := resolveTraceFile(root, file)
+		if (err == nil) != ok {
+			t.Errorf("resolveTraceFile(%q) expect ok=%v, err: %v", file, ok, err)
+		}
+	}
+}
@@ -24,6 +24,7 @@ The commands are:
     trace          stack trace visualization
     test-explorer  test explorer
     coverage       incremental coverage tool
+    patch          validate .xgo.patch files against a GOROOT
     list           list all tools
     help           show help
 
@@ -31,6 +32,7 @@ Examples:
     xgo tool trace TestSomething.json     visualize a generated trace
     xgo tool test-explorer                open test explorer UI
     xgo tool coverage serve cover.out     visualize incremental coverage of cover.out
+    xgo tool patch check /usr/local/go    check patches resolve against a GOROOT
 
 See https://github.com/xhd2015/xgo for documentation.
 
@@ -41,6 +43,7 @@ Available tools:
   trace            visualize a generated trace
   test-explorer    open test explorer UI
   coverage         visualize incremental coverage 
+  patch            validate .xgo.patch files against a GOROOT
 `
 
 func handleTool(args []string) error {
@@ -69,6 +72,9 @@ func handleTool(args []string) error {
 		coverage.Main(args)
 		return nil
 	}
+	if tool == "patch" {
+		return handlePatchTool(args)
+	}
 	if tool == "test-explorer" {
 		return test_explorer.Main(args, &test_explorer.Options{
 			DefaultGoCommand: "xgo",
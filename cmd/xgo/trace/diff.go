@@ -0,0 +1,170 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xhd2015/xgo/cmd/xgo/trace/diff"
+)
+
+const diffHelp = `
+Xgo tool trace diff compares two generated trace files structurally.
+
+Calls are aligned by function, added and removed calls,
+changed args and results, new errors and panics, and
+slower calls are reported.
+
+Usage:
+    xgo tool trace diff [options] <old.json> <new.json>
+
+Options:
+    --all                 show calls without difference
+    --html <file>         also write the result as HTML to file
+    --time-ratio <ratio>  report a call as slower when its cost exceeds ratio times the old cost, default 1.5
+    --time-min <dur>      ignore timing changes smaller than dur, default 1ms
+    --exit-code           exit with 1 if there are differences, errors always exit with 2
+
+Lines are prefixed with:
+    +   added call
+    -   removed call
+    ~   changed call
+
+Examples:
+    xgo tool trace diff before/TestSomething.json after/TestSomething.json
+    xgo tool trace diff --html diff.html --time-ratio 2 before/TestSomething.json after/TestSomething.json
+
+`
+
+// handleDiff returns true if --exit-code is set
+// and there are differences
+func handleDiff(args []string) (bool, error) {
+	var files []string
+	var all bool
+	var exitCode bool
+	var htmlFile string
+	var timeRatio string
+	var timeMin string
+
+	n := len(args)
+	for i := 0; i < n; i++ {
+		arg := args[i]
+		if arg == "--" {
+			files = append(files, args[i+1:]...)
+			break
+		}
+		if arg == "-h" || arg == "--help" {
+			fmt.Print(strings.TrimPrefix(diffHelp, "\n"))
+			return false, nil
+		}
+		if arg == "--all" {
+			all = true
+			continue
+		}
+		if arg == "--exit-code" {
+			exitCode = true
+			continue
+		}
+		var value *string
+		switch arg {
+		case "--html":
+			value = &htmlFile
+		case "--time-ratio":
+			value = &timeRatio
+		case "--time-min":
+			value = &timeMin
+		}
+		if value != nil {
+			if i+1 >= n {
+				return false, fmt.Errorf("%s requires arg", arg)
+			}
+			*value = args[i+1]
+			i++
+			continue
+		}
+		if strings.HasPrefix(arg, "--html=") {
+			htmlFile = strings.TrimPrefix(arg, "--html=")
+			continue
+		} else if strings.HasPrefix(arg, "--time-ratio=") {
+			timeRatio = strings.TrimPrefix(arg, "--time-ratio=")
+			continue
+		} else if strings.HasPrefix(arg, "--time-min=") {
+			timeMin = strings.TrimPrefix(arg, "--time-min=")
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") {
+			files = append(files, arg)
+			continue
+		}
+		return false, fmt.Errorf("unrecognized flag: %s", arg)
+	}
+	if len(files) != 2 {
+		return false, fmt.Errorf("xgo tool trace diff requires exactly 2 files, given: %v", files)
+	}
+	opts := &diff.Options{}
+	if timeRatio != "" {
+		ratio, err := strconv.ParseFloat(timeRatio, 64)
+		if err != nil || ratio < 1 {
+			return false, fmt.Errorf("--time-ratio: expects a number not less than 1, got %q", timeRatio)
+		}
+		opts.TimeRatio = ratio
+	}
+	if timeMin != "" {
+		dur, err := time.ParseDuration(timeMin)
+		if err != nil {
+			return false, fmt.Errorf("--time-min: %w", err)
+		}
+		opts.TimeMin = dur
+	}
+
+	oldStacks, err := readStacks(files[0])
+	if err != nil {
+		return false, err
+	}
+	newStacks, err := readStacks(files[1])
+	if err != nil {
+		return false, err
+	}
+	res := diff.Compare(mergeStacks(oldStacks), mergeStacks(newStacks), opts)
+
+	w := bufio.NewWriter(os.Stdout)
+	err = diff.WriteText(w, res, &diff.TextOptions{All: all})
+	if err != nil {
+		return false, err
+	}
+	err = w.Flush()
+	if err != nil {
+		return false, err
+	}
+	if htmlFile != "" {
+		err = writeDiffHTML(htmlFile, res, &diff.HTMLOptions{
+			All:   all,
+			Title: fmt.Sprintf("Trace diff of %s and %s", files[0], files[1]),
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	return exitCode && res.HasDiff(), nil
+}
+
+func writeDiffHTML(file string, res *diff.Result, opts *diff.HTMLOptions) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	err = diff.WriteHTML(w, res, opts)
+	if err != nil {
+		return err
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
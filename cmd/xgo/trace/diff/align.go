@@ -0,0 +1,131 @@
+package diff
+
+import (
+	"github.com/xhd2015/xgo/cmd/xgo/trace/render/stack_model"
+)
+
+// lists larger than this are aligned greedily
+// instead of by the longest common subsequence
+const maxLCSCells = 4 * 1024 * 1024
+
+// align matches entries of a and b by function identity,
+// each pair is {indexA, indexB}, -1 means the entry only
+// exists on the other side.
+// pairs are ordered so that removed calls come before
+// added calls at the same position.
+func align(a []*stack_model.StackEntry, b []*stack_model.StackEntry) [][2]int {
+	keysA := make([]string, len(a))
+	for i, entry := range a {
+		keysA[i] = entryKey(entry)
+	}
+	keysB := make([]string, len(b))
+	for i, entry := range b {
+		keysB[i] = entryKey(entry)
+	}
+
+	// common prefix and suffix are matched directly
+	n, m := len(keysA), len(keysB)
+	prefix := 0
+	for prefix < n && prefix < m && keysA[prefix] == keysB[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < n-prefix && suffix < m-prefix && keysA[n-1-suffix] == keysB[m-1-suffix] {
+		suffix++
+	}
+
+	pairs := make([][2]int, 0, n+m)
+	for i := 0; i < prefix; i++ {
+		pairs = append(pairs, [2]int{i, i})
+	}
+	midA := keysA[prefix : n-suffix]
+	midB := keysB[prefix : m-suffix]
+	var matches [][2]int
+	if len(midA)*len(midB) <= maxLCSCells {
+		matches = lcs(midA, midB)
+	} else {
+		matches = greedy(midA, midB)
+	}
+	i, j := 0, 0
+	for _, match := range append(matches, [2]int{len(midA), len(midB)}) {
+		for ; i < match[0]; i++ {
+			pairs = append(pairs, [2]int{prefix + i, -1})
+		}
+		for ; j < match[1]; j++ {
+			pairs = append(pairs, [2]int{-1, prefix + j})
+		}
+		if i < len(midA) && j < len(midB) {
+			pairs = append(pairs, [2]int{prefix + i, prefix + j})
+			i++
+			j++
+		}
+	}
+	for k := 0; k < suffix; k++ {
+		pairs = append(pairs, [2]int{n - suffix + k, m - suffix + k})
+	}
+	return pairs
+}
+
+func lcs(a []string, b []string) [][2]int {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return nil
+	}
+	// dp[i][j] is the lcs length of a[i:] and b[j:]
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else if dp[i+1][j] >= dp[i][j+1] {
+				dp[i][j] = dp[i+1][j]
+			} else {
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+	var matches [][2]int
+	i, j := 0, 0
+	for i < n && j < m {
+		if a[i] == b[j] {
+			matches = append(matches, [2]int{i, j})
+			i++
+			j++
+		} else if dp[i+1][j] >= dp[i][j+1] {
+			i++
+		} else {
+			j++
+		}
+	}
+	return matches
+}
+
+// greedy matches each entry of a to the next
+// entry of b with the same key
+func greedy(a []string, b []string) [][2]int {
+	var matches [][2]int
+	j := 0
+	for i, key := range a {
+		for k := j; k < len(b); k++ {
+			if b[k] == key {
+				matches = append(matches, [2]int{i, k})
+				j = k + 1
+				break
+			}
+		}
+	}
+	return matches
+}
+
+func nonNil(entries []*stack_model.StackEntry) []*stack_model.StackEntry {
+	res := make([]*stack_model.StackEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry != nil {
+			res = append(res, entry)
+		}
+	}
+	return res
+}
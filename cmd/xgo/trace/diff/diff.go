@@ -0,0 +1,261 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/xhd2015/xgo/cmd/xgo/trace/render/stack_model"
+)
+
+type Kind string
+
+const (
+	Kind_Same    Kind = "same"
+	Kind_Added   Kind = "added"
+	Kind_Removed Kind = "removed"
+	// Kind_Changed means the call exists in both
+	// stacks, but some fields are different
+	Kind_Changed Kind = "changed"
+)
+
+type Field string
+
+const (
+	Field_Args     Field = "args"
+	Field_Results  Field = "results"
+	Field_Error    Field = "error"
+	Field_Panic    Field = "panic"
+	Field_Repeated Field = "repeated"
+	Field_Time     Field = "time"
+)
+
+type Options struct {
+	// TimeRatio reports a call as slower when its cost
+	// exceeds TimeRatio times the old cost, default 1.5
+	TimeRatio float64
+	// TimeMin ignores timing changes smaller than TimeMin,
+	// default 1ms
+	TimeMin time.Duration
+}
+
+const (
+	defaultTimeRatio = 1.5
+	defaultTimeMin   = time.Millisecond
+)
+
+type Change struct {
+	Field Field
+	Old   string
+	New   string
+}
+
+// Node is a call aligned between the old stack(A)
+// and the new stack(B), A is nil for added calls,
+// B is nil for removed calls
+type Node struct {
+	Kind     Kind
+	A        *stack_model.StackEntry
+	B        *stack_model.StackEntry
+	Changes  []*Change
+	Children []*Node
+
+	// HasDiff is true if the node or any of
+	// its children is different
+	HasDiff bool
+}
+
+type Summary struct {
+	Added          int
+	Removed        int
+	ArgsChanged    int
+	ResultsChanged int
+	NewErrors      int
+	NewPanics      int
+	Slower         int
+}
+
+type Result struct {
+	Roots   []*Node
+	Summary Summary
+}
+
+func (c *Result) HasDiff() bool {
+	for _, root := range c.Roots {
+		if root.HasDiff {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare aligns the call trees of a and b by function
+// identity, and reports the differences
+func Compare(a *stack_model.Stack, b *stack_model.Stack, opts *Options) *Result {
+	c := &comparer{
+		timeRatio: defaultTimeRatio,
+		timeMin:   defaultTimeMin,
+	}
+	if opts != nil {
+		if opts.TimeRatio > 0 {
+			c.timeRatio = opts.TimeRatio
+		}
+		if opts.TimeMin > 0 {
+			c.timeMin = opts.TimeMin
+		}
+	}
+	var aChildren, bChildren []*stack_model.StackEntry
+	if a != nil {
+		aChildren = a.Children
+	}
+	if b != nil {
+		bChildren = b.Children
+	}
+	res := &Result{}
+	c.summary = &res.Summary
+	res.Roots = c.compareList(aChildren, bChildren)
+	return res
+}
+
+type comparer struct {
+	timeRatio float64
+	timeMin   time.Duration
+	summary   *Summary
+}
+
+func (c *comparer) compareList(a []*stack_model.StackEntry, b []*stack_model.StackEntry) []*Node {
+	a = nonNil(a)
+	b = nonNil(b)
+	pairs := align(a, b)
+	nodes := make([]*Node, 0, len(pairs))
+	for _, p := range pairs {
+		var node *Node
+		if p[0] < 0 {
+			node = c.single(Kind_Added, b[p[1]])
+			c.summary.Added++
+		} else if p[1] < 0 {
+			node = c.single(Kind_Removed, a[p[0]])
+			c.summary.Removed++
+		} else {
+			node = c.compare(a[p[0]], b[p[1]])
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// single creates a node for an added or removed subtree
+func (c *comparer) single(kind Kind, entry *stack_model.StackEntry) *Node {
+	node := &Node{
+		Kind:    kind,
+		HasDiff: true,
+	}
+	if kind == Kind_Added {
+		node.B = entry
+	} else {
+		node.A = entry
+	}
+	for _, child := range nonNil(entry.Children) {
+		node.Children = append(node.Children, c.single(kind, child))
+	}
+	return node
+}
+
+func (c *comparer) compare(a *stack_model.StackEntry, b *stack_model.StackEntry) *Node {
+	node := &Node{
+		Kind: Kind_Same,
+		A:    a,
+		B:    b,
+	}
+	addChange := func(field Field, old string, new string) {
+		node.Changes = append(node.Changes, &Change{Field: field, Old: old, New: new})
+	}
+	if oldArgs, newArgs := jsonValue(a.Args), jsonValue(b.Args); oldArgs != newArgs {
+		addChange(Field_Args, oldArgs, newArgs)
+		c.summary.ArgsChanged++
+	}
+	if oldResults, newResults := jsonValue(a.Results), jsonValue(b.Results); oldResults != newResults {
+		addChange(Field_Results, oldResults, newResults)
+		c.summary.ResultsChanged++
+	}
+	if a.Panic != b.Panic {
+		addChange(Field_Panic, fmt.Sprint(a.Panic), fmt.Sprint(b.Panic))
+		if b.Panic {
+			c.summary.NewPanics++
+		}
+	}
+	if a.Error != b.Error {
+		addChange(Field_Error, a.Error, b.Error)
+		if a.Error == "" {
+			c.summary.NewErrors++
+		}
+	}
+	if a.Repeated != b.Repeated {
+		addChange(Field_Repeated, fmt.Sprintf("x%d", a.Repeated+1), fmt.Sprintf("x%d", b.Repeated+1))
+	}
+	oldCost := time.Duration(a.EndNs - a.BeginNs)
+	newCost := time.Duration(b.EndNs - b.BeginNs)
+	if newCost-oldCost >= c.timeMin && float64(newCost) > float64(oldCost)*c.timeRatio {
+		addChange(Field_Time, oldCost.String(), newCost.String())
+		c.summary.Slower++
+	}
+
+	node.Children = c.compareList(a.Children, b.Children)
+	if len(node.Changes) > 0 {
+		node.Kind = Kind_Changed
+		node.HasDiff = true
+	}
+	for _, child := range node.Children {
+		if child.HasDiff {
+			node.HasDiff = true
+			break
+		}
+	}
+	return node
+}
+
+// Name returns the function name of the node
+func (c *Node) Name() string {
+	entry := c.B
+	if entry == nil {
+		entry = c.A
+	}
+	return entryName(entry)
+}
+
+// Entry returns B if present, otherwise A
+func (c *Node) Entry() *stack_model.StackEntry {
+	if c.B != nil {
+		return c.B
+	}
+	return c.A
+}
+
+func entryName(entry *stack_model.StackEntry) string {
+	if entry == nil || entry.FuncInfo == nil || entry.FuncInfo.Name == "" {
+		return "<unknown>"
+	}
+	return entry.FuncInfo.Name
+}
+
+// entryKey identifies a function
+func entryKey(entry *stack_model.StackEntry) string {
+	if entry == nil || entry.FuncInfo == nil {
+		return ""
+	}
+	return entry.FuncInfo.Pkg + "." + entry.FuncInfo.Name
+}
+
+// jsonValue formats args and results, map keys
+// are sorted by json.Marshal so equal values
+// give the same string
+func jsonValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return string(data)
+}
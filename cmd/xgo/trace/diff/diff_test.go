@@ -0,0 +1,161 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/xgo/cmd/xgo/trace/render/stack_model"
+)
+
+func call(name string, beginNs int64, endNs int64, children ...*stack_model.StackEntry) *stack_model.StackEntry {
+	return &stack_model.StackEntry{
+		FuncInfo: &stack_model.FuncInfo{Kind: stack_model.FuncKind_Func, Pkg: "example.com/app", Name: name},
+		BeginNs:  beginNs,
+		EndNs:    endNs,
+		Children: children,
+	}
+}
+
+func withArgs(entry *stack_model.StackEntry, args interface{}, results interface{}) *stack_model.StackEntry {
+	entry.Args = args
+	entry.Results = results
+	return entry
+}
+
+func TestCompare(t *testing.T) {
+	old := &stack_model.Stack{
+		Children: []*stack_model.StackEntry{
+			call("Handle", 0, 10e6,
+				withArgs(call("Get", 0, 1e6), map[string]interface{}{"id": 1}, map[string]interface{}{"name": "a"}),
+				call("Log", 1e6, 2e6),
+				call("Save", 2e6, 3e6),
+			),
+		},
+	}
+	slow := call("Save", 2e6, 12e6)
+	slow.Error = "disk full"
+	newStack := &stack_model.Stack{
+		Children: []*stack_model.StackEntry{
+			call("Handle", 0, 20e6,
+				withArgs(call("Get", 0, 1e6), map[string]interface{}{"id": 2}, map[string]interface{}{"name": "a"}),
+				call("Validate", 1e6, 2e6),
+				slow,
+			),
+		},
+	}
+
+	res := Compare(old, newStack, nil)
+	if !res.HasDiff() {
+		t.Fatalf("expect diff")
+	}
+	want := Summary{
+		Added:       1,
+		Removed:     1,
+		ArgsChanged: 1,
+		NewErrors:   1,
+		// Handle and Save
+		Slower: 2,
+	}
+	if res.Summary != want {
+		t.Errorf("expect summary %+v, actual: %+v", want, res.Summary)
+	}
+
+	handle := res.Roots[0]
+	var kinds []string
+	for _, child := range handle.Children {
+		kinds = append(kinds, string(child.Kind)+":"+child.Name())
+	}
+	expectKinds := "changed:Get,removed:Log,added:Validate,changed:Save"
+	if strings.Join(kinds, ",") != expectKinds {
+		t.Errorf("expect %s, actual: %s", expectKinds, strings.Join(kinds, ","))
+	}
+
+	var buf bytes.Buffer
+	err := WriteText(&buf, res, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := buf.String()
+	for _, line := range []string{
+		"~ Handle 20ms",
+		`~   Get 1ms`,
+		`      args: {"id":1} -> {"id":2}`,
+		"-   Log 1ms",
+		"+   Validate 1ms",
+		"      error: <none> -> disk full",
+		"      time: 1ms -> 10ms",
+		"1 added, 1 removed, 1 args changed, 1 new errors, 2 slower",
+	} {
+		if !strings.Contains(text, line) {
+			t.Errorf("expect text to contain %q, actual:\n%s", line, text)
+		}
+	}
+
+	buf.Reset()
+	err = WriteHTML(&buf, res, &HTMLOptions{Title: "a.json <-> b.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, s := range []string{
+		"a.json &lt;-&gt; b.json",
+		`<li class="diff-node added">`,
+		`<li class="diff-node removed">`,
+		`<td class="new">{&#34;id&#34;:2}</td>`,
+	} {
+		if !strings.Contains(page, s) {
+			t.Errorf("expect html to contain %q", s)
+		}
+	}
+}
+
+func TestCompareSame(t *testing.T) {
+	stack := &stack_model.Stack{
+		Children: []*stack_model.StackEntry{
+			call("Handle", 0, 10e6, call("Get", 0, 1e6)),
+		},
+	}
+	res := Compare(stack, stack, nil)
+	if res.HasDiff() {
+		t.Fatalf("expect no diff")
+	}
+
+	var buf bytes.Buffer
+	err := WriteText(&buf, res, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(buf.String()) != "no difference" {
+		t.Errorf("expect no difference, actual: %s", buf.String())
+	}
+
+	buf.Reset()
+	err = WriteText(&buf, res, &TextOptions{All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "    Get 1ms") {
+		t.Errorf("expect all calls shown, actual: %s", buf.String())
+	}
+}
+
+func TestAlign(t *testing.T) {
+	entries := func(names ...string) []*stack_model.StackEntry {
+		list := make([]*stack_model.StackEntry, len(names))
+		for i, name := range names {
+			list[i] = call(name, 0, 0)
+		}
+		return list
+	}
+	pairs := align(entries("A", "B", "C", "B", "D"), entries("A", "C", "E", "B", "D"))
+	expect := [][2]int{{0, 0}, {1, -1}, {2, 1}, {-1, 2}, {3, 3}, {4, 4}}
+	if len(pairs) != len(expect) {
+		t.Fatalf("expect %v, actual: %v", expect, pairs)
+	}
+	for i := range pairs {
+		if pairs[i] != expect[i] {
+			t.Fatalf("expect %v, actual: %v", expect, pairs)
+		}
+	}
+}
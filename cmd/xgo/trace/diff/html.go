@@ -0,0 +1,111 @@
+package diff
+
+import (
+	_ "embed"
+	"fmt"
+	"html"
+	"io"
+	"time"
+)
+
+//go:embed style.css
+var styles string
+
+type HTMLOptions struct {
+	// All shows calls without difference
+	All bool
+	// Title of the page, typically the compared files
+	Title string
+}
+
+// WriteHTML writes the result as a standalone HTML page,
+// calls with differences are expanded
+func WriteHTML(w io.Writer, res *Result, opts *HTMLOptions) error {
+	hw := &htmlWriter{w: w}
+	var title string
+	if opts != nil {
+		hw.all = opts.All
+		title = opts.Title
+	}
+	if title == "" {
+		title = "Trace diff"
+	}
+	hw.printf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>%s</title>
+<style>
+%s
+</style>
+</head>
+<body>
+`, html.EscapeString(title), styles)
+	hw.printf("<h3>%s</h3>\n", html.EscapeString(title))
+	hw.printf(`<div class="summary">%s</div>`+"\n", html.EscapeString(FormatSummary(&res.Summary)))
+	hw.printf(`<ul class="diff-tree">` + "\n")
+	for _, root := range res.Roots {
+		hw.writeNode(root)
+	}
+	hw.printf("</ul>\n</body>\n</html>\n")
+	return hw.err
+}
+
+type htmlWriter struct {
+	w   io.Writer
+	all bool
+	err error
+}
+
+func (c *htmlWriter) printf(format string, args ...interface{}) {
+	if c.err != nil {
+		return
+	}
+	_, c.err = fmt.Fprintf(c.w, format, args...)
+}
+
+func (c *htmlWriter) writeNode(node *Node) {
+	if !node.HasDiff && !c.all {
+		return
+	}
+	entry := node.Entry()
+	head := fmt.Sprintf(`<span class="mark">%s</span><span class="name" title="%s">%s</span><span class="cost">%s</span>`,
+		html.EscapeString(mark(node.Kind)),
+		html.EscapeString(entryKey(entry)),
+		html.EscapeString(node.Name()),
+		time.Duration(entry.EndNs-entry.BeginNs),
+	)
+	var children []*Node
+	for _, child := range node.Children {
+		if child.HasDiff || c.all {
+			children = append(children, child)
+		}
+	}
+
+	c.printf(`<li class="diff-node %s">`, node.Kind)
+	if len(children) > 0 {
+		c.printf("<details open><summary>%s</summary>\n", head)
+	} else {
+		c.printf(`<div class="head">%s</div>`+"\n", head)
+	}
+	if len(node.Changes) > 0 {
+		c.printf(`<table class="changes">` + "\n")
+		for _, change := range node.Changes {
+			c.printf(`<tr><td class="field">%s</td><td class="old">%s</td><td class="new">%s</td></tr>`+"\n",
+				html.EscapeString(string(change.Field)),
+				html.EscapeString(formatValue(change.Old)),
+				html.EscapeString(formatValue(change.New)),
+			)
+		}
+		c.printf("</table>\n")
+	}
+	if len(children) > 0 {
+		c.printf(`<ul class="diff-sub-tree">` + "\n")
+		for _, child := range children {
+			c.writeNode(child)
+		}
+		c.printf("</ul></details>")
+	}
+	c.printf("</li>\n")
+}
@@ -0,0 +1,109 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// max length of args and results shown in terminal
+const maxValueLen = 200
+
+type TextOptions struct {
+	// All shows calls without difference
+	All bool
+}
+
+// WriteText writes the result as an indented tree,
+// each line is prefixed with a mark:
+//
+//	' ' same, '+' added, '-' removed, '~' changed
+func WriteText(w io.Writer, res *Result, opts *TextOptions) error {
+	var all bool
+	if opts != nil {
+		all = opts.All
+	}
+	tw := &textWriter{w: w, all: all}
+	for _, root := range res.Roots {
+		tw.writeNode(root, 0)
+	}
+	tw.printf("\n%s\n", FormatSummary(&res.Summary))
+	return tw.err
+}
+
+// FormatSummary returns a one-line description
+// of the summary
+func FormatSummary(s *Summary) string {
+	var parts []string
+	add := func(n int, desc string) {
+		if n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, desc))
+		}
+	}
+	add(s.Added, "added")
+	add(s.Removed, "removed")
+	add(s.ArgsChanged, "args changed")
+	add(s.ResultsChanged, "results changed")
+	add(s.NewErrors, "new errors")
+	add(s.NewPanics, "new panics")
+	add(s.Slower, "slower")
+	if len(parts) == 0 {
+		return "no difference"
+	}
+	return strings.Join(parts, ", ")
+}
+
+type textWriter struct {
+	w   io.Writer
+	all bool
+	err error
+}
+
+func (c *textWriter) printf(format string, args ...interface{}) {
+	if c.err != nil {
+		return
+	}
+	_, c.err = fmt.Fprintf(c.w, format, args...)
+}
+
+func (c *textWriter) writeNode(node *Node, depth int) {
+	if !node.HasDiff && !c.all {
+		return
+	}
+	indent := strings.Repeat("  ", depth)
+	entry := node.Entry()
+	c.printf("%s %s%s %s\n", mark(node.Kind), indent, node.Name(), time.Duration(entry.EndNs-entry.BeginNs))
+	for _, change := range node.Changes {
+		c.printf("  %s  %s: %s -> %s\n", indent, change.Field, shorten(formatValue(change.Old)), shorten(formatValue(change.New)))
+	}
+	for _, child := range node.Children {
+		c.writeNode(child, depth+1)
+	}
+}
+
+func mark(kind Kind) string {
+	switch kind {
+	case Kind_Added:
+		return "+"
+	case Kind_Removed:
+		return "-"
+	case Kind_Changed:
+		return "~"
+	}
+	return " "
+}
+
+func formatValue(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+func shorten(s string) string {
+	if len(s) <= maxValueLen {
+		return s
+	}
+	return s[:maxValueLen] + "..."
+}
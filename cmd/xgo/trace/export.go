@@ -0,0 +1,172 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xhd2015/xgo/cmd/xgo/trace/render"
+	"github.com/xhd2015/xgo/cmd/xgo/trace/render/stack_model"
+)
+
+const exportHelp = `
+Xgo tool trace export converts a generated trace file to other formats.
+
+Usage:
+    xgo tool trace export [options] <file>
+
+Options:
+    --format <format>  output format: json, chrome or otlp
+    -o <file>          output file, default is stdout
+
+Formats:
+    json     the format generated by xgo, can be visualized by xgo tool trace
+    chrome   Chrome Trace Event format, can be opened by chrome://tracing or https://ui.perfetto.dev
+    otlp     OpenTelemetry OTLP/JSON, can be posted to an OTLP/HTTP collector
+
+Examples:
+    xgo tool trace export --format=chrome -o trace.json TestSomething.json
+    xgo tool trace export --format=otlp TestSomething.json | curl -H 'Content-Type: application/json' --data-binary @- http://localhost:4318/v1/traces
+
+`
+
+func handleExport(args []string) error {
+	var files []string
+	var format string
+	var outFile string
+
+	n := len(args)
+	for i := 0; i < n; i++ {
+		arg := args[i]
+		if arg == "--" {
+			files = append(files, args[i+1:]...)
+			break
+		}
+		if arg == "-h" || arg == "--help" {
+			fmt.Print(strings.TrimPrefix(exportHelp, "\n"))
+			return nil
+		}
+		if arg == "--format" || arg == "-o" {
+			if i+1 >= n {
+				return fmt.Errorf("%s requires arg", arg)
+			}
+			if arg == "--format" {
+				format = args[i+1]
+			} else {
+				outFile = args[i+1]
+			}
+			i++
+			continue
+		} else if strings.HasPrefix(arg, "--format=") {
+			format = strings.TrimPrefix(arg, "--format=")
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") {
+			files = append(files, arg)
+			continue
+		}
+		return fmt.Errorf("unrecognized flag: %s", arg)
+	}
+	if format == "" {
+		return fmt.Errorf("requires --format, available: json,chrome,otlp")
+	}
+	exportFormat, err := stack_model.ParseExportFormat(format)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("requires file")
+	}
+	if len(files) != 1 {
+		return fmt.Errorf("xgo tool trace export requires exactly 1 file, given: %v", files)
+	}
+	stacks, err := readStacks(files[0])
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if outFile != "" {
+		file, err := os.Create(outFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	bw := bufio.NewWriter(w)
+	err = stack_model.Export(mergeStacks(stacks), exportFormat, bw)
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// readStacks reads stacks from file, legacy
+// records are converted to stack
+func readStacks(file string) ([]*stack_model.Stack, error) {
+	stacks, ok, err := render.ReadStacks(file)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return stacks, nil
+	}
+	record, err := parseRecord(file)
+	if err != nil {
+		return nil, err
+	}
+	return []*stack_model.Stack{convert(record)}, nil
+}
+
+// mergeStacks combines stacks of the same file into
+// one, entries are shifted to be relative to the
+// earliest begin time
+func mergeStacks(stacks []*stack_model.Stack) *stack_model.Stack {
+	if len(stacks) == 1 {
+		return stacks[0]
+	}
+	var begin time.Time
+	begins := make([]time.Time, len(stacks))
+	for i, stack := range stacks {
+		t, err := time.Parse(time.RFC3339, stack.Begin)
+		if err != nil {
+			continue
+		}
+		begins[i] = t
+		if begin.IsZero() || t.Before(begin) {
+			begin = t
+		}
+	}
+	merged := &stack_model.Stack{
+		Format: "stack",
+	}
+	if !begin.IsZero() {
+		merged.Begin = begin.Format(time.RFC3339)
+	}
+	for i, stack := range stacks {
+		var offsetNs int64
+		if !begins[i].IsZero() {
+			offsetNs = begins[i].Sub(begin).Nanoseconds()
+		}
+		for _, entry := range stack.Children {
+			shiftEntry(entry, offsetNs)
+		}
+		merged.Children = append(merged.Children, stack.Children...)
+	}
+	return merged
+}
+
+func shiftEntry(entry *stack_model.StackEntry, offsetNs int64) {
+	if entry == nil || offsetNs == 0 {
+		return
+	}
+	entry.BeginNs += offsetNs
+	entry.EndNs += offsetNs
+	for _, child := range entry.Children {
+		shiftEntry(child, offsetNs)
+	}
+}
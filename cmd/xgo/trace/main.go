@@ -24,6 +24,8 @@ Xgo tool trace visualize a generated trace file.
 
 Usage:
     xgo tool trace [options] <file>
+    xgo tool trace export [options] <file>
+    xgo tool trace diff [options] <old.json> <new.json>
 
 Options:
     -v, --version <version>  specify the version of the trace file, default is 1.0
@@ -31,12 +33,37 @@ Options:
 Examples:
     xgo test -run TestSomething --strace ./   generate trace file
     xgo tool trace TestSomething.json         visualize a generated trace
+    xgo tool trace export --format=chrome -o chrome.json TestSomething.json
+                                              export a generated trace to Chrome Trace Event format,
+                                              see xgo tool trace export --help
+    xgo tool trace diff before.json after.json
+                                              compare two generated traces,
+                                              see xgo tool trace diff --help
 
 See https://github.com/xhd2015/xgo for documentation.
 
 `
 
 func Main(args []string) {
+	if len(args) > 0 && args[0] == "export" {
+		err := handleExport(args[1:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "diff" {
+		hasDiff, err := handleDiff(args[1:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+		if hasDiff {
+			os.Exit(1)
+		}
+		return
+	}
 	var files []string
 	var port string
 	var bind string
@@ -130,24 +157,12 @@ func serveFile(bindStr string, portStr string, file string) error {
 				io.WriteString(w, fmt.Sprintf("<pre>panic: %v\n%s</pre>", e, stack))
 			}
 		}()
-		var stack *stack_model.Stack
-
-		stacks, ok, err := render.ReadStacks(file)
+		stacks, err := readStacks(file)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			io.WriteString(w, fmt.Sprintf("%v", err))
 			return
 		}
-		if !ok {
-			record, err := parseRecord(file)
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				io.WriteString(w, fmt.Sprintf("%v", err))
-				return
-			}
-			stack = convert(record)
-			stacks = []*stack_model.Stack{stack}
-		}
 		w.Header().Set("Content-Type", "text/html")
 		render.RenderStacks(stacks, file, w)
 	})
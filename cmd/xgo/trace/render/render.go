@@ -69,7 +69,18 @@ func RenderFile(file string, w io.Writer) error {
 	return RenderStacks(stacks, file, w)
 }
 
-func RenderStacks(stacks []*stack_model.Stack, file string, w io.Writer) (err error) {
+// Options controls how stacks are rendered
+type Options struct {
+	// Select is selected and scrolled into
+	// view when the page loads
+	Select *stack_model.StackEntry
+}
+
+func RenderStacks(stacks []*stack_model.Stack, file string, w io.Writer) error {
+	return RenderStacksWithOptions(stacks, file, w, nil)
+}
+
+func RenderStacksWithOptions(stacks []*stack_model.Stack, file string, w io.Writer, opts *Options) (err error) {
 	defer func() {
 		if e := recover(); e != nil {
 			if pe, ok := e.(error); ok {
@@ -147,6 +158,12 @@ func RenderStacks(stacks []*stack_model.Stack, file string, w io.Writer) (err er
 	}
 
 	h(script)
+	if opts != nil && opts.Select != nil {
+		if id, ok := traceIDMapping[opts.Select]; ok {
+			h(fmt.Sprintf(` onClickHead("%d")`, id))
+			h(fmt.Sprintf(` document.getElementById(getHeadID("%d")).scrollIntoView({block: "center"})`, id))
+		}
+	}
 	h("}")
 	h("</script>")
 
@@ -45,7 +45,7 @@ func renderItem(h func(string), stack *stack_model.StackEntry, traceIDMapping ma
 	<div class="head-info" id="head_%d" onclick="onClickHead('%d')">
 		<div class="%s"></div>
 		<span class="head-name">%s</span>
-		<span class="head-cost">%s</span>
+		<span class="head-cost">%s</span>%s
 	</div>
 	</div>
 	`,
@@ -54,6 +54,7 @@ func renderItem(h func(string), stack *stack_model.StackEntry, traceIDMapping ma
 		headClass,
 		html.EscapeString(name),
 		formatCost(stack.BeginNs, stack.EndNs),
+		renderBadges(stack),
 	))
 
 	if len(stack.Children) == 0 {
@@ -67,3 +68,18 @@ func renderItem(h func(string), stack *stack_model.StackEntry, traceIDMapping ma
 	}
 	h("</ul>")
 }
+
+// renderBadges shows entries affected by trace limits
+func renderBadges(stack *stack_model.StackEntry) string {
+	var badges string
+	if stack.Repeated > 0 {
+		badges += fmt.Sprintf(`<span class="head-badge" title="%d identical calls collapsed">x%d</span>`, stack.Repeated+1, stack.Repeated+1)
+	}
+	if stack.Omitted > 0 {
+		badges += fmt.Sprintf(`<span class="head-badge" title="child calls omitted by trace limits">+%d omitted</span>`, stack.Omitted)
+	}
+	if stack.Truncated {
+		badges += `<span class="head-badge truncated" title="args or results exceeded the size limit">truncated</span>`
+	}
+	return badges
+}
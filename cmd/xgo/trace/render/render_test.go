@@ -3,7 +3,10 @@ package render
 import (
 	"bytes"
 	"encoding/json"
+	"strings"
 	"testing"
+
+	"github.com/xhd2015/xgo/cmd/xgo/trace/render/stack_model"
 )
 
 // see https://github.com/xhd2015/xgo/issues/351
@@ -68,3 +71,36 @@ func TestReadStacksFromReader_LargeNumber(t *testing.T) {
 		t.Errorf("expected __xgo_res to be %q, got %q", expected, xgoRes)
 	}
 }
+
+func TestRenderStacksSelect(t *testing.T) {
+	failed := &stack_model.StackEntry{
+		FuncInfo: &stack_model.FuncInfo{Name: "Get"},
+		Error:    "not found",
+	}
+	stacks := []*stack_model.Stack{{
+		Format: "stack",
+		Children: []*stack_model.StackEntry{{
+			FuncInfo: &stack_model.FuncInfo{Name: "TestGet"},
+			Children: []*stack_model.StackEntry{failed},
+		}},
+	}}
+
+	var buf bytes.Buffer
+	err := RenderStacksWithOptions(stacks, "TestGet.json", &buf, &Options{Select: failed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ids: <root>=1, TestGet=2, Get=3
+	if !strings.Contains(buf.String(), `onClickHead("3")`) {
+		t.Fatalf("expect Get to be selected")
+	}
+
+	buf.Reset()
+	err = RenderStacks(stacks, "TestGet.json", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), `onClickHead("3")`) {
+		t.Fatalf("expect nothing selected")
+	}
+}
@@ -0,0 +1,30 @@
+package stack_model
+
+// DeepestError finds the deepest entry with an error or panic,
+// which is usually where a failure originates, errors of
+// its callers are just propagated. Among entries of the
+// same depth, the last one is returned because it is
+// the closest to the end of the test.
+func DeepestError(stacks []*Stack) *StackEntry {
+	var found *StackEntry
+	foundDepth := -1
+	var walk func(entries []*StackEntry, depth int)
+	walk = func(entries []*StackEntry, depth int) {
+		for _, entry := range entries {
+			if entry == nil {
+				continue
+			}
+			if (entry.Error != "" || entry.Panic) && depth >= foundDepth {
+				found = entry
+				foundDepth = depth
+			}
+			walk(entry.Children, depth+1)
+		}
+	}
+	for _, stack := range stacks {
+		if stack != nil {
+			walk(stack.Children, 0)
+		}
+	}
+	return found
+}
@@ -0,0 +1,26 @@
+package stack_model
+
+import "testing"
+
+func TestDeepestError(t *testing.T) {
+	stack := testStack()
+	// same depth, the last wins
+	entry := DeepestError([]*Stack{stack})
+	if entry == nil || entry.FuncInfo.Name != "crash" {
+		t.Fatalf("expect crash, actual: %+v", entry)
+	}
+
+	get := stack.Children[0].Children[0]
+	get.Children = []*StackEntry{
+		{FuncInfo: &FuncInfo{Name: "query"}},
+		{FuncInfo: &FuncInfo{Name: "scan"}, Error: "no rows"},
+	}
+	entry = DeepestError([]*Stack{stack})
+	if entry == nil || entry.FuncInfo.Name != "scan" {
+		t.Fatalf("expect scan, actual: %+v", entry)
+	}
+
+	if entry := DeepestError([]*Stack{{Children: []*StackEntry{{FuncInfo: &FuncInfo{Name: "ok"}}}}}); entry != nil {
+		t.Fatalf("expect no error, actual: %+v", entry)
+	}
+}
@@ -0,0 +1,125 @@
+package stack_model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ExportFormat is the format a Stack can be exported to
+type ExportFormat string
+
+const (
+	// ExportFormat_JSON is the native format, which
+	// can be opened by `xgo tool trace`
+	ExportFormat_JSON ExportFormat = "json"
+	// ExportFormat_Chrome is the Chrome Trace Event format,
+	// which can be opened by chrome://tracing or https://ui.perfetto.dev
+	ExportFormat_Chrome ExportFormat = "chrome"
+	// ExportFormat_OTLP is the OpenTelemetry OTLP/JSON format,
+	// which can be posted to any OTLP/HTTP collector,
+	// e.g. http://localhost:4318/v1/traces of a jaeger all-in-one
+	ExportFormat_OTLP ExportFormat = "otlp"
+)
+
+// ParseExportFormat parses format, an empty format is ExportFormat_JSON
+func ParseExportFormat(format string) (ExportFormat, error) {
+	switch ExportFormat(format) {
+	case "", ExportFormat_JSON:
+		return ExportFormat_JSON, nil
+	case ExportFormat_Chrome, ExportFormat_OTLP:
+		return ExportFormat(format), nil
+	}
+	return "", fmt.Errorf("unsupported trace format: %s, available: json,chrome,otlp", format)
+}
+
+// Export writes stack to w in the given format,
+// entries are written as they are visited so that
+// large stacks do not need to be buffered
+func Export(stack *Stack, format ExportFormat, w io.Writer) error {
+	switch format {
+	case "", ExportFormat_JSON:
+		return json.NewEncoder(w).Encode(stack)
+	case ExportFormat_Chrome:
+		return ExportChrome(stack, w)
+	case ExportFormat_OTLP:
+		return ExportOTLP(stack, w)
+	}
+	return fmt.Errorf("unsupported trace format: %s", format)
+}
+
+// exportWriter writes json values separated by comma,
+// the first error is kept and later writes are ignored
+type exportWriter struct {
+	w     io.Writer
+	err   error
+	first bool
+}
+
+func (c *exportWriter) raw(s string) {
+	if c.err != nil {
+		return
+	}
+	_, c.err = io.WriteString(c.w, s)
+}
+
+func (c *exportWriter) item(v interface{}) {
+	if c.err != nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		c.err = err
+		return
+	}
+	if !c.first {
+		c.raw(",")
+	}
+	c.first = false
+	if c.err != nil {
+		return
+	}
+	_, c.err = c.w.Write(data)
+}
+
+func (c *exportWriter) beginList(prefix string) {
+	c.raw(prefix)
+	c.first = true
+}
+
+// stackBegin returns the absolute begin time of the stack,
+// which all entries' BeginNs and EndNs are relative to
+func stackBegin(stack *Stack) time.Time {
+	if stack.Begin == "" {
+		return time.Time{}
+	}
+	begin, err := time.Parse(time.RFC3339, stack.Begin)
+	if err != nil {
+		return time.Time{}
+	}
+	return begin
+}
+
+// jsonString encodes args or results as compact json text,
+// returns empty if v is nil
+func jsonString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if raw, ok := v.(json.RawMessage); ok && len(raw) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return string(data)
+}
+
+func entryName(funcInfo *FuncInfo) string {
+	if funcInfo == nil {
+		return "<unknown>"
+	}
+	return funcInfo.Name
+}
@@ -0,0 +1,79 @@
+package stack_model
+
+import (
+	"io"
+)
+
+// see https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+type chromeEvent struct {
+	Name string      `json:"name"`
+	Cat  string      `json:"cat,omitempty"`
+	Ph   string      `json:"ph"`
+	Ts   float64     `json:"ts"`  // us
+	Dur  float64     `json:"dur"` // us
+	Pid  int         `json:"pid"`
+	Tid  int         `json:"tid"`
+	Args *chromeArgs `json:"args,omitempty"`
+}
+
+type chromeArgs struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Args    string `json:"args,omitempty"`
+	Results string `json:"results,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Panic   bool   `json:"panic,omitempty"`
+
+	Omitted   int  `json:"omitted,omitempty"`
+	Repeated  int  `json:"repeated,omitempty"`
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ExportChrome writes stack in the Chrome Trace Event format,
+// each StackEntry becomes a complete event("ph":"X")
+func ExportChrome(stack *Stack, w io.Writer) error {
+	ew := &exportWriter{w: w}
+	ew.beginList(`{"displayTimeUnit":"ns","traceEvents":[`)
+	if stack != nil {
+		// timestamps are relative to the begin of the stack
+		exportChromeEntries(ew, stack.Children)
+	}
+	ew.raw("]}\n")
+	return ew.err
+}
+
+func exportChromeEntries(ew *exportWriter, entries []*StackEntry) {
+	for _, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		event := &chromeEvent{
+			Name: entryName(entry.FuncInfo),
+			Ph:   "X",
+			Ts:   float64(entry.BeginNs) / 1000,
+			Dur:  float64(entry.EndNs-entry.BeginNs) / 1000,
+			Pid:  1,
+			Tid:  1,
+			Args: &chromeArgs{
+				Args:    jsonString(entry.Args),
+				Results: jsonString(entry.Results),
+				Error:   entry.Error,
+				Panic:   entry.Panic,
+
+				Omitted:   entry.Omitted,
+				Repeated:  entry.Repeated,
+				Truncated: entry.Truncated,
+			},
+		}
+		if entry.FuncInfo != nil {
+			event.Cat = entry.FuncInfo.Pkg
+			event.Args.File = entry.FuncInfo.File
+			event.Args.Line = entry.FuncInfo.Line
+		}
+		ew.item(event)
+		exportChromeEntries(ew, entry.Children)
+		if ew.err != nil {
+			return
+		}
+	}
+}
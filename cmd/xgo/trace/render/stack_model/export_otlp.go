@@ -0,0 +1,149 @@
+package stack_model
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"strconv"
+)
+
+// see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []*otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+	// int64 is encoded as decimal string
+	IntValue *string `json:"intValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Message string `json:"message,omitempty"`
+	Code    int    `json:"code"`
+}
+
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusCodeError  = 2
+)
+
+type otlpExporter struct {
+	ew      *exportWriter
+	traceID string
+	beginNs int64
+	nextID  uint64
+}
+
+// ExportOTLP writes stack as an OTLP/JSON ExportTraceServiceRequest,
+// each StackEntry becomes a span carrying args, results, error
+// and panic as attributes
+func ExportOTLP(stack *Stack, w io.Writer) error {
+	ew := &exportWriter{w: w}
+	ew.raw(`{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"xgo"}}]},"scopeSpans":[{"scope":{"name":"github.com/xhd2015/xgo"},`)
+	ew.beginList(`"spans":[`)
+	if stack != nil {
+		var traceID [16]byte
+		_, err := rand.Read(traceID[:])
+		if err != nil {
+			return err
+		}
+		e := &otlpExporter{
+			ew:      ew,
+			traceID: hex.EncodeToString(traceID[:]),
+		}
+		begin := stackBegin(stack)
+		if !begin.IsZero() {
+			e.beginNs = begin.UnixNano()
+		}
+		e.exportEntries(stack.Children, "")
+	}
+	ew.raw("]}]}]}\n")
+	return ew.err
+}
+
+func (c *otlpExporter) exportEntries(entries []*StackEntry, parentSpanID string) {
+	for _, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		c.nextID++
+		var spanID [8]byte
+		binary.BigEndian.PutUint64(spanID[:], c.nextID)
+
+		span := &otlpSpan{
+			TraceID:           c.traceID,
+			SpanID:            hex.EncodeToString(spanID[:]),
+			ParentSpanID:      parentSpanID,
+			Name:              entryName(entry.FuncInfo),
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(c.beginNs+entry.BeginNs, 10),
+			EndTimeUnixNano:   strconv.FormatInt(c.beginNs+entry.EndNs, 10),
+			Attributes:        otlpAttributes(entry),
+		}
+		if entry.Panic || entry.Error != "" {
+			span.Status = &otlpStatus{
+				Code:    otlpStatusCodeError,
+				Message: entry.Error,
+			}
+		}
+		c.ew.item(span)
+		c.exportEntries(entry.Children, span.SpanID)
+		if c.ew.err != nil {
+			return
+		}
+	}
+}
+
+func otlpAttributes(entry *StackEntry) []*otlpKeyValue {
+	var attrs []*otlpKeyValue
+	addString := func(key string, value string) {
+		if value == "" {
+			return
+		}
+		attrs = append(attrs, &otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}})
+	}
+	addBool := func(key string, value bool) {
+		if !value {
+			return
+		}
+		attrs = append(attrs, &otlpKeyValue{Key: key, Value: otlpAnyValue{BoolValue: &value}})
+	}
+	addInt := func(key string, value int) {
+		if value == 0 {
+			return
+		}
+		s := strconv.Itoa(value)
+		attrs = append(attrs, &otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &s}})
+	}
+	if entry.FuncInfo != nil {
+		// see https://opentelemetry.io/docs/specs/semconv/attributes-registry/code/
+		addString("code.function", entry.FuncInfo.Name)
+		addString("code.namespace", entry.FuncInfo.Pkg)
+		addString("code.filepath", entry.FuncInfo.File)
+		addInt("code.lineno", entry.FuncInfo.Line)
+	}
+	addString("xgo.args", jsonString(entry.Args))
+	addString("xgo.results", jsonString(entry.Results))
+	addString("xgo.error", entry.Error)
+	addBool("xgo.panic", entry.Panic)
+	addInt("xgo.omitted", entry.Omitted)
+	addInt("xgo.repeated", entry.Repeated)
+	addBool("xgo.truncated", entry.Truncated)
+	return attrs
+}
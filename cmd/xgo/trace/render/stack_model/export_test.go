@@ -0,0 +1,167 @@
+package stack_model
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func testStack() *Stack {
+	return &Stack{
+		Format: "stack",
+		Begin:  "2024-03-01T12:00:00Z",
+		Children: []*StackEntry{
+			{
+				FuncInfo: &FuncInfo{Kind: FuncKind_Func, Pkg: "example.com/app", Name: "Handle", File: "app.go", Line: 10},
+				BeginNs:  1000,
+				EndNs:    9000,
+				Args:     map[string]interface{}{"id": 1},
+				Results:  map[string]interface{}{"name": "x"},
+				Children: []*StackEntry{
+					{
+						FuncInfo: &FuncInfo{Kind: FuncKind_Func, Pkg: "example.com/app/db", Name: "(*DB).Get"},
+						BeginNs:  2000,
+						EndNs:    5000,
+						Error:    "not found",
+					},
+					{
+						FuncInfo: &FuncInfo{Kind: FuncKind_Func, Pkg: "example.com/app", Name: "crash"},
+						BeginNs:  6000,
+						EndNs:    7000,
+						Panic:    true,
+						Error:    "boom",
+						Repeated: 2,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestExportChrome(t *testing.T) {
+	var buf bytes.Buffer
+	err := ExportChrome(testStack(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		TraceEvents []struct {
+			Name string
+			Cat  string
+			Ph   string
+			Ts   float64
+			Dur  float64
+			Args map[string]interface{}
+		} `json:"traceEvents"`
+	}
+	err = json.Unmarshal(buf.Bytes(), &res)
+	if err != nil {
+		t.Fatalf("invalid json: %v\n%s", err, buf.String())
+	}
+	if len(res.TraceEvents) != 3 {
+		t.Fatalf("expect 3 events, actual: %d", len(res.TraceEvents))
+	}
+	root := res.TraceEvents[0]
+	if root.Name != "Handle" || root.Cat != "example.com/app" || root.Ph != "X" || root.Ts != 1 || root.Dur != 8 {
+		t.Errorf("bad root event: %+v", root)
+	}
+	if root.Args["args"] != `{"id":1}` || root.Args["results"] != `{"name":"x"}` || root.Args["line"] != float64(10) {
+		t.Errorf("bad root args: %v", root.Args)
+	}
+	if res.TraceEvents[1].Args["error"] != "not found" {
+		t.Errorf("expect error, actual: %v", res.TraceEvents[1].Args)
+	}
+	if res.TraceEvents[2].Args["panic"] != true || res.TraceEvents[2].Args["repeated"] != float64(2) {
+		t.Errorf("expect panic, actual: %v", res.TraceEvents[2].Args)
+	}
+}
+
+func TestExportOTLP(t *testing.T) {
+	var buf bytes.Buffer
+	err := ExportOTLP(testStack(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type span struct {
+		TraceID           string `json:"traceId"`
+		SpanID            string `json:"spanId"`
+		ParentSpanID      string `json:"parentSpanId"`
+		Name              string `json:"name"`
+		StartTimeUnixNano string `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string `json:"endTimeUnixNano"`
+		Attributes        []struct {
+			Key   string                 `json:"key"`
+			Value map[string]interface{} `json:"value"`
+		} `json:"attributes"`
+		Status *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"status"`
+	}
+	var res struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []*span `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	err = json.Unmarshal(buf.Bytes(), &res)
+	if err != nil {
+		t.Fatalf("invalid json: %v\n%s", err, buf.String())
+	}
+	if len(res.ResourceSpans) != 1 || len(res.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("bad structure: %s", buf.String())
+	}
+	spans := res.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 3 {
+		t.Fatalf("expect 3 spans, actual: %d", len(spans))
+	}
+	root := spans[0]
+	if len(root.TraceID) != 32 || len(root.SpanID) != 16 || root.ParentSpanID != "" {
+		t.Errorf("bad root ids: %+v", root)
+	}
+	// 2024-03-01T12:00:00Z
+	if root.StartTimeUnixNano != "1709294400000001000" || root.EndTimeUnixNano != "1709294400000009000" {
+		t.Errorf("bad root time: %s - %s", root.StartTimeUnixNano, root.EndTimeUnixNano)
+	}
+	attrs := make(map[string]interface{})
+	for _, attr := range root.Attributes {
+		for _, v := range attr.Value {
+			attrs[attr.Key] = v
+		}
+	}
+	if attrs["code.function"] != "Handle" || attrs["code.namespace"] != "example.com/app" || attrs["code.lineno"] != "10" || attrs["xgo.args"] != `{"id":1}` {
+		t.Errorf("bad root attributes: %v", attrs)
+	}
+	for _, child := range spans[1:] {
+		if child.TraceID != root.TraceID || child.ParentSpanID != root.SpanID || child.SpanID == root.SpanID {
+			t.Errorf("bad child ids: %+v", child)
+		}
+		if child.Status == nil || child.Status.Code != 2 {
+			t.Errorf("expect error status: %+v", child)
+		}
+	}
+	var repeated interface{}
+	for _, attr := range spans[2].Attributes {
+		if attr.Key == "xgo.repeated" {
+			repeated = attr.Value["intValue"]
+		}
+	}
+	if repeated != "2" {
+		t.Errorf("expect xgo.repeated 2, actual: %v", repeated)
+	}
+	if spans[1].Status.Message != "not found" {
+		t.Errorf("bad status: %+v", spans[1].Status)
+	}
+}
+
+func TestParseExportFormat(t *testing.T) {
+	format, err := ParseExportFormat("")
+	if err != nil || format != ExportFormat_JSON {
+		t.Errorf("expect json, actual: %v %v", format, err)
+	}
+	_, err = ParseExportFormat("zipkin")
+	if err == nil {
+		t.Errorf("expect error for unsupported format")
+	}
+}
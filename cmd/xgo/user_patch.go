@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xhd2015/xgo/instrument/overlay"
+	"github.com/xhd2015/xgo/instrument/patch"
+	"github.com/xhd2015/xgo/support/cmd"
+	"github.com/xhd2015/xgo/support/filecopy"
+	"github.com/xhd2015/xgo/support/fileutil"
+	"github.com/xhd2015/xgo/support/goinfo"
+)
+
+// userPatchFile is a .xgo.patch file from --patch-dir,
+// the dir of which relative to the patch dir is the
+// import path of the target package, e.g.
+//
+//	xgo-patches/net/http/server.go.xgo.patch
+//	xgo-patches/github.com/x/y/z.go.xgo.patch
+type userPatchFile struct {
+	// the patch file as found under the patch dir
+	file     string
+	pkgPath  string
+	fileName string
+}
+
+func collectUserPatches(patchDirs []string) ([]*userPatchFile, error) {
+	var files []*userPatchFile
+	for _, patchDir := range patchDirs {
+		stat, err := os.Stat(patchDir)
+		if err != nil {
+			return nil, fmt.Errorf("--patch-dir: %w", err)
+		}
+		if !stat.IsDir() {
+			return nil, fmt.Errorf("--patch-dir: not a dir: %s", patchDir)
+		}
+		err = filepath.Walk(patchDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !strings.HasSuffix(path, ".xgo.patch") {
+				return nil
+			}
+			relPath, err := filepath.Rel(patchDir, path)
+			if err != nil {
+				return err
+			}
+			pkgPath := filepath.ToSlash(filepath.Dir(relPath))
+			if pkgPath == "." {
+				return fmt.Errorf("%s: patch file must be placed under the import path of its package", path)
+			}
+			files = append(files, &userPatchFile{
+				file:     path,
+				pkgPath:  pkgPath,
+				fileName: strings.TrimSuffix(filepath.Base(relPath), ".xgo.patch"),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// applyUserPatches applies patches from patchDirs to GOROOT packages and
+// dependencies through overlayFS, nothing is written to the original files.
+// Since go1.25 files in GOMODCACHE cannot be overlaid, so a dependency from
+// the module cache is copied to .xgo/gen/modules and replaced in the modfile,
+// which is created under .xgo/gen/overlay if not already.
+// It returns the modfile to build with.
+func applyUserPatches(patchDirs []string, overlayFS overlay.Overlay, goroot string, goBinary string, goVersion *goinfo.GoVersion, projectDir string, projectRoot string, localXgoGenDir string, mod string, modfile string) (string, error) {
+	files, err := collectUserPatches(patchDirs)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return modfile, nil
+	}
+	pkgPaths := make([]string, 0, len(files))
+	seen := make(map[string]bool, len(files))
+	for _, file := range files {
+		if seen[file.pkgPath] {
+			continue
+		}
+		seen[file.pkgPath] = true
+		pkgPaths = append(pkgPaths, file.pkgPath)
+	}
+	pkgs, err := goinfo.ListPackages(pkgPaths, goinfo.LoadPackageOptions{
+		Dir:     projectDir,
+		Mod:     mod,
+		ModFile: modfile,
+		Goroot:  goroot,
+	})
+	if err != nil {
+		return "", fmt.Errorf("--patch-dir: list packages: %w", err)
+	}
+	pkgMap := make(map[string]*goinfo.Package, len(pkgs))
+	for _, pkg := range pkgs {
+		pkgMap[pkg.ImportPath] = pkg
+	}
+
+	absGenDir, err := filepath.Abs(localXgoGenDir)
+	if err != nil {
+		return "", err
+	}
+	// module path@version -> local dir
+	localModules := make(map[string]string)
+	for _, file := range files {
+		pkg := pkgMap[file.pkgPath]
+		if pkg == nil || pkg.Dir == "" {
+			msg := "not found"
+			if pkg != nil && pkg.Error != nil {
+				msg = pkg.Error.Err
+			}
+			return "", fmt.Errorf("%s: package %s: %s", file.file, file.pkgPath, msg)
+		}
+		dir := pkg.Dir
+		if goVersion.Minor >= 25 && isModCacheModule(pkg.Module) {
+			dir, err = localizeModulePkg(pkg, filepath.Join(absGenDir, "modules"), localModules, goVersion)
+			if err != nil {
+				return "", err
+			}
+		}
+		target := overlay.AbsFile(filepath.Join(dir, file.fileName))
+		_, content, err := overlayFS.Read(target)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", file.file, err)
+		}
+		patchContent, err := os.ReadFile(file.file)
+		if err != nil {
+			return "", err
+		}
+		patched, err := patch.ApplyXgoPatchContent(content, string(patchContent))
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", file.file, err)
+		}
+		logDebug("user patch %s -> %s", file.file, target)
+		overlayFS.OverrideContent(target, patched)
+	}
+	if len(localModules) == 0 {
+		return modfile, nil
+	}
+
+	modVersions := make([]string, 0, len(localModules))
+	for modVersion := range localModules {
+		modVersions = append(modVersions, modVersion)
+	}
+	sort.Strings(modVersions)
+	editArgs := []string{"mod", "edit"}
+	for _, modVersion := range modVersions {
+		editArgs = append(editArgs, fmt.Sprintf("-replace=%s=%s", modVersion, localModules[modVersion]))
+	}
+	modfile, err = getGenModfile(modfile, projectRoot, filepath.Join(absGenDir, "overlay"))
+	if err != nil {
+		return "", err
+	}
+	editArgs = append(editArgs, modfile)
+	err = cmd.Env([]string{
+		"GOROOT=" + goroot,
+	}).Run(goBinary, editArgs...)
+	if err != nil {
+		return "", err
+	}
+	return modfile, nil
+}
+
+// isModCacheModule tells if the module is loaded
+// from GOMODCACHE rather than a local replace
+// or the vendor dir
+func isModCacheModule(module *goinfo.Module) bool {
+	if module == nil || module.Main {
+		return false
+	}
+	effective := module
+	if module.Replace != nil {
+		effective = module.Replace
+	}
+	return effective.Version != "" && effective.Dir != ""
+}
+
+// localizeModulePkg copies the module of pkg into modulesDir once,
+// and returns the dir of pkg within the copy
+func localizeModulePkg(pkg *goinfo.Package, modulesDir string, localModules map[string]string, goVersion *goinfo.GoVersion) (string, error) {
+	module := pkg.Module
+	srcDir := module.Dir
+	if module.Replace != nil {
+		srcDir = module.Replace.Dir
+	}
+	modVersion := module.Path + "@" + module.Version
+	localDir := filepath.Join(modulesDir, filepath.FromSlash(modVersion))
+	if _, ok := localModules[modVersion]; !ok {
+		logDebug("copy %s to %s for user patches", modVersion, localDir)
+		err := filecopy.NewOptions().CopyReplaceDir(srcDir, localDir)
+		if err != nil {
+			return "", err
+		}
+		// modules without go.mod are still required to have
+		// one when replaced by a dir
+		goMod := filepath.Join(localDir, "go.mod")
+		if !isFile(goMod) {
+			err := createGoModPlaceholder(goMod, module.Path, fmt.Sprintf("%d.%d", goVersion.Major, goVersion.Minor))
+			if err != nil {
+				return "", err
+			}
+		}
+		localModules[modVersion] = localDir
+	}
+	relDir, err := filepath.Rel(srcDir, pkg.Dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(localDir, relDir), nil
+}
+
+// getGenModfile returns modfile if it is already generated
+// under overlayDir, otherwise copies go.mod and go.sum there
+func getGenModfile(modfile string, projectRoot string, overlayDir string) (string, error) {
+	if modfile != "" && strings.HasPrefix(modfile, overlayDir+string(filepath.Separator)) {
+		return modfile, nil
+	}
+	goMod := modfile
+	if goMod == "" {
+		goMod = filepath.Join(projectRoot, "go.mod")
+	}
+	absGoMod, err := filepath.Abs(goMod)
+	if err != nil {
+		return "", err
+	}
+	tmpGoMod := fileutil.RebasePath(overlayDir, absGoMod)
+	err = filecopy.CopyFileAll(absGoMod, tmpGoMod)
+	if err != nil {
+		return "", err
+	}
+	goSum := strings.TrimSuffix(absGoMod, ".mod") + ".sum"
+	tmpGoSum := strings.TrimSuffix(tmpGoMod, ".mod") + ".sum"
+	if isFile(goSum) {
+		err = filecopy.CopyFileAll(goSum, tmpGoSum)
+		if err != nil {
+			return "", err
+		}
+	}
+	return tmpGoMod, nil
+}
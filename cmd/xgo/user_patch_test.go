@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/xgo/instrument/overlay"
+	"github.com/xhd2015/xgo/support/goinfo"
+)
+
+func TestCollectUserPatchesRequiresPackageDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go.xgo.patch"), []byte("<patch a>\n</patch>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := collectUserPatches([]string{dir})
+	if err == nil || !strings.Contains(err.Error(), "import path") {
+		t.Fatalf("expect import path error, actual: %v", err)
+	}
+}
+
+func TestApplyUserPatches(t *testing.T) {
+	projectDir := t.TempDir()
+	patchDir := t.TempDir()
+	write := func(file string, content string) {
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(projectDir, "go.mod"), "module example.com/demo\n\ngo 1.18\n")
+	write(filepath.Join(projectDir, "lib", "lib.go"), "package lib\n\nfunc Lib() int {\n\treturn 1\n}\n")
+	write(filepath.Join(patchDir, "example.com", "demo", "lib", "lib.go.xgo.patch"), "<patch lib_two>\ngoto func Lib\nfind_for_replace return 1\nreplace return 2\n</patch>\n")
+	write(filepath.Join(patchDir, "strings", "strings.go.xgo.patch"), "<patch upper>\ngoto func ToUpper\ngoto opening {\ninsert_after _ = 0;\n</patch>\n")
+
+	goVersion := &goinfo.GoVersion{Major: 1, Minor: 18}
+	overlayFS := overlay.MakeOverlay()
+	modfile, err := applyUserPatches([]string{patchDir}, overlayFS, "", "go", goVersion, projectDir, projectDir, filepath.Join(projectDir, ".xgo", "gen"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modfile != "" {
+		t.Fatalf("expect modfile unchanged, actual: %s", modfile)
+	}
+
+	libFile := overlay.AbsFile(filepath.Join(projectDir, "lib", "lib.go"))
+	hit, content, err := overlayFS.Read(libFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hit || !strings.Contains(content, "/*<begin lib_two>*//*old:return 1*/return 2/*<end lib_two>*/") {
+		t.Fatalf("lib not patched:\n%s", content)
+	}
+	var stringsPatched bool
+	for file, o := range overlayFS {
+		if strings.HasSuffix(string(file), filepath.Join("strings", "strings.go")) {
+			stringsPatched = strings.Contains(o.Content, "/*<begin upper>*/_ = 0;/*<end upper>*/")
+		}
+	}
+	if !stringsPatched {
+		t.Fatalf("strings.go not patched")
+	}
+	// the original file is not modified
+	data, err := os.ReadFile(string(libFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "return 2") {
+		t.Fatalf("original file modified:\n%s", data)
+	}
+
+	// a failed block fails with the patch file
+	write(filepath.Join(patchDir, "example.com", "demo", "lib", "lib.go.xgo.patch"), "<patch lib_two>\ngoto func Missing\n</patch>\n")
+	_, err = applyUserPatches([]string{patchDir}, overlay.MakeOverlay(), "", "go", goVersion, projectDir, projectDir, filepath.Join(projectDir, ".xgo", "gen"), "", "")
+	if err == nil || !strings.Contains(err.Error(), "lib.go.xgo.patch: ") || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expect error with patch file and line, actual: %v", err)
+	}
+}
@@ -13,3 +13,10 @@ func IsGenericFunc(funcDecl *ast.FuncDecl) bool {
 	}
 	return true
 }
+
+func IsGenericType(typeSpec *ast.TypeSpec) bool {
+	if typeSpec.TypeParams == nil || len(typeSpec.TypeParams.List) == 0 {
+		return false
+	}
+	return true
+}
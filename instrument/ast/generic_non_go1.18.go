@@ -10,3 +10,7 @@ import (
 func IsGenericFunc(funcDecl *ast.FuncDecl) bool {
 	return false
 }
+
+func IsGenericType(typeSpec *ast.TypeSpec) bool {
+	return false
+}
@@ -32,6 +32,9 @@ type InterfaceType struct {
 	Name    string
 	LineNum int
 	InfoVar string
+
+	// generated implementation, optional
+	Impl string
 }
 
 type Fields []*Field
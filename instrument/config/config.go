@@ -156,6 +156,17 @@ var defaultStdPkgConfig = map[string]PkgConfig{
 			"Sleep":       true, // NOTE: time.Sleep links to runtime.timeSleep
 			"NewTicker":   true,
 			"Time.Format": true,
+			// used by runtime/clock
+			"Since":           true,
+			"Until":           true,
+			"NewTimer":        true,
+			"After":           true,
+			"AfterFunc":       true,
+			"Tick":            true,
+			"(*Timer).Stop":   true,
+			"(*Timer).Reset":  true,
+			"(*Ticker).Stop":  true,
+			"(*Ticker).Reset": true,
 		},
 	},
 	"os/exec": {
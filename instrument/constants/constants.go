@@ -13,6 +13,7 @@ const (
 	RUNTIME_MOCK_PKG             = "github.com/xhd2015/xgo/runtime/mock"
 	RUNTIME_TRACE_PKG            = "github.com/xhd2015/xgo/runtime/trace"
 	RUNTIME_TRAP_PKG             = "github.com/xhd2015/xgo/runtime/trap"
+	RUNTIME_COVERAGE_PKG         = "github.com/xhd2015/xgo/runtime/coverage"
 )
 
 // legacy
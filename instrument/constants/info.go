@@ -23,15 +23,21 @@ const (
 )
 
 const (
-	FUNC_INFO = "__xgo_func_info" // __xgo_func_info_<fileIndex>_<declIndex>
-	VAR_INFO  = "__xgo_var_info"  // __xgo_var_info_<fileIndex>_<declIndex>
-	INTF_INFO = "__xgo_intf_info" // __xgo_intf_info_<fileIndex>_<declIndex>
+	FUNC_INFO = "__xgo_func_info"  // __xgo_func_info_<fileIndex>_<declIndex>
+	VAR_INFO  = "__xgo_var_info"   // __xgo_var_info_<fileIndex>_<declIndex>
+	INTF_INFO = "__xgo_intf_info"  // __xgo_intf_info_<fileIndex>_<declIndex>
+	INTF_IMPL = "__xgo_intf_impl_" // __xgo_intf_impl_<interfaceName>
 )
 
 const (
 	REG_FILE_GEN = "__xgo_reg_file_gen_"
 )
 
+const (
+	BRANCH_VAR        = "__xgo_branch_" // __xgo_branch_<fileIndex>
+	BRANCH_IMPORT_PKG = "__xgo_cover_branch"
+)
+
 type InfoKind int
 
 const (
@@ -117,6 +123,14 @@ func IntfInfoVarName(fileIndex int, declIndex int) string {
 	return INTF_INFO + "_" + strconv.Itoa(fileIndex) + "_" + strconv.Itoa(declIndex)
 }
 
+func IntfImplName(intfName string) string {
+	return INTF_IMPL + intfName
+}
+
 func RegFileGen(fileIndex int) string {
 	return REG_FILE_GEN + strconv.Itoa(fileIndex)
 }
+
+func BranchVar(fileIndex int) string {
+	return BRANCH_VAR + strconv.Itoa(fileIndex)
+}
@@ -133,6 +133,13 @@ type InterfaceType struct {
 	Name    string
 	Ident   *ast.Ident
 	Type    *ast.InterfaceType
+
+	// Generic indicates the interface has type params
+	Generic bool
+
+	// Impl is the name of the generated type
+	// implementing this interface, if any
+	Impl string
 }
 
 type Fields []*Field
@@ -0,0 +1,234 @@
+package instrument_branch
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"github.com/xhd2015/xgo/instrument/constants"
+	"github.com/xhd2015/xgo/instrument/edit"
+	"github.com/xhd2015/xgo/instrument/patch"
+)
+
+// kinds of branch points, see xgo/runtime/coverage
+const (
+	KindIf      = "if"      // condition of if statement
+	KindCond    = "cond"    // operand of && or ||
+	KindCase    = "case"    // case clause of switch
+	KindDefault = "default" // implicit default of switch
+)
+
+type point struct {
+	start token.Pos
+	end   token.Pos
+	kind  string
+}
+
+type instrumenter struct {
+	fset    *token.FileSet
+	file    *edit.File
+	varName string
+	points  []point
+}
+
+// Instrument rewrites conditions of the given file so that
+// each if/switch outcome and each operand of && and || is
+// recorded by xgo/runtime/coverage:
+//
+//	if a && b {     -->  if V.Cond(0, bool(V.Cond(1, bool(a)) && V.Cond(2, bool(b)))) {
+//	switch x {      -->  switch x {
+//	case 1:         -->  case 1:V.Hit(3);
+//	}               -->  ;default:V.Hit(4);}
+//
+// no new line is inserted, so line numbers are kept.
+// && and || outside if and switch are not instrumented
+// because the result type may be a named bool.
+// Returns true if any branch point is found.
+func Instrument(fset *token.FileSet, file *edit.File, pkgPath string) bool {
+	c := &instrumenter{
+		fset:    fset,
+		file:    file,
+		varName: constants.BranchVar(file.Index),
+	}
+	syntax := file.File.Syntax
+	ast.Inspect(syntax, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt:
+			c.wrapCond(n.Cond, KindIf)
+			c.wrapOperands(n.Cond)
+		case *ast.SwitchStmt:
+			c.hitCases(n, n.Body, n.Tag == nil)
+		case *ast.TypeSwitchStmt:
+			c.hitCases(n, n.Body, false)
+		}
+		return true
+	})
+	if len(c.points) == 0 {
+		return false
+	}
+
+	quotedPoints := make([]string, 0, len(c.points))
+	for _, p := range c.points {
+		quotedPoints = append(quotedPoints, strconv.Quote(c.formatPoint(p)))
+	}
+	fileName := pkgPath + "/" + file.File.Name
+	decl := fmt.Sprintf("\nvar %s = %s.Register(%q, []string{%s})", c.varName, constants.BRANCH_IMPORT_PKG, fileName, strings.Join(quotedPoints, ","))
+
+	patch.AddImport(file.Edit, syntax, constants.BRANCH_IMPORT_PKG, constants.RUNTIME_COVERAGE_PKG)
+	patch.Append(file.Edit, syntax, decl)
+	return true
+}
+
+// AddFlush inserts `defer coverage.Flush()` into each
+// top-level test, benchmark, fuzz target and example of a
+// test file, so counters are written when each of them finishes.
+// Returns true if any of them is found.
+func AddFlush(file *edit.File) bool {
+	syntax := file.File.Syntax
+	var found bool
+	for _, decl := range syntax.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Body == nil || !isTestFunc(fn) {
+			continue
+		}
+		file.Edit.Insert(fn.Body.Lbrace+1, fmt.Sprintf("defer %s.Flush();", constants.BRANCH_IMPORT_PKG))
+		found = true
+	}
+	if found {
+		patch.AddImport(file.Edit, syntax, constants.BRANCH_IMPORT_PKG, constants.RUNTIME_COVERAGE_PKG)
+	}
+	return found
+}
+
+// isTestFunc follows `go test`, the param type is
+// checked by name, so both `*testing.T` with testing
+// imported under any name, and `*T` with testing
+// dot-imported are accepted
+func isTestFunc(fn *ast.FuncDecl) bool {
+	name := fn.Name.Name
+	params := fn.Type.Params.List
+	switch {
+	case name == "TestMain":
+		return false
+	case strings.HasPrefix(name, "Test"):
+		return isTestParam(params, "T")
+	case strings.HasPrefix(name, "Benchmark"):
+		return isTestParam(params, "B")
+	case strings.HasPrefix(name, "Fuzz"):
+		return isTestParam(params, "F")
+	case strings.HasPrefix(name, "Example"):
+		return len(params) == 0 && fn.Type.Results == nil
+	}
+	return false
+}
+
+func isTestParam(params []*ast.Field, typeName string) bool {
+	if len(params) != 1 || len(params[0].Names) > 1 {
+		return false
+	}
+	star, ok := params[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	switch x := star.X.(type) {
+	case *ast.SelectorExpr:
+		return x.Sel.Name == typeName
+	case *ast.Ident:
+		return x.Name == typeName
+	}
+	return false
+}
+
+func (c *instrumenter) addPoint(start token.Pos, end token.Pos, kind string) int {
+	c.points = append(c.points, point{start: start, end: end, kind: kind})
+	return len(c.points) - 1
+}
+
+func (c *instrumenter) wrapCond(expr ast.Expr, kind string) {
+	id := c.addPoint(expr.Pos(), expr.End(), kind)
+	c.file.Edit.Insert(expr.Pos(), fmt.Sprintf("%s.Cond(%d, bool(", c.varName, id))
+	c.file.Edit.Insert(expr.End(), "))")
+}
+
+// wrapOperands wraps each leaf operand of && and ||,
+// nothing is done if expr has no && or ||
+func (c *instrumenter) wrapOperands(expr ast.Expr) {
+	if !hasLogicalOp(expr) {
+		return
+	}
+	var walk func(expr ast.Expr)
+	walk = func(expr ast.Expr) {
+		switch e := expr.(type) {
+		case *ast.ParenExpr:
+			walk(e.X)
+		case *ast.UnaryExpr:
+			if e.Op == token.NOT {
+				walk(e.X)
+				return
+			}
+			c.wrapCond(e, KindCond)
+		case *ast.BinaryExpr:
+			if e.Op == token.LAND || e.Op == token.LOR {
+				walk(e.X)
+				walk(e.Y)
+				return
+			}
+			c.wrapCond(e, KindCond)
+		default:
+			c.wrapCond(e, KindCond)
+		}
+	}
+	walk(expr)
+}
+
+func hasLogicalOp(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.ParenExpr:
+		return hasLogicalOp(e.X)
+	case *ast.UnaryExpr:
+		return e.Op == token.NOT && hasLogicalOp(e.X)
+	case *ast.BinaryExpr:
+		return e.Op == token.LAND || e.Op == token.LOR
+	}
+	return false
+}
+
+// hitCases records each case clause, an implicit default
+// clause is added if there is none
+func (c *instrumenter) hitCases(stmt ast.Stmt, body *ast.BlockStmt, tagless bool) {
+	var hasDefault bool
+	for _, s := range body.List {
+		clause, ok := s.(*ast.CaseClause)
+		if !ok {
+			continue
+		}
+		if clause.List == nil {
+			hasDefault = true
+		}
+		id := c.addPoint(clause.Case, clause.Colon+1, KindCase)
+		c.file.Edit.Insert(clause.Colon+1, fmt.Sprintf("%s.Hit(%d);", c.varName, id))
+		if tagless {
+			for _, expr := range clause.List {
+				c.wrapOperands(expr)
+			}
+		}
+	}
+	if !hasDefault {
+		id := c.addPoint(stmt.Pos(), body.Lbrace, KindDefault)
+		code := fmt.Sprintf("default:%s.Hit(%d);", c.varName, id)
+		if len(body.List) > 0 {
+			// terminate the last clause
+			code = ";" + code
+		}
+		c.file.Edit.Insert(body.Rbrace, code)
+	}
+}
+
+func (c *instrumenter) formatPoint(p point) string {
+	// physical positions, ignoring //line directives
+	start := c.fset.PositionFor(p.start, false)
+	end := c.fset.PositionFor(p.end, false)
+	return fmt.Sprintf("%d.%d,%d.%d %s", start.Line, start.Column, end.Line, end.Column, p.kind)
+}
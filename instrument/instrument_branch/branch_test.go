@@ -0,0 +1,156 @@
+package instrument_branch
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/xgo/instrument/edit"
+	"github.com/xhd2015/xgo/instrument/load"
+	"github.com/xhd2015/xgo/support/edit/goedit"
+)
+
+func newFile(t *testing.T, name string, code string) (*token.FileSet, *edit.File) {
+	fset := token.NewFileSet()
+	syntax, err := parser.ParseFile(fset, name, code, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fset, &edit.File{
+		File: &load.File{
+			Name:    name,
+			Content: []byte(code),
+			Syntax:  syntax,
+		},
+		Index: 0,
+		Edit:  goedit.New(fset, code),
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	code := `package demo
+
+func check(a int, b bool) int {
+	if a > 0 && !b {
+		return 1
+	}
+	switch a {
+	case 1:
+	}
+	return 0
+}
+`
+	fset, file := newFile(t, "demo.go", code)
+	if !Instrument(fset, file, "example.com/demo") {
+		t.Fatalf("expect branches")
+	}
+	expect := `package demo;import __xgo_cover_branch "github.com/xhd2015/xgo/runtime/coverage"
+
+func check(a int, b bool) int {
+	if __xgo_branch_0.Cond(0, bool(__xgo_branch_0.Cond(1, bool(a > 0)) && !__xgo_branch_0.Cond(2, bool(b)))) {
+		return 1
+	}
+	switch a {
+	case 1:__xgo_branch_0.Hit(3);
+	;default:__xgo_branch_0.Hit(4);}
+	return 0
+}
+var __xgo_branch_0 = __xgo_cover_branch.Register("example.com/demo/demo.go", []string{"4.5,4.16 if","4.5,4.10 cond","4.15,4.16 cond","8.2,8.9 case","7.2,7.11 default"})`
+	if got := strings.TrimSuffix(file.Edit.String(), "\n"); got != expect {
+		t.Fatalf("expect:\n%s\nactual:\n%s", expect, got)
+	}
+	if strings.Count(file.Edit.String(), "\n") != strings.Count(code, "\n")+1 {
+		t.Fatalf("expect line numbers to be kept")
+	}
+}
+
+func TestInstrumentEmptySwitch(t *testing.T) {
+	code := `package demo
+
+func check(a int, v interface{}) {
+	switch a {}
+	switch {}
+	switch v.(type) {}
+}
+`
+	fset, file := newFile(t, "demo.go", code)
+	if !Instrument(fset, file, "example.com/demo") {
+		t.Fatalf("expect branches")
+	}
+	got := file.Edit.String()
+	for _, stmt := range []string{
+		"switch a {default:__xgo_branch_0.Hit(0);}",
+		"switch {default:__xgo_branch_0.Hit(1);}",
+		"switch v.(type) {default:__xgo_branch_0.Hit(2);}",
+	} {
+		if !strings.Contains(got, stmt) {
+			t.Errorf("expect %s, actual:\n%s", stmt, got)
+		}
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "demo.go", got, 0); err != nil {
+		t.Fatalf("expect instrumented code to parse: %v\n%s", err, got)
+	}
+}
+
+func TestInstrumentNoBranch(t *testing.T) {
+	fset, file := newFile(t, "demo.go", "package demo\n\nfunc add(a, b int) int {\n\treturn a + b\n}\n")
+	if Instrument(fset, file, "example.com/demo") {
+		t.Fatalf("expect no branch")
+	}
+	if file.Edit.HasEdit() {
+		t.Fatalf("expect no edit")
+	}
+}
+
+func TestAddFlush(t *testing.T) {
+	code := `package demo
+
+import "testing"
+
+func TestMain(m *testing.M) {}
+
+func TestCheck(t *testing.T) {
+}
+
+func BenchmarkCheck(b *testing.B) {}
+
+func FuzzCheck(f *testing.F) {}
+
+func ExampleCheck() {}
+
+func helper(t *testing.T) {}
+
+func ExampleHelper() int { return 0 }
+`
+	_, file := newFile(t, "demo_test.go", code)
+	if !AddFlush(file) {
+		t.Fatalf("expect tests")
+	}
+	got := file.Edit.String()
+	if strings.Count(got, "Flush()") != 4 {
+		t.Fatalf("unexpected flush:\n%s", got)
+	}
+	for _, fn := range []string{"TestCheck(t *testing.T)", "BenchmarkCheck(b *testing.B)", "FuzzCheck(f *testing.F)", "ExampleCheck()"} {
+		if !strings.Contains(got, "func "+fn+" {defer __xgo_cover_branch.Flush();") {
+			t.Errorf("expect %s flushed:\n%s", fn, got)
+		}
+	}
+}
+
+func TestAddFlushDotImport(t *testing.T) {
+	code := `package demo
+
+import . "testing"
+
+func TestCheck(t *T) {
+}
+`
+	_, file := newFile(t, "demo_test.go", code)
+	if !AddFlush(file) {
+		t.Fatalf("expect tests")
+	}
+	if got := file.Edit.String(); !strings.Contains(got, "func TestCheck(t *T) {defer __xgo_cover_branch.Flush();") {
+		t.Fatalf("unexpected flush:\n%s", got)
+	}
+}
@@ -207,15 +207,7 @@ func TrapFuncs(editor *goedit.Edit, pkgPath string, file *ast.File, fileIndex in
 		//     }
 		// trap: func(recvName string, recvPtr interface{}, argNames []string, args []interface{}, resultNames []string, results []interface{}) (func(), bool)
 		funcInfo := fmt.Sprintf("%s_%d_%d", constants.FUNC_INFO, fileIndex, len(funcInfos))
-		editor.Insert(pos, fmt.Sprintf(trapTemplate,
-			line, line,
-			fileIndex,
-			funcInfo,
-			receiverAddr,
-			strings.Join(paramAddrs, ","),
-			strings.Join(resultAddrs, ","),
-			line, line, line,
-		))
+		editor.Insert(pos, TrapStmt(fileIndex, line, funcInfo, receiverAddr, paramAddrs, resultAddrs))
 
 		funcInfos = append(funcInfos, &edit.FuncInfo{
 			InfoVar:      funcInfo,
@@ -232,6 +224,21 @@ func TrapFuncs(editor *goedit.Edit, pkgPath string, file *ast.File, fileIndex in
 	return funcInfos, extraFuncs
 }
 
+// TrapStmt returns the statement inserted at the
+// beginning of a function body, `line` is used to
+// make variable names unique
+func TrapStmt(fileIndex int, line int, funcInfo string, recvAddr string, paramAddrs []string, resultAddrs []string) string {
+	return fmt.Sprintf(trapTemplate,
+		line, line,
+		fileIndex,
+		funcInfo,
+		recvAddr,
+		strings.Join(paramAddrs, ","),
+		strings.Join(resultAddrs, ","),
+		line, line, line,
+	)
+}
+
 func ParseReceiverInfo(fnName string, receiver *ast.Field) (identityName string, recvPtr bool, recvGeneric bool, recvType *ast.Ident) {
 	if receiver == nil {
 		identityName = fnName
@@ -0,0 +1,462 @@
+package instrument_intf
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"github.com/xhd2015/xgo/instrument/constants"
+	"github.com/xhd2015/xgo/instrument/edit"
+	"github.com/xhd2015/xgo/instrument/instrument_func"
+	"github.com/xhd2015/xgo/instrument/patch"
+	"github.com/xhd2015/xgo/instrument/resolve"
+)
+
+const (
+	implRecvName     = "__xgo_intf_recv"
+	paramNamePrefix  = "__xgo_auto_param_"
+	resultNamePrefix = "__xgo_auto_res_"
+	implImportPrefix = "__xgo_intf_ref_"
+)
+
+type method struct {
+	name  string
+	ident *ast.Ident
+	// nil for the predeclared error.Error
+	funcType *ast.FuncType
+
+	// where the method is declared
+	pkg  *edit.Package
+	file *edit.File
+}
+
+type implContext struct {
+	registry resolve.PackageRegistry
+	pkg      *edit.Package
+	file     *edit.File
+
+	fileImports map[*edit.File]resolve.Imports
+
+	// pkgPath -> local name in file
+	pkgRefs    map[string]string
+	newImports []string
+}
+
+// GenerateImpls generates a struct type implementing each
+// of intfTypes referenced by `mock.NewInterface[T]()` or
+// `mock.NewInterfaceOf((*T)(nil))`, example:
+//
+//	type Service interface {
+//		Get(id int) string
+//	}
+//
+//	-->
+//
+//	type __xgo_intf_impl_Service struct{Service}
+//	func (__xgo_intf_recv *__xgo_intf_impl_Service) Get(id int) (__xgo_auto_res_0 string){<trap>;return}
+//
+// Methods promoted from embedded interfaces are generated too,
+// types declared in other files or packages are qualified and
+// imported as needed.
+// The interface is embedded so that methods not generated,
+// i.e. unexported methods of other packages, are still part
+// of the method set.
+// Returns the generated methods, which are registered the
+// same way as ordinary trapped functions.
+func GenerateImpls(registry resolve.PackageRegistry, pkg *edit.Package, file *edit.File, intfTypes []*edit.InterfaceType, pkgRecorder *resolve.PkgRecorder) []*edit.FuncInfo {
+	if !pkgRecorder.HasInterfaceRef() {
+		return nil
+	}
+	// dependencies are not collected by default
+	resolve.CollectDecls(pkg)
+
+	fset := file.Edit.Fset()
+	c := &implContext{
+		registry: registry,
+		pkg:      pkg,
+		file:     file,
+	}
+	pkgPath := pkg.LoadPackage.GoPackage.ImportPath
+
+	var funcInfos []*edit.FuncInfo
+	var codes []string
+	for _, intfType := range intfTypes {
+		if intfType.Generic {
+			continue
+		}
+		rec := pkgRecorder.Get(intfType.Name)
+		if rec == nil || !rec.HasInterfaceRef {
+			continue
+		}
+		methods, ok := c.collectMethods(pkg, file, intfType.Type, map[string]bool{pkgPath + "." + intfType.Name: true}, nil)
+		if !ok {
+			continue
+		}
+		implName := constants.IntfImplName(intfType.Name)
+		codes = append(codes, fmt.Sprintf("type %s struct{%s}", implName, intfType.Name))
+
+		for _, m := range methods {
+			numImports := len(c.newImports)
+			params, paramTypes, variadic, paramOK := c.methodFields(m, false)
+			results, resultTypes, _, resultOK := c.methodFields(m, true)
+			if !paramOK || !resultOK {
+				// promoted from the embedded field
+				c.dropImports(numImports)
+				continue
+			}
+
+			paramDefs := make([]string, len(params))
+			paramAddrs := make([]string, len(params))
+			for i, param := range params {
+				paramType := paramTypes[i]
+				if variadic && i == len(params)-1 {
+					paramType = "..." + paramType
+				}
+				paramDefs[i] = param.Name + " " + paramType
+				paramAddrs[i] = "&" + param.Name
+			}
+			resultDefs := make([]string, len(results))
+			resultAddrs := make([]string, len(results))
+			for i, result := range results {
+				resultDefs[i] = result.Name + " " + resultTypes[i]
+				resultAddrs[i] = "&" + result.Name
+			}
+
+			// methods declared elsewhere take the line of the interface
+			pos := intfType.Ident.Pos()
+			if m.file == file {
+				pos = m.ident.Pos()
+			}
+			line := fset.Position(pos).Line
+			infoVar := constants.FuncInfoVarName(file.Index, len(file.TrapFuncs)+len(funcInfos))
+			trapStmt := instrument_func.TrapStmt(file.Index, line, infoVar, "&"+implRecvName, paramAddrs, resultAddrs)
+			codes = append(codes, fmt.Sprintf("func (%s *%s) %s(%s) (%s){%sreturn}",
+				implRecvName, implName, m.name,
+				strings.Join(paramDefs, ","),
+				strings.Join(resultDefs, ","),
+				trapStmt,
+			))
+
+			funcInfos = append(funcInfos, &edit.FuncInfo{
+				InfoVar: infoVar,
+				// only name and position are used for registering
+				FuncDecl: &ast.FuncDecl{
+					Name: ast.NewIdent(m.name),
+					Type: &ast.FuncType{Func: pos},
+				},
+				IdentityName: fmt.Sprintf("(*%s).%s", implName, m.name),
+				RecvPtr:      true,
+				// display as the interface
+				RecvType: intfType.Ident,
+				Receiver: &edit.Field{Name: implRecvName},
+				Params:   params,
+				Results:  results,
+			})
+		}
+		intfType.Impl = implName
+	}
+	for _, importPath := range c.newImports {
+		patch.AddImport(file.Edit, file.File.Syntax, c.pkgRefs[importPath], importPath)
+	}
+	if len(codes) > 0 {
+		patch.Append(file.Edit, file.File.Syntax, "\n"+strings.Join(codes, "\n")+"\n")
+	}
+	return funcInfos
+}
+
+// collectMethods flattens methods of `intf` declared in `file`
+// of `pkg`, embedded interfaces are expanded recursively.
+// Returns false if `intf` cannot be implemented, e.g.
+// it is a type constraint.
+func (c *implContext) collectMethods(pkg *edit.Package, file *edit.File, intf *ast.InterfaceType, visited map[string]bool, methods []*method) ([]*method, bool) {
+	if intf.Methods == nil {
+		return methods, true
+	}
+	for _, field := range intf.Methods.List {
+		if len(field.Names) > 0 {
+			funcType, ok := field.Type.(*ast.FuncType)
+			if !ok {
+				return nil, false
+			}
+			for _, name := range field.Names {
+				if pkg != c.pkg && !token.IsExported(name.Name) {
+					// promoted from the embedded field
+					continue
+				}
+				methods = addMethod(methods, &method{name: name.Name, ident: name, funcType: funcType, pkg: pkg, file: file})
+			}
+			continue
+		}
+		var embedPkg *edit.Package
+		var embedName string
+		switch embed := field.Type.(type) {
+		case *ast.Ident:
+			if pkg.Decls[embed.Name] == nil {
+				switch embed.Name {
+				case "any":
+				case "error":
+					methods = addMethod(methods, &method{name: "Error", ident: embed, pkg: pkg, file: file})
+				default:
+					// comparable, or basic types
+					return nil, false
+				}
+				continue
+			}
+			embedPkg = pkg
+			embedName = embed.Name
+		case *ast.SelectorExpr:
+			embedPkg = c.importedPackage(file, embed)
+			if embedPkg == nil {
+				return nil, false
+			}
+			embedName = embed.Sel.Name
+		default:
+			// union, ~T or generic instances
+			return nil, false
+		}
+		decl := embedPkg.Decls[embedName]
+		if decl == nil || decl.Kind != edit.DeclKindType {
+			return nil, false
+		}
+		embedIntf, ok := decl.Type.(*ast.InterfaceType)
+		if !ok {
+			return nil, false
+		}
+		key := embedPkg.LoadPackage.GoPackage.ImportPath + "." + embedName
+		if visited[key] {
+			continue
+		}
+		visited[key] = true
+		methods, ok = c.collectMethods(embedPkg, decl.File, embedIntf, visited, methods)
+		if !ok {
+			return nil, false
+		}
+	}
+	return methods, true
+}
+
+// importedPackage loads the package referenced by `pkg.Name` in file
+func (c *implContext) importedPackage(file *edit.File, sel *ast.SelectorExpr) *edit.Package {
+	idt, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	pkgPath, ok := c.getFileImports(file)[idt.Name]
+	if !ok {
+		return nil
+	}
+	pkg, _, err := c.registry.LoadPackage(pkgPath)
+	if err != nil || pkg == nil {
+		return nil
+	}
+	resolve.CollectDecls(pkg)
+	return pkg
+}
+
+func (c *implContext) getFileImports(file *edit.File) resolve.Imports {
+	imports, ok := c.fileImports[file]
+	if ok {
+		return imports
+	}
+	imports = resolve.GetFileImports(c.registry, file.File.Syntax)
+	if c.fileImports == nil {
+		c.fileImports = make(map[*edit.File]resolve.Imports, 1)
+	}
+	c.fileImports[file] = imports
+	return imports
+}
+
+func addMethod(methods []*method, m *method) []*method {
+	for _, x := range methods {
+		if x.name == m.name {
+			return methods
+		}
+	}
+	return append(methods, m)
+}
+
+// methodFields names each param or result, unnamed
+// and blank ones get generated names.
+// Returns false if any type cannot be referred in c.file.
+func (c *implContext) methodFields(m *method, isResult bool) (fields edit.Fields, typeTexts []string, variadic bool, ok bool) {
+	if m.funcType == nil {
+		// error.Error() string
+		if isResult {
+			return edit.Fields{{Name: resultNamePrefix + "0"}}, []string{"string"}, false, true
+		}
+		return nil, nil, false, true
+	}
+	fieldList := m.funcType.Params
+	prefix := paramNamePrefix
+	if isResult {
+		fieldList = m.funcType.Results
+		prefix = resultNamePrefix
+	}
+	if fieldList == nil {
+		return nil, nil, false, true
+	}
+	for _, field := range fieldList.List {
+		typ := field.Type
+		if ellipsis, ok := typ.(*ast.Ellipsis); ok {
+			variadic = true
+			typ = ellipsis.Elt
+		}
+		typeText, ok := c.typeText(m, typ)
+		if !ok {
+			return nil, nil, false, false
+		}
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{nil}
+		}
+		for _, name := range names {
+			fieldName := prefix + strconv.Itoa(len(fields))
+			if name != nil && name.Name != "_" {
+				fieldName = name.Name
+			}
+			fields = append(fields, &edit.Field{
+				Name:      fieldName,
+				NameIdent: name,
+				Type:      field.Type,
+			})
+			typeTexts = append(typeTexts, typeText)
+		}
+	}
+	return fields, typeTexts, variadic, true
+}
+
+type typeEdit struct {
+	start token.Pos
+	end   token.Pos
+	text  string
+}
+
+// typeText returns the source of typ, declared in m.file,
+// as referred in c.file: names of m.pkg are qualified,
+// and imports of m.file are mapped to those of c.file.
+func (c *implContext) typeText(m *method, typ ast.Expr) (string, bool) {
+	fset := c.file.Edit.Fset()
+	content := m.file.File.Content
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+	if m.file == c.file {
+		return string(content[offset(typ.Pos()):offset(typ.End())]), true
+	}
+	var edits []*typeEdit
+	if !c.qualify(m, typ, &edits) {
+		return "", false
+	}
+	var b strings.Builder
+	last := typ.Pos()
+	for _, e := range edits {
+		b.Write(content[offset(last):offset(e.start)])
+		b.WriteString(e.text)
+		last = e.end
+	}
+	b.Write(content[offset(last):offset(typ.End())])
+	return b.String(), true
+}
+
+// qualify collects edits of names in expr, in source order
+func (c *implContext) qualify(m *method, expr ast.Expr, edits *[]*typeEdit) bool {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if m.pkg == c.pkg || m.pkg.Decls[expr.Name] == nil {
+			// predeclared
+			return true
+		}
+		if !token.IsExported(expr.Name) {
+			return false
+		}
+		ref := c.pkgRef(m.pkg.LoadPackage.GoPackage.ImportPath)
+		*edits = append(*edits, &typeEdit{start: expr.Pos(), end: expr.Pos(), text: ref + "."})
+		return true
+	case *ast.SelectorExpr:
+		idt, ok := expr.X.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		pkgPath, ok := c.getFileImports(m.file)[idt.Name]
+		if !ok {
+			return false
+		}
+		var text string
+		if pkgPath != c.pkg.LoadPackage.GoPackage.ImportPath {
+			text = c.pkgRef(pkgPath) + "."
+		}
+		*edits = append(*edits, &typeEdit{start: expr.Pos(), end: expr.Sel.Pos(), text: text})
+		return true
+	case *ast.BasicLit:
+		return true
+	case *ast.ParenExpr:
+		return c.qualify(m, expr.X, edits)
+	case *ast.StarExpr:
+		return c.qualify(m, expr.X, edits)
+	case *ast.Ellipsis:
+		return c.qualify(m, expr.Elt, edits)
+	case *ast.ArrayType:
+		if expr.Len != nil && !c.qualify(m, expr.Len, edits) {
+			return false
+		}
+		return c.qualify(m, expr.Elt, edits)
+	case *ast.MapType:
+		return c.qualify(m, expr.Key, edits) && c.qualify(m, expr.Value, edits)
+	case *ast.ChanType:
+		return c.qualify(m, expr.Value, edits)
+	case *ast.FuncType:
+		return c.qualifyFields(m, expr.Params, edits) && c.qualifyFields(m, expr.Results, edits)
+	case *ast.StructType:
+		return c.qualifyFields(m, expr.Fields, edits)
+	case *ast.InterfaceType:
+		return c.qualifyFields(m, expr.Methods, edits)
+	default:
+		// generic instances, or const expressions
+		return false
+	}
+}
+
+func (c *implContext) qualifyFields(m *method, fieldList *ast.FieldList, edits *[]*typeEdit) bool {
+	if fieldList == nil {
+		return true
+	}
+	for _, field := range fieldList.List {
+		if !c.qualify(m, field.Type, edits) {
+			return false
+		}
+	}
+	return true
+}
+
+// pkgRef returns the local name of pkgPath in c.file,
+// the import is added if missing
+func (c *implContext) pkgRef(pkgPath string) string {
+	if ref, ok := c.pkgRefs[pkgPath]; ok {
+		return ref
+	}
+	if c.pkgRefs == nil {
+		c.pkgRefs = make(map[string]string, 1)
+	}
+	ref := fmt.Sprintf("%s%d", implImportPrefix, len(c.pkgRefs))
+	for name, path := range c.getFileImports(c.file) {
+		if path == pkgPath {
+			ref = name
+			break
+		}
+	}
+	if strings.HasPrefix(ref, implImportPrefix) {
+		c.newImports = append(c.newImports, pkgPath)
+	}
+	c.pkgRefs[pkgPath] = ref
+	return ref
+}
+
+// dropImports forgets imports added after the first n
+func (c *implContext) dropImports(n int) {
+	for _, pkgPath := range c.newImports[n:] {
+		delete(c.pkgRefs, pkgPath)
+	}
+	c.newImports = c.newImports[:n]
+}
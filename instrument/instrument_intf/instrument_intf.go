@@ -5,6 +5,7 @@ import (
 	"go/ast"
 	"go/token"
 
+	astutil "github.com/xhd2015/xgo/instrument/ast"
 	"github.com/xhd2015/xgo/instrument/constants"
 	"github.com/xhd2015/xgo/instrument/edit"
 )
@@ -41,6 +42,7 @@ func CollectInterfaces(file *edit.File) []*edit.InterfaceType {
 				Name:    typeSpec.Name.Name,
 				Ident:   typeSpec.Name,
 				Type:    intfType,
+				Generic: astutil.IsGenericType(typeSpec),
 			})
 		}
 	}
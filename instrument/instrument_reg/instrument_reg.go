@@ -111,6 +111,7 @@ func buildCompilerExtra(fset *token.FileSet, file *edit.File) *compiler_extra.Fi
 			Name:    intfType.Name,
 			LineNum: lineNum,
 			InfoVar: intfType.InfoVar,
+			Impl:    intfType.Impl,
 		})
 	}
 	return decls
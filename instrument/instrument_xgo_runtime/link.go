@@ -31,6 +31,9 @@ type LinkOptions struct {
 	XgoNumber           int
 	CollectTestTrace    bool
 	CollectTestTraceDir string
+	StraceLimits        string
+	StraceReplayDir     string
+	StraceReplayRules   string
 	XgoRaceSafe         bool
 
 	ReadRuntimeGenFile func(path []string) ([]byte, error)
@@ -45,6 +48,9 @@ func LinkXgoRuntime(goroot string, projectDir string, xgoRuntimeModuleDir string
 	xgoNumber := linkOpts.XgoNumber
 	collectTestTrace := linkOpts.CollectTestTrace
 	collectTestTraceDir := linkOpts.CollectTestTraceDir
+	straceLimits := linkOpts.StraceLimits
+	straceReplayDir := linkOpts.StraceReplayDir
+	straceReplayRules := linkOpts.StraceReplayRules
 	xgoRaceSafe := linkOpts.XgoRaceSafe
 	readRuntimeGenFile := linkOpts.ReadRuntimeGenFile
 
@@ -187,8 +193,8 @@ func LinkXgoRuntime(goroot string, projectDir string, xgoRuntimeModuleDir string
 			absFile := overlay.AbsFile(loadFile.AbsPath)
 			switch loadFile.Name {
 			case constants.FLAG_FILE:
-				if suffixPkg == constants.RUNTIME_TRAP_FLAGS_PKG[n:] && (collectTestTrace || collectTestTraceDir != "" || xgoRaceSafe) {
-					flagsContent := InjectFlags(strutil.ToReadonlyString(content), collectTestTrace, collectTestTraceDir, xgoRaceSafe)
+				if suffixPkg == constants.RUNTIME_TRAP_FLAGS_PKG[n:] && (collectTestTrace || collectTestTraceDir != "" || straceLimits != "" || straceReplayDir != "" || xgoRaceSafe) {
+					flagsContent := InjectFlags(strutil.ToReadonlyString(content), collectTestTrace, collectTestTraceDir, straceLimits, straceReplayDir, straceReplayRules, xgoRaceSafe)
 					overrideContent(absFile, flagsContent)
 				}
 			case constants.TRACE_FILE:
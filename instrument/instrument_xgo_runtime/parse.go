@@ -100,9 +100,12 @@ func ParseCoreVersion(versionCode string) (string, error) {
 	return ver, nil
 }
 
-func InjectFlags(flagsCode string, collectTestTrace bool, collectTestTraceDir string, xgoRaceSafe bool) string {
+func InjectFlags(flagsCode string, collectTestTrace bool, collectTestTraceDir string, straceLimits string, straceReplayDir string, straceReplayRules string, xgoRaceSafe bool) string {
 	flagsCode = replaceByLine(flagsCode, `const COLLECT_TEST_TRACE = `, fmt.Sprintf(`const COLLECT_TEST_TRACE = %t`, collectTestTrace))
 	flagsCode = replaceByLine(flagsCode, `const COLLECT_TEST_TRACE_DIR = `, fmt.Sprintf(`const COLLECT_TEST_TRACE_DIR = %q`, collectTestTraceDir))
+	flagsCode = replaceByLine(flagsCode, `const STRACE_LIMITS = `, fmt.Sprintf(`const STRACE_LIMITS = %q`, straceLimits))
+	flagsCode = replaceByLine(flagsCode, `const STRACE_REPLAY_DIR = `, fmt.Sprintf(`const STRACE_REPLAY_DIR = %q`, straceReplayDir))
+	flagsCode = replaceByLine(flagsCode, `const STRACE_REPLAY_RULES = `, fmt.Sprintf(`const STRACE_REPLAY_RULES = %q`, straceReplayRules))
 	flagsCode = replaceByLine(flagsCode, `const XGO_RACE_SAFE = `, fmt.Sprintf(`const XGO_RACE_SAFE = %t`, xgoRaceSafe))
 	return flagsCode
 }
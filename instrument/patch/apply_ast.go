@@ -0,0 +1,382 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// wrapBodyPlaceholder stands for the original statements in a
+// wrap_body template, it cannot appear in valid Go source
+const wrapBodyPlaceholder = "$body"
+
+// structInsert is an insert made by a structural command
+// (add_field, add_param, add_result, wrap_body, add_import).
+// Unlike text commands it does not move the cursor, and
+// inserts at the same offset are wrapped with a single marker.
+type structInsert struct {
+	offset int
+	text   string
+}
+
+func (s *applyState) addInsert(offset int, text string) {
+	s.inserts = append(s.inserts, structInsert{offset: offset, text: text})
+}
+
+func (s *applyState) offsetOf(pos token.Pos) int {
+	return s.fset.Position(pos).Offset
+}
+
+func (s *applyState) sameLine(a token.Pos, b token.Pos) bool {
+	return s.fset.Position(a).Line == s.fset.Position(b).Line
+}
+
+// lineIndent returns the leading whitespace of the line containing offset
+func (s *applyState) lineIndent(offset int) string {
+	start := strings.LastIndex(s.original[:offset], "\n") + 1
+	line := s.original[start:]
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// evalAddField appends a field to the end of a struct,
+// on its own line unless the struct is a one-liner.
+func evalAddField(s *applyState, cmd Command) error {
+	st, err := findStruct(s, cmd.DeclTarget)
+	if err != nil {
+		return err
+	}
+	if !token.IsIdentifier(cmd.Name) {
+		return fmt.Errorf("invalid field name: %q", cmd.Name)
+	}
+	typ, err := formatTypeExpr(cmd.TypeExpr, false)
+	if err != nil {
+		return err
+	}
+	for _, field := range st.Fields.List {
+		if fieldHasName(field, cmd.Name) {
+			return fmt.Errorf("field %s already exists in struct %s", cmd.Name, cmd.DeclTarget)
+		}
+	}
+	decl := cmd.Name + " " + typ
+
+	list := st.Fields.List
+	if len(list) == 0 {
+		if s.sameLine(st.Fields.Opening, st.Fields.Closing) {
+			s.addInsert(s.offsetOf(st.Fields.Opening)+1, decl)
+			return nil
+		}
+		// the line after {
+		opening := s.offsetOf(st.Fields.Opening)
+		nl := strings.Index(s.original[opening:], "\n")
+		s.addInsert(opening+nl+1, s.lineIndent(s.offsetOf(st.Fields.Closing))+"\t"+decl+"\n")
+		return nil
+	}
+	last := list[len(list)-1]
+	if s.sameLine(last.End(), st.Fields.Closing) {
+		s.addInsert(s.offsetOf(last.End()), "; "+decl)
+		return nil
+	}
+	// the line after the last field, skipping its trailing comment
+	lastEnd := s.offsetOf(last.End())
+	nl := strings.Index(s.original[lastEnd:], "\n")
+	s.addInsert(lastEnd+nl+1, s.lineIndent(s.offsetOf(last.Pos()))+decl+"\n")
+	return nil
+}
+
+// evalAddParam appends a parameter to a function.
+// The existing parameters must be named and not variadic.
+func evalAddParam(s *applyState, cmd Command) error {
+	fd, err := findFunc(s, cmd.DeclTarget)
+	if err != nil {
+		return err
+	}
+	if !token.IsIdentifier(cmd.Name) {
+		return fmt.Errorf("invalid param name: %q", cmd.Name)
+	}
+	typ, err := formatTypeExpr(cmd.TypeExpr, true)
+	if err != nil {
+		return err
+	}
+	params := fd.Type.Params
+	if err := requireNamedFields(fd, params, "params"); err != nil {
+		return err
+	}
+	if err := checkNameFree(fd, cmd.Name); err != nil {
+		return err
+	}
+	if n := len(params.List); n > 0 {
+		if _, ok := params.List[n-1].Type.(*ast.Ellipsis); ok {
+			return fmt.Errorf("func %s is variadic, cannot append param", cmd.DeclTarget)
+		}
+	}
+	s.addToFieldList(params, cmd.Name+" "+typ)
+	return nil
+}
+
+// evalAddResult appends a named result to a function, and appends
+// it to every return statement with values, so the body still
+// compiles. The existing results must be named, bare returns
+// then return the new result as is.
+func evalAddResult(s *applyState, cmd Command) error {
+	fd, err := findFunc(s, cmd.DeclTarget)
+	if err != nil {
+		return err
+	}
+	if !token.IsIdentifier(cmd.Name) {
+		return fmt.Errorf("invalid result name: %q", cmd.Name)
+	}
+	typ, err := formatTypeExpr(cmd.TypeExpr, false)
+	if err != nil {
+		return err
+	}
+	if err := checkNameFree(fd, cmd.Name); err != nil {
+		return err
+	}
+	decl := cmd.Name + " " + typ
+	results := fd.Type.Results
+	if results == nil || len(results.List) == 0 {
+		if results != nil && results.Opening.IsValid() {
+			s.addInsert(s.offsetOf(results.Opening)+1, decl)
+		} else {
+			s.addInsert(s.offsetOf(fd.Type.Params.Closing)+1, " ("+decl+")")
+		}
+		return nil
+	}
+	if err := requireNamedFields(fd, results, "results"); err != nil {
+		return err
+	}
+	var numResults int
+	for _, field := range results.List {
+		numResults += len(field.Names)
+	}
+	if fd.Body != nil {
+		var retErr error
+		ast.Inspect(fd.Body, func(n ast.Node) bool {
+			if retErr != nil {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.ReturnStmt:
+				if len(n.Results) == 0 {
+					return false
+				}
+				if len(n.Results) != numResults {
+					retErr = fmt.Errorf("return at line %d has %d values for %d results", s.fset.Position(n.Pos()).Line, len(n.Results), numResults)
+					return false
+				}
+				s.addInsert(s.offsetOf(n.Results[len(n.Results)-1].End()), ", "+cmd.Name)
+				return false
+			}
+			return true
+		})
+		if retErr != nil {
+			return retErr
+		}
+	}
+	s.addToFieldList(results, decl)
+	return nil
+}
+
+// evalWrapBody puts the statements of the current function into
+// the template, in place of $body. The original statements are
+// left untouched, the text around $body is inserted after { and
+// before }, so line numbers of the body do not change if the
+// template is a single line.
+func evalWrapBody(s *applyState, template string) error {
+	fd, ok := s.cursorNode().(*ast.FuncDecl)
+	if !ok {
+		return fmt.Errorf("requires prior goto func")
+	}
+	if fd.Body == nil {
+		return fmt.Errorf("func %s has no body", fd.Name.Name)
+	}
+	idx := strings.Index(template, wrapBodyPlaceholder)
+	if idx < 0 {
+		return fmt.Errorf("template requires %s", wrapBodyPlaceholder)
+	}
+	prefix := template[:idx]
+	suffix := template[idx+len(wrapBodyPlaceholder):]
+
+	lbrace := s.offsetOf(fd.Body.Lbrace) + 1
+	rbrace := s.offsetOf(fd.Body.Rbrace)
+	wrapped := "package p\nfunc _() {" + prefix + s.original[lbrace:rbrace] + suffix + "}\n"
+	_, err := parser.ParseFile(token.NewFileSet(), "", wrapped, 0)
+	if err != nil {
+		return fmt.Errorf("wrapped body does not parse: %w", err)
+	}
+	if prefix != "" {
+		s.addInsert(lbrace, prefix)
+	}
+	if suffix != "" {
+		s.addInsert(rbrace, suffix)
+	}
+	return nil
+}
+
+// evalAddImport adds an import right after the package clause,
+// on the same line so that line numbers do not change.
+func evalAddImport(s *applyState, cmd Command) error {
+	name := cmd.Name
+	if name != "" && name != "." && !token.IsIdentifier(name) {
+		return fmt.Errorf("invalid import name: %q", name)
+	}
+	localName := name
+	if localName == "" {
+		localName = path.Base(cmd.ImportPath)
+	}
+	for _, imp := range s.astFile.Imports {
+		impPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		impName := path.Base(impPath)
+		if imp.Name != nil {
+			impName = imp.Name.Name
+		}
+		if localName == "_" || localName == "." || impName != localName {
+			continue
+		}
+		if impPath == cmd.ImportPath {
+			return fmt.Errorf("%q already imported", cmd.ImportPath)
+		}
+		return fmt.Errorf("import name %s already used by %q", localName, impPath)
+	}
+	text := ";import "
+	if name != "" {
+		text += name + " "
+	}
+	text += strconv.Quote(cmd.ImportPath)
+	s.addInsert(s.offsetOf(s.astFile.Name.End()), text)
+	return nil
+}
+
+func (s *applyState) addToFieldList(list *ast.FieldList, decl string) {
+	closing := s.offsetOf(list.Closing)
+	if len(list.List) == 0 {
+		s.addInsert(closing, decl)
+		return
+	}
+	last := list.List[len(list.List)-1]
+	lastEnd := s.offsetOf(last.End())
+	between := s.original[lastEnd:closing]
+	if strings.Contains(between, "\n") && strings.HasPrefix(strings.TrimSpace(between), ",") {
+		// one per line with trailing comma
+		comma := lastEnd + strings.Index(between, ",") + 1
+		s.addInsert(comma, "\n"+s.lineIndent(s.offsetOf(last.Pos()))+decl+",")
+		return
+	}
+	s.addInsert(lastEnd, ", "+decl)
+}
+
+func findStruct(s *applyState, name string) (*ast.StructType, error) {
+	for _, decl := range s.astFile.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok || ts.Name.Name != name {
+				continue
+			}
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok || st.Fields == nil {
+				return nil, fmt.Errorf("type %s is not a struct", name)
+			}
+			return st, nil
+		}
+	}
+	return nil, fmt.Errorf("struct not found: %s", name)
+}
+
+func findFunc(s *applyState, target string) (*ast.FuncDecl, error) {
+	c, err := evalGotoFunc(s, target)
+	if err != nil {
+		return nil, err
+	}
+	for _, decl := range s.astFile.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if ok && s.offsetOf(fd.Pos()) == c.offset {
+			return fd, nil
+		}
+	}
+	return nil, fmt.Errorf("function not found: %s", target)
+}
+
+// requireNamedFields checks that a param or result list is named,
+// otherwise appending a named one makes it invalid
+func requireNamedFields(fd *ast.FuncDecl, list *ast.FieldList, what string) error {
+	for _, field := range list.List {
+		if len(field.Names) == 0 {
+			return fmt.Errorf("%s of func %s are unnamed", what, fd.Name.Name)
+		}
+	}
+	return nil
+}
+
+// checkNameFree checks that no param or result of fd is called name
+func checkNameFree(fd *ast.FuncDecl, name string) error {
+	for _, list := range []*ast.FieldList{fd.Type.Params, fd.Type.Results} {
+		if list == nil {
+			continue
+		}
+		for _, field := range list.List {
+			if fieldHasName(field, name) {
+				return fmt.Errorf("%s already declared in func %s", name, fd.Name.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// fieldHasName tells if field declares name,
+// an embedded field is named after its type
+func fieldHasName(field *ast.Field, name string) bool {
+	if len(field.Names) == 0 {
+		typ := field.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		switch t := typ.(type) {
+		case *ast.Ident:
+			return t.Name == name
+		case *ast.SelectorExpr:
+			return t.Sel.Name == name
+		}
+		return false
+	}
+	for _, n := range field.Names {
+		if n.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// formatTypeExpr parses typ as an expression and prints it
+// in gofmt style, e.g. "map[string] int" -> "map[string]int"
+func formatTypeExpr(typ string, allowVariadic bool) (string, error) {
+	var prefix string
+	if allowVariadic && strings.HasPrefix(typ, "...") {
+		prefix = "..."
+		typ = typ[len("..."):]
+	}
+	expr, err := parser.ParseExpr(typ)
+	if err != nil {
+		return "", fmt.Errorf("invalid type %q: %w", typ, err)
+	}
+	var buf bytes.Buffer
+	err = format.Node(&buf, token.NewFileSet(), expr)
+	if err != nil {
+		return "", fmt.Errorf("invalid type %q: %w", typ, err)
+	}
+	return prefix + buf.String(), nil
+}
@@ -0,0 +1,269 @@
+package patch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/xgo/support/assert"
+)
+
+func TestParseXgoPatch_StructuralCommands(t *testing.T) {
+	patch := `<patch s>
+add_field g labels map[string] string
+add_param (*g) run ctx context.Context
+add_result f err error
+goto func f
+wrap_body with defer trace()(); $body
+add_import "context"
+add_import rt "runtime"
+</patch>`
+	pf, err := ParseXgoPatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for _, cmd := range pf.Blocks[0].Commands {
+		actual = append(actual, cmd.String())
+	}
+	expect := []string{
+		"add_field g labels map[string] string",
+		"add_param (*g) run ctx context.Context",
+		"add_result f err error",
+		"goto func f",
+		"wrap_body with defer trace()(); $body",
+		`add_import "context"`,
+		`add_import rt "runtime"`,
+	}
+	if strings.Join(actual, "\n") != strings.Join(expect, "\n") {
+		t.Fatalf("%s", assert.Diff(strings.Join(expect, "\n"), strings.Join(actual, "\n")))
+	}
+
+	bad := map[string]string{
+		"add_field g labels":           "requires",
+		"wrap_body defer x(); $body":   "with",
+		"wrap_body with defer x()":     "$body",
+		"wrap_body with $body; $body":  "$body",
+		"add_import a b c":             "add_import",
+		"add_param (*g run ctx string": "receiver",
+	}
+	for line, msg := range bad {
+		_, err := ParseXgoPatch("<patch bad>\n" + line + "\n</patch>")
+		if err == nil || !strings.Contains(err.Error(), msg) || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("%s: expect error containing %q at line 2, actual: %v", line, msg, err)
+		}
+	}
+}
+
+func TestApplyPatch_AddField(t *testing.T) {
+	source := `package p
+
+type g struct {
+	a int // a
+	b string
+}
+
+type one struct{ a int }
+
+type empty struct {
+}
+`
+	patch := `<patch f>
+add_field g labels map[string] string
+add_field one b  []byte
+add_field empty c int
+</patch>`
+	expect := `package p
+
+type g struct {
+	a int // a
+	b string
+/*<begin f>*/	labels map[string]string
+/*<end f>*/}
+
+type one struct{ a int/*<begin f>*/; b []byte/*<end f>*/ }
+
+type empty struct {
+/*<begin f>*/	c int
+/*<end f>*/}
+`
+	assertApply(t, source, patch, expect)
+}
+
+func TestApplyPatch_AddParamAndResult(t *testing.T) {
+	source := `package p
+
+func (x *g) run(a int) (n int, err error) {
+	if a > 0 {
+		return a, nil
+	}
+	f := func() (int, error) { return 0, nil }
+	_, _ = f()
+	return
+}
+
+func multi(
+	a int,
+	b string,
+) {
+}
+
+func none() {
+}
+`
+	patch := `<patch r>
+add_param (*g) run ctx context.Context
+add_result (*g) run ok bool
+add_param multi c ...int
+add_result none err error
+</patch>`
+	expect := `package p
+
+func (x *g) run(a int/*<begin r>*/, ctx context.Context/*<end r>*/) (n int, err error/*<begin r>*/, ok bool/*<end r>*/) {
+	if a > 0 {
+		return a, nil/*<begin r>*/, ok/*<end r>*/
+	}
+	f := func() (int, error) { return 0, nil }
+	_, _ = f()
+	return
+}
+
+func multi(
+	a int,
+	b string,/*<begin r>*/
+	c ...int,/*<end r>*/
+) {
+}
+
+func none()/*<begin r>*/ (err error)/*<end r>*/ {
+}
+`
+	assertApply(t, source, patch, expect)
+}
+
+func TestApplyPatch_WrapBodyAndAddImport(t *testing.T) {
+	source := `package p
+
+import "fmt"
+
+func f() {
+	fmt.Println("f")
+}
+`
+	patch := `<patch w>
+add_import "context"
+add_import rt "runtime"
+goto func f
+wrap_body with defer trace(context.TODO(), rt.GOOS)(); $body
+</patch>`
+	expect := `package p/*<begin w>*/;import "context";import rt "runtime"/*<end w>*/
+
+import "fmt"
+
+func f() {/*<begin w>*/defer trace(context.TODO(), rt.GOOS)(); /*<end w>*/
+	fmt.Println("f")
+}
+`
+	assertApply(t, source, patch, expect)
+}
+
+func TestApplyPatch_StructuralErrors(t *testing.T) {
+	source := `package p
+
+import "fmt"
+
+type g struct {
+	a int
+}
+
+type i interface{}
+
+func f(int) error {
+	return fmt.Errorf("f")
+}
+
+func v(a ...int) (int, error) {
+	return 0, nil
+}
+
+func h(a int) (n int) {
+	return 1
+}
+`
+	tests := map[string]string{
+		"add_field g a string":                   `add_field g: field a already exists in struct g`,
+		"add_field i a string":                   `add_field i: type i is not a struct`,
+		"add_field x a string":                   `add_field x: struct not found: x`,
+		"add_field g b map[string":               `add_field g: invalid type "map[string"`,
+		"add_param f ctx context.Context":        `add_param f: params of func f are unnamed`,
+		"add_param v ctx context.Context":        `add_param v: func v is variadic, cannot append param`,
+		"add_param h a string":                   `add_param h: a already declared in func h`,
+		"add_param missing a string":             `add_param missing: function not found: missing`,
+		"add_result f err error":                 `add_result f: results of func f are unnamed`,
+		"add_import \"fmt\"":                     `add_import: "fmt" already imported`,
+		"add_import fmt \"example.com/fmt\"":     `add_import: import name fmt already used by "fmt"`,
+		"wrap_body with defer x(); $body":        `wrap_body: requires prior goto func`,
+		"goto func h\nwrap_body with if { $body": `wrap_body: wrapped body does not parse`,
+	}
+	for line, msg := range tests {
+		_, err := ApplyXgoPatchContent(source, "<patch e>\n"+line+"\n</patch>")
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: expect error containing %q, actual: %v", line, msg, err)
+		}
+	}
+}
+
+func TestApplyPatch_AddResultReturnMismatch(t *testing.T) {
+	source := `package p
+
+func h() (a, b int) {
+	return pair()
+}
+`
+	_, err := ApplyXgoPatchContent(source, "<patch e>\nadd_result h err error\n</patch>")
+	if err == nil || !strings.Contains(err.Error(), "return at line 4 has 1 values for 2 results") {
+		t.Fatalf("expect return mismatch, actual: %v", err)
+	}
+}
+
+func TestApplyPatch_StructuralIdempotent(t *testing.T) {
+	source := `package p
+
+type g struct {
+	a int
+}
+
+func f(a int) (n int) {
+	return a
+}
+`
+	patch := `<patch s>
+add_import "context"
+add_field g ctx context.Context
+add_param f ctx context.Context
+add_result f err error
+goto func f
+wrap_body with _ = ctx; $body
+</patch>`
+	result1, err := ApplyXgoPatchContent(source, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result2, err := ApplyXgoPatchContent(result1, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result1 != result2 {
+		t.Errorf("idempotent apply failed:\n%s", assert.Diff(result1, result2))
+	}
+}
+
+func assertApply(t *testing.T, source string, patch string, expect string) {
+	t.Helper()
+	result, err := ApplyXgoPatchContent(source, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != expect {
+		t.Errorf("%s", assert.Diff(expect, result))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"sort"
 	"strings"
 )
 
@@ -37,10 +38,29 @@ type editGroup struct {
 
 // applyPatch applies a single PatchBlock to the original Go source text.
 func applyPatch(original string, block PatchBlock) (string, error) {
+	state, err := execCommands(original, block.Commands)
+	if err != nil {
+		return "", err
+	}
+
+	result := state.applyEdits(block.Name)
+	if len(state.inserts) > 0 {
+		// structural commands are expected to keep the file valid
+		_, err := parser.ParseFile(token.NewFileSet(), "", result, parser.ParseComments)
+		if err != nil {
+			return "", fmt.Errorf("patched file does not parse: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// execCommands executes cmds on original without applying the edits,
+// a failed command is returned as *CommandError
+func execCommands(original string, cmds []Command) (*applyState, error) {
 	fset := token.NewFileSet()
 	astFile, err := parser.ParseFile(fset, "", original, parser.ParseComments)
 	if err != nil {
-		return "", fmt.Errorf("parse target file: %w", err)
+		return nil, fmt.Errorf("parse target file: %w", err)
 	}
 
 	state := &applyState{
@@ -50,92 +70,13 @@ func applyPatch(original string, block PatchBlock) (string, error) {
 		groups:   make(map[int]*editGroup),
 	}
 
-	for _, cmd := range block.Commands {
-		switch cmd.Type {
-		case CmdGoto:
-			c, err := evalGoto(state, cmd.GotoTarget)
-			if err != nil {
-				return "", fmt.Errorf("goto %s: %w", cmd.GotoTarget, err)
-			}
-			state.cursor = c
-			state.seq++
-			state.lastInsertMode = insertBefore
-
-		case CmdMatch:
-			c, err := evalMatch(state, cmd.SearchText, false)
-			if err != nil {
-				return "", fmt.Errorf("match %q: %w", cmd.SearchText, err)
-			}
-			state.cursor = c
-			state.seq++
-			state.lastInsertMode = insertBefore
-
-		case CmdFindForReplace:
-			c, err := evalMatch(state, cmd.SearchText, true)
-			if err != nil {
-				return "", fmt.Errorf("find_for_replace %q: %w", cmd.SearchText, err)
-			}
-			state.cursor = c
-			state.seq++
-			state.lastInsertMode = insertBefore
-
-		case CmdInsertBefore:
-			if cmd.EditText == "" {
-				return "", fmt.Errorf("insert_before requires text")
-			}
-			state.lastInsertMode = insertBefore
-			state.addSegment(cmd.EditText)
-
-		case CmdInsertAfter:
-			if cmd.EditText == "" {
-				return "", fmt.Errorf("insert_after requires text")
-			}
-			state.lastInsertMode = insertAfter
-			state.addSegment(cmd.EditText)
-
-		case CmdInsertAfterLine:
-			if cmd.EditText == "" {
-				return "", fmt.Errorf("insert_after_line requires text")
-			}
-			if state.cursor.endOffset < len(state.original) && state.original[state.cursor.endOffset] == '\n' {
-				state.cursor.endOffset++
-			}
-			state.lastInsertMode = insertAfter
-			state.addSegment(cmd.EditText)
-
-		case CmdReplace:
-			if cmd.EditText == "" {
-				return "", fmt.Errorf("replace requires text")
-			}
-			if !state.cursor.isReplace {
-				return "", fmt.Errorf("replace requires prior find_for_replace")
-			}
-			oldText := state.original[state.cursor.offset:state.cursor.endOffset]
-			state.lastInsertMode = insertBefore
-			state.addReplace(cmd.EditText, oldText)
-
-		case CmdNewline:
-			state.addNewline()
-
-		case CmdCopyFunc:
-			copied, err := evalCopyFunc(state, cmd.CopySource, cmd.CopyTarget)
-			if err != nil {
-				return "", fmt.Errorf("copy_func %q: %w", cmd.CopySource, err)
-			}
-			state.appendContent = strings.TrimSuffix(copied, "\n")
-
-		case CmdReplaceDirective:
-			if cmd.SearchText == "" || cmd.CopyTarget == "" {
-				return "", fmt.Errorf("replace_directive requires old and new text")
-			}
-			state.directiveReplace = &directiveReplacement{
-				oldText: cmd.SearchText,
-				newText: cmd.CopyTarget,
-			}
+	for _, cmd := range cmds {
+		err := state.exec(cmd)
+		if err != nil {
+			return nil, &CommandError{Command: cmd, Err: err}
 		}
 	}
-
-	return state.applyEdits(block.Name), nil
+	return state, nil
 }
 
 type insertMode int
@@ -154,6 +95,7 @@ type applyState struct {
 	lastInsertMode insertMode
 
 	groups           map[int]*editGroup
+	inserts          []structInsert
 	appendContent    string
 	directiveReplace *directiveReplacement
 }
@@ -163,6 +105,123 @@ type directiveReplacement struct {
 	newText string
 }
 
+// exec moves the cursor or collects edits of a single command
+func (s *applyState) exec(cmd Command) error {
+	switch cmd.Type {
+	case CmdGoto:
+		c, err := evalGoto(s, cmd.GotoTarget)
+		if err != nil {
+			return fmt.Errorf("goto %s: %w", cmd.GotoTarget, err)
+		}
+		s.cursor = c
+		s.seq++
+		s.lastInsertMode = insertBefore
+
+	case CmdMatch:
+		c, err := evalMatch(s, cmd.SearchText, false)
+		if err != nil {
+			return fmt.Errorf("match %q: %w", cmd.SearchText, err)
+		}
+		s.cursor = c
+		s.seq++
+		s.lastInsertMode = insertBefore
+
+	case CmdFindForReplace:
+		c, err := evalMatch(s, cmd.SearchText, true)
+		if err != nil {
+			return fmt.Errorf("find_for_replace %q: %w", cmd.SearchText, err)
+		}
+		s.cursor = c
+		s.seq++
+		s.lastInsertMode = insertBefore
+
+	case CmdInsertBefore:
+		if cmd.EditText == "" {
+			return fmt.Errorf("insert_before requires text")
+		}
+		s.lastInsertMode = insertBefore
+		s.addSegment(cmd.EditText)
+
+	case CmdInsertAfter:
+		if cmd.EditText == "" {
+			return fmt.Errorf("insert_after requires text")
+		}
+		s.lastInsertMode = insertAfter
+		s.addSegment(cmd.EditText)
+
+	case CmdInsertAfterLine:
+		if cmd.EditText == "" {
+			return fmt.Errorf("insert_after_line requires text")
+		}
+		if s.cursor.endOffset < len(s.original) && s.original[s.cursor.endOffset] == '\n' {
+			s.cursor.endOffset++
+		}
+		s.lastInsertMode = insertAfter
+		s.addSegment(cmd.EditText)
+
+	case CmdReplace:
+		if cmd.EditText == "" {
+			return fmt.Errorf("replace requires text")
+		}
+		if !s.cursor.isReplace {
+			return fmt.Errorf("replace requires prior find_for_replace")
+		}
+		oldText := s.original[s.cursor.offset:s.cursor.endOffset]
+		s.lastInsertMode = insertBefore
+		s.addReplace(cmd.EditText, oldText)
+
+	case CmdNewline:
+		s.addNewline()
+
+	case CmdCopyFunc:
+		copied, err := evalCopyFunc(s, cmd.CopySource, cmd.CopyTarget)
+		if err != nil {
+			return fmt.Errorf("copy_func %q: %w", cmd.CopySource, err)
+		}
+		s.appendContent = strings.TrimSuffix(copied, "\n")
+
+	case CmdReplaceDirective:
+		if cmd.SearchText == "" || cmd.CopyTarget == "" {
+			return fmt.Errorf("replace_directive requires old and new text")
+		}
+		s.directiveReplace = &directiveReplacement{
+			oldText: cmd.SearchText,
+			newText: cmd.CopyTarget,
+		}
+
+	case CmdAddField:
+		err := evalAddField(s, cmd)
+		if err != nil {
+			return fmt.Errorf("add_field %s: %w", cmd.DeclTarget, err)
+		}
+
+	case CmdAddParam:
+		err := evalAddParam(s, cmd)
+		if err != nil {
+			return fmt.Errorf("add_param %s: %w", cmd.DeclTarget, err)
+		}
+
+	case CmdAddResult:
+		err := evalAddResult(s, cmd)
+		if err != nil {
+			return fmt.Errorf("add_result %s: %w", cmd.DeclTarget, err)
+		}
+
+	case CmdWrapBody:
+		err := evalWrapBody(s, cmd.EditText)
+		if err != nil {
+			return fmt.Errorf("wrap_body: %w", err)
+		}
+
+	case CmdAddImport:
+		err := evalAddImport(s, cmd)
+		if err != nil {
+			return fmt.Errorf("add_import: %w", err)
+		}
+	}
+	return nil
+}
+
 func (s *applyState) getGroup() *editGroup {
 	g, ok := s.groups[s.seq]
 	if !ok {
@@ -229,12 +288,20 @@ func (s *applyState) combineSegments(g *editGroup) string {
 	return buf.String()
 }
 
+// placement replaces original[start:end] with text, end == start for inserts
+type placement struct {
+	start int
+	end   int
+	text  string
+}
+
 // applyEdits applies all collected edits and wraps them with markers.
 func (s *applyState) applyEdits(blockName string) string {
 	result := s.original
+	markerBegin := fmt.Sprintf("/*<begin %s>*/", blockName)
+	markerEnd := fmt.Sprintf("/*<end %s>*/", blockName)
 
-	// Process edits in REVERSE offset order (highest offset first)
-	// to avoid position shifts when inserting text.
+	var placements []placement
 	maxSeq := s.seq
 	for seq := maxSeq; seq >= 1; seq-- {
 		g, ok := s.groups[seq]
@@ -248,37 +315,52 @@ func (s *applyState) applyEdits(blockName string) string {
 		}
 
 		if g.isReplace {
-			markerBegin := fmt.Sprintf("/*<begin %s>*/", blockName)
+			begin := markerBegin
 			if g.oldText != "" {
-				markerBegin += fmt.Sprintf("/*old:%s*/", g.oldText)
+				begin += fmt.Sprintf("/*old:%s*/", g.oldText)
 			}
-			markerEnd := fmt.Sprintf("/*<end %s>*/", blockName)
-			wrapped := markerBegin + insertText + markerEnd
-
 			// Replace old content (from offset to oldEnd) with wrapped text
-			result = result[:g.offset] + wrapped + result[g.offset+len(g.oldText):]
+			placements = append(placements, placement{start: g.offset, end: g.offset + len(g.oldText), text: begin + insertText + markerEnd})
 		} else {
-			markerBegin := fmt.Sprintf("/*<begin %s>*/", blockName)
-			markerEnd := fmt.Sprintf("/*<end %s>*/", blockName)
-
+			wrapped := markerBegin + insertText + markerEnd
 			if s.lastInsertMode == insertBefore || seq < maxSeq {
 				// insert_before: insert at offset
-				wrapped := markerBegin + insertText + markerEnd
-				result = result[:g.offset] + wrapped + result[g.offset:]
+				placements = append(placements, placement{start: g.offset, end: g.offset, text: wrapped})
 			} else {
 				// insert_after: insert at insertEnd
-				wrapped := markerBegin + insertText + markerEnd
-				result = result[:g.insertEnd] + wrapped + result[g.insertEnd:]
+				placements = append(placements, placement{start: g.insertEnd, end: g.insertEnd, text: wrapped})
 			}
 		}
 	}
 
+	// merge structural inserts of the same offset, in command order
+	insertTexts := make(map[int]string, len(s.inserts))
+	var insertOffsets []int
+	for _, ins := range s.inserts {
+		if _, ok := insertTexts[ins.offset]; !ok {
+			insertOffsets = append(insertOffsets, ins.offset)
+		}
+		insertTexts[ins.offset] += ins.text
+	}
+	for _, offset := range insertOffsets {
+		placements = append(placements, placement{start: offset, end: offset, text: markerBegin + insertTexts[offset] + markerEnd})
+	}
+
+	// Process edits in REVERSE offset order (highest offset first)
+	// to avoid position shifts when inserting text. Edits of the
+	// same offset are kept in reverse sequence order, so the first
+	// one ends up first.
+	sort.SliceStable(placements, func(i, j int) bool {
+		return placements[i].start > placements[j].start
+	})
+	for _, p := range placements {
+		result = result[:p.start] + p.text + result[p.end:]
+	}
+
 	if s.appendContent != "" {
 		if !strings.HasSuffix(result, "\n") {
 			result += "\n"
 		}
-		markerBegin := fmt.Sprintf("/*<begin %s>*/", blockName)
-		markerEnd := fmt.Sprintf("/*<end %s>*/", blockName)
 		result += markerBegin + s.appendContent + markerEnd
 	}
 
@@ -513,6 +595,16 @@ func (s *applyState) findNodeAt(node ast.Node, pos token.Pos) ast.Node {
 	return node
 }
 
+// matchScope returns the range searched by match and find_for_replace,
+// which is the declaration at the cursor, or the whole file
+func (s *applyState) matchScope() (start int, end int) {
+	node := s.cursorNode()
+	if node != nil && node != s.astFile {
+		return s.fset.Position(node.Pos()).Offset, s.fset.Position(node.End()).Offset
+	}
+	return 0, len(s.original)
+}
+
 // evalMatch finds text within the current scope and returns a cursor.
 //
 // When forReplace is true and the cursor already sits past the start of the
@@ -521,15 +613,7 @@ func (s *applyState) findNodeAt(node ast.Node, pos token.Pos) ast.Node {
 // following marker and replace the preceding line without matching an earlier
 // decoy in the same function (see xgo_proc_defer_racegostart).
 func evalMatch(state *applyState, searchText string, forReplace bool) (cursor, error) {
-	// Determine search scope
-	scopeStart := 0
-	scopeEnd := len(state.original)
-	node := state.cursorNode()
-
-	if node != nil && node != state.astFile {
-		scopeStart = state.fset.Position(node.Pos()).Offset
-		scopeEnd = state.fset.Position(node.End()).Offset
-	}
+	scopeStart, scopeEnd := state.matchScope()
 
 	if forReplace && state.cursor.offset > scopeStart && state.cursor.offset <= scopeEnd {
 		before := state.original[scopeStart:state.cursor.offset]
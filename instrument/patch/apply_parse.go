@@ -2,6 +2,7 @@ package patch
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -17,6 +18,7 @@ func ParseXgoPatch(content string) (*PatchFile, error) {
 func parseBlocks(content string) ([]PatchBlock, error) {
 	var blocks []PatchBlock
 
+	line := 1
 	for {
 		openIdx := strings.Index(content, "<patch")
 		if openIdx < 0 {
@@ -36,18 +38,21 @@ func parseBlocks(content string) ([]PatchBlock, error) {
 		closeIdx += openTagEnd
 		closeEnd := closeIdx + len("</patch>")
 
-		block, err := parseBlock(content[openIdx:closeEnd])
+		line += strings.Count(content[:openIdx], "\n")
+		block, err := parseBlock(content[openIdx:closeEnd], line)
 		if err != nil {
 			return nil, err
 		}
 		blocks = append(blocks, block)
+		line += strings.Count(content[openIdx:closeEnd], "\n")
 		content = content[closeEnd:]
 	}
 
 	return blocks, nil
 }
 
-func parseBlock(blockContent string) (PatchBlock, error) {
+// parseBlock parses a single block starting at startLine
+func parseBlock(blockContent string, startLine int) (PatchBlock, error) {
 	openIdx := strings.Index(blockContent, "<patch")
 	openTagEnd := strings.Index(blockContent[openIdx:], ">")
 	openTagEnd += openIdx + 1
@@ -59,10 +64,11 @@ func parseBlock(blockContent string) (PatchBlock, error) {
 	closeIdx += openTagEnd
 	bodyContent := blockContent[openTagEnd:closeIdx]
 
-	block := PatchBlock{Name: tagContent}
+	block := PatchBlock{Name: tagContent, Line: startLine}
 
+	bodyLine := startLine + strings.Count(blockContent[:openTagEnd], "\n")
 	lines := strings.Split(bodyContent, "\n")
-	for _, line := range lines {
+	for i, line := range lines {
 		line = strings.TrimRight(line, " \t\r")
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
@@ -70,8 +76,9 @@ func parseBlock(blockContent string) (PatchBlock, error) {
 
 		cmd, err := parseLine(line)
 		if err != nil {
-			return PatchBlock{}, fmt.Errorf("in patch %q: %w", tagContent, err)
+			return PatchBlock{}, fmt.Errorf("in patch %q: line %d: %w", tagContent, bodyLine+i, err)
 		}
+		cmd.Line = bodyLine + i
 		block.Commands = append(block.Commands, cmd)
 	}
 
@@ -113,6 +120,16 @@ func parseLine(line string) (Command, error) {
 		return parseCopyFuncLine(rest)
 	case cmd == "replace_directive":
 		return parseReplaceDirectiveLine(rest)
+	case cmd == "add_field":
+		return parseAddDeclLine(CmdAddField, cmd, rest)
+	case cmd == "add_param":
+		return parseAddDeclLine(CmdAddParam, cmd, rest)
+	case cmd == "add_result":
+		return parseAddDeclLine(CmdAddResult, cmd, rest)
+	case cmd == "wrap_body":
+		return parseWrapBodyLine(rest)
+	case cmd == "add_import":
+		return parseAddImportLine(rest)
 	default:
 		return Command{}, fmt.Errorf("unknown command: %q", line)
 	}
@@ -190,6 +207,65 @@ func parseReplaceDirectiveLine(rest string) (Command, error) {
 	}, nil
 }
 
+// parseAddDeclLine parses `<target> <name> <type>` of add_field,
+// add_param and add_result, where target of the latter two can
+// be a method like `(b *Builder) build`. The type is the rest of
+// the line, so it may contain spaces, e.g. `chan int`.
+func parseAddDeclLine(typ CommandType, name string, rest string) (Command, error) {
+	rest = strings.TrimSpace(rest)
+	var recv string
+	if typ != CmdAddField && strings.HasPrefix(rest, "(") {
+		closeParen := strings.Index(rest, ")")
+		if closeParen < 0 {
+			return Command{}, fmt.Errorf("%s: invalid func receiver: %q", name, rest)
+		}
+		recv = rest[:closeParen+1] + " "
+		rest = strings.TrimSpace(rest[closeParen+1:])
+	}
+	fields := strings.SplitN(rest, " ", 3)
+	if len(fields) < 3 || strings.TrimSpace(fields[2]) == "" {
+		return Command{}, fmt.Errorf("%s requires <target> <name> <type>: %q", name, rest)
+	}
+	return Command{
+		Type:       typ,
+		DeclTarget: recv + fields[0],
+		Name:       fields[1],
+		TypeExpr:   strings.TrimSpace(fields[2]),
+	}, nil
+}
+
+func parseWrapBodyLine(rest string) (Command, error) {
+	rest = strings.TrimSpace(rest)
+	if !strings.HasPrefix(rest, "with ") {
+		return Command{}, fmt.Errorf("wrap_body requires 'with' keyword: %q", rest)
+	}
+	template := strings.TrimSpace(rest[len("with "):])
+	if strings.Count(template, wrapBodyPlaceholder) != 1 {
+		return Command{}, fmt.Errorf("wrap_body template requires exactly one %s: %q", wrapBodyPlaceholder, template)
+	}
+	return Command{Type: CmdWrapBody, EditText: template}, nil
+}
+
+func parseAddImportLine(rest string) (Command, error) {
+	fields := strings.Fields(rest)
+	var name, path string
+	switch len(fields) {
+	case 1:
+		path = fields[0]
+	case 2:
+		name, path = fields[0], fields[1]
+	default:
+		return Command{}, fmt.Errorf("add_import requires [<name>] <path>: %q", rest)
+	}
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		path = unquoted
+	}
+	if path == "" {
+		return Command{}, fmt.Errorf("add_import requires path")
+	}
+	return Command{Type: CmdAddImport, Name: name, ImportPath: path}, nil
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
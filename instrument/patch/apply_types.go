@@ -1,5 +1,10 @@
 package patch
 
+import (
+	"fmt"
+	"strconv"
+)
+
 // PatchFile represents a parsed .xgo.patch file containing one or more <patch> blocks.
 type PatchFile struct {
 	Blocks []PatchBlock
@@ -9,6 +14,9 @@ type PatchFile struct {
 type PatchBlock struct {
 	Name     string
 	Commands []Command
+
+	// Line of the <patch> tag in the .xgo.patch file, 1-based
+	Line int
 }
 
 // CommandType identifies the type of a command within a patch block.
@@ -25,6 +33,11 @@ const (
 	CmdCopyFunc                          // copy_func <source> as <target> [append to file end]
 	CmdReplaceDirective                  // replace_directive <old> with <new>
 	CmdInsertAfterLine                   // insert_after_line <text> (includes trailing \n in insert point)
+	CmdAddField                          // add_field <struct> <name> <type>
+	CmdAddParam                          // add_param <func> <name> <type>
+	CmdAddResult                         // add_result <func> <name> <type>
+	CmdWrapBody                          // wrap_body with <template> (requires prior goto func)
+	CmdAddImport                         // add_import [<name>] <path>
 )
 
 // Command represents a single instruction within a <patch> block.
@@ -43,6 +56,17 @@ type Command struct {
 	// For copy_func / replace_directive:
 	CopySource string // source function name
 	CopyTarget string // target function name or replace_directive new text
+
+	// For add_field / add_param / add_result:
+	DeclTarget string // struct name, or func as in goto, e.g. "newproc", "(b *Builder) build"
+	Name       string // field, param, result or import name
+	TypeExpr   string // type of the field, param or result
+
+	// For add_import:
+	ImportPath string
+
+	// Line in the .xgo.patch file, 1-based
+	Line int
 }
 
 // String returns a human-readable representation of the command.
@@ -68,7 +92,38 @@ func (c Command) String() string {
 		return "copy_func " + c.CopySource + " as " + c.CopyTarget + " append to file end"
 	case CmdReplaceDirective:
 		return "replace_directive " + c.SearchText + " with " + c.CopyTarget
+	case CmdAddField:
+		return "add_field " + c.DeclTarget + " " + c.Name + " " + c.TypeExpr
+	case CmdAddParam:
+		return "add_param " + c.DeclTarget + " " + c.Name + " " + c.TypeExpr
+	case CmdAddResult:
+		return "add_result " + c.DeclTarget + " " + c.Name + " " + c.TypeExpr
+	case CmdWrapBody:
+		return "wrap_body with " + c.EditText
+	case CmdAddImport:
+		if c.Name != "" {
+			return "add_import " + c.Name + " " + strconv.Quote(c.ImportPath)
+		}
+		return "add_import " + strconv.Quote(c.ImportPath)
 	default:
 		return "unknown"
 	}
 }
+
+// CommandError reports a command of a block that cannot be
+// applied to the target file, e.g. an anchor not found
+type CommandError struct {
+	Command Command
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	if e.Command.Line > 0 {
+		return fmt.Sprintf("line %d: %v", e.Command.Line, e.Err)
+	}
+	return e.Err.Error()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
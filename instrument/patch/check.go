@@ -0,0 +1,145 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileCheck is the result of applying a .xgo.patch file
+// to its target in memory, without writing the target
+type FileCheck struct {
+	// PatchFile is relative to the patch dir, slash separated
+	PatchFile string
+	// TargetFile is relative to GOROOT, slash separated
+	TargetFile string
+
+	Blocks   int
+	Original string
+	// Patched has all blocks applied except failed ones
+	Patched string
+	Errors  []*BlockError
+}
+
+// BlockError reports a block that cannot be applied.
+// Block is empty if the whole file failed, e.g. the
+// target does not exist or the patch cannot be parsed.
+type BlockError struct {
+	Block string
+	// Line of the failed command, or the block if
+	// not caused by a single command
+	Line int
+	Err  error
+}
+
+func (e *BlockError) Error() string {
+	if e.Block == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("line %d: patch %q: %v", e.Line, e.Block, e.Err)
+}
+
+func (e *BlockError) Unwrap() error {
+	return e.Err
+}
+
+// CheckPatches applies every .xgo.patch file under patchDir to
+// the corresponding file of goroot in memory. Unlike ApplyPatches,
+// failed blocks are skipped so that all of them are reported, and
+// other files and __config__.json are not processed.
+func CheckPatches(patchDir string, goroot string) ([]*FileCheck, error) {
+	var checks []*FileCheck
+	err := filepath.Walk(patchDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".xgo.patch") {
+			return nil
+		}
+		relPath, err := filepath.Rel(patchDir, path)
+		if err != nil {
+			return err
+		}
+		targetRel := strings.TrimSuffix(relPath, ".xgo.patch")
+		check, err := checkPatchFile(path, filepath.Join(goroot, targetRel))
+		if err != nil {
+			return err
+		}
+		check.PatchFile = filepath.ToSlash(relPath)
+		check.TargetFile = filepath.ToSlash(targetRel)
+		checks = append(checks, check)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
+
+func checkPatchFile(patchFile string, targetFile string) (*FileCheck, error) {
+	patchContent, err := os.ReadFile(patchFile)
+	if err != nil {
+		return nil, err
+	}
+	check := &FileCheck{}
+	targetContent, err := os.ReadFile(targetFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		check.Errors = append(check.Errors, &BlockError{Err: fmt.Errorf("target not found: %s", targetFile)})
+		return check, nil
+	}
+	check.Original = string(targetContent)
+	check.Patched, check.Blocks, check.Errors = CheckXgoPatchContent(check.Original, string(patchContent))
+	return check, nil
+}
+
+// CheckXgoPatchContent applies patch content to source like ApplyXgoPatchContent,
+// but skips failed blocks and reports all of them instead of stopping at the first.
+// It also reports replace_directive commands whose directive is not found, which
+// ApplyXgoPatchContent silently ignores.
+func CheckXgoPatchContent(source string, patchContent string) (result string, blocks int, errs []*BlockError) {
+	pf, err := ParseXgoPatch(patchContent)
+	if err != nil {
+		return source, 0, []*BlockError{{Err: err}}
+	}
+
+	result = source
+	for _, block := range pf.Blocks {
+		cleared := result
+		if block.Name != "" {
+			cleared = clearPatch(result, block.Name)
+		}
+		patched, err := applyPatch(cleared, block)
+		if err == nil {
+			err = checkDirectives(cleared, block)
+		}
+		if err != nil {
+			line := block.Line
+			var cmdErr *CommandError
+			if errors.As(err, &cmdErr) {
+				line = cmdErr.Command.Line
+				err = cmdErr.Err
+			}
+			errs = append(errs, &BlockError{Block: block.Name, Line: line, Err: err})
+			continue
+		}
+		result = patched
+	}
+	return result, len(pf.Blocks), errs
+}
+
+func checkDirectives(source string, block PatchBlock) error {
+	for _, cmd := range block.Commands {
+		if cmd.Type == CmdReplaceDirective && !strings.Contains(source, cmd.SearchText) {
+			return &CommandError{
+				Command: cmd,
+				Err:     fmt.Errorf("replace_directive: directive not found: %q", cmd.SearchText),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package patch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseXgoPatch_Lines(t *testing.T) {
+	patch := `# header
+<patch first>
+goto func A
+# comment
+
+insert_after x()
+</patch>
+
+<patch second>
+match y
+insert_before z
+</patch>`
+	pf, err := ParseXgoPatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []int
+	for _, block := range pf.Blocks {
+		lines = append(lines, block.Line)
+		for _, cmd := range block.Commands {
+			lines = append(lines, cmd.Line)
+		}
+	}
+	expect := []int{2, 3, 6, 9, 10, 11}
+	if len(lines) != len(expect) {
+		t.Fatalf("expect lines %v, actual: %v", expect, lines)
+	}
+	for i := range expect {
+		if lines[i] != expect[i] {
+			t.Fatalf("expect lines %v, actual: %v", expect, lines)
+		}
+	}
+
+	_, err = ParseXgoPatch("<patch bad>\ngoto func A\nfrobnicate\n</patch>")
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("expect error at line 3, actual: %v", err)
+	}
+}
+
+func TestCheckXgoPatchContent(t *testing.T) {
+	source := `package p
+
+//go:linkname a b
+func A() {
+	a()
+}
+`
+	patch := `<patch ok>
+goto func A
+match a()
+insert_before before();
+</patch>
+
+<patch missing_func>
+goto func B
+goto opening {
+insert_after x()
+</patch>
+
+<patch missing_match>
+goto func A
+match c()
+insert_after x()
+</patch>
+
+<patch missing_directive>
+replace_directive //go:linkname c d with //go:linkname c e
+</patch>`
+
+	result, blocks, errs := CheckXgoPatchContent(source, patch)
+	if blocks != 4 {
+		t.Fatalf("expect 4 blocks, actual: %d", blocks)
+	}
+	if !strings.Contains(result, "/*<begin ok>*/before();/*<end ok>*/a()") {
+		t.Fatalf("expect ok applied:\n%s", result)
+	}
+	var actual []string
+	for _, err := range errs {
+		actual = append(actual, err.Error())
+	}
+	expect := []string{
+		`line 8: patch "missing_func": goto func B: function not found: B`,
+		`line 15: patch "missing_match": match "c()": text not found in scope: "c()"`,
+		`line 20: patch "missing_directive": replace_directive: directive not found: "//go:linkname c d"`,
+	}
+	if strings.Join(actual, "\n") != strings.Join(expect, "\n") {
+		t.Fatalf("expect:\n%s\nactual:\n%s", strings.Join(expect, "\n"), strings.Join(actual, "\n"))
+	}
+
+	// the same error is reported by apply
+	_, err := ApplyXgoPatchContent(source, patch)
+	if err == nil || !strings.Contains(err.Error(), "line 8: goto func B") {
+		t.Fatalf("expect error at line 8, actual: %v", err)
+	}
+}
+
+func TestCheckPatches(t *testing.T) {
+	patchDir := t.TempDir()
+	goroot := t.TempDir()
+	write := func(file string, content string) {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(goroot, "src", "p", "a.go"), "package p\n\nfunc A() {}\n")
+	write(filepath.Join(patchDir, "src", "p", "a.go.xgo.patch"), "<patch a>\ngoto func A\ninsert_before // A\nnewline\n</patch>\n")
+	write(filepath.Join(patchDir, "src", "p", "missing.go.xgo.patch"), "<patch m>\ngoto func M\n</patch>\n")
+	write(filepath.Join(patchDir, "src", "p", "xgo_copied.go"), "package p\n")
+
+	checks, err := CheckPatches(patchDir, goroot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 2 {
+		t.Fatalf("expect 2 files, actual: %d", len(checks))
+	}
+	a, missing := checks[0], checks[1]
+	if a.PatchFile != "src/p/a.go.xgo.patch" || a.TargetFile != "src/p/a.go" || a.Blocks != 1 || len(a.Errors) != 0 {
+		t.Fatalf("unexpected check: %+v", a)
+	}
+	if !strings.Contains(a.Patched, "/*<begin a>*/// A\n/*<end a>*/func A() {}") {
+		t.Fatalf("unexpected patched:\n%s", a.Patched)
+	}
+	if missing.TargetFile != "src/p/missing.go" || len(missing.Errors) != 1 || !strings.Contains(missing.Errors[0].Error(), "target not found") {
+		t.Fatalf("unexpected check: %+v", missing)
+	}
+	// nothing is written
+	content, err := os.ReadFile(filepath.Join(goroot, "src", "p", "a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "package p\n\nfunc A() {}\n" {
+		t.Fatalf("target modified:\n%s", content)
+	}
+}
@@ -0,0 +1,685 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/xhd2015/xgo/support/transform/astdiff"
+)
+
+// PortStatus tells how a block is ported to a new GOROOT
+type PortStatus int
+
+const (
+	// PortUnchanged means the block applies as is
+	PortUnchanged PortStatus = iota
+	// PortRelocated means some anchors of the block are relocated
+	PortRelocated
+	// PortFailed means the block cannot be applied, it is kept as is
+	PortFailed
+)
+
+func (s PortStatus) String() string {
+	switch s {
+	case PortUnchanged:
+		return "unchanged"
+	case PortRelocated:
+		return "relocated"
+	case PortFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("PortStatus(%d)", int(s))
+}
+
+// BlockPort is the result of porting a single block
+type BlockPort struct {
+	Block  string
+	Line   int
+	Status PortStatus
+	// Notes describe relocated anchors and edit text
+	// that may not compile, each starts with the line
+	// of the command in the .xgo.patch file
+	Notes []string
+	// Err is why the block failed
+	Err error
+}
+
+// NeedsAttention tells if the block should be reviewed
+// by a human before the ported patches are used
+func (b *BlockPort) NeedsAttention() bool {
+	return b.Status == PortFailed || len(b.Notes) > 0
+}
+
+// FilePort is the result of porting a .xgo.patch file
+type FilePort struct {
+	// PatchFile is relative to the patch dir, slash separated
+	PatchFile string
+	// TargetFile is relative to GOROOT, slash separated
+	TargetFile string
+
+	// Content is the ported .xgo.patch file, with
+	// relocated anchors rewritten in place
+	Content string
+	Blocks  []*BlockPort
+	// Err is set if the file cannot be ported at all,
+	// e.g. the target does not exist in the new GOROOT
+	Err error
+}
+
+// PortPatches tries every .xgo.patch file under patchDir against the
+// corresponding file of goroot, a newer release than the one patchDir
+// was written for. Failed match and find_for_replace anchors are
+// relocated if exactly one similar code is found in scope, see
+// PortXgoPatchContent. Nothing is written.
+func PortPatches(patchDir string, goroot string) ([]*FilePort, error) {
+	var ports []*FilePort
+	err := filepath.Walk(patchDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".xgo.patch") {
+			return nil
+		}
+		relPath, err := filepath.Rel(patchDir, path)
+		if err != nil {
+			return err
+		}
+		targetRel := strings.TrimSuffix(relPath, ".xgo.patch")
+		port, err := portPatchFile(path, filepath.Join(goroot, targetRel))
+		if err != nil {
+			return err
+		}
+		port.PatchFile = filepath.ToSlash(relPath)
+		port.TargetFile = filepath.ToSlash(targetRel)
+		ports = append(ports, port)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ports, nil
+}
+
+func portPatchFile(patchFile string, targetFile string) (*FilePort, error) {
+	patchContent, err := os.ReadFile(patchFile)
+	if err != nil {
+		return nil, err
+	}
+	port := &FilePort{Content: string(patchContent)}
+	targetContent, err := os.ReadFile(targetFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		port.Err = fmt.Errorf("target not found: %s", targetFile)
+		return port, nil
+	}
+	// go files copied along with the patch
+	// declare names used by edit text
+	declared, err := copiedGoIdents(filepath.Dir(patchFile))
+	if err != nil {
+		return nil, err
+	}
+	content, blocks, err := PortXgoPatchContent(string(targetContent), string(patchContent), declared)
+	if err != nil {
+		port.Err = err
+		return port, nil
+	}
+	port.Content = content
+	port.Blocks = blocks
+	return port, nil
+}
+
+func copiedGoIdents(dir string) (map[string]bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	idents := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, tok := range scanTokens(string(content)) {
+			if tok.tok == token.IDENT {
+				idents[tok.text] = true
+			}
+		}
+	}
+	return idents, nil
+}
+
+// PortXgoPatchContent applies each block of patchContent to source like
+// CheckXgoPatchContent. When a match or find_for_replace anchor is not
+// found, it looks for code in the same scope that is the same as the
+// anchor except for formatting and at most one renamed identifier,
+// compared by astdiff if the anchor is a complete expression or statement,
+// or token by token otherwise. If exactly one is found, the command is
+// rewritten to it in the returned content. Identifiers referenced by edit
+// text but declared neither in source, the patch itself nor declared
+// (e.g. go files copied along with the patch) are reported as notes.
+func PortXgoPatchContent(source string, patchContent string, declared map[string]bool) (string, []*BlockPort, error) {
+	pf, err := ParseXgoPatch(patchContent)
+	if err != nil {
+		return patchContent, nil, err
+	}
+	lines := strings.Split(patchContent, "\n")
+
+	known := make(map[string]bool, len(declared))
+	for name := range declared {
+		known[name] = true
+	}
+	for _, tok := range scanTokens(source) {
+		if tok.tok == token.IDENT {
+			known[tok.text] = true
+		}
+	}
+	for _, block := range pf.Blocks {
+		for _, cmd := range block.Commands {
+			for name := range patchDeclaredIdents(cmd) {
+				known[name] = true
+			}
+		}
+	}
+
+	result := source
+	ports := make([]*BlockPort, 0, len(pf.Blocks))
+	for _, block := range pf.Blocks {
+		patched, port := portBlock(result, block, lines)
+		if port.Status != PortFailed {
+			result = patched
+			port.Notes = append(port.Notes, checkEditIdents(block, known)...)
+		}
+		ports = append(ports, port)
+	}
+	return strings.Join(lines, "\n"), ports, nil
+}
+
+func portBlock(source string, block PatchBlock, lines []string) (string, *BlockPort) {
+	port := &BlockPort{Block: block.Name, Line: block.Line}
+	cleared := source
+	if block.Name != "" {
+		cleared = clearPatch(source, block.Name)
+	}
+	// relocated commands are not written
+	// back to lines until the block applies
+	cmds := make([]Command, len(block.Commands))
+	copy(cmds, block.Commands)
+	block.Commands = cmds
+	relocated := make(map[int]bool)
+	var notes []string
+	for {
+		patched, err := applyPatch(cleared, block)
+		if err == nil {
+			err = checkDirectives(cleared, block)
+		}
+		if err == nil {
+			for i, cmd := range cmds {
+				if relocated[i] {
+					line := lines[cmd.Line-1]
+					indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+					lines[cmd.Line-1] = indent + cmd.String()
+				}
+			}
+			port.Status = PortUnchanged
+			if len(relocated) > 0 {
+				port.Status = PortRelocated
+			}
+			port.Line = block.Line
+			port.Err = nil
+			port.Notes = notes
+			return patched, port
+		}
+		port.Status = PortFailed
+		port.Line = block.Line
+		port.Err = err
+		var cmdErr *CommandError
+		if !errors.As(err, &cmdErr) {
+			return source, port
+		}
+		port.Line = cmdErr.Command.Line
+		port.Err = cmdErr.Err
+		idx := commandIndex(cmds, cmdErr.Command.Line)
+		if idx < 0 || relocated[idx] || (cmds[idx].Type != CmdMatch && cmds[idx].Type != CmdFindForReplace) {
+			return source, port
+		}
+		state, err := execCommands(cleared, cmds[:idx])
+		if err != nil {
+			return source, port
+		}
+		cmd := &cmds[idx]
+		newText, rename, err := relocateAnchor(state, cmd.SearchText)
+		if err != nil {
+			port.Err = fmt.Errorf("%v, cannot relocate: %v", port.Err, err)
+			return source, port
+		}
+		note := fmt.Sprintf("line %d: %s %q -> %q", cmd.Line, cmdName(cmd.Type), cmd.SearchText, newText)
+		if rename != "" {
+			note += fmt.Sprintf(" (renamed %s)", rename)
+		}
+		notes = append(notes, note)
+		cmd.SearchText = newText
+		relocated[idx] = true
+	}
+}
+
+func commandIndex(cmds []Command, line int) int {
+	for i, cmd := range cmds {
+		if cmd.Line == line {
+			return i
+		}
+	}
+	return -1
+}
+
+func cmdName(typ CommandType) string {
+	if typ == CmdFindForReplace {
+		return "find_for_replace"
+	}
+	return "match"
+}
+
+// anchorCandidate is a range of the source similar to an anchor
+type anchorCandidate struct {
+	start  int
+	end    int
+	rename string // "old -> new", empty if none
+}
+
+// relocateAnchor finds the code in the current scope of s that is similar
+// to text, it returns the code and the renamed identifier if any
+func relocateAnchor(s *applyState, text string) (string, string, error) {
+	start, end := s.matchScope()
+	candidates := astCandidates(s, start, end, text)
+	if len(candidates) == 0 {
+		candidates = tokenCandidates(s.original, start, end, text)
+	}
+	// prefer the ones differing only in formatting
+	var exact []anchorCandidate
+	for _, c := range candidates {
+		if c.rename == "" {
+			exact = append(exact, c)
+		}
+	}
+	if len(exact) > 0 {
+		candidates = exact
+	}
+	if len(candidates) == 0 {
+		return "", "", fmt.Errorf("no similar code in scope")
+	}
+	if len(candidates) > 1 {
+		const maxShown = 5
+		var positions []string
+		for i, c := range candidates {
+			if i == maxShown {
+				positions = append(positions, "...")
+				break
+			}
+			positions = append(positions, fmt.Sprintf("line %d", lineOfOffset(s.original, c.start)))
+		}
+		return "", "", fmt.Errorf("%d similar code in scope: %s", len(candidates), strings.Join(positions, ", "))
+	}
+	c := candidates[0]
+	newText := s.original[c.start:c.end]
+	if strings.Contains(newText, "\n") {
+		return "", "", fmt.Errorf("similar code at line %d spans multiple lines", lineOfOffset(s.original, c.start))
+	}
+	if strings.Count(s.original[start:end], newText) != 1 {
+		return "", "", fmt.Errorf("similar code %q is not unique in scope", newText)
+	}
+	return newText, c.rename, nil
+}
+
+func lineOfOffset(content string, offset int) int {
+	return strings.Count(content[:offset], "\n") + 1
+}
+
+// parseAnchor parses text as an expression or a single statement,
+// it returns nil if text is only a fragment of code
+func parseAnchor(text string) ast.Node {
+	expr, err := parser.ParseExpr(text)
+	if err == nil {
+		return expr
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "", "package p;func _(){"+text+"\n}", 0)
+	if err != nil {
+		return nil
+	}
+	body := f.Decls[0].(*ast.FuncDecl).Body
+	if len(body.List) != 1 {
+		return nil
+	}
+	return body.List[0]
+}
+
+func astCandidates(s *applyState, start int, end int, text string) []anchorCandidate {
+	anchor := parseAnchor(text)
+	if anchor == nil {
+		return nil
+	}
+	anchorIdents := collectIdents(anchor)
+	anchorType := reflect.TypeOf(anchor)
+	var candidates []anchorCandidate
+	ast.Inspect(s.astFile, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		nodeStart, nodeEnd := s.offsetOf(n.Pos()), s.offsetOf(n.End())
+		if nodeEnd < start || nodeStart > end {
+			return false
+		}
+		if nodeStart < start || nodeEnd > end || reflect.TypeOf(n) != anchorType {
+			return true
+		}
+		oldName, newName, ok := identRename(anchorIdents, collectIdents(n))
+		if !ok {
+			return true
+		}
+		// compare with a fresh copy, renamed
+		renamed := parseAnchor(text)
+		if oldName != "" {
+			ast.Inspect(renamed, func(n ast.Node) bool {
+				if ident, ok := n.(*ast.Ident); ok && ident.Name == oldName {
+					ident.Name = newName
+				}
+				return true
+			})
+		}
+		if nodeSame(renamed, n) {
+			c := anchorCandidate{start: nodeStart, end: nodeEnd}
+			if oldName != "" {
+				c.rename = oldName + " -> " + newName
+			}
+			candidates = append(candidates, c)
+			return false
+		}
+		return true
+	})
+	return candidates
+}
+
+// nodeSame compares by astdiff, which panics
+// on expressions it does not support yet
+func nodeSame(a ast.Node, b ast.Node) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return astdiff.NodeSame(a, b)
+}
+
+func collectIdents(node ast.Node) []string {
+	var names []string
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			names = append(names, ident.Name)
+		}
+		return true
+	})
+	return names
+}
+
+// identRename tells if b is a, with at most one identifier renamed
+func identRename(a []string, b []string) (oldName string, newName string, ok bool) {
+	if len(a) != len(b) {
+		return "", "", false
+	}
+	for i := range a {
+		if a[i] == b[i] {
+			continue
+		}
+		if oldName == "" {
+			oldName, newName = a[i], b[i]
+			continue
+		}
+		if a[i] != oldName || b[i] != newName {
+			return "", "", false
+		}
+	}
+	// the new name must not be used for something else
+	if oldName != "" {
+		for i := range a {
+			if a[i] == newName {
+				return "", "", false
+			}
+		}
+	}
+	return oldName, newName, true
+}
+
+type sourceToken struct {
+	tok    token.Token
+	text   string
+	offset int
+}
+
+// scanTokens returns tokens of src without comments and
+// automatically inserted semicolons, or nil if src cannot
+// be scanned, e.g. an unterminated string
+func scanTokens(src string) []sourceToken {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var hasErr bool
+	var sc scanner.Scanner
+	sc.Init(file, []byte(src), func(pos token.Position, msg string) { hasErr = true }, 0)
+	var tokens []sourceToken
+	for {
+		pos, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit != ";" {
+			continue
+		}
+		text := lit
+		if text == "" {
+			text = tok.String()
+		}
+		tokens = append(tokens, sourceToken{tok: tok, text: text, offset: file.Offset(pos)})
+	}
+	if hasErr {
+		return nil
+	}
+	return tokens
+}
+
+// tokenCandidates finds token sequences in scope the same as
+// text except for at most one consistently renamed identifier
+func tokenCandidates(src string, start int, end int, text string) []anchorCandidate {
+	anchor := scanTokens(text)
+	if len(anchor) == 0 {
+		return nil
+	}
+	tokens := scanTokens(src[start:end])
+	anchorIdents := make([]string, 0, len(anchor))
+	for _, tok := range anchor {
+		if tok.tok == token.IDENT {
+			anchorIdents = append(anchorIdents, tok.text)
+		}
+	}
+	var candidates []anchorCandidate
+	for i := 0; i+len(anchor) <= len(tokens); i++ {
+		window := tokens[i : i+len(anchor)]
+		var idents []string
+		match := true
+		for j, tok := range window {
+			if tok.tok != anchor[j].tok || (tok.tok != token.IDENT && tok.text != anchor[j].text) {
+				match = false
+				break
+			}
+			if tok.tok == token.IDENT {
+				idents = append(idents, tok.text)
+			}
+		}
+		if !match {
+			continue
+		}
+		oldName, newName, ok := identRename(anchorIdents, idents)
+		if !ok {
+			continue
+		}
+		last := window[len(window)-1]
+		c := anchorCandidate{start: start + window[0].offset, end: start + last.offset + len(last.text)}
+		if oldName != "" {
+			c.rename = oldName + " -> " + newName
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
+
+// editText returns the Go code a command adds to the target
+func editText(cmd Command) string {
+	switch cmd.Type {
+	case CmdInsertBefore, CmdInsertAfter, CmdInsertAfterLine, CmdReplace:
+		return cmd.EditText
+	case CmdWrapBody:
+		return strings.Replace(cmd.EditText, wrapBodyPlaceholder, "", 1)
+	}
+	return ""
+}
+
+// patchDeclaredIdents returns names a command declares,
+// approximated by looking at the tokens around identifiers
+func patchDeclaredIdents(cmd Command) map[string]bool {
+	names := make(map[string]bool)
+	switch cmd.Type {
+	case CmdCopyFunc:
+		names[cmd.CopyTarget] = true
+		return names
+	case CmdAddField, CmdAddParam, CmdAddResult:
+		names[cmd.Name] = true
+		return names
+	case CmdAddImport:
+		if cmd.Name != "" {
+			names[cmd.Name] = true
+		}
+		return names
+	}
+	tokens := scanTokens(editText(cmd))
+	for i, tok := range tokens {
+		if tok.tok == token.FUNC {
+			for _, name := range funcParamNames(tokens[i+1:]) {
+				names[name] = true
+			}
+			continue
+		}
+		if tok.tok != token.IDENT {
+			continue
+		}
+		if i > 0 {
+			switch tokens[i-1].tok {
+			case token.VAR, token.CONST, token.TYPE, token.FUNC, token.IMPORT:
+				names[tok.text] = true
+				continue
+			}
+		}
+		// a, b := ...
+		j := i + 1
+		for j+1 < len(tokens) && tokens[j].tok == token.COMMA && tokens[j+1].tok == token.IDENT {
+			j += 2
+		}
+		if j < len(tokens) && tokens[j].tok == token.DEFINE {
+			names[tok.text] = true
+		}
+	}
+	return names
+}
+
+// funcParamNames returns names of the receiver, params and results
+// of a func declaration, type or literal, tokens start after func
+func funcParamNames(tokens []sourceToken) []string {
+	var names []string
+	i := 0
+	for n := 0; n < 3 && i < len(tokens); n++ {
+		if tokens[i].tok == token.IDENT && i+1 < len(tokens) && tokens[i+1].tok == token.LPAREN {
+			// func name
+			i++
+		}
+		if tokens[i].tok != token.LPAREN {
+			break
+		}
+		depth := 0
+		// names before a comma wait for the type, e.g. (a, b int)
+		var pending []string
+		for ; i < len(tokens); i++ {
+			tok := tokens[i]
+			switch tok.tok {
+			case token.LPAREN, token.LBRACK, token.LBRACE:
+				depth++
+			case token.RPAREN, token.RBRACK, token.RBRACE:
+				depth--
+			}
+			if depth == 0 {
+				i++
+				break
+			}
+			if depth != 1 || tok.tok != token.IDENT || i+1 >= len(tokens) {
+				continue
+			}
+			if prev := tokens[i-1].tok; prev != token.LPAREN && prev != token.COMMA {
+				continue
+			}
+			switch tokens[i+1].tok {
+			case token.COMMA:
+				pending = append(pending, tok.text)
+			case token.IDENT, token.MUL, token.LBRACK, token.FUNC, token.MAP, token.CHAN,
+				token.ARROW, token.ELLIPSIS, token.STRUCT, token.INTERFACE, token.LPAREN:
+				names = append(names, pending...)
+				names = append(names, tok.text)
+				pending = nil
+			default:
+				pending = nil
+			}
+		}
+	}
+	return names
+}
+
+// checkEditIdents reports identifiers in edit text of block
+// that are not known, usually renamed in the new release
+func checkEditIdents(block PatchBlock, known map[string]bool) []string {
+	var notes []string
+	for _, cmd := range block.Commands {
+		tokens := scanTokens(editText(cmd))
+		var unknown []string
+		seen := make(map[string]bool)
+		for i, tok := range tokens {
+			if tok.tok != token.IDENT || known[tok.text] || seen[tok.text] || tok.text == "_" {
+				continue
+			}
+			// selectors, struct keys and labels
+			if i > 0 && tokens[i-1].tok == token.PERIOD {
+				continue
+			}
+			if i+1 < len(tokens) && tokens[i+1].tok == token.COLON {
+				continue
+			}
+			if types.Universe.Lookup(tok.text) != nil {
+				continue
+			}
+			seen[tok.text] = true
+			unknown = append(unknown, tok.text)
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			notes = append(notes, fmt.Sprintf("line %d: %s references undeclared %s", cmd.Line, strings.SplitN(cmd.String(), " ", 2)[0], strings.Join(unknown, ", ")))
+		}
+	}
+	return notes
+}
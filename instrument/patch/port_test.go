@@ -0,0 +1,161 @@
+package patch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPortXgoPatchContent_Relocate(t *testing.T) {
+	source := `package p
+
+var mainInitDoneChan chan bool
+
+func main() {
+	doInit(
+		runtime_inittasks,
+	)
+	close(mainInitDoneChan)
+	fn := main_main
+	fn()
+}
+`
+	patch := `<patch init_finished>
+goto func main
+match close(main_init_done)
+insert_before onInitFinished()
+newline
+</patch>
+
+<patch init_format>
+goto func main
+match doInit(runtime_inittasks)
+insert_before before();
+</patch>
+
+<patch fragment>
+goto func main
+find_for_replace fn :=  main_main
+replace fn = main_main
+</patch>
+
+<patch unchanged>
+goto func main
+match fn()
+insert_after ;after()
+</patch>`
+
+	declared := map[string]bool{"onInitFinished": true, "before": true, "after": true}
+	content, blocks, err := PortXgoPatchContent(source, patch, declared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectContent := strings.Replace(patch, "match close(main_init_done)", "match close(mainInitDoneChan)", 1)
+	expectContent = strings.Replace(expectContent, "find_for_replace fn :=  main_main", "find_for_replace fn := main_main", 1)
+	if content != expectContent {
+		t.Fatalf("unexpected content:\n%s", content)
+	}
+	if len(blocks) != 4 {
+		t.Fatalf("expect 4 blocks, actual: %d", len(blocks))
+	}
+	relocated, multiLine, fragment, unchanged := blocks[0], blocks[1], blocks[2], blocks[3]
+	if relocated.Status != PortRelocated || relocated.Err != nil || len(relocated.Notes) != 1 ||
+		relocated.Notes[0] != `line 3: match "close(main_init_done)" -> "close(mainInitDoneChan)" (renamed main_init_done -> mainInitDoneChan)` {
+		t.Fatalf("unexpected relocated: %+v", relocated)
+	}
+	if multiLine.Status != PortFailed || multiLine.Line != 10 || !strings.Contains(multiLine.Err.Error(), "spans multiple lines") {
+		t.Fatalf("unexpected multi line: %+v", multiLine)
+	}
+	if fragment.Status != PortRelocated || len(fragment.Notes) != 1 || fragment.Notes[0] != `line 16: find_for_replace "fn :=  main_main" -> "fn := main_main"` {
+		t.Fatalf("unexpected fragment: %+v", fragment)
+	}
+	if unchanged.Status != PortUnchanged || unchanged.NeedsAttention() {
+		t.Fatalf("unexpected unchanged: %+v", unchanged)
+	}
+}
+
+func TestPortXgoPatchContent_Ambiguous(t *testing.T) {
+	source := `package p
+
+func f() {
+	a(x)
+	a(y)
+}
+`
+	_, blocks, err := PortXgoPatchContent(source, "<patch amb>\ngoto func f\nmatch a(z)\ninsert_before b()\n</patch>", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks[0].Status != PortFailed || !strings.Contains(blocks[0].Err.Error(), "cannot relocate: 2 similar code in scope: line 4, line 5") {
+		t.Fatalf("unexpected: %+v", blocks[0])
+	}
+}
+
+func TestPortXgoPatchContent_UndeclaredIdents(t *testing.T) {
+	source := `package p
+
+import "fmt"
+
+func run(loader *Loader) {
+	pkgs := load(loader)
+	fmt.Println(pkgs)
+}
+`
+	patch := `<patch declare>
+goto func run
+insert_before var OnLoad func(pkgPath string, a, b int) (name string, err error);
+</patch>
+
+<patch call>
+goto func run
+match pkgs := load(loader)
+insert_after ;xgoState = moduleLoaderState;pkgs = unify(ctx, pkgs, Opts{Key: 1});v := len(pkgs);_ = v;fmt.Println(OnLoad)
+</patch>`
+	_, blocks, err := PortXgoPatchContent(source, patch, map[string]bool{"unify": true, "xgoState": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks[0].NeedsAttention() {
+		t.Fatalf("unexpected declare: %+v", blocks[0])
+	}
+	if len(blocks[1].Notes) != 1 || blocks[1].Notes[0] != "line 9: insert_after references undeclared Opts, ctx, moduleLoaderState" {
+		t.Fatalf("unexpected call: %+v", blocks[1])
+	}
+}
+
+func TestPortPatches(t *testing.T) {
+	patchDir := t.TempDir()
+	goroot := t.TempDir()
+	write := func(file string, content string) {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(goroot, "src", "p", "a.go"), "package p\n\nfunc A() {\n\tcloseDone()\n}\n")
+	write(filepath.Join(patchDir, "src", "p", "a.go.xgo.patch"), "<patch a>\ngoto func A\nmatch close_done()\ninsert_before xgoHook()\n</patch>\n")
+	write(filepath.Join(patchDir, "src", "p", "xgo_hook.go"), "package p\n\nfunc xgoHook() {}\n")
+	write(filepath.Join(patchDir, "src", "q", "missing.go.xgo.patch"), "<patch m>\ngoto func M\n</patch>\n")
+
+	ports, err := PortPatches(patchDir, goroot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ports) != 2 {
+		t.Fatalf("expect 2 files, actual: %d", len(ports))
+	}
+	a, missing := ports[0], ports[1]
+	if a.PatchFile != "src/p/a.go.xgo.patch" || a.Err != nil || len(a.Blocks) != 1 || a.Blocks[0].Status != PortRelocated {
+		t.Fatalf("unexpected port: %+v", a)
+	}
+	// xgoHook is declared by the copied file
+	if len(a.Blocks[0].Notes) != 1 || !strings.Contains(a.Content, "match closeDone()") {
+		t.Fatalf("unexpected port: %+v\n%s", a.Blocks[0], a.Content)
+	}
+	if missing.TargetFile != "src/q/missing.go" || missing.Err == nil || !strings.Contains(missing.Err.Error(), "target not found") {
+		t.Fatalf("unexpected port: %+v", missing)
+	}
+}
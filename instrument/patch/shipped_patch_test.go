@@ -0,0 +1,93 @@
+package patch
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/xgo/support/assert"
+)
+
+// shipped patches used to add imports by
+//
+//	match package <pkg>
+//	insert_after ;import <name> "<path>"
+//
+// TestShippedAddImportMatchesTextPatch checks each add_import block
+// produces exactly what the text-based block did.
+func TestShippedAddImportMatchesTextPatch(t *testing.T) {
+	patchesDir := filepath.Join("..", "..", "patches")
+	var found int
+	err := filepath.Walk(patchesDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(file, ".xgo.patch") {
+			return nil
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		pf, err := ParseXgoPatch(string(content))
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			return nil
+		}
+		pkg := filepath.Base(filepath.Dir(file))
+		if pkg == "v2" {
+			// encoding/json/v2
+			pkg = filepath.Base(filepath.Dir(filepath.Dir(file)))
+		}
+		source := "// Copyright 2009 The Go Authors. All rights reserved.\n\n" +
+			"// Package " + pkg + " is a test.\n" +
+			"package " + pkg + "\n\nimport (\n\t\"fmt\"\n)\n\nvar _ = fmt.Sprint\n"
+		for _, block := range pf.Blocks {
+			var imports string
+			for _, cmd := range block.Commands {
+				if cmd.Type != CmdAddImport {
+					continue
+				}
+				imports += ";import "
+				if cmd.Name != "" {
+					imports += cmd.Name + " "
+				}
+				imports += strconv.Quote(cmd.ImportPath)
+			}
+			if imports == "" {
+				continue
+			}
+			if len(block.Commands) != strings.Count(imports, ";import ") {
+				t.Errorf("%s: block %s mixes add_import with other commands", file, block.Name)
+				continue
+			}
+			found++
+			textPatch := "<patch " + block.Name + ">\nmatch package " + pkg + "\ninsert_after " + imports + "\n</patch>"
+
+			expect, err := ApplyXgoPatchContent(source, textPatch)
+			if err != nil {
+				t.Errorf("%s: text patch: %v", file, err)
+				continue
+			}
+			result, err := applyPatch(source, block)
+			if err != nil {
+				t.Errorf("%s: %v", file, err)
+				continue
+			}
+			if result != expect {
+				t.Errorf("%s: block %s: %s", file, block.Name, assert.Diff(expect, result))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// gc/main.go, noder.go and encoding/json/encode.go of go1.24~go1.27,
+	// plus encoding/json/v2 of go1.27
+	if found < 13 {
+		t.Errorf("expect at least 13 add_import blocks, found %d", found)
+	}
+}
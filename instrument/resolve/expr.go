@@ -30,7 +30,7 @@ func (c *Scope) traverseCallExpr(callExpr *ast.CallExpr) {
 		}
 		if c.needDetectMock() && len(callExpr.Args) > 0 {
 			// check if mock.Patch
-			if c.recordTrap(sel) {
+			if c.recordTrap(sel) || c.recordScopedTrap(sel) {
 				// resolve the first argument's type
 				// to see its type so that we
 				// need to insert trap points
@@ -38,6 +38,9 @@ func (c *Scope) traverseCallExpr(callExpr *ast.CallExpr) {
 			}
 		}
 	}
+	if c.needDetectMock() {
+		c.recordInterfaceRef(callExpr)
+	}
 	if !isSelector {
 		c.traverseExpr(fn)
 	}
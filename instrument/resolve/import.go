@@ -9,7 +9,8 @@ import (
 // name -> path
 type Imports map[string]string
 
-func getFileImports(registry PackageRegistry, file *ast.File) Imports {
+// GetFileImports maps local names of imports in file to their paths
+func GetFileImports(registry PackageRegistry, file *ast.File) Imports {
 	imports := make(Imports)
 	for _, impDecl := range file.Imports {
 		pkgPath, err := strconv.Unquote(impDecl.Path.Value)
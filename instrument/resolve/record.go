@@ -40,6 +40,22 @@ func (c *Scope) recordTrap(sel *ast.SelectorExpr) bool {
 	return false
 }
 
+// `mock.Global(t).Patch(fn,...)`, `mock.Goroutine().Mock(fn,...)`
+func (c *Scope) recordScopedTrap(sel *ast.SelectorExpr) bool {
+	if sel.Sel.Name != "Patch" && sel.Sel.Name != "Mock" {
+		return false
+	}
+	scopeCall, ok := sel.X.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	scopeSel, ok := scopeCall.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	return c.isMockFunc(scopeSel, "Global") || c.isMockFunc(scopeSel, "GoroutineTree") || c.isMockFunc(scopeSel, "Goroutine")
+}
+
 // `mock.Patch(fn,...)`
 // fn examples:
 //
@@ -97,6 +113,59 @@ func (c *Scope) recordMockRef(fn ast.Expr) {
 	}
 }
 
+// `mock.NewInterface[T]()` or `mock.NewInterfaceOf((*T)(nil))`,
+// T needs an implementation generated
+func (c *Scope) recordInterfaceRef(callExpr *ast.CallExpr) {
+	var intfType ast.Expr
+	switch fn := callExpr.Fun.(type) {
+	case *ast.IndexExpr:
+		sel, ok := fn.X.(*ast.SelectorExpr)
+		if !ok || !c.isMockFunc(sel, "NewInterface") {
+			return
+		}
+		intfType = fn.Index
+	case *ast.SelectorExpr:
+		if len(callExpr.Args) != 1 || !c.isMockFunc(fn, "NewInterfaceOf") {
+			return
+		}
+		conv, ok := callExpr.Args[0].(*ast.CallExpr)
+		if !ok {
+			return
+		}
+		paren, ok := conv.Fun.(*ast.ParenExpr)
+		if !ok {
+			return
+		}
+		star, ok := paren.X.(*ast.StarExpr)
+		if !ok {
+			return
+		}
+		intfType = star.X
+	default:
+		return
+	}
+	namedType, ok := c.resolveType(intfType).(types.NamedType)
+	if !ok || namedType.PkgPath == "" || namedType.Name == "" {
+		return
+	}
+	if _, allow := config.CheckInstrument(namedType.PkgPath); !allow {
+		return
+	}
+	pkgRecord := c.Global.Recorder.GetOrInit(namedType.PkgPath)
+	pkgRecord.GetOrInit(namedType.Name).HasInterfaceRef = true
+}
+
+func (c *Scope) isMockFunc(sel *ast.SelectorExpr, name string) bool {
+	if sel.Sel.Name != name {
+		return false
+	}
+	pkgObject, ok := c.tryResolvePkgRef(sel).(types.PkgVariable)
+	if !ok {
+		return false
+	}
+	return pkgObject.PkgPath == constants.RUNTIME_MOCK_PKG
+}
+
 // try simply resolve as pkg.Name, to detect for mock.Patch calls
 func (c *Scope) tryResolvePkgRef(sel *ast.SelectorExpr) types.Info {
 	idt, ok := sel.X.(*ast.Ident)
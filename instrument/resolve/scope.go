@@ -60,10 +60,28 @@ func (c *PkgRecorder) Get(name string) *NameRecorder {
 	return c.Names[name]
 }
 
+// HasInterfaceRef reports whether any interface
+// of the package needs an implementation generated
+func (c *PkgRecorder) HasInterfaceRef() bool {
+	if c == nil {
+		return false
+	}
+	for _, rec := range c.Names {
+		if rec.HasInterfaceRef {
+			return true
+		}
+	}
+	return false
+}
+
 type NameRecorder struct {
 	HasMockRef      bool
 	HasVarTrap      bool
 	NamesHavingMock map[string]bool
+
+	// has called `mock.NewInterface[T]()`
+	// or `mock.NewInterfaceOf((*T)(nil))`?
+	HasInterfaceRef bool
 }
 
 func (c *NameRecorder) AddMockName(name string) {
@@ -141,7 +159,7 @@ func newFileScope(global *GlobalScope, pkg *edit.Package, file *edit.File, pkgDe
 	if scope != nil {
 		return scope
 	}
-	imports := getFileImports(global.Packages, file.File.Syntax)
+	imports := GetFileImports(global.Packages, file.File.Syntax)
 	scope = &Scope{
 		Global: global,
 		Package: &PackageScope{
@@ -34,6 +34,9 @@ type InterfaceType struct {
 	Name    string
 	LineNum int
 	InfoVar string
+
+	// generated implementation, optional
+	Impl string
 }
 
 type Fields []*Field
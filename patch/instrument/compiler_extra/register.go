@@ -137,7 +137,15 @@ func DefineIntfTypeLiteral(intfType *InterfaceType, stdlib bool, fileVar string,
 		"Interface:true",
 		fmt.Sprintf("RecvType: %q", intfType.Name),
 	})
-	return defineLiteral(REGISTER, intfType.InfoVar, literal, "", "")
+
+	// Func constructs the generated implementation
+	var delayInitProp string
+	var delayInitValue string
+	if intfType.Impl != "" {
+		delayInitProp = "Func"
+		delayInitValue = fmt.Sprintf("func() %s {return &%s{}}", intfType.Name, intfType.Impl)
+	}
+	return defineLiteral(REGISTER, intfType.InfoVar, literal, delayInitProp, delayInitValue)
 }
 
 type Literal struct {
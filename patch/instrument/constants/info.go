@@ -25,9 +25,10 @@ const (
 )
 
 const (
-	FUNC_INFO = "__xgo_func_info" // __xgo_func_info_<fileIndex>_<declIndex>
-	VAR_INFO  = "__xgo_var_info"  // __xgo_var_info_<fileIndex>_<declIndex>
-	INTF_INFO = "__xgo_intf_info" // __xgo_intf_info_<fileIndex>_<declIndex>
+	FUNC_INFO = "__xgo_func_info"  // __xgo_func_info_<fileIndex>_<declIndex>
+	VAR_INFO  = "__xgo_var_info"   // __xgo_var_info_<fileIndex>_<declIndex>
+	INTF_INFO = "__xgo_intf_info"  // __xgo_intf_info_<fileIndex>_<declIndex>
+	INTF_IMPL = "__xgo_intf_impl_" // __xgo_intf_impl_<interfaceName>
 )
 
 const (
@@ -119,6 +120,10 @@ func IntfInfoVarName(fileIndex int, declIndex int) string {
 	return INTF_INFO + "_" + strconv.Itoa(fileIndex) + "_" + strconv.Itoa(declIndex)
 }
 
+func IntfImplName(intfName string) string {
+	return INTF_IMPL + intfName
+}
+
 func RegFileGen(fileIndex int) string {
 	return REG_FILE_GEN + strconv.Itoa(fileIndex)
 }
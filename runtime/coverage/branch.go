@@ -0,0 +1,103 @@
+// Package coverage collects branch coverage of code
+// instrumented by `xgo test --cover-branch`.
+//
+// The instrumented code registers its branch points via
+// Register, and records outcomes via Cond and Hit. Flush
+// writes all registered points to the directory specified
+// by env XGO_COVER_BRANCH_DIR, xgo merges these files into
+// the final branch profile after tests finish.
+package coverage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// XGO_COVER_BRANCH_DIR is set by xgo when running
+// tests with --cover-branch
+const XGO_COVER_BRANCH_DIR = "XGO_COVER_BRANCH_DIR"
+
+// Branches holds branch points of a single file
+type Branches struct {
+	file string
+	// each point is formatted as:
+	//   startLine.startCol,endLine.endCol kind
+	points []string
+	// 2 counters per point: taken, not taken
+	counts []uint32
+}
+
+var mutex sync.Mutex
+var registered []*Branches
+
+// Register registers branch points of file,
+// file is in the form of pkgPath/fileName, same
+// as go's coverage profile
+func Register(file string, points []string) *Branches {
+	c := &Branches{
+		file:   file,
+		points: points,
+		counts: make([]uint32, 2*len(points)),
+	}
+	mutex.Lock()
+	registered = append(registered, c)
+	mutex.Unlock()
+	return c
+}
+
+// Cond records the outcome of a condition
+func (c *Branches) Cond(id int, cond bool) bool {
+	idx := 2 * id
+	if !cond {
+		idx++
+	}
+	atomic.AddUint32(&c.counts[idx], 1)
+	return cond
+}
+
+// Hit records that a switch case is taken
+func (c *Branches) Hit(id int) {
+	atomic.AddUint32(&c.counts[2*id], 1)
+}
+
+// Flush writes counters of all registered files, it is
+// called when each test finishes. The output file is
+// overwritten each time since counters are cumulative.
+func Flush() {
+	dir := os.Getenv(XGO_COVER_BRANCH_DIR)
+	if dir == "" {
+		return
+	}
+	var buf bytes.Buffer
+	buf.WriteString("mode: branch\n")
+	mutex.Lock()
+	for _, c := range registered {
+		for i, point := range c.points {
+			buf.WriteString(c.file)
+			buf.WriteString(":")
+			buf.WriteString(point)
+			buf.WriteString(" ")
+			buf.WriteString(strconv.FormatUint(uint64(atomic.LoadUint32(&c.counts[2*i])), 10))
+			buf.WriteString(" ")
+			buf.WriteString(strconv.FormatUint(uint64(atomic.LoadUint32(&c.counts[2*i+1])), 10))
+			buf.WriteString("\n")
+		}
+	}
+	mutex.Unlock()
+
+	file := filepath.Join(dir, strconv.Itoa(os.Getpid())+".branch")
+	tmpFile := file + ".tmp"
+	err := ioutil.WriteFile(tmpFile, buf.Bytes(), 0644)
+	if err == nil {
+		err = os.Rename(tmpFile, file)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: xgo cover branch: %v\n", err)
+	}
+}
@@ -20,6 +20,7 @@ var varAddrMapping map[uintptr]*core.FuncInfo                    // addr->FuncIn
 var funcFullNameMapping map[string]*core.FuncInfo                // fullName -> FuncInfo
 var interfaceMapping map[string]map[string]*core.FuncInfo        // pkg -> interfaceName -> FuncInfo
 var typeMethodMapping map[reflect.Type]map[string]*core.FuncInfo // reflect.Type -> interfaceName -> FuncInfo
+var interfaceTypeMapping map[reflect.Type]*core.FuncInfo         // interface reflect.Type -> FuncInfo
 
 func init() {
 	funcPCMapping = make(map[uintptr]*core.FuncInfo)
@@ -122,6 +123,13 @@ func GetTypeMethods(typ reflect.Type) map[string]*core.FuncInfo {
 	return getTypeMethodMapping()[typ]
 }
 
+// GetInterfaceByType returns the interface info
+// of `typ`, its Func is a constructor of the
+// generated implementation, if any
+func GetInterfaceByType(typ reflect.Type) *core.FuncInfo {
+	return getInterfaceTypeMapping()[typ]
+}
+
 func getInterfaceOrGenericByFullName(fullName string) *core.FuncInfo {
 	pkgPath, recvName, recvPtr, typeGeneric, funcGeneric, funcName := core.ParseFuncName(fullName)
 	if typeGeneric != "" || funcGeneric != "" {
@@ -345,6 +353,25 @@ func registerTypeMethod(funcInfo *core.FuncInfo) {
 	methodMapping[funcInfo.Name] = funcInfo
 }
 
+var mappingInterfaceOnce sync.Once
+
+func getInterfaceTypeMapping() map[reflect.Type]*core.FuncInfo {
+	mappingInterfaceOnce.Do(initInterfaceTypeMapping)
+	return interfaceTypeMapping
+}
+
+func initInterfaceTypeMapping() {
+	interfaceTypeMapping = make(map[reflect.Type]*core.FuncInfo)
+	for _, funcInfo := range funcInfos {
+		if !funcInfo.Interface || funcInfo.Func == nil {
+			continue
+		}
+		// Func: func() Interface
+		intfType := reflect.TypeOf(funcInfo.Func).Out(0)
+		interfaceTypeMapping[intfType] = funcInfo
+	}
+}
+
 func getFuncPC(fn interface{}) uintptr {
 	type _func struct {
 		pc uintptr
@@ -21,6 +21,36 @@ const COLLECT_TEST_TRACE = false
 //	directory, default current dir
 const COLLECT_TEST_TRACE_DIR = ""
 
+// when: xgo test and --strace is on
+// flag: --strace-max-depth, --strace-max-children,
+// --strace-max-arg-bytes, --strace-sample and
+// --strace-collapse-repeated
+// values:
+//
+//	JSON object of trace limits, same fields as
+//	trace.Config, empty string means no limit
+const STRACE_LIMITS = ""
+
+// when: xgo test
+// flag: --strace-replay
+// description:
+//
+//	directory containing traces collected by a previous
+//	--strace run, functions selected by STRACE_REPLAY_RULES
+//	are mocked with recorded results
+//
+// values:
+//
+//	directory, empty string means no replay
+const STRACE_REPLAY_DIR = ""
+
+// when: xgo test and --strace-replay is set
+// flag: --strace-replay-rule
+// values:
+//
+//	JSON array of rules, same format as --mock-rule
+const STRACE_REPLAY_RULES = ""
+
 // when: xgo test,xgo run, xgo build
 // flag: --xgo-race-safe
 // description:
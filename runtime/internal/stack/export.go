@@ -83,6 +83,9 @@ func ExportStackEntry(entry *Entry, rootBegin time.Time, offsetNS int64) *stack_
 		Panic:     entry.Panic,
 		PanicLine: entry.PanicLine,
 		Error:     entry.Error,
+		Omitted:   entry.Omitted,
+		Repeated:  entry.Repeated,
+		Truncated: entry.Truncated,
 		Children:  children,
 	}
 }
@@ -100,6 +103,8 @@ func ExportFuncInfo(entry *Entry) *stack_model.FuncInfo {
 	var name string
 	var kind stack_model.FuncKind
 
+	var recvType string
+	var recvPtr bool
 	var recvName string
 	var argNames []string
 	var resNames []string
@@ -114,6 +119,8 @@ func ExportFuncInfo(entry *Entry) *stack_model.FuncInfo {
 		pkg = entry.FuncInfo.Pkg
 		name = entry.FuncInfo.IdentityName
 
+		recvType = entry.FuncInfo.RecvType
+		recvPtr = entry.FuncInfo.RecvPtr
 		recvName = entry.FuncInfo.RecvName
 		argNames = entry.FuncInfo.ArgNames
 		resNames = entry.FuncInfo.ResNames
@@ -143,6 +150,8 @@ func ExportFuncInfo(entry *Entry) *stack_model.FuncInfo {
 		Pkg:           pkg,
 		File:          file,
 		Line:          line,
+		RecvType:      recvType,
+		RecvPtr:       recvPtr,
 		RecvName:      recvName,
 		ArgNames:      argNames,
 		ResNames:      resNames,
@@ -0,0 +1,116 @@
+package stack
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// TryOmit checks whether a new call should be omitted from the
+// stack because of trace limits. An omitted call is counted in
+// Omitted of the top entry, calls nested inside it are omitted
+// without counting. EndOmit must be called when an omitted call
+// finishes.
+// sampledOut is called under lock only if the call is not
+// already omitted by other limits, it must not call
+// instrumented functions.
+func (c *Stack) TryOmit(maxDepth int, maxChildren int, sampledOut func() bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Omitting > 0 {
+		c.Omitting++
+		return true
+	}
+	var omit bool
+	// the first call is at depth 0
+	if maxDepth > 0 && c.BaseDepth+c.Depth > maxDepth {
+		omit = true
+	} else if maxChildren > 0 {
+		n := len(c.Roots)
+		if c.Top != nil {
+			n = len(c.Top.Children)
+		}
+		omit = n >= maxChildren
+	}
+	if !omit && sampledOut != nil {
+		omit = sampledOut()
+	}
+	if !omit {
+		return false
+	}
+	c.Omitting++
+	if c.Top != nil {
+		c.Top.Omitted++
+	}
+	return true
+}
+
+// EndOmit finishes a call omitted by TryOmit
+func (c *Stack) EndOmit() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Omitting--
+}
+
+// CollapseRepeated merges a finished entry into its previous
+// sibling if they are identical calls, i.e. same function,
+// args, results, errors and children.
+// parent is the Top before cur was pushed.
+func (c *Stack) CollapseRepeated(cur *Entry, parent *Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	siblings := c.Roots
+	if parent != nil {
+		siblings = parent.Children
+	}
+	n := len(siblings)
+	if n < 2 || siblings[n-1] != cur {
+		return
+	}
+	prev := siblings[n-2]
+	if !sameCall(prev, cur) {
+		return
+	}
+	prev.Repeated += 1 + cur.Repeated
+	siblings[n-1] = nil
+	if parent != nil {
+		parent.Children = siblings[:n-1]
+	} else {
+		c.Roots = siblings[:n-1]
+	}
+}
+
+func sameCall(a *Entry, b *Entry) bool {
+	if a == nil || b == nil || !a.Finished || !b.Finished || a.Go || b.Go {
+		return false
+	}
+	if a.FuncInfo != b.FuncInfo || a.FuncName != b.FuncName || a.File != b.File || a.Line != b.Line {
+		return false
+	}
+	if a.Panic != b.Panic || a.Error != b.Error || a.HitMock != b.HitMock || a.Omitted != b.Omitted || a.Truncated != b.Truncated {
+		return false
+	}
+	if !sameJSON(a.Args, b.Args) || !sameJSON(a.Results, b.Results) {
+		return false
+	}
+	if len(a.Children) != len(b.Children) {
+		return false
+	}
+	for i, child := range a.Children {
+		if !sameCall(child, b.Children[i]) || child.Repeated != b.Children[i].Repeated {
+			return false
+		}
+	}
+	return true
+}
+
+func sameJSON(a interface{}, b interface{}) bool {
+	ra, ok := a.(json.RawMessage)
+	if !ok {
+		return false
+	}
+	rb, ok := b.(json.RawMessage)
+	if !ok {
+		return false
+	}
+	return bytes.Equal(ra, rb)
+}
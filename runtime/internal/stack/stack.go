@@ -25,6 +25,12 @@ type Stack struct {
 	Roots []*Entry
 	Top   *Entry
 	Depth int
+	// BaseDepth is the depth of the go statement
+	// creating this stack in its parent stack
+	BaseDepth int
+	// Omitting is the number of running calls
+	// omitted because of trace limits
+	Omitting int
 
 	Data map[interface{}]interface{}
 }
@@ -65,6 +71,16 @@ type Entry struct {
 	PanicLine int
 	Error     string
 
+	// Omitted is the number of child calls
+	// omitted because of trace limits
+	Omitted int
+	// Repeated is the number of identical calls
+	// following this one, collapsed into this entry
+	Repeated int
+	// Truncated is true if args or results
+	// exceeded the size limit
+	Truncated bool
+
 	Args    interface{}
 	Results interface{}
 }
@@ -153,14 +169,16 @@ func (c *Stack) Finish(cur *Entry, oldTop *Entry, end time.Time, setStackEnd boo
 }
 
 // AppendGoChild attaches a synthetic "go" node under the current Top under lock.
-func (c *Stack) AppendGoChild(beginNs int64, getStack func() *Stack) {
+// Returns the depth of the "go" node, and false if the node is not attached,
+// either because there is no Top or the go statement is inside omitted calls.
+func (c *Stack) AppendGoChild(beginNs int64, getStack func() *Stack) (int, bool) {
 	if c == nil {
-		return
+		return 0, false
 	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.Top == nil {
-		return
+	if c.Top == nil || c.Omitting > 0 {
+		return 0, false
 	}
 	child := &Entry{
 		BeginNs:  beginNs,
@@ -169,6 +187,7 @@ func (c *Stack) AppendGoChild(beginNs int64, getStack func() *Stack) {
 		GetStack: getStack,
 	}
 	c.Top.Children = append(c.Top.Children, child)
+	return c.BaseDepth + c.Depth, true
 }
 
 // SetEndIfZero records stack end time once (goroutine exit).
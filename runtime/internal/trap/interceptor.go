@@ -21,6 +21,11 @@ import (
 // ErrMocked indicates the target function is mocked
 var ErrMocked = errors.New("func mocked by xgo")
 
+// ErrCallOld indicates the mock interceptor
+// does not handle the call, the target function
+// should be called instead
+var ErrCallOld = errors.New("call old func")
+
 type Interceptor func(ctx context.Context, fn *core.FuncInfo, args core.Object, results core.Object) error
 
 type PreInterceptor func(ctx context.Context, fn *core.FuncInfo, args core.Object, results core.Object) (interface{}, error)
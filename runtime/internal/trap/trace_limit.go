@@ -0,0 +1,136 @@
+package trap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/xhd2015/xgo/runtime/core"
+	"github.com/xhd2015/xgo/runtime/internal/flags"
+	"github.com/xhd2015/xgo/runtime/internal/stack"
+)
+
+// traceLimits controls the size of a trace,
+// the fields are decoded from trace.Config,
+// or STRACE_LIMITS for --strace.
+// keep the same with runtime/trace/trace.go
+// and cmd/xgo/strace.go
+type traceLimits struct {
+	MaxDepth    int `json:"MaxDepth,omitempty"`
+	MaxChildren int `json:"MaxChildren,omitempty"`
+	MaxArgBytes int `json:"MaxArgBytes,omitempty"`
+	// function pattern -> rate
+	Sampling         map[string]float64 `json:"Sampling,omitempty"`
+	CollapseRepeated bool               `json:"CollapseRepeated,omitempty"`
+
+	mutex sync.Mutex
+	// rate by function, negative means not sampled
+	rates map[*core.FuncInfo]float64
+	// calls by function
+	calls map[*core.FuncInfo]int
+}
+
+var testTraceLimitsOnce sync.Once
+var testTraceLimits *traceLimits
+
+// getTestTraceLimits returns limits specified by
+// --strace-max-depth and other --strace flags
+func getTestTraceLimits() *traceLimits {
+	testTraceLimitsOnce.Do(func() {
+		if flags.STRACE_LIMITS == "" {
+			return
+		}
+		testTraceLimits = parseTraceLimits([]byte(flags.STRACE_LIMITS))
+	})
+	return testTraceLimits
+}
+
+// parseTraceLimits returns nil if no limit is set
+func parseTraceLimits(data []byte) *traceLimits {
+	limits := &traceLimits{}
+	err := json.Unmarshal(data, limits)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: parse trace limits: %v\n", err)
+		return nil
+	}
+	if limits.MaxDepth <= 0 && limits.MaxChildren <= 0 && limits.MaxArgBytes <= 0 && len(limits.Sampling) == 0 && !limits.CollapseRepeated {
+		return nil
+	}
+	return limits
+}
+
+func (c *traceLimits) maxArgBytes() int {
+	if c == nil {
+		return 0
+	}
+	return c.MaxArgBytes
+}
+
+func (c *traceLimits) collapseRepeated() bool {
+	return c != nil && c.CollapseRepeated
+}
+
+// omit checks whether a call should be omitted from the
+// trace, if so, stk.EndOmit must be called when the call
+// finishes
+func (c *traceLimits) omit(stk *stack.Stack, funcInfo *core.FuncInfo) bool {
+	if c == nil || (c.MaxDepth <= 0 && c.MaxChildren <= 0 && len(c.Sampling) == 0) {
+		return false
+	}
+	return stk.TryOmit(c.MaxDepth, c.MaxChildren, c.sampler(funcInfo))
+}
+
+// sampler returns nil if all calls of the function are kept,
+// otherwise it returns a func that decides whether a call is
+// sampled out, which keeps the first call and about `rate` of
+// the calls after that.
+func (c *traceLimits) sampler(funcInfo *core.FuncInfo) func() bool {
+	if len(c.Sampling) == 0 || funcInfo == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	rate, ok := c.rates[funcInfo]
+	c.mutex.Unlock()
+	if !ok {
+		// matching may call instrumented stdlib functions,
+		// so it must be done outside the stack lock
+		rate = samplingRate(c.Sampling, funcInfo.Pkg+"."+funcInfo.IdentityName)
+		c.mutex.Lock()
+		if c.rates == nil {
+			c.rates = make(map[*core.FuncInfo]float64)
+		}
+		c.rates[funcInfo] = rate
+		c.mutex.Unlock()
+	}
+	if rate < 0 || rate >= 1 {
+		return nil
+	}
+	return func() bool {
+		c.mutex.Lock()
+		if c.calls == nil {
+			c.calls = make(map[*core.FuncInfo]int)
+		}
+		i := c.calls[funcInfo]
+		c.calls[funcInfo] = i + 1
+		c.mutex.Unlock()
+		return i > 0 && int(float64(i)*rate) == int(float64(i-1)*rate)
+	}
+}
+
+// samplingRate returns the rate of the longest pattern
+// matching the function, or -1 if none matches
+func samplingRate(sampling map[string]float64, fullName string) float64 {
+	rate := -1.0
+	matchLen := -1
+	for pattern, r := range sampling {
+		if len(pattern) <= matchLen {
+			continue
+		}
+		if pattern == fullName || matchAnyPattern([]string{pattern}, fullName) {
+			rate = r
+			matchLen = len(pattern)
+		}
+	}
+	return rate
+}
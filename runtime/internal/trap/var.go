@@ -42,8 +42,12 @@ func trapVar(infoPtr unsafe.Pointer, varAddr interface{}, res interface{}) {
 	if mock == nil && len(recorders) == 0 && len(interceptors) == 0 && !tracing {
 		return
 	}
+	var limits *traceLimits
+	if tracing {
+		limits = stkData.traceLimits
+	}
 	begin := xgo_runtime.XgoRealTimeNow()
-	doTrapVar(funcInfo, stk, begin, tracing, res, recorders, interceptors, mock, res)
+	doTrapVar(funcInfo, stk, begin, tracing, limits, res, recorders, interceptors, mock, res)
 }
 
 func trapVarPtr(infoPtr unsafe.Pointer, varAddr interface{}, res interface{}) {
@@ -102,11 +106,15 @@ func trapVarPtr(infoPtr unsafe.Pointer, varAddr interface{}, res interface{}) {
 	if mock == nil && len(recorders) == 0 && len(interceptors) == 0 && !tracing {
 		return
 	}
+	var limits *traceLimits
+	if tracing {
+		limits = stkData.traceLimits
+	}
 	begin := xgo_runtime.XgoRealTimeNow()
-	doTrapVar(funcInfo, stk, begin, tracing, res, recorders, interceptors, mock, mockRes)
+	doTrapVar(funcInfo, stk, begin, tracing, limits, res, recorders, interceptors, mock, mockRes)
 }
 
-func doTrapVar(funcInfo *core.FuncInfo, stk *stack.Stack, begin time.Time, tracing bool, res interface{}, recorders []*varRecordHolder, interceptors []*recorderHolder, mock func(fnInfo *core.FuncInfo, res interface{}), mockRes interface{}) {
+func doTrapVar(funcInfo *core.FuncInfo, stk *stack.Stack, begin time.Time, tracing bool, limits *traceLimits, res interface{}, recorders []*varRecordHolder, interceptors []*recorderHolder, mock func(fnInfo *core.FuncInfo, res interface{}), mockRes interface{}) {
 	var postRecorders []func()
 	for _, recorder := range recorders {
 		var data interface{}
@@ -147,19 +155,33 @@ func doTrapVar(funcInfo *core.FuncInfo, stk *stack.Stack, begin time.Time, traci
 	if !tracing {
 		return
 	}
+	if limits.omit(stk, funcInfo) {
+		// variable access has no nested calls
+		stk.EndOmit()
+		return
+	}
 	_, file, line, _ := runtime.Caller(SKIP + 2)
 	end := xgo_runtime.XgoRealTimeNow()
 	endNs := end.UnixNano() - stk.Begin.UnixNano()
 	resultsJSON := json.RawMessage(xgo_runtime.MarshalNoError(res))
+	var truncated bool
+	if maxArgBytes := limits.maxArgBytes(); maxArgBytes > 0 && len(resultsJSON) > maxArgBytes {
+		truncated = true
+		resultsJSON = json.RawMessage(xgo_runtime.MarshalNoError(truncateJSON(resultsJSON, maxArgBytes)))
+	}
 	// Single locked push+finish so export cannot race with var trap records.
 	cur, oldTop := stk.PushNew(begin, funcInfo.Name, func(cur *stack.Entry) {
 		cur.File = file
 		cur.Line = line
 		cur.FuncInfo = funcInfo
 		cur.Results = resultsJSON
+		cur.Truncated = truncated
 	})
 	stk.Finish(cur, oldTop, end, false, func(cur *stack.Entry) {
 		cur.EndNs = endNs
 		cur.Finished = true
 	})
+	if limits.collapseRepeated() {
+		stk.CollapseRepeated(cur, oldTop)
+	}
 }
@@ -0,0 +1,16 @@
+//go:build go1.22
+// +build go1.22
+
+package mock
+
+import "reflect"
+
+// NewInterface creates a value implementing interface `T`,
+// see NewInterfaceOf.
+//
+// Example:
+//
+//	svc := mock.NewInterface[Service]()
+func NewInterface[T any]() T {
+	return newInterface(reflect.TypeOf((*T)(nil)).Elem()).(T)
+}
@@ -7,6 +7,11 @@ import (
 	"github.com/xhd2015/xgo/runtime/internal/trap"
 )
 
+// ErrCallOld can be returned by an interceptor
+// to indicate that the original function should
+// be called, as if it was not mocked
+var ErrCallOld = trap.ErrCallOld
+
 type Interceptor func(ctx context.Context, fn *core.FuncInfo, args core.Object, results core.Object) error
 
 // Mock setup mock on given function `fn`.
@@ -17,13 +22,13 @@ type Interceptor func(ctx context.Context, fn *core.FuncInfo, args core.Object,
 // The returned function can be used to cancel
 // the passed interceptor.
 func Mock(fn interface{}, interceptor Interceptor) func() {
-	return trap.PushMockInterceptor(fn, trap.Interceptor(interceptor))
+	return trap.PushMockInterceptor(trap.ScopeDefault, fn, trap.Interceptor(interceptor))
 }
 
 func MockByName(pkgPath string, funcName string, interceptor Interceptor) func() {
-	return trap.PushMockByName(pkgPath, funcName, trap.Interceptor(interceptor))
+	return trap.PushMockByName(trap.ScopeDefault, pkgPath, funcName, trap.Interceptor(interceptor))
 }
 
 func MockMethodByName(instance interface{}, method string, interceptor Interceptor) func() {
-	return trap.PushMockMethodByName(instance, method, trap.Interceptor(interceptor))
+	return trap.PushMockMethodByName(trap.ScopeDefault, instance, method, trap.Interceptor(interceptor))
 }
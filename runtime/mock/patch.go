@@ -12,13 +12,13 @@ import (
 // this function returns a clean up function that can be
 // used to clear the replacer.
 func Patch(fn interface{}, replacer interface{}) func() {
-	return trap.PushMockReplacer(fn, replacer)
+	return trap.PushMockReplacer(trap.ScopeDefault, fn, replacer)
 }
 
 func PatchByName(pkgPath string, funcName string, replacer interface{}) func() {
-	return trap.PushMockReplacerByName(pkgPath, funcName, replacer)
+	return trap.PushMockReplacerByName(trap.ScopeDefault, pkgPath, funcName, replacer)
 }
 
 func PatchMethodByName(instance interface{}, method string, replacer interface{}) func() {
-	return trap.PushMockReplacerMethodByName(instance, method, replacer)
+	return trap.PushMockReplacerMethodByName(trap.ScopeDefault, instance, method, replacer)
 }
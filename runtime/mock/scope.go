@@ -0,0 +1,88 @@
+package mock
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/xhd2015/xgo/runtime/internal/trap"
+)
+
+// Scope sets up mocks visible to a chosen set of goroutines,
+// see Global, GoroutineTree and Goroutine.
+//
+// When mocks of different scopes are set up on the same function,
+// the mocks owned by the calling goroutine(GoroutineTree or Goroutine)
+// take precedence over Global mocks, which in turn take precedence
+// over mocks set up during init. Within the same level, the most
+// recently set up mock wins.
+type Scope struct {
+	scope trap.Scope
+	t     testing.TB
+}
+
+// Global returns a scope whose mocks are visible to all goroutines,
+// including goroutines created before the mock was set up, e.g.
+// worker pools started in TestMain or init.
+// If `t` is not nil, the mock is cancelled when the test finishes.
+// Otherwise, the returned cancel function must be called to
+// avoid affecting other tests.
+func Global(t testing.TB) *Scope {
+	return &Scope{scope: trap.ScopeGlobal, t: t}
+}
+
+// GoroutineTree returns a scope whose mocks are visible to current
+// goroutine and goroutines created by it afterwards.
+// This is the default scope of Mock and Patch.
+func GoroutineTree() *Scope {
+	return &Scope{scope: trap.ScopeGoroutineTree}
+}
+
+// Goroutine returns a scope whose mocks are visible to
+// current goroutine only, goroutines created by it
+// afterwards are not affected.
+func Goroutine() *Scope {
+	return &Scope{scope: trap.ScopeGoroutine}
+}
+
+// Mock is like the package level Mock, but with the scope applied
+func (c *Scope) Mock(fn interface{}, interceptor Interceptor) func() {
+	return c.cleanup(trap.PushMockInterceptor(c.scope, fn, trap.Interceptor(interceptor)))
+}
+
+// MockByName is like the package level MockByName, but with the scope applied
+func (c *Scope) MockByName(pkgPath string, funcName string, interceptor Interceptor) func() {
+	return c.cleanup(trap.PushMockByName(c.scope, pkgPath, funcName, trap.Interceptor(interceptor)))
+}
+
+// MockMethodByName is like the package level MockMethodByName, but with the scope applied
+func (c *Scope) MockMethodByName(instance interface{}, method string, interceptor Interceptor) func() {
+	return c.cleanup(trap.PushMockMethodByName(c.scope, instance, method, trap.Interceptor(interceptor)))
+}
+
+// Patch is like the package level Patch, but with the scope applied
+func (c *Scope) Patch(fn interface{}, replacer interface{}) func() {
+	return c.cleanup(trap.PushMockReplacer(c.scope, fn, replacer))
+}
+
+// PatchByName is like the package level PatchByName, but with the scope applied
+func (c *Scope) PatchByName(pkgPath string, funcName string, replacer interface{}) func() {
+	return c.cleanup(trap.PushMockReplacerByName(c.scope, pkgPath, funcName, replacer))
+}
+
+// PatchMethodByName is like the package level PatchMethodByName, but with the scope applied
+func (c *Scope) PatchMethodByName(instance interface{}, method string, replacer interface{}) func() {
+	return c.cleanup(trap.PushMockReplacerMethodByName(c.scope, instance, method, replacer))
+}
+
+// cleanup makes cancel safe to be called more than
+// once, and registers it to the test if any
+func (c *Scope) cleanup(cancel func()) func() {
+	var once sync.Once
+	cancelOnce := func() {
+		once.Do(cancel)
+	}
+	if c.t != nil {
+		c.t.Cleanup(cancelOnce)
+	}
+	return cancelOnce
+}
@@ -0,0 +1,354 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/xhd2015/xgo/runtime/core"
+	"github.com/xhd2015/xgo/runtime/internal/trap"
+)
+
+// callSeq orders calls across all verifiers,
+// it is used by VerifyInOrder
+var callSeq int64
+
+// Call is a single call recorded by a Verifier
+type Call struct {
+	// Seq is the global sequence of this call
+	// among all verifiers, a call with smaller
+	// Seq happened earlier
+	Seq int64
+
+	// Args is a snapshot of the arguments
+	// when the call happened, a leading
+	// context.Context is not included
+	Args core.Object
+}
+
+// Verifier records calls of a function, and
+// checks them against expectations.
+// Calls from goroutines created by the
+// setting up goroutine are also recorded.
+// Failures are reported through the testing.TB
+// passed in when creating the verifier.
+type Verifier struct {
+	t        testing.TB
+	funcInfo *core.FuncInfo
+
+	mutex sync.Mutex
+	calls []*Call
+
+	cancels []func()
+}
+
+// Verify records calls of `fn` without changing its behavior.
+// `fn` can be a function or a method, if `fn` is a method,
+// only calls on the bound instance are recorded.
+func Verify(t testing.TB, fn interface{}) *Verifier {
+	_, funcInfo, _, _ := trap.Inspect(fn)
+	v := &Verifier{
+		t:        t,
+		funcInfo: funcInfo,
+	}
+	v.cancels = append(v.cancels, trap.PushRecorderInterceptor(fn, v.record, nil))
+	return v
+}
+
+// MockVerify is like Mock, but also returns a verifier
+// recording calls of `fn`.
+// Calling Cancel() on the verifier cancels both
+// the recorder and the mock.
+func MockVerify(t testing.TB, fn interface{}, interceptor Interceptor) *Verifier {
+	v := Verify(t, fn)
+	v.cancels = append(v.cancels, Mock(fn, interceptor))
+	return v
+}
+
+// PatchVerify is like Patch, but also returns a verifier
+// recording calls of `fn`.
+// Calling Cancel() on the verifier cancels both
+// the recorder and the replacer.
+func PatchVerify(t testing.TB, fn interface{}, replacer interface{}) *Verifier {
+	v := Verify(t, fn)
+	v.cancels = append(v.cancels, Patch(fn, replacer))
+	return v
+}
+
+func (c *Verifier) record(ctx context.Context, fn *core.FuncInfo, args core.Object, results core.Object) (interface{}, error) {
+	call := &Call{
+		Seq:  atomic.AddInt64(&callSeq, 1),
+		Args: snapshotArgs(fn, args),
+	}
+	c.mutex.Lock()
+	c.calls = append(c.calls, call)
+	c.mutex.Unlock()
+	return nil, nil
+}
+
+// Cancel stops recording, and cancels the
+// mock if the verifier was created by MockVerify
+// or PatchVerify.
+// Calls recorded so far are kept.
+func (c *Verifier) Cancel() {
+	cancels := c.cancels
+	c.cancels = nil
+	for i := len(cancels) - 1; i >= 0; i-- {
+		cancels[i]()
+	}
+}
+
+// Calls returns all calls recorded so far, in calling order
+func (c *Verifier) Calls() []*Call {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	calls := make([]*Call, len(c.calls))
+	copy(calls, c.calls)
+	return calls
+}
+
+// Count returns the number of calls recorded so far
+func (c *Verifier) Count() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.calls)
+}
+
+// Reset clears recorded calls
+func (c *Verifier) Reset() {
+	c.mutex.Lock()
+	c.calls = nil
+	c.mutex.Unlock()
+}
+
+// Times checks that `fn` was called exactly `n` times
+func (c *Verifier) Times(n int) bool {
+	c.t.Helper()
+	count := c.Count()
+	if count != n {
+		c.t.Errorf("expect %s to be called %d times, actual: %d", c.funcInfo.DisplayName(), n, count)
+		return false
+	}
+	return true
+}
+
+// NeverCalled checks that `fn` was not called
+func (c *Verifier) NeverCalled() bool {
+	c.t.Helper()
+	count := c.Count()
+	if count != 0 {
+		c.t.Errorf("expect %s to be never called, actual called %d times, first with: %s", c.funcInfo.DisplayName(), count, formatArgs(c.Calls()[0].Args))
+		return false
+	}
+	return true
+}
+
+// CalledWith checks that `fn` was called at least once
+// with `args`.
+// A leading context.Context argument should be omitted.
+// For unbound methods like `(*T).Method`, the receiver
+// should be passed as the first argument.
+// Args are compared with reflect.DeepEqual, a nil arg
+// matches any nil value.
+func (c *Verifier) CalledWith(args ...interface{}) bool {
+	c.t.Helper()
+	calls := c.Calls()
+	for _, call := range calls {
+		if argsMatch(call.Args, args) {
+			return true
+		}
+	}
+	if len(calls) == 0 {
+		c.t.Errorf("expect %s to be called with %s, actual not called", c.funcInfo.DisplayName(), formatValues(args))
+		return false
+	}
+	actuals := make([]string, len(calls))
+	for i, call := range calls {
+		actuals[i] = formatArgs(call.Args)
+	}
+	c.t.Errorf("expect %s to be called with %s, actual calls:\n  %s", c.funcInfo.DisplayName(), formatValues(args), strings.Join(actuals, "\n  "))
+	return false
+}
+
+// VerifyInOrder checks that calls happened in the order
+// of the given verifiers. Other calls may interleave.
+// A verifier can appear more than once, meaning the
+// function was called again at that position.
+//
+// Example:
+//
+//	open := mock.Verify(t, Open)
+//	read := mock.Verify(t, Read)
+//	close := mock.Verify(t, Close)
+//	...
+//	mock.VerifyInOrder(t, open, read, read, close)
+func VerifyInOrder(t testing.TB, verifiers ...*Verifier) bool {
+	t.Helper()
+	var allCalls []*orderedCall
+	seen := make(map[*Verifier]bool, len(verifiers))
+	for _, v := range verifiers {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		for _, call := range v.Calls() {
+			allCalls = append(allCalls, &orderedCall{verifier: v, seq: call.Seq})
+		}
+	}
+	sort.Slice(allCalls, func(i, j int) bool {
+		return allCalls[i].seq < allCalls[j].seq
+	})
+
+	i := 0
+	for _, call := range allCalls {
+		if i >= len(verifiers) {
+			break
+		}
+		if call.verifier == verifiers[i] {
+			i++
+		}
+	}
+	if i < len(verifiers) {
+		expect := make([]string, len(verifiers))
+		for j, v := range verifiers {
+			expect[j] = v.funcInfo.DisplayName()
+		}
+		actual := make([]string, len(allCalls))
+		for j, call := range allCalls {
+			actual[j] = call.verifier.funcInfo.DisplayName()
+		}
+		t.Errorf("expect calls in order: %s, actual: %s", strings.Join(expect, " -> "), strings.Join(actual, " -> "))
+		return false
+	}
+	return true
+}
+
+type orderedCall struct {
+	verifier *Verifier
+	seq      int64
+}
+
+func argsMatch(actual core.Object, expect []interface{}) bool {
+	if actual.NumField() != len(expect) {
+		return false
+	}
+	for i, e := range expect {
+		if !valueMatch(actual.GetFieldIndex(i).Value(), e) {
+			return false
+		}
+	}
+	return true
+}
+
+func valueMatch(actual interface{}, expect interface{}) bool {
+	if expect == nil {
+		return isNil(actual)
+	}
+	return reflect.DeepEqual(actual, expect)
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// snapshotArgs copies values of args, so that
+// later modifications to the arguments inside
+// the function do not affect what was recorded
+func snapshotArgs(fn *core.FuncInfo, args core.Object) core.Object {
+	n := args.NumField()
+	obj := make(argObject, 0, n)
+	skipCtx := fn.FirstArgCtx
+	for i := 0; i < n; i++ {
+		f := args.GetFieldIndex(i)
+		ptr := reflect.ValueOf(f.Ptr())
+		if skipCtx {
+			if _, ok := f.Ptr().(*context.Context); ok {
+				skipCtx = false
+				continue
+			}
+		}
+		valPtr := reflect.New(ptr.Type().Elem())
+		valPtr.Elem().Set(ptr.Elem())
+		obj = append(obj, argField{
+			name:   f.Name(),
+			valPtr: valPtr.Interface(),
+		})
+	}
+	return obj
+}
+
+func formatArgs(args core.Object) string {
+	values := make([]interface{}, args.NumField())
+	for i := range values {
+		values[i] = args.GetFieldIndex(i).Value()
+	}
+	return formatValues(values)
+}
+
+func formatValues(values []interface{}) string {
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = fmt.Sprintf("%#v", v)
+	}
+	return "(" + strings.Join(strs, ", ") + ")"
+}
+
+type argObject []argField
+
+type argField struct {
+	name   string
+	valPtr interface{}
+}
+
+var _ core.Object = (argObject)(nil)
+var _ core.Field = argField{}
+
+func (c argObject) GetField(name string) core.Field {
+	for _, field := range c {
+		if field.name == name {
+			return field
+		}
+	}
+	panic(fmt.Errorf("no field: %s", name))
+}
+
+func (c argObject) GetFieldIndex(i int) core.Field {
+	return c[i]
+}
+
+func (c argObject) NumField() int {
+	return len(c)
+}
+
+func (c argField) Name() string {
+	return c.name
+}
+
+func (c argField) Value() interface{} {
+	return reflect.ValueOf(c.valPtr).Elem().Interface()
+}
+
+func (c argField) Ptr() interface{} {
+	return c.valPtr
+}
+
+func (c argField) Set(val interface{}) {
+	if val == nil {
+		reflect.ValueOf(c.valPtr).Elem().Set(reflect.Zero(reflect.TypeOf(c.valPtr).Elem()))
+		return
+	}
+	reflect.ValueOf(c.valPtr).Elem().Set(reflect.ValueOf(val))
+}
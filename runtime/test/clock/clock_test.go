@@ -0,0 +1,259 @@
+package clock
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xhd2015/xgo/runtime/clock"
+)
+
+var base = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFreezeAndAdvance(t *testing.T) {
+	c := clock.FreezeAt(t, base)
+
+	if now := time.Now(); !now.Equal(base) {
+		t.Fatalf("expect time.Now() to be %v, actual: %v", base, now)
+	}
+	c.Advance(time.Hour)
+	if now := time.Now(); !now.Equal(base.Add(time.Hour)) {
+		t.Fatalf("expect time.Now() to be %v, actual: %v", base.Add(time.Hour), now)
+	}
+	if d := time.Since(base); d != time.Hour {
+		t.Fatalf("expect time.Since() to be %v, actual: %v", time.Hour, d)
+	}
+	if d := time.Until(base.Add(2 * time.Hour)); d != time.Hour {
+		t.Fatalf("expect time.Until() to be %v, actual: %v", time.Hour, d)
+	}
+
+	c.Set(base)
+	if now := time.Now(); !now.Equal(base) {
+		t.Fatalf("expect time.Now() after Set to be %v, actual: %v", base, now)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	c := clock.FreezeAt(t, base)
+	c.Restore()
+	if now := time.Now(); now.Year() == 2024 {
+		t.Fatalf("expect real time after Restore, actual: %v", now)
+	}
+}
+
+func TestSleepBlocksUntilAdvance(t *testing.T) {
+	c := clock.FreezeAt(t, base)
+
+	done := make(chan time.Time)
+	go func() {
+		time.Sleep(10 * time.Second)
+		done <- time.Now()
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(5 * time.Second)
+	select {
+	case <-done:
+		t.Fatalf("expect sleep not finished before deadline")
+	default:
+	}
+	c.Advance(5 * time.Second)
+	woke := <-done
+	if !woke.Equal(base.Add(10 * time.Second)) {
+		t.Fatalf("expect woke at %v, actual: %v", base.Add(10*time.Second), woke)
+	}
+}
+
+func retry(n int, fn func() error) error {
+	var err error
+	backoff := time.Second
+	for i := 0; i < n; i++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	return err
+}
+
+func TestAutoAdvanceRetry(t *testing.T) {
+	c := clock.FreezeAt(t, base)
+	c.SetAutoAdvance(true)
+
+	realBegin := time.Now()
+	err := retry(4, func() error {
+		return errors.New("fail")
+	})
+	if err == nil {
+		t.Fatalf("expect err")
+	}
+	// 1+2+4+8
+	if d := time.Since(realBegin); d != 15*time.Second {
+		t.Fatalf("expect clock advanced by %v, actual: %v", 15*time.Second, d)
+	}
+}
+
+func TestTimerAndAfter(t *testing.T) {
+	c := clock.FreezeAt(t, base)
+
+	timer := time.NewTimer(time.Minute)
+	after := time.After(2 * time.Minute)
+
+	c.Advance(time.Minute)
+	select {
+	case v := <-timer.C:
+		if !v.Equal(base.Add(time.Minute)) {
+			t.Fatalf("expect timer fired at %v, actual: %v", base.Add(time.Minute), v)
+		}
+	default:
+		t.Fatalf("expect timer fired")
+	}
+	select {
+	case <-after:
+		t.Fatalf("expect after not fired yet")
+	default:
+	}
+	c.Advance(time.Minute)
+	select {
+	case <-after:
+	default:
+		t.Fatalf("expect after fired")
+	}
+
+	if timer.Stop() {
+		t.Fatalf("expect Stop on fired timer to return false")
+	}
+	if timer.Reset(time.Second) {
+		t.Fatalf("expect Reset on fired timer to return false")
+	}
+	if !timer.Stop() {
+		t.Fatalf("expect Stop on pending timer to return true")
+	}
+	c.Advance(time.Hour)
+	select {
+	case <-timer.C:
+		t.Fatalf("expect stopped timer not fired")
+	default:
+	}
+}
+
+func TestAfterFunc(t *testing.T) {
+	c := clock.FreezeAt(t, base)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var called bool
+	time.AfterFunc(time.Second, func() {
+		called = true
+		wg.Done()
+	})
+	c.Advance(time.Second)
+	wg.Wait()
+	if !called {
+		t.Fatalf("expect AfterFunc called")
+	}
+}
+
+func TestTicker(t *testing.T) {
+	c := clock.FreezeAt(t, base)
+
+	ticker := time.NewTicker(time.Second)
+	var ticks []time.Time
+	for i := 0; i < 3; i++ {
+		c.Advance(time.Second)
+		ticks = append(ticks, <-ticker.C)
+	}
+	for i, tick := range ticks {
+		expect := base.Add(time.Duration(i+1) * time.Second)
+		if !tick.Equal(expect) {
+			t.Fatalf("expect tick %d at %v, actual: %v", i, expect, tick)
+		}
+	}
+
+	// slow receiver drops ticks
+	c.Advance(5 * time.Second)
+	<-ticker.C
+	select {
+	case <-ticker.C:
+		t.Fatalf("expect ticks dropped")
+	default:
+	}
+
+	ticker.Stop()
+	c.Advance(time.Minute)
+	select {
+	case <-ticker.C:
+		t.Fatalf("expect stopped ticker not ticking")
+	default:
+	}
+}
+
+func TestRealTimerNotAffected(t *testing.T) {
+	realTimer := time.NewTimer(time.Hour)
+
+	clock.FreezeAt(t, base)
+
+	if !realTimer.Stop() {
+		t.Fatalf("expect real timer stopped")
+	}
+}
+
+func TestTimerStopFromForeignGoroutine(t *testing.T) {
+	timers := make(chan *time.Timer)
+	stopped := make(chan bool)
+	// created before the clock, so not inheriting its mocks
+	go func() {
+		timer := <-timers
+		stopped <- timer.Stop()
+	}()
+
+	clock.FreezeAt(t, base)
+
+	timers <- time.NewTimer(time.Second)
+	if !<-stopped {
+		t.Fatalf("expect fake timer stopped by foreign goroutine")
+	}
+}
+
+func TestRestoreReleasesTimers(t *testing.T) {
+	c := clock.FreezeAt(t, base)
+	timer := time.NewTimer(time.Second)
+	ticker := time.NewTicker(time.Second)
+
+	done := make(chan struct{})
+	go func() {
+		<-timer.C
+		for range ticker.C {
+		}
+		close(done)
+	}()
+
+	c.BlockUntil(2)
+	c.Restore()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expect timer and ticker released after Restore")
+	}
+}
+
+func TestRestoredWhenTestFinishes(t *testing.T) {
+	var timer *time.Timer
+	t.Run("frozen", func(t *testing.T) {
+		clock.FreezeAt(t, base)
+		timer = time.NewTimer(time.Second)
+	})
+	if now := time.Now(); now.Year() == 2024 {
+		t.Fatalf("expect real time after the test finished, actual: %v", now)
+	}
+	if _, ok := <-timer.C; ok {
+		t.Fatalf("expect timer channel closed after the test finished")
+	}
+	realTimer := time.NewTimer(time.Hour)
+	if !realTimer.Stop() {
+		t.Fatalf("expect real timer stopped")
+	}
+}
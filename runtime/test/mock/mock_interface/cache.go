@@ -0,0 +1,10 @@
+package mock_interface
+
+import "time"
+
+// Cache is declared in a file other than
+// the interface embedding it
+type Cache interface {
+	Load(key string) (*User, bool)
+	Expire(key string, after time.Duration)
+}
@@ -0,0 +1,34 @@
+//go:build go1.22
+// +build go1.22
+
+package mock_interface
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/xhd2015/xgo/runtime/mock"
+)
+
+func TestNewInterfaceGeneric(t *testing.T) {
+	getter := mock.NewInterface[Getter]()
+	mock.Patch(getter.Get, func(ctx context.Context, id int) (*User, error) {
+		return &User{Name: "generic"}, nil
+	})
+	user, err := getter.Get(context.Background(), 1)
+	if err != nil || user.Name != "generic" {
+		t.Fatalf("expect generic user, actual: %v %v", user, err)
+	}
+}
+
+func TestNewInterfaceGenericDependency(t *testing.T) {
+	rw := mock.NewInterface[io.ReadWriter]()
+	mock.Patch(rw.Write, func(p []byte) (int, error) {
+		return len(p), nil
+	})
+	n, err := rw.Write([]byte("hello"))
+	if err != nil || n != 5 {
+		t.Fatalf("expect 5 bytes written, actual: %v %v", n, err)
+	}
+}
@@ -0,0 +1,216 @@
+package mock_interface
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/xhd2015/xgo/runtime/core"
+	"github.com/xhd2015/xgo/runtime/mock"
+)
+
+type User struct {
+	Name string
+}
+
+type Getter interface {
+	Get(ctx context.Context, id int) (*User, error)
+}
+
+// Service has no implementation in this package
+type Service interface {
+	Getter
+	error
+	List(prefix string, ids ...int) []string
+	Count() int
+}
+
+// CachedService embeds interfaces declared in
+// other files and packages
+type CachedService interface {
+	Cache
+	io.ReadCloser
+	Name() string
+}
+
+func TestNewInterfaceZeroValues(t *testing.T) {
+	svc := mock.NewInterfaceOf((*Service)(nil)).(Service)
+
+	user, err := svc.Get(context.Background(), 1)
+	if user != nil || err != nil {
+		t.Fatalf("expect zero results, actual: %v %v", user, err)
+	}
+	if n := svc.Count(); n != 0 {
+		t.Fatalf("expect zero count, actual: %d", n)
+	}
+	if msg := svc.Error(); msg != "" {
+		t.Fatalf("expect empty error message, actual: %q", msg)
+	}
+}
+
+func TestNewInterfaceMock(t *testing.T) {
+	svc := mock.NewInterfaceOf((*Service)(nil)).(Service)
+	mock.Mock(svc.Get, func(ctx context.Context, fn *core.FuncInfo, args, results core.Object) error {
+		id := args.GetField("id").Value().(int)
+		results.GetFieldIndex(0).Set(&User{Name: fmt.Sprintf("user_%d", id)})
+		return nil
+	})
+	user, err := svc.Get(context.Background(), 1)
+	if err != nil || user == nil || user.Name != "user_1" {
+		t.Fatalf("expect mocked user, actual: %v %v", user, err)
+	}
+}
+
+func TestNewInterfacePatch(t *testing.T) {
+	svc := mock.NewInterfaceOf((*Service)(nil)).(Service)
+	mock.Patch(svc.List, func(prefix string, ids ...int) []string {
+		var res []string
+		for _, id := range ids {
+			res = append(res, fmt.Sprintf("%s%d", prefix, id))
+		}
+		return res
+	})
+	res := svc.List("a", 1, 2)
+	if fmt.Sprint(res) != "[a1 a2]" {
+		t.Fatalf("expect patched list, actual: %v", res)
+	}
+}
+
+func TestNewInterfaceInstancesAreIndependent(t *testing.T) {
+	a := mock.NewInterfaceOf((*Service)(nil)).(Service)
+	b := mock.NewInterfaceOf((*Service)(nil)).(Service)
+	mock.Patch(a.Count, func() int {
+		return 10
+	})
+	if n := a.Count(); n != 10 {
+		t.Fatalf("expect a.Count() to be 10, actual: %d", n)
+	}
+	if n := b.Count(); n != 0 {
+		t.Fatalf("expect b.Count() not mocked, actual: %d", n)
+	}
+}
+
+func TestNewInterfaceErrorMethod(t *testing.T) {
+	svc := mock.NewInterfaceOf((*Service)(nil)).(Service)
+	mock.Patch(svc.Error, func() string {
+		return "mock error"
+	})
+	var err error = svc
+	if err.Error() != "mock error" {
+		t.Fatalf("expect mock error, actual: %q", err.Error())
+	}
+}
+
+func TestNewInterfaceStubAndVerify(t *testing.T) {
+	errNotFound := errors.New("not found")
+	svc := mock.NewInterfaceOf((*Service)(nil)).(Service)
+
+	v := mock.Verify(t, svc.Get)
+	mock.Stub(svc.Get).When(mock.Args(404)).Error(errNotFound)
+
+	_, err := svc.Get(context.Background(), 404)
+	if err != errNotFound {
+		t.Fatalf("expect %v, actual: %v", errNotFound, err)
+	}
+	svc.Get(context.Background(), 1)
+
+	v.Times(2)
+	v.CalledWith(404)
+}
+
+func TestNewInterfaceMockMethodByName(t *testing.T) {
+	svc := mock.NewInterfaceOf((*Service)(nil)).(Service)
+	mock.PatchMethodByName(svc, "Count", func() int {
+		return 5
+	})
+	if n := svc.Count(); n != 5 {
+		t.Fatalf("expect Count() to be 5, actual: %d", n)
+	}
+}
+
+func TestNewInterfaceFuncInfo(t *testing.T) {
+	svc := mock.NewInterfaceOf((*Service)(nil)).(Service)
+	var fnInfo *core.FuncInfo
+	mock.Mock(svc.Count, func(ctx context.Context, fn *core.FuncInfo, args, results core.Object) error {
+		fnInfo = fn
+		return nil
+	})
+	svc.Count()
+	if fnInfo == nil {
+		t.Fatalf("expect interceptor called")
+	}
+	if fnInfo.DisplayName() != "Service.Count" {
+		t.Fatalf("expect display name %q, actual: %q", "Service.Count", fnInfo.DisplayName())
+	}
+}
+
+func TestNewInterfaceEmbedOtherFiles(t *testing.T) {
+	svc := mock.NewInterfaceOf((*CachedService)(nil)).(CachedService)
+	if user, ok := svc.Load("a"); user != nil || ok {
+		t.Fatalf("expect zero results, actual: %v %v", user, ok)
+	}
+	mock.Patch(svc.Load, func(key string) (*User, bool) {
+		return &User{Name: key}, true
+	})
+	var expired time.Duration
+	mock.Patch(svc.Expire, func(key string, after time.Duration) {
+		expired = after
+	})
+	user, ok := svc.Load("a")
+	if !ok || user.Name != "a" {
+		t.Fatalf("expect cached user, actual: %v %v", user, ok)
+	}
+	svc.Expire("a", time.Second)
+	if expired != time.Second {
+		t.Fatalf("expect expired after 1s, actual: %v", expired)
+	}
+}
+
+func TestNewInterfaceEmbedOtherPackages(t *testing.T) {
+	svc := mock.NewInterfaceOf((*CachedService)(nil)).(CachedService)
+	if n, err := svc.Read(nil); n != 0 || err != nil {
+		t.Fatalf("expect zero results, actual: %v %v", n, err)
+	}
+	mock.Patch(svc.Read, func(p []byte) (int, error) {
+		return copy(p, "hello"), io.EOF
+	})
+	closed := errors.New("closed")
+	mock.Patch(svc.Close, func() error {
+		return closed
+	})
+	buf := make([]byte, 8)
+	n, err := svc.Read(buf)
+	if err != io.EOF || string(buf[:n]) != "hello" {
+		t.Fatalf("expect hello, actual: %q %v", buf[:n], err)
+	}
+	if err := svc.Close(); err != closed {
+		t.Fatalf("expect %v, actual: %v", closed, err)
+	}
+}
+
+func TestNewInterfaceOfDependency(t *testing.T) {
+	s := mock.NewInterfaceOf((*fmt.Stringer)(nil)).(fmt.Stringer)
+	mock.Patch(s.String, func() string {
+		return "mock"
+	})
+	if str := s.String(); str != "mock" {
+		t.Fatalf("expect mock, actual: %q", str)
+	}
+}
+
+func TestNewInterfaceOfInvalid(t *testing.T) {
+	var pe interface{}
+	func() {
+		defer func() {
+			pe = recover()
+		}()
+		mock.NewInterfaceOf(Service(nil))
+	}()
+	expectMsg := "requires pointer to interface, given: <nil>"
+	if fmt.Sprint(pe) != expectMsg {
+		t.Fatalf("expect panic %q, actual: %v", expectMsg, pe)
+	}
+}
@@ -0,0 +1,166 @@
+package mock_scope
+
+import (
+	"testing"
+
+	"github.com/xhd2015/xgo/runtime/mock"
+)
+
+func greet(name string) string {
+	return "hello " + name
+}
+
+var greeting string = "hello"
+
+// worker is a goroutine created before mocks are set up,
+// like a worker pool started in TestMain
+type worker struct {
+	req  chan func()
+	done chan bool
+}
+
+func startWorker() *worker {
+	w := &worker{req: make(chan func()), done: make(chan bool)}
+	go func() {
+		for f := range w.req {
+			f()
+			w.done <- true
+		}
+	}()
+	return w
+}
+
+func (c *worker) run(f func()) {
+	c.req <- f
+	<-c.done
+}
+
+func (c *worker) stop() {
+	close(c.req)
+}
+
+func inNewGoroutine(f func()) {
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		f()
+	}()
+	<-done
+}
+
+func greetIn(run func(f func())) string {
+	var res string
+	run(func() {
+		res = greet("world")
+	})
+	return res
+}
+
+func direct(f func()) {
+	f()
+}
+
+func TestGlobalVisibleToExistingGoroutines(t *testing.T) {
+	w := startWorker()
+	defer w.stop()
+
+	cancel := mock.Global(nil).Patch(greet, func(name string) string {
+		return "global " + name
+	})
+	for _, run := range []func(f func()){direct, inNewGoroutine, w.run} {
+		res := greetIn(run)
+		if res != "global world" {
+			t.Fatalf("expect greet mocked: %q, actual: %q", "global world", res)
+		}
+	}
+
+	cancel()
+	// calling twice is safe
+	cancel()
+	res := greetIn(w.run)
+	if res != "hello world" {
+		t.Fatalf("expect greet not mocked after cancel: %q, actual: %q", "hello world", res)
+	}
+}
+
+func TestGlobalCleanupWithTest(t *testing.T) {
+	w := startWorker()
+	defer w.stop()
+
+	t.Run("sub", func(t *testing.T) {
+		mock.Global(t).Patch(greet, func(name string) string {
+			return "global " + name
+		})
+		res := greetIn(w.run)
+		if res != "global world" {
+			t.Fatalf("expect greet mocked: %q, actual: %q", "global world", res)
+		}
+	})
+
+	res := greetIn(w.run)
+	if res != "hello world" {
+		t.Fatalf("expect greet not mocked after test finished: %q, actual: %q", "hello world", res)
+	}
+}
+
+func TestGoroutineTree(t *testing.T) {
+	w := startWorker()
+	defer w.stop()
+
+	mock.GoroutineTree().Patch(greet, func(name string) string {
+		return "tree " + name
+	})
+	if res := greetIn(direct); res != "tree world" {
+		t.Fatalf("expect greet mocked in current goroutine: %q, actual: %q", "tree world", res)
+	}
+	if res := greetIn(inNewGoroutine); res != "tree world" {
+		t.Fatalf("expect greet mocked in new goroutine: %q, actual: %q", "tree world", res)
+	}
+	if res := greetIn(w.run); res != "hello world" {
+		t.Fatalf("expect greet not mocked in existing goroutine: %q, actual: %q", "hello world", res)
+	}
+}
+
+func TestGoroutine(t *testing.T) {
+	mock.Goroutine().Patch(greet, func(name string) string {
+		return "goroutine " + name
+	})
+	mock.Goroutine().Patch(&greeting, func() string {
+		return "hi"
+	})
+	if res := greetIn(direct); res != "goroutine world" {
+		t.Fatalf("expect greet mocked in current goroutine: %q, actual: %q", "goroutine world", res)
+	}
+	if greeting != "hi" {
+		t.Fatalf("expect greeting mocked in current goroutine: %q, actual: %q", "hi", greeting)
+	}
+	var newGreeting string
+	inNewGoroutine(func() {
+		newGreeting = greeting
+	})
+	if newGreeting != "hello" {
+		t.Fatalf("expect greeting not mocked in new goroutine: %q, actual: %q", "hello", newGreeting)
+	}
+	if res := greetIn(inNewGoroutine); res != "hello world" {
+		t.Fatalf("expect greet not mocked in new goroutine: %q, actual: %q", "hello world", res)
+	}
+}
+
+func TestGoroutineTakesPrecedenceOverGlobal(t *testing.T) {
+	w := startWorker()
+	defer w.stop()
+
+	mock.Goroutine().Patch(greet, func(name string) string {
+		return "goroutine " + name
+	})
+	// set up later, but global mocks have lower precedence
+	mock.Global(t).Patch(greet, func(name string) string {
+		return "global " + name
+	})
+	if res := greetIn(direct); res != "goroutine world" {
+		t.Fatalf("expect goroutine mock: %q, actual: %q", "goroutine world", res)
+	}
+	if res := greetIn(w.run); res != "global world" {
+		t.Fatalf("expect global mock: %q, actual: %q", "global world", res)
+	}
+}
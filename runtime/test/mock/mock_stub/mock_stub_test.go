@@ -0,0 +1,172 @@
+package mock_stub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/xhd2015/xgo/runtime/core"
+	"github.com/xhd2015/xgo/runtime/mock"
+)
+
+var errNotFound = errors.New("not found")
+
+type User struct {
+	Name  string
+	Admin bool
+}
+
+func GetUser(ctx context.Context, name string) (*User, error) {
+	return &User{Name: name}, nil
+}
+
+func add(a int, b int) int {
+	return a + b
+}
+
+func TestStubWhenReturn(t *testing.T) {
+	s := mock.Stub(GetUser)
+	s.When(mock.Args("admin")).Return(&User{Name: "admin", Admin: true}, nil)
+	s.When(mock.Arg("name", "missing")).Error(errNotFound)
+
+	user, err := GetUser(context.Background(), "admin")
+	if err != nil || !user.Admin {
+		t.Fatalf("expect admin user, actual: %v %v", user, err)
+	}
+
+	user, err = GetUser(context.Background(), "missing")
+	if err != errNotFound || user != nil {
+		t.Fatalf("expect %v, actual: %v %v", errNotFound, user, err)
+	}
+
+	// unmatched calls go to the original function
+	user, err = GetUser(context.Background(), "other")
+	if err != nil || user.Name != "other" || user.Admin {
+		t.Fatalf("expect original result, actual: %v %v", user, err)
+	}
+
+	s.Cancel()
+	user, _ = GetUser(context.Background(), "admin")
+	if user.Admin {
+		t.Fatalf("expect stub cancelled")
+	}
+}
+
+func TestStubReturnOnceAndSequence(t *testing.T) {
+	s := mock.Stub(add)
+	s.When(nil).ReturnOnce(100).ReturnSequence([]interface{}{200}, []interface{}{300}).Return(0)
+
+	var results []int
+	for i := 0; i < 5; i++ {
+		results = append(results, add(1, 2))
+	}
+	expect := "[100 200 300 0 0]"
+	if fmt.Sprint(results) != expect {
+		t.Fatalf("expect %s, actual: %v", expect, results)
+	}
+}
+
+func TestStubOnceThenFallback(t *testing.T) {
+	s := mock.Stub(add)
+	s.When(mock.Args(1, 1)).ReturnOnce(10)
+
+	if res := add(1, 1); res != 10 {
+		t.Fatalf("expect first call stubbed, actual: %d", res)
+	}
+	if res := add(1, 1); res != 2 {
+		t.Fatalf("expect second call to call original, actual: %d", res)
+	}
+}
+
+func TestStubRuleOrder(t *testing.T) {
+	s := mock.Stub(add)
+	s.When(mock.Args(1, 2)).Return(-1)
+	s.When(nil).Return(0)
+
+	if res := add(1, 2); res != -1 {
+		t.Fatalf("expect first rule, actual: %d", res)
+	}
+	if res := add(3, 4); res != 0 {
+		t.Fatalf("expect second rule, actual: %d", res)
+	}
+}
+
+func TestStubErrorOnceAndPanic(t *testing.T) {
+	s := mock.Stub(GetUser)
+	s.When(nil).ErrorOnce(errNotFound).Panic("boom")
+
+	_, err := GetUser(context.Background(), "a")
+	if err != errNotFound {
+		t.Fatalf("expect %v, actual: %v", errNotFound, err)
+	}
+
+	var pe interface{}
+	func() {
+		defer func() {
+			pe = recover()
+		}()
+		GetUser(context.Background(), "a")
+	}()
+	if pe != "boom" {
+		t.Fatalf("expect panic %q, actual: %v", "boom", pe)
+	}
+}
+
+func TestStubCheckResults(t *testing.T) {
+	var pe interface{}
+	func() {
+		defer func() {
+			pe = recover()
+		}()
+		mock.Stub(add).When(nil).Return(1, 2)
+	}()
+	expectMsg := "stub add: expect 1 results, actual: 2"
+	if fmt.Sprint(pe) != expectMsg {
+		t.Fatalf("expect panic %q, actual: %v", expectMsg, pe)
+	}
+}
+
+func TestStubCheckArgName(t *testing.T) {
+	var pe interface{}
+	func() {
+		defer func() {
+			pe = recover()
+		}()
+		mock.Stub(GetUser).When(mock.Arg("nmae", "admin"))
+	}()
+	expectMsg := `stub GetUser: no arg named "nmae", available: name`
+	if fmt.Sprint(pe) != expectMsg {
+		t.Fatalf("expect panic %q, actual: %v", expectMsg, pe)
+	}
+}
+
+func TestStubArgMatcherFunc(t *testing.T) {
+	s := mock.Stub(add)
+	s.When(mock.ArgMatcherFunc(func(args core.Object) bool {
+		return args.GetField("b").Value().(int) > 10
+	})).Return(0)
+
+	if res := add(1, 20); res != 0 {
+		t.Fatalf("expect stubbed, actual: %d", res)
+	}
+	if res := add(1, 2); res != 3 {
+		t.Fatalf("expect original, actual: %d", res)
+	}
+}
+
+func TestMockErrCallOld(t *testing.T) {
+	mock.Mock(add, func(ctx context.Context, fn *core.FuncInfo, args, results core.Object) error {
+		if args.GetField("a").Value().(int) == 0 {
+			return mock.ErrCallOld
+		}
+		results.GetFieldIndex(0).Set(-1)
+		return nil
+	})
+	if res := add(0, 5); res != 5 {
+		t.Fatalf("expect original add(0,5) to be 5, actual: %d", res)
+	}
+	if res := add(1, 5); res != -1 {
+		t.Fatalf("expect mocked add(1,5) to be -1, actual: %d", res)
+	}
+}
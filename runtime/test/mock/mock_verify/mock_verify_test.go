@@ -0,0 +1,163 @@
+package mock_verify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/xhd2015/xgo/runtime/core"
+	"github.com/xhd2015/xgo/runtime/mock"
+)
+
+func greet(ctx context.Context, name string) string {
+	return "hello " + name
+}
+
+func open(name string) {}
+
+func read(name string) {}
+
+func closeFile(name string) {}
+
+type service struct {
+	name string
+}
+
+func (c *service) Get(id int) string {
+	return fmt.Sprintf("%s:%d", c.name, id)
+}
+
+// recordTB captures failures instead of failing the test
+type recordTB struct {
+	testing.TB
+	errors []string
+}
+
+func (c *recordTB) Helper() {}
+
+func (c *recordTB) Errorf(format string, args ...interface{}) {
+	c.errors = append(c.errors, fmt.Sprintf(format, args...))
+}
+
+func TestVerifyTimesAndArgs(t *testing.T) {
+	v := mock.Verify(t, greet)
+	v.NeverCalled()
+
+	greet(context.Background(), "a")
+	greet(context.Background(), "b")
+
+	v.Times(2)
+	v.CalledWith("a")
+	v.CalledWith("b")
+
+	calls := v.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expect 2 calls, actual: %d", len(calls))
+	}
+	name := calls[1].Args.GetField("name").Value()
+	if name != "b" {
+		t.Fatalf("expect second call name to be %q, actual: %v", "b", name)
+	}
+}
+
+func TestVerifyReportsFailure(t *testing.T) {
+	tb := &recordTB{TB: t}
+	v := mock.Verify(tb, greet)
+	greet(context.Background(), "a")
+
+	if v.Times(2) {
+		t.Fatalf("expect Times(2) to fail")
+	}
+	if v.CalledWith("b") {
+		t.Fatalf("expect CalledWith(b) to fail")
+	}
+	if v.NeverCalled() {
+		t.Fatalf("expect NeverCalled() to fail")
+	}
+	if len(tb.errors) != 3 {
+		t.Fatalf("expect 3 errors, actual: %v", tb.errors)
+	}
+	expectMsg := `expect greet to be called 2 times, actual: 1`
+	if tb.errors[0] != expectMsg {
+		t.Fatalf("expect error %q, actual: %q", expectMsg, tb.errors[0])
+	}
+	if !strings.Contains(tb.errors[1], `("a")`) {
+		t.Fatalf("expect error to contain actual call args, actual: %q", tb.errors[1])
+	}
+}
+
+func TestMockVerify(t *testing.T) {
+	v := mock.MockVerify(t, greet, func(ctx context.Context, fn *core.FuncInfo, args, results core.Object) error {
+		results.GetFieldIndex(0).Set("mock")
+		return nil
+	})
+	res := greet(context.Background(), "a")
+	if res != "mock" {
+		t.Fatalf("expect greet to be mocked, actual: %q", res)
+	}
+	v.Times(1)
+
+	v.Cancel()
+	res = greet(context.Background(), "b")
+	if res != "hello b" {
+		t.Fatalf("expect greet not mocked after cancel, actual: %q", res)
+	}
+	v.Times(1)
+}
+
+func TestPatchVerifyMethod(t *testing.T) {
+	s := &service{name: "s"}
+	other := &service{name: "other"}
+	v := mock.PatchVerify(t, s.Get, func(id int) string {
+		return "patched"
+	})
+	if res := s.Get(1); res != "patched" {
+		t.Fatalf("expect patched, actual: %q", res)
+	}
+	other.Get(2)
+
+	// only the bound instance is recorded
+	v.Times(1)
+	v.CalledWith(1)
+}
+
+func TestVerifyChildGoroutine(t *testing.T) {
+	v := mock.Verify(t, open)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			open(fmt.Sprintf("file_%d", i))
+		}(i)
+	}
+	wg.Wait()
+
+	v.Times(3)
+	v.CalledWith("file_2")
+}
+
+func TestVerifyInOrder(t *testing.T) {
+	vOpen := mock.Verify(t, open)
+	vRead := mock.Verify(t, read)
+	vClose := mock.Verify(t, closeFile)
+
+	open("a")
+	read("a")
+	read("a")
+	closeFile("a")
+
+	mock.VerifyInOrder(t, vOpen, vRead, vRead, vClose)
+
+	tb := &recordTB{TB: t}
+	if mock.VerifyInOrder(tb, vClose, vOpen) {
+		t.Fatalf("expect close before open to fail")
+	}
+	expectMsg := "expect calls in order: closeFile -> open, actual: open -> closeFile"
+	if len(tb.errors) != 1 || tb.errors[0] != expectMsg {
+		t.Fatalf("expect error %q, actual: %v", expectMsg, tb.errors)
+	}
+}
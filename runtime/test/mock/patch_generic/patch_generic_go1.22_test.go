@@ -0,0 +1,95 @@
+//go:build go1.22
+// +build go1.22
+
+package patch_generic
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xhd2015/xgo/runtime/mock"
+)
+
+func greet(s string) string {
+	return "hello " + s
+}
+
+type Service struct {
+	Name string
+}
+
+func (c *Service) Get(ctx context.Context, id int) (string, error) {
+	return "real", nil
+}
+
+var timeout = 10 * time.Second
+
+func TestPatchT(t *testing.T) {
+	cancel := mock.PatchT(greet, func(s string) string {
+		return "mock " + s
+	})
+	if res := greet("world"); res != "mock world" {
+		t.Fatalf("expect patched result to be %q, actual: %q", "mock world", res)
+	}
+	cancel()
+	if res := greet("world"); res != "hello world" {
+		t.Fatalf("expect result after cancel to be %q, actual: %q", "hello world", res)
+	}
+}
+
+func TestPatchTMethod(t *testing.T) {
+	svc := &Service{Name: "svc"}
+	mock.PatchT(svc.Get, func(ctx context.Context, id int) (string, error) {
+		return "mock", nil
+	})
+	res, err := svc.Get(context.Background(), 1)
+	if err != nil || res != "mock" {
+		t.Fatalf("expect mock, actual: %q %v", res, err)
+	}
+	other := &Service{Name: "other"}
+	res, _ = other.Get(context.Background(), 1)
+	if res != "real" {
+		t.Fatalf("expect other instance not affected, actual: %q", res)
+	}
+}
+
+func TestPatchVarT(t *testing.T) {
+	mock.PatchVarT(&timeout, func() time.Duration {
+		return time.Second
+	})
+	if timeout != time.Second {
+		t.Fatalf("expect timeout to be patched, actual: %v", timeout)
+	}
+}
+
+func TestPatchScopeT(t *testing.T) {
+	mock.PatchScopeT(mock.Global(t), greet, func(s string) string {
+		return "global " + s
+	})
+	var res string
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		res = greet("world")
+	}()
+	wg.Wait()
+	if res != "global world" {
+		t.Fatalf("expect global patch, actual: %q", res)
+	}
+}
+
+func TestPatchVerifyT(t *testing.T) {
+	v := mock.PatchVerifyT(t, greet, func(s string) string {
+		return "verified " + s
+	})
+	if res := greet("a"); res != "verified a" {
+		t.Fatalf("expect patched, actual: %q", res)
+	}
+	greet("b")
+	if !v.Times(2) || !v.CalledWith("b") {
+		t.Fatalf("expect 2 calls")
+	}
+}
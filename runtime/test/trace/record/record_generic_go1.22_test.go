@@ -0,0 +1,99 @@
+//go:build go1.22
+// +build go1.22
+
+package record
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/xgo/runtime/trace"
+	"github.com/xhd2015/xgo/support/assert"
+)
+
+func Add(a int, b int) int {
+	return a + b
+}
+
+func TestRecordT(t *testing.T) {
+	var records []string
+	trace.RecordT(Add, func(a int, b int) int {
+		records = append(records, fmt.Sprintf("pre Add(%d, %d)", a, b))
+		return 0
+	}, func(a int, b int, res int) {
+		records = append(records, fmt.Sprintf("post Add(%d, %d) = %d", a, b, res))
+	})
+	res := Add(1, 2)
+	if res != 3 {
+		t.Fatalf("expect Add not affected, actual: %d", res)
+	}
+	expected := "pre Add(1, 2)\npost Add(1, 2) = 3"
+	if diff := assert.Diff(expected, strings.Join(records, "\n")); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRecordCallT(t *testing.T) {
+	var records []string
+	trace.RecordCallT(Add, func(a int, b int) int {
+		records = append(records, fmt.Sprintf("pre Add(%d, %d)", a, b))
+		return 0
+	})
+	Add(1, 2)
+	expected := "pre Add(1, 2)"
+	if diff := assert.Diff(expected, strings.Join(records, "\n")); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRecordResultT(t *testing.T) {
+	var records []string
+	trace.RecordResultT(Add, func(a int, b int, res int) {
+		records = append(records, fmt.Sprintf("post Add(%d, %d) = %d", a, b, res))
+	})
+	Add(2, 3)
+	expected := "post Add(2, 3) = 5"
+	if diff := assert.Diff(expected, strings.Join(records, "\n")); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRecordTNilPost(t *testing.T) {
+	var records []string
+	var post func(a int, b int, res int)
+	trace.RecordT(Add, func(a int, b int) int {
+		records = append(records, fmt.Sprintf("pre Add(%d, %d)", a, b))
+		return 0
+	}, post)
+	Add(1, 2)
+	expected := "pre Add(1, 2)"
+	if diff := assert.Diff(expected, strings.Join(records, "\n")); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRecordResultTMismatch(t *testing.T) {
+	var pe interface{}
+	func() {
+		defer func() {
+			pe = recover()
+		}()
+		trace.RecordResultT(Add, func(a int, b int) {})
+	}()
+	if pe == nil {
+		t.Fatalf("expect post without results to panic")
+	}
+}
+
+func TestRecordCallTVariadic(t *testing.T) {
+	var records []string
+	trace.RecordCallT(Variadic, func(msg string, args ...string) {
+		records = append(records, fmt.Sprintf("Variadic is called: %s, %v", msg, args))
+	})
+	Variadic("hello", "world", "foo")
+	expected := "Variadic is called: hello, [world foo]"
+	if diff := assert.Diff(expected, strings.Join(records, "\n")); diff != "" {
+		t.Error(diff)
+	}
+}
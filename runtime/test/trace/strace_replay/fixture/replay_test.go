@@ -0,0 +1,72 @@
+package fixture
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	// enable trace when running inside sub directory
+	_ "github.com/xhd2015/xgo/runtime/trace"
+)
+
+type store struct {
+	prefix string
+}
+
+var calls int
+
+func TestReplay(t *testing.T) {
+	want := os.Getenv("TEST_WANT_GREETING")
+	if want == "" {
+		t.Skip("run by TestStraceReplay")
+	}
+	s := &store{prefix: os.Getenv("TEST_GREETING")}
+
+	greeting, err := s.greet(context.Background(), "world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if greeting != want+" world" {
+		t.Fatalf("expect greet: %q, actual: %q", want+" world", greeting)
+	}
+
+	_, err = s.greet(context.Background(), "")
+	if err == nil || err.Error() != "empty name" {
+		t.Fatalf("expect err: %q, actual: %v", "empty name", err)
+	}
+
+	// unnamed args of methods
+	if formatted := s.format("world", 1); formatted != want+" formatted" {
+		t.Fatalf("expect format: %q, actual: %q", want+" formatted", formatted)
+	}
+
+	n1 := count()
+	n2 := count()
+	if n1 != 1 || n2 != 2 {
+		t.Fatalf("expect count: 1,2, actual: %d,%d", n1, n2)
+	}
+}
+
+func (c *store) greet(ctx context.Context, name string) (greeting string, err error) {
+	if name == "" {
+		return "", errors.New("empty name")
+	}
+	if c.prefix == "" {
+		return "", errors.New("no prefix")
+	}
+	return c.prefix + " " + name, nil
+}
+
+func (c *store) format(string, int) string {
+	return c.prefix + " formatted"
+}
+
+func count() int {
+	calls++
+	if os.Getenv("TEST_WANT_GREETING") != os.Getenv("TEST_GREETING") {
+		// not replayed
+		return -1
+	}
+	return calls
+}
@@ -0,0 +1,47 @@
+package strace_replay
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/xgo/support/cmd"
+)
+
+// TestStraceReplay records a trace with real results,
+// then replays it with a different environment
+func TestStraceReplay(t *testing.T) {
+	var xgoCmd string
+	var args []string
+
+	testCmd := os.Getenv("XGO_TEST_COMMAND")
+	if testCmd != "" {
+		cmds := strings.Split(testCmd, " ")
+		xgoCmd = cmds[0]
+		args = cmds[1:]
+	} else {
+		xgoCmd = "xgo"
+	}
+
+	tmpDir, err := os.MkdirTemp("", "strace_replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// record
+	recordArgs := append(append([]string{}, args...), "test", "-count=1", "--strace", "--strace-dir", tmpDir)
+	err = cmd.Debug().Env([]string{"TEST_GREETING=hello", "TEST_WANT_GREETING=hello"}).Dir("./fixture").Run(xgoCmd, recordArgs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replay
+	replayArgs := append(append([]string{}, args...), "test", "-count=1", "--strace-replay", tmpDir,
+		"--strace-replay-rule", `{"name":"(*store).greet,(*store).format,count","action":"include"}`,
+	)
+	err = cmd.Debug().Env([]string{"TEST_GREETING=", "TEST_WANT_GREETING=hello"}).Dir("./fixture").Run(xgoCmd, replayArgs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
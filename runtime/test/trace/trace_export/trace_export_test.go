@@ -0,0 +1,112 @@
+package trace_export
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xhd2015/xgo/runtime/trace"
+	"github.com/xhd2015/xgo/runtime/trace/stack_model"
+)
+
+func TestTraceOutputFormatChrome(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "chrome.json")
+	trace.Trace(trace.Config{
+		OutputFile:   file,
+		OutputFormat: stack_model.ExportFormat_Chrome,
+	}, nil, func() (interface{}, error) {
+		greet("world")
+		return nil, nil
+	})
+
+	var res struct {
+		TraceEvents []struct {
+			Name string
+			Ph   string
+			Args map[string]interface{}
+		} `json:"traceEvents"`
+	}
+	readJSON(t, file, &res)
+
+	var found bool
+	for _, event := range res.TraceEvents {
+		if event.Name == "greet" {
+			found = true
+			if event.Ph != "X" {
+				t.Errorf("expect ph X, actual: %s", event.Ph)
+			}
+			if event.Args["args"] != `{"name":"world"}` || event.Args["results"] != `{"greeting":"hello world"}` {
+				t.Errorf("bad greet args: %v", event.Args)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expect greet in events: %+v", res.TraceEvents)
+	}
+}
+
+func TestTraceOutputFormatOTLP(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "otlp.json")
+	trace.Trace(trace.Config{
+		OutputFile:   file,
+		OutputFormat: stack_model.ExportFormat_OTLP,
+	}, nil, func() (interface{}, error) {
+		return nil, fail()
+	})
+
+	var res struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+					Status       *struct {
+						Code    int    `json:"code"`
+						Message string `json:"message"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	readJSON(t, file, &res)
+	if len(res.ResourceSpans) != 1 || len(res.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("bad otlp structure: %+v", res)
+	}
+	var found bool
+	for _, span := range res.ResourceSpans[0].ScopeSpans[0].Spans {
+		if span.Name == "fail" {
+			found = true
+			if span.ParentSpanID == "" {
+				t.Errorf("expect fail to have parent span")
+			}
+			if span.Status == nil || span.Status.Code != 2 || span.Status.Message != "failed" {
+				t.Errorf("expect error status, actual: %+v", span.Status)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expect fail in spans: %+v", res)
+	}
+}
+
+func readJSON(t *testing.T, file string, v interface{}) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = json.Unmarshal(content, v)
+	if err != nil {
+		t.Fatalf("invalid json: %v\n%s", err, content)
+	}
+}
+
+func greet(name string) (greeting string) {
+	return "hello " + name
+}
+
+func fail() error {
+	return errors.New("failed")
+}
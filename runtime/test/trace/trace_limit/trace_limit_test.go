@@ -0,0 +1,217 @@
+package trace_limit
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/xgo/runtime/trace"
+	"github.com/xhd2015/xgo/runtime/trace/stack_model"
+)
+
+func TestTraceMaxDepth(t *testing.T) {
+	stack := traceWith(t, trace.Config{MaxDepth: 2}, func() {
+		depth1()
+	})
+	// Trace(0) -> depth1(1) -> depth2(2) -> depth3(omitted)
+	d2 := findEntry(stack.Children, "depth2")
+	if d2 == nil {
+		t.Fatalf("expect depth2 to be traced")
+	}
+	if len(d2.Children) != 0 {
+		t.Errorf("expect depth2 to have no children, actual: %d", len(d2.Children))
+	}
+	if d2.Omitted != 1 {
+		t.Errorf("expect depth2 omitted 1, actual: %d", d2.Omitted)
+	}
+	if findEntry(stack.Children, "depth4") != nil {
+		t.Errorf("expect depth4 to be omitted")
+	}
+}
+
+func TestTraceMaxChildren(t *testing.T) {
+	stack := traceWith(t, trace.Config{MaxChildren: 3}, func() {
+		addN(10)
+	})
+	fn := findEntry(stack.Children, "addN")
+	if fn == nil {
+		t.Fatalf("expect addN to be traced")
+	}
+	if len(fn.Children) != 3 {
+		t.Errorf("expect 3 children, actual: %d", len(fn.Children))
+	}
+	if fn.Omitted != 7 {
+		t.Errorf("expect 7 omitted, actual: %d", fn.Omitted)
+	}
+}
+
+func TestTraceSampling(t *testing.T) {
+	stack := traceWith(t, trace.Config{
+		Sampling: map[string]float64{
+			"github.com/xhd2015/xgo/runtime/test/trace/trace_limit.add": 0.5,
+		},
+	}, func() {
+		addAndSub(4)
+	})
+	fn := findEntry(stack.Children, "addAndSub")
+	if fn == nil {
+		t.Fatalf("expect addAndSub to be traced")
+	}
+	var adds, subs int
+	for _, child := range fn.Children {
+		switch child.FuncInfo.Name {
+		case "add":
+			adds++
+		case "sub":
+			subs++
+		}
+	}
+	if adds != 2 {
+		t.Errorf("expect 2 add sampled, actual: %d", adds)
+	}
+	if subs != 4 {
+		t.Errorf("expect 4 sub, actual: %d", subs)
+	}
+	if fn.Omitted != 2 {
+		t.Errorf("expect 2 omitted, actual: %d", fn.Omitted)
+	}
+}
+
+func TestTraceMaxArgBytes(t *testing.T) {
+	long := strings.Repeat("a", 100)
+	stack := traceWith(t, trace.Config{MaxArgBytes: 20}, func() {
+		echo(long)
+		echo("short")
+	})
+	var echos []*stack_model.StackEntry
+	collectEntries(stack.Children, "echo", &echos)
+	if len(echos) != 2 {
+		t.Fatalf("expect 2 echo, actual: %d", len(echos))
+	}
+	if !echos[0].Truncated {
+		t.Errorf("expect long echo to be truncated")
+	}
+	args := marshal(t, echos[0].Args)
+	if !strings.Contains(args, "...(truncated, total ") {
+		t.Errorf("expect truncation marker in args, actual: %s", args)
+	}
+	if echos[1].Truncated {
+		t.Errorf("expect short echo not to be truncated")
+	}
+	if args := marshal(t, echos[1].Args); args != `{"s":"short"}` {
+		t.Errorf("expect short args kept, actual: %s", args)
+	}
+}
+
+func TestTraceCollapseRepeated(t *testing.T) {
+	stack := traceWith(t, trace.Config{CollapseRepeated: true}, func() {
+		addRepeated(5)
+	})
+	fn := findEntry(stack.Children, "addRepeated")
+	if fn == nil {
+		t.Fatalf("expect addRepeated to be traced")
+	}
+	if len(fn.Children) != 2 {
+		t.Fatalf("expect 2 children after collapsing, actual: %d", len(fn.Children))
+	}
+	if fn.Children[0].Repeated != 4 {
+		t.Errorf("expect first add repeated 4, actual: %d", fn.Children[0].Repeated)
+	}
+	if fn.Children[1].Repeated != 0 {
+		t.Errorf("expect second add not repeated, actual: %d", fn.Children[1].Repeated)
+	}
+}
+
+func traceWith(t *testing.T, config trace.Config, f func()) *stack_model.Stack {
+	var stack stack_model.IStack
+	config.OnFinish = func(s stack_model.IStack) {
+		stack = s
+	}
+	trace.Trace(config, nil, func() (interface{}, error) {
+		f()
+		return nil, nil
+	})
+	if stack == nil {
+		t.Fatalf("expect trace to be finished")
+	}
+	return stack.Data()
+}
+
+func findEntry(entries []*stack_model.StackEntry, name string) *stack_model.StackEntry {
+	for _, entry := range entries {
+		if entry.FuncInfo != nil && entry.FuncInfo.Name == name {
+			return entry
+		}
+		if found := findEntry(entry.Children, name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func collectEntries(entries []*stack_model.StackEntry, name string, res *[]*stack_model.StackEntry) {
+	for _, entry := range entries {
+		if entry.FuncInfo != nil && entry.FuncInfo.Name == name {
+			*res = append(*res, entry)
+		}
+		collectEntries(entry.Children, name, res)
+	}
+}
+
+func marshal(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func depth1() {
+	depth2()
+}
+
+func depth2() {
+	depth3()
+}
+
+func depth3() {
+	depth4()
+}
+
+func depth4() {
+}
+
+func addN(n int) {
+	for i := 0; i < n; i++ {
+		add(i, 1)
+	}
+}
+
+func addAndSub(n int) {
+	for i := 0; i < n; i++ {
+		add(i, 1)
+	}
+	for i := 0; i < n; i++ {
+		sub(i, 1)
+	}
+}
+
+// addRepeated calls add(1, 1) n times, then add(2, 2) once
+func addRepeated(n int) {
+	for i := 0; i < n; i++ {
+		add(1, 1)
+	}
+	add(2, 2)
+}
+
+func add(a int, b int) int {
+	return a + b
+}
+
+func sub(a int, b int) int {
+	return a - b
+}
+
+func echo(s string) string {
+	return s
+}
@@ -2,6 +2,9 @@ package trace
 
 // trace v2's core functionality are all implemented in trap package
 import (
+	"fmt"
+	"reflect"
+
 	"github.com/xhd2015/xgo/runtime/internal/trap"
 )
 
@@ -27,3 +30,42 @@ func RecordCall(v interface{}, pre interface{}) func() {
 func RecordResult(v interface{}, post interface{}) func() {
 	return trap.PushRecorder(v, nil, post)
 }
+
+// argsRecorder adapts `hook`, which has the same type as `fn`,
+// to a recorder accepting args and results of `fn`, the
+// results are not passed to `hook`.
+// it returns nil if hook is nil.
+func argsRecorder(fn interface{}, hook interface{}) interface{} {
+	if hook == nil {
+		return nil
+	}
+	hookV := reflect.ValueOf(hook)
+	if hookV.Kind() != reflect.Func {
+		panic(fmt.Errorf("requires hook to be func, given %T", hook))
+	}
+	if hookV.IsNil() {
+		return nil
+	}
+	fnType := reflect.TypeOf(fn)
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		panic(fmt.Errorf("fn should be func, actual: %T", fn))
+	}
+	nIn := fnType.NumIn()
+	argTypes := make([]reflect.Type, 0, nIn+fnType.NumOut())
+	for i := 0; i < nIn; i++ {
+		argTypes = append(argTypes, fnType.In(i))
+	}
+	for i := 0; i < fnType.NumOut(); i++ {
+		argTypes = append(argTypes, fnType.Out(i))
+	}
+	variadic := fnType.IsVariadic()
+	recorderType := reflect.FuncOf(argTypes, nil, false)
+	return reflect.MakeFunc(recorderType, func(args []reflect.Value) []reflect.Value {
+		if variadic {
+			hookV.CallSlice(args[:nIn])
+		} else {
+			hookV.Call(args[:nIn])
+		}
+		return nil
+	}).Interface()
+}
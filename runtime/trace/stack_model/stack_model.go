@@ -35,6 +35,17 @@ type StackEntry struct {
 	PanicLine int
 	Error     string
 
+	// number of child calls omitted because of
+	// trace limits, e.g. max depth, max children
+	// or sampling
+	Omitted int `json:",omitempty"`
+	// number of identical calls following this
+	// one, which are collapsed into this entry
+	Repeated int `json:",omitempty"`
+	// args or results exceeded the size limit
+	// and were truncated
+	Truncated bool `json:",omitempty"`
+
 	Children []*StackEntry
 }
 
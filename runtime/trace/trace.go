@@ -17,9 +17,48 @@ type Config struct {
 	// in json format, which can be open by:
 	//      xgo tool trace <OutputFile>
 	OutputFile string `json:"OutputFile,omitempty"`
+	// OutputFormat specifies the format of OutputFile,
+	// available: json(default), chrome and otlp.
+	// chrome can be opened by https://ui.perfetto.dev,
+	// otlp can be posted to an OpenTelemetry collector
+	// like jaeger.
+	// see also `xgo tool trace export`
+	OutputFormat stack_model.ExportFormat `json:"OutputFormat,omitempty"`
 
 	// FilterTrace is called to filter the trace
 	FilterTrace func(funcInfo *core.FuncInfo) bool `json:"-"`
+
+	// the following options limit the size of the trace,
+	// omitted calls are counted in the `Omitted` field
+	// of their parent entry.
+	// the same options are available for `xgo test --strace`
+	// as --strace-max-depth, --strace-max-children,
+	// --strace-max-arg-bytes, --strace-sample and
+	// --strace-collapse-repeated
+
+	// MaxDepth omits calls deeper than MaxDepth,
+	// the Trace call itself is at depth 0.
+	// 0 means no limit
+	MaxDepth int `json:"MaxDepth,omitempty"`
+	// MaxChildren omits calls after the first MaxChildren
+	// direct child calls of each call, 0 means no limit
+	MaxChildren int `json:"MaxChildren,omitempty"`
+	// MaxArgBytes truncates each marshaled arg or result
+	// exceeding MaxArgBytes, the truncated value is a string
+	// ending with "...(truncated, total <N> bytes)".
+	// 0 means the default 1MB
+	MaxArgBytes int `json:"MaxArgBytes,omitempty"`
+	// Sampling maps function patterns to sampling rates
+	// between 0 and 1, e.g. {"example.com/app/db.*": 0.1}.
+	// A pattern matches `<pkg>.<func>`, `*` matches within a
+	// path segment and `**` matches any segments, the longest
+	// matching pattern applies.
+	// The first call of a sampled function is always kept.
+	Sampling map[string]float64 `json:"Sampling,omitempty"`
+	// CollapseRepeated collapses consecutive identical sibling
+	// calls into the first one, whose `Repeated` field counts
+	// the collapsed calls
+	CollapseRepeated bool `json:"CollapseRepeated,omitempty"`
 }
 
 // the `request` and `response` are only for recording purpose
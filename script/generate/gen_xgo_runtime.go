@@ -42,19 +42,38 @@ func copyTraceModel(rootDir string) error {
 	genName := string(gen_defs.GenernateType_RuntimeTraceModel)
 	runtimeDir := filepath.Join(rootDir, "runtime")
 
-	traceRenderingStackModel := filepath.Join(rootDir, "cmd", "xgo", "trace", "render", "stack_model", "stack_model.go")
-	runtimeStackModel := filepath.Join(runtimeDir, "trace", "stack_model", "stack_model.go")
+	traceRenderingStackModelDir := filepath.Join(rootDir, "cmd", "xgo", "trace", "render", "stack_model")
+	runtimeStackModelDir := filepath.Join(runtimeDir, "trace", "stack_model")
 
-	// copy stack model from xgo to runtime
-	return copyStackTraceExport(genName, traceRenderingStackModel, runtimeStackModel)
+	// copy stack model and its exporters from xgo to runtime
+	files, err := os.ReadDir(traceRenderingStackModelDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		err := copyStackTraceExport(genName, name, filepath.Join(traceRenderingStackModelDir, name), filepath.Join(runtimeStackModelDir, name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func copyStackTraceExport(cmd string, srcFile string, dstFile string) error {
+func copyStackTraceExport(cmd string, name string, srcFile string, dstFile string) error {
 	content, err := fileutil.ReadFile(srcFile)
 	if err != nil {
 		return err
 	}
-	newCode := getCmdPrelude(cmd) + "// keep the same with cmd/xgo/trace/render/stack_model/stack_model.go\n" + string(content)
+	keepSame := "// keep the same with cmd/xgo/trace/render/stack_model/" + name + "\n"
+	if strings.HasPrefix(string(content), "package ") {
+		// avoid being treated as package doc
+		keepSame += "\n"
+	}
+	newCode := getCmdPrelude(cmd) + keepSame + string(content)
 
 	return fileutil.WriteFile(dstFile, []byte(newCode))
 }
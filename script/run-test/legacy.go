@@ -83,6 +83,11 @@ var extraSubTests = []*TestCase{
 		dir:               "runtime/test/trace/trace_dir",
 		windowsFailIgnore: true,
 	},
+	{
+		name:              "trace-replay",
+		dir:               "runtime/test/trace/strace_replay",
+		windowsFailIgnore: true,
+	},
 	{
 		// see https://github.com/xhd2015/xgo/issues/202
 		name: "asm_func",
@@ -0,0 +1,416 @@
+// Package affected selects tests affected by changes of the
+// working tree since a git ref. Packages are selected by the
+// import graph, and narrowed down to tests covering changed
+// lines if a per-test coverage index is available.
+package affected
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/xhd2015/xgo/support/coverage"
+	"github.com/xhd2015/xgo/support/git"
+	"github.com/xhd2015/xgo/support/goinfo"
+)
+
+type Options struct {
+	// Dir is where go commands run
+	Dir string
+	// Ref is the git ref the working tree is compared to
+	Ref string
+	// Args are package patterns, default: ./...
+	Args []string
+	// Mod is passed to go list as -mod
+	Mod string
+	// Index is the optional per-test coverage index generated
+	// by `xgo test --cover-per-test`, it is only used if generated
+	// at the commit of Ref, since changed lines of the ref side
+	// are looked up in it
+	Index *coverage.TestIndex
+}
+
+type Package struct {
+	ImportPath string `json:"importPath"`
+	Dir        string `json:"dir"`
+	// Tests are names of selected top-level tests,
+	// empty if all tests of the package are selected
+	Tests  []string `json:"tests,omitempty"`
+	Reason string   `json:"reason"`
+}
+
+type Result struct {
+	Changes  []*git.FileChange `json:"changes"`
+	Packages []*Package        `json:"packages"`
+	// IndexIgnored tells why Options.Index is not
+	// used to narrow down tests, empty if used
+	IndexIgnored string `json:"indexIgnored,omitempty"`
+}
+
+// Select lists changes since opts.Ref and selects
+// packages whose tests may be affected
+func Select(opts *Options) (*Result, error) {
+	if opts.Ref == "" {
+		return nil, fmt.Errorf("requires ref")
+	}
+	_, projectRoot, err := goinfo.FindGoModDirSubPath(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := git.ListChangedLines(projectRoot, opts.Ref)
+	if err != nil {
+		return nil, fmt.Errorf("diff with %s: %w", opts.Ref, err)
+	}
+	if len(changes) == 0 {
+		return &Result{}, nil
+	}
+	index := opts.Index
+	var indexIgnored string
+	if index != nil {
+		refCommit, err := git.GetCommit(projectRoot, opts.Ref)
+		if err != nil {
+			return nil, fmt.Errorf("resolve %s: %w", opts.Ref, err)
+		}
+		if index.Revision != refCommit {
+			if index.Revision == "" {
+				indexIgnored = fmt.Sprintf("index has no revision, generate it with a clean working tree at %s", opts.Ref)
+			} else {
+				indexIgnored = fmt.Sprintf("index is generated at %s, not at %s (%s)", shortCommit(index.Revision), opts.Ref, shortCommit(refCommit))
+			}
+			index = nil
+		}
+	}
+	all, err := goinfo.ListPackages([]string{"./..."}, goinfo.LoadPackageOptions{Dir: projectRoot, Mod: opts.Mod})
+	if err != nil {
+		return nil, err
+	}
+	args := opts.Args
+	if len(args) == 0 {
+		args = []string{"./..."}
+	}
+	candidates, err := goinfo.ListPackages(args, goinfo.LoadPackageOptions{Dir: opts.Dir, Mod: opts.Mod})
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		Changes:      changes,
+		Packages:     selectPackages(projectRoot, changes, all, candidates, index),
+		IndexIgnored: indexIgnored,
+	}, nil
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// TestArgs returns import paths of selected packages, and a
+// -run pattern if tests of every package are narrowed down.
+func (c *Result) TestArgs() (pkgs []string, run string) {
+	for _, pkg := range c.Packages {
+		pkgs = append(pkgs, pkg.ImportPath)
+	}
+	names := c.Tests()
+	if len(names) == 0 {
+		return pkgs, ""
+	}
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, regexp.QuoteMeta(name))
+	}
+	return pkgs, "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+// Tests returns sorted names of selected tests across packages.
+// Since -run applies to all packages, nil is returned if any
+// package needs to run all of its tests.
+func (c *Result) Tests() []string {
+	var names []string
+	for _, pkg := range c.Packages {
+		if len(pkg.Tests) == 0 {
+			return nil
+		}
+		names = append(names, pkg.Tests...)
+	}
+	sort.Strings(names)
+	uniq := names[:0]
+	for i, name := range names {
+		if i > 0 && names[i-1] == name {
+			continue
+		}
+		uniq = append(uniq, name)
+	}
+	return uniq
+}
+
+type selector struct {
+	byDir  map[string]*goinfo.Package
+	byPath map[string]*goinfo.Package
+
+	// non-test changes of each package
+	changed     map[string][]*git.FileChange
+	testChanged map[string]bool
+
+	index *coverage.TestIndex
+	// blocks of index grouped by file
+	indexBlocks map[string][]*indexBlock
+}
+
+type indexBlock struct {
+	idx   int
+	block *coverage.Block
+}
+
+func selectPackages(projectRoot string, changes []*git.FileChange, all []*goinfo.Package, candidates []*goinfo.Package, index *coverage.TestIndex) []*Package {
+	s := &selector{
+		byDir:       make(map[string]*goinfo.Package, len(all)),
+		byPath:      make(map[string]*goinfo.Package, len(all)),
+		changed:     make(map[string][]*git.FileChange),
+		testChanged: make(map[string]bool),
+		index:       index,
+	}
+	for _, pkg := range append(all[:len(all):len(all)], candidates...) {
+		if pkg.Dir != "" {
+			s.byDir[pkg.Dir] = pkg
+		}
+		s.byPath[pkg.ImportPath] = pkg
+	}
+
+	var modChanged bool
+	for _, change := range changes {
+		if change.File == "go.mod" || change.File == "go.sum" {
+			modChanged = true
+			continue
+		}
+		pkg, isTest := s.packageOf(projectRoot, change.File)
+		if pkg == nil {
+			continue
+		}
+		if isTest {
+			s.testChanged[pkg.ImportPath] = true
+			continue
+		}
+		s.changed[pkg.ImportPath] = append(s.changed[pkg.ImportPath], change)
+	}
+
+	var result []*Package
+	for _, pkg := range candidates {
+		if pkg.Standard || len(pkg.TestGoFiles)+len(pkg.XTestGoFiles) == 0 {
+			continue
+		}
+		selected := &Package{
+			ImportPath: pkg.ImportPath,
+			Dir:        pkg.Dir,
+		}
+		if modChanged {
+			selected.Reason = "go.mod changed"
+			result = append(result, selected)
+			continue
+		}
+		if s.testChanged[pkg.ImportPath] {
+			selected.Reason = "test files changed"
+			result = append(result, selected)
+			continue
+		}
+		reached := s.reachedChanges(pkg)
+		if len(reached) == 0 {
+			continue
+		}
+		if reached[0] == pkg.ImportPath {
+			selected.Reason = "changed"
+		} else {
+			selected.Reason = "depends on " + reached[0]
+		}
+		if len(reached) > 1 {
+			selected.Reason += fmt.Sprintf(" and %d more", len(reached)-1)
+		}
+		tests, ok := s.narrow(pkg, reached)
+		if ok {
+			if len(tests) == 0 {
+				// no test covers the changes
+				continue
+			}
+			selected.Tests = tests
+			selected.Reason += ", narrowed by coverage"
+		}
+		result = append(result, selected)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ImportPath < result[j].ImportPath
+	})
+	return result
+}
+
+// packageOf finds the package a changed file belongs to,
+// files under testdata belong to tests of the package
+// containing the testdata dir
+func (c *selector) packageOf(projectRoot string, file string) (pkg *goinfo.Package, isTest bool) {
+	if idx := strings.Index("/"+file, "/testdata/"); idx >= 0 {
+		dir := filepath.Join(projectRoot, filepath.FromSlash(strings.TrimSuffix(file[:idx], "/")))
+		return c.byDir[dir], true
+	}
+	dir := filepath.Join(projectRoot, filepath.FromSlash(path.Dir(file)))
+	return c.byDir[dir], strings.HasSuffix(file, "_test.go")
+}
+
+// reachedChanges returns changed packages the test binary
+// of pkg depends on, pkg itself comes first if changed
+func (c *selector) reachedChanges(pkg *goinfo.Package) []string {
+	seen := make(map[string]bool)
+	var reached []string
+	add := func(imp string) {
+		if seen[imp] {
+			return
+		}
+		seen[imp] = true
+		if len(c.changed[imp]) > 0 {
+			reached = append(reached, imp)
+		}
+	}
+	add(pkg.ImportPath)
+	for _, dep := range pkg.Deps {
+		add(dep)
+	}
+	for _, imports := range [][]string{pkg.TestImports, pkg.XTestImports} {
+		for _, imp := range imports {
+			add(imp)
+			if p := c.byPath[imp]; p != nil {
+				for _, dep := range p.Deps {
+					add(dep)
+				}
+			}
+		}
+	}
+	if len(reached) > 1 {
+		rest := reached[1:]
+		if reached[0] != pkg.ImportPath {
+			rest = reached
+		}
+		sort.Strings(rest)
+	}
+	return reached
+}
+
+// narrow selects tests of pkg covering changed lines, ok
+// is false if the index cannot tell, for example the package
+// is missing from the index, or a changed line is outside of
+// any block, which is the case for declarations and comments.
+// The index is generated at the ref, so lines of the ref side
+// are looked up.
+// Tests not recorded in the index are always selected.
+func (c *selector) narrow(pkg *goinfo.Package, reached []string) (tests []string, ok bool) {
+	if c.index == nil {
+		return nil, false
+	}
+	var indexed []*coverage.TestCoverage
+	for _, test := range c.index.Tests {
+		if test.Pkg == pkg.ImportPath {
+			indexed = append(indexed, test)
+		}
+	}
+	if len(indexed) == 0 {
+		return nil, false
+	}
+	if c.indexBlocks == nil {
+		c.indexBlocks = make(map[string][]*indexBlock)
+		for i, prefix := range c.index.Blocks {
+			block, ok := coverage.ParseBlock(prefix)
+			if !ok {
+				continue
+			}
+			c.indexBlocks[block.File] = append(c.indexBlocks[block.File], &indexBlock{idx: i, block: block})
+		}
+	}
+
+	hit := make(map[int]bool)
+	for _, imp := range reached {
+		for _, change := range c.changed[imp] {
+			if change.OldLines == nil {
+				return nil, false
+			}
+			blocks := c.indexBlocks[imp+"/"+path.Base(change.File)]
+			if len(blocks) == 0 {
+				return nil, false
+			}
+			for _, line := range change.OldLines {
+				var found bool
+				for _, b := range blocks {
+					if b.block.StartLine <= line && line <= b.block.EndLine {
+						hit[b.idx] = true
+						found = true
+					}
+				}
+				if !found {
+					return nil, false
+				}
+			}
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, test := range indexed {
+		names[test.Name] = true
+		for _, idx := range test.Blocks {
+			if hit[idx] {
+				tests = append(tests, test.Name)
+				break
+			}
+		}
+	}
+	// tests added after the index was generated
+	for _, name := range listTestFuncs(pkg) {
+		if !names[name] {
+			tests = append(tests, name)
+		}
+	}
+	sort.Strings(tests)
+	return tests, true
+}
+
+func listTestFuncs(pkg *goinfo.Package) []string {
+	var names []string
+	fset := token.NewFileSet()
+	for _, files := range [][]string{pkg.TestGoFiles, pkg.XTestGoFiles} {
+		for _, file := range files {
+			f, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, file), nil, parser.SkipObjectResolution)
+			if err != nil {
+				continue
+			}
+			for _, decl := range f.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Recv != nil {
+					continue
+				}
+				if isTestName(fn.Name.Name) {
+					names = append(names, fn.Name.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// isTestName follows the rule of go test: TestXxx where
+// Xxx does not start with a lowercase letter
+func isTestName(name string) bool {
+	if name == "TestMain" {
+		return false
+	}
+	for _, prefix := range []string{"Test", "Example", "Fuzz"} {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := name[len(prefix):]
+		return rest == "" || !unicode.IsLower([]rune(rest)[0])
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package affected
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/xgo/support/coverage"
+	"github.com/xhd2015/xgo/support/git"
+	"github.com/xhd2015/xgo/support/goinfo"
+)
+
+func TestSelectPackages(t *testing.T) {
+	root := filepath.FromSlash("/project")
+	pkg := func(name string, deps ...string) *goinfo.Package {
+		return &goinfo.Package{
+			Dir:         filepath.Join(root, name),
+			ImportPath:  "example.com/m/" + name,
+			TestGoFiles: []string{name + "_test.go"},
+			Deps:        deps,
+		}
+	}
+	a := pkg("a")
+	b := pkg("b", "example.com/m/a", "fmt")
+	c := pkg("c", "fmt")
+	d := pkg("d")
+	// only tests of e import a
+	e := pkg("e")
+	e.TestImports = []string{"example.com/m/b"}
+	pkgs := []*goinfo.Package{a, b, c, d, e}
+
+	index := &coverage.TestIndex{
+		Blocks: []string{
+			"example.com/m/a/a.go:4.10,6.2 1",
+			"example.com/m/a/a.go:8.10,10.2 1",
+		},
+		Tests: []*coverage.TestCoverage{
+			{Pkg: "example.com/m/b", Name: "TestB1", Blocks: []int{0}},
+			{Pkg: "example.com/m/b", Name: "TestB2", Blocks: []int{1}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		changes []*git.FileChange
+		index   *coverage.TestIndex
+		expect  []string
+	}{
+		{
+			name:    "import graph",
+			changes: []*git.FileChange{{File: "a/a.go", Lines: []int{5}}, {File: "d/testdata/x.json"}, {File: "README.md"}},
+			expect: []string{
+				"example.com/m/a [] changed",
+				"example.com/m/b [] depends on example.com/m/a",
+				"example.com/m/d [] test files changed",
+				"example.com/m/e [] depends on example.com/m/a",
+			},
+		},
+		{
+			name: "narrowed by index",
+			// line 5 at the ref is moved to line 9 by
+			// insertions, the index is generated at the ref
+			changes: []*git.FileChange{{File: "a/a.go", Lines: []int{9}, OldLines: []int{5}}},
+			index:   index,
+			expect: []string{
+				"example.com/m/a [] changed",
+				"example.com/m/b [TestB1] depends on example.com/m/a, narrowed by coverage",
+				"example.com/m/e [] depends on example.com/m/a",
+			},
+		},
+		{
+			name:    "line outside blocks",
+			changes: []*git.FileChange{{File: "a/a.go", Lines: []int{5}, OldLines: []int{20}}},
+			index:   index,
+			expect: []string{
+				"example.com/m/a [] changed",
+				"example.com/m/b [] depends on example.com/m/a",
+				"example.com/m/e [] depends on example.com/m/a",
+			},
+		},
+		{
+			name:    "go.mod",
+			changes: []*git.FileChange{{File: "go.mod", Lines: []int{3}}},
+			expect: []string{
+				"example.com/m/a [] go.mod changed",
+				"example.com/m/b [] go.mod changed",
+				"example.com/m/c [] go.mod changed",
+				"example.com/m/d [] go.mod changed",
+				"example.com/m/e [] go.mod changed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected := selectPackages(root, tt.changes, pkgs, pkgs, tt.index)
+			var actual []string
+			for _, p := range selected {
+				actual = append(actual, fmt.Sprintf("%s %v %s", p.ImportPath, p.Tests, p.Reason))
+			}
+			if strings.Join(actual, "\n") != strings.Join(tt.expect, "\n") {
+				t.Fatalf("expect:\n%s\nactual:\n%s", strings.Join(tt.expect, "\n"), strings.Join(actual, "\n"))
+			}
+		})
+	}
+}
+
+func TestResultTestArgs(t *testing.T) {
+	res := &Result{Packages: []*Package{
+		{ImportPath: "x/a", Tests: []string{"TestA", "TestB"}},
+		{ImportPath: "x/b", Tests: []string{"TestA"}},
+	}}
+	pkgs, run := res.TestArgs()
+	if strings.Join(pkgs, " ") != "x/a x/b" || run != "^(TestA|TestB)$" {
+		t.Fatalf("unexpected: %v %s", pkgs, run)
+	}
+	res.Packages = append(res.Packages, &Package{ImportPath: "x/c"})
+	_, run = res.TestArgs()
+	if run != "" {
+		t.Fatalf("expect all tests to run, actual: %s", run)
+	}
+}
+
+func TestIsTestName(t *testing.T) {
+	for name, expect := range map[string]bool{
+		"Test":        true,
+		"TestX":       true,
+		"Test_x":      true,
+		"Testify":     false,
+		"TestMain":    false,
+		"ExampleFoo":  true,
+		"FuzzParse":   true,
+		"BenchmarkXx": false,
+	} {
+		if isTestName(name) != expect {
+			t.Errorf("isTestName(%q) expect %v", name, expect)
+		}
+	}
+}
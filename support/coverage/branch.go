@@ -0,0 +1,185 @@
+package coverage
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BranchMode is the mode line of branch profiles
+// written by xgo test --cover-branch
+const BranchMode = "branch"
+
+// kinds of branch points, see xgo/runtime/coverage
+const (
+	BranchKindIf      = "if"      // condition of if statement
+	BranchKindCond    = "cond"    // operand of && or ||
+	BranchKindCase    = "case"    // case clause of switch
+	BranchKindDefault = "default" // implicit default of switch
+)
+
+// Branch is a branch point, each line of branch profile
+// has the form:
+//
+//	example.com/demo/demo.go:5.5,5.15 if 3 1
+//
+// for case and default, Taken is the times the clause is
+// taken, and NotTaken is always 0.
+type Branch struct {
+	Block
+	Kind     string
+	Taken    int64
+	NotTaken int64
+}
+
+// BranchLine summaries branch points starting at the same line
+type BranchLine struct {
+	File     string
+	Line     int
+	Total    int
+	Covered  int
+	Branches []*Branch
+}
+
+// Outcomes returns the number of outcomes of the branch
+// point, and how many of them are covered
+func (c *Branch) Outcomes() (total int, covered int) {
+	if c.Kind == BranchKindCase || c.Kind == BranchKindDefault {
+		if c.Taken > 0 {
+			return 1, 1
+		}
+		return 1, 0
+	}
+	if c.Taken > 0 {
+		covered++
+	}
+	if c.NotTaken > 0 {
+		covered++
+	}
+	return 2, covered
+}
+
+// Partial reports whether some outcomes of the line are not covered
+func (c *BranchLine) Partial() bool {
+	return c.Covered < c.Total
+}
+
+func (c *Branch) key() string {
+	return fmt.Sprintf("%s:%d.%d,%d.%d %s", c.File, c.StartLine, c.StartCol, c.EndLine, c.EndCol, c.Kind)
+}
+
+func ParseBranchProfile(content string) ([]*Branch, error) {
+	lines := strings.Split(content, "\n")
+	if len(lines) > 0 && strings.HasPrefix(lines[0], modePrefix) {
+		mode := strings.TrimSpace(lines[0][len(modePrefix):])
+		if mode != BranchMode {
+			return nil, fmt.Errorf("expect mode %s, actual: %s", BranchMode, mode)
+		}
+		lines = lines[1:]
+	}
+	branches := make([]*Branch, 0, len(lines))
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		branch, ok := parseBranchLine(line)
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid branch: %s", i+1, line)
+		}
+		branches = append(branches, branch)
+	}
+	return branches, nil
+}
+
+func parseBranchLine(line string) (*Branch, bool) {
+	idx := strings.LastIndex(line, ":")
+	if idx < 0 {
+		return nil, false
+	}
+	fields := strings.Fields(line[idx+1:])
+	if len(fields) != 4 {
+		return nil, false
+	}
+	block, ok := ParseBlock(line[:idx+1] + fields[0])
+	if !ok {
+		return nil, false
+	}
+	taken, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	notTaken, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &Branch{
+		Block:    *block,
+		Kind:     fields[1],
+		Taken:    taken,
+		NotTaken: notTaken,
+	}, true
+}
+
+func FormatBranchProfile(branches []*Branch) string {
+	var b strings.Builder
+	b.WriteString(modePrefix + " " + BranchMode + "\n")
+	for _, branch := range branches {
+		fmt.Fprintf(&b, "%s %d %d\n", branch.key(), branch.Taken, branch.NotTaken)
+	}
+	return b.String()
+}
+
+// MergeBranches sums counts of the same branch point,
+// the result is sorted by file and position
+func MergeBranches(list ...[]*Branch) []*Branch {
+	byKey := make(map[string]*Branch)
+	var merged []*Branch
+	for _, branches := range list {
+		for _, branch := range branches {
+			key := branch.key()
+			prev := byKey[key]
+			if prev == nil {
+				copied := *branch
+				byKey[key] = &copied
+				merged = append(merged, &copied)
+				continue
+			}
+			prev.Taken += branch.Taken
+			prev.NotTaken += branch.NotTaken
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		a, b := merged[i], merged[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.StartLine != b.StartLine {
+			return a.StartLine < b.StartLine
+		}
+		return a.StartCol < b.StartCol
+	})
+	return merged
+}
+
+// GroupBranchesByLine groups sorted branches by their start line,
+// see MergeBranches
+func GroupBranchesByLine(branches []*Branch) []*BranchLine {
+	var lines []*BranchLine
+	var last *BranchLine
+	for _, branch := range branches {
+		if last == nil || last.File != branch.File || last.Line != branch.StartLine {
+			last = &BranchLine{
+				File: branch.File,
+				Line: branch.StartLine,
+			}
+			lines = append(lines, last)
+		}
+		total, covered := branch.Outcomes()
+		last.Total += total
+		last.Covered += covered
+		last.Branches = append(last.Branches, branch)
+	}
+	return lines
+}
@@ -0,0 +1,93 @@
+package coverage
+
+import (
+	"testing"
+)
+
+const testBranchProfile = `mode: branch
+example.com/demo/demo.go:26.5,26.27 if 1 1
+example.com/demo/demo.go:26.5,26.15 cond 1 1
+example.com/demo/demo.go:26.19,26.26 cond 0 1
+example.com/demo/demo.go:30.2,30.15 case 1 0
+example.com/demo/demo.go:29.2,29.10 default 0 0
+example.com/demo/demo.go:32.2,32.16 case 0 0
+`
+
+func TestParseBranchProfile(t *testing.T) {
+	branches, err := ParseBranchProfile(testBranchProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 6 {
+		t.Fatalf("expect 6 branches, actual: %d", len(branches))
+	}
+	cond := branches[2]
+	if cond.File != "example.com/demo/demo.go" || cond.StartLine != 26 || cond.StartCol != 19 || cond.Kind != BranchKindCond || cond.Taken != 0 || cond.NotTaken != 1 {
+		t.Fatalf("unexpected branch: %+v", cond)
+	}
+	if got := FormatBranchProfile(branches); got != testBranchProfile {
+		t.Fatalf("expect format:\n%s\nactual:\n%s", testBranchProfile, got)
+	}
+
+	_, err = ParseBranchProfile("mode: set\nexample.com/demo/demo.go:3.24,5.2 1 1\n")
+	if err == nil {
+		t.Fatalf("expect error for go profile")
+	}
+}
+
+func TestMergeBranches(t *testing.T) {
+	a, err := ParseBranchProfile(testBranchProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseBranchProfile("mode: branch\nexample.com/demo/demo.go:26.19,26.26 cond 2 0\nexample.com/demo/a.go:3.5,3.10 if 1 0\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := MergeBranches(a, b)
+	expect := `mode: branch
+example.com/demo/a.go:3.5,3.10 if 1 0
+example.com/demo/demo.go:26.5,26.27 if 1 1
+example.com/demo/demo.go:26.5,26.15 cond 1 1
+example.com/demo/demo.go:26.19,26.26 cond 2 1
+example.com/demo/demo.go:29.2,29.10 default 0 0
+example.com/demo/demo.go:30.2,30.15 case 1 0
+example.com/demo/demo.go:32.2,32.16 case 0 0
+`
+	if got := FormatBranchProfile(merged); got != expect {
+		t.Fatalf("expect merged:\n%s\nactual:\n%s", expect, got)
+	}
+	// inputs are not modified
+	if a[2].Taken != 0 {
+		t.Fatalf("expect input unchanged, actual: %+v", a[2])
+	}
+}
+
+func TestGroupBranchesByLine(t *testing.T) {
+	branches, err := ParseBranchProfile(testBranchProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := GroupBranchesByLine(MergeBranches(branches))
+	type lineResult struct {
+		Line    int
+		Total   int
+		Covered int
+		Partial bool
+	}
+	expects := []lineResult{
+		{Line: 26, Total: 6, Covered: 5, Partial: true},
+		{Line: 29, Total: 1, Covered: 0, Partial: true},
+		{Line: 30, Total: 1, Covered: 1},
+		{Line: 32, Total: 1, Covered: 0, Partial: true},
+	}
+	if len(lines) != len(expects) {
+		t.Fatalf("expect %d lines, actual: %d", len(expects), len(lines))
+	}
+	for i, line := range lines {
+		got := lineResult{Line: line.Line, Total: line.Total, Covered: line.Covered, Partial: line.Partial()}
+		if got != expects[i] {
+			t.Fatalf("line %d: expect %+v, actual: %+v", i, expects[i], got)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package coverage
+
+import (
+	"encoding/xml"
+	"path"
+	"strconv"
+)
+
+const coberturaHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">
+`
+
+type CoberturaOptions struct {
+	// Source is the directory that file names are relative to,
+	// typically the module root
+	Source string
+	// Timestamp in milliseconds
+	Timestamp int64
+}
+
+// see http://cobertura.sourceforge.net/xml/coverage-04.dtd
+type coberturaCoverage struct {
+	XMLName         xml.Name           `xml:"coverage"`
+	LineRate        string             `xml:"line-rate,attr"`
+	BranchRate      string             `xml:"branch-rate,attr"`
+	LinesCovered    int                `xml:"lines-covered,attr"`
+	LinesValid      int                `xml:"lines-valid,attr"`
+	BranchesCovered int                `xml:"branches-covered,attr"`
+	BranchesValid   int                `xml:"branches-valid,attr"`
+	Complexity      string             `xml:"complexity,attr"`
+	Version         string             `xml:"version,attr"`
+	Timestamp       int64              `xml:"timestamp,attr"`
+	Sources         []string           `xml:"sources>source"`
+	Packages        []coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Name       string           `xml:"name,attr"`
+	LineRate   string           `xml:"line-rate,attr"`
+	BranchRate string           `xml:"branch-rate,attr"`
+	Complexity string           `xml:"complexity,attr"`
+	Classes    []coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Name       string          `xml:"name,attr"`
+	Filename   string          `xml:"filename,attr"`
+	LineRate   string          `xml:"line-rate,attr"`
+	BranchRate string          `xml:"branch-rate,attr"`
+	Complexity string          `xml:"complexity,attr"`
+	Methods    struct{}        `xml:"methods"`
+	Lines      []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Number int   `xml:"number,attr"`
+	Hits   int64 `xml:"hits,attr"`
+}
+
+// FormatCobertura formats files as Cobertura XML, each
+// file is a class, and files are grouped into packages
+// by FileCoverage.Pkg. Branch rates are always 0.
+func FormatCobertura(files []*FileCoverage, opts *CoberturaOptions) (string, error) {
+	if opts == nil {
+		opts = &CoberturaOptions{}
+	}
+	cov := &coberturaCoverage{
+		BranchRate: "0",
+		Complexity: "0",
+		Version:    "xgo",
+		Timestamp:  opts.Timestamp,
+	}
+	if opts.Source != "" {
+		cov.Sources = []string{opts.Source}
+	}
+	pkgIndex := make(map[string]int)
+	var pkgLines []int
+	var pkgHits []int
+	for _, file := range files {
+		idx, ok := pkgIndex[file.Pkg]
+		if !ok {
+			idx = len(cov.Packages)
+			pkgIndex[file.Pkg] = idx
+			cov.Packages = append(cov.Packages, coberturaPackage{
+				Name:       file.Pkg,
+				BranchRate: "0",
+				Complexity: "0",
+			})
+			pkgLines = append(pkgLines, 0)
+			pkgHits = append(pkgHits, 0)
+		}
+		hits := file.HitLines()
+		class := coberturaClass{
+			Name:       path.Base(file.File),
+			Filename:   file.File,
+			LineRate:   formatRate(hits, len(file.Lines)),
+			BranchRate: "0",
+			Complexity: "0",
+			Lines:      make([]coberturaLine, 0, len(file.Lines)),
+		}
+		for _, line := range file.Lines {
+			class.Lines = append(class.Lines, coberturaLine{Number: line.Line, Hits: line.Count})
+		}
+		pkg := &cov.Packages[idx]
+		pkg.Classes = append(pkg.Classes, class)
+		pkgLines[idx] += len(file.Lines)
+		pkgHits[idx] += hits
+		cov.LinesValid += len(file.Lines)
+		cov.LinesCovered += hits
+	}
+	for i := range cov.Packages {
+		cov.Packages[i].LineRate = formatRate(pkgHits[i], pkgLines[i])
+	}
+	cov.LineRate = formatRate(cov.LinesCovered, cov.LinesValid)
+
+	data, err := xml.MarshalIndent(cov, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return coberturaHeader + string(data) + "\n", nil
+}
+
+func formatRate(hit int, total int) string {
+	if total == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(float64(hit)/float64(total), 'f', 4, 64)
+}
@@ -0,0 +1,127 @@
+package coverage
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// FileCoverage is the coverage of a single file,
+// grouped from profile lines
+type FileCoverage struct {
+	// Pkg is the package of the file, i.e. dir
+	// of the file name in the profile
+	Pkg string
+	// File is the mapped file name, see GroupByFile
+	File   string
+	Blocks []*BlockCoverage
+	// Lines are sorted by line number
+	Lines []*LineCoverage
+}
+
+type BlockCoverage struct {
+	Block
+	Count int64
+}
+
+type LineCoverage struct {
+	Line  int
+	Count int64
+}
+
+// GroupByFile groups profile lines by file, blocks without
+// statements are ignored. `mapFile` converts file names in
+// the profile like `example.com/pkg/file.go` into the names
+// expected by consumers, typically relative to the module
+// root like `pkg/file.go`, nil keeps the original name.
+//
+// A line's count is the max count of blocks spanning it.
+// Files are sorted by name.
+func GroupByFile(lines []*CovLine, mapFile func(file string) string) []*FileCoverage {
+	fileMapping := make(map[string]*FileCoverage)
+	var files []*FileCoverage
+	for _, line := range lines {
+		block, ok := ParseBlock(line.Prefix)
+		if !ok || block.NumStmt == 0 {
+			continue
+		}
+		file := block.File
+		if mapFile != nil {
+			file = mapFile(file)
+		}
+		fileCov := fileMapping[file]
+		if fileCov == nil {
+			fileCov = &FileCoverage{Pkg: path.Dir(block.File), File: file}
+			fileMapping[file] = fileCov
+			files = append(files, fileCov)
+		}
+		fileCov.Blocks = append(fileCov.Blocks, &BlockCoverage{
+			Block: *block,
+			Count: line.Count,
+		})
+	}
+	for _, fileCov := range files {
+		countByLine := make(map[int]int64)
+		for _, block := range fileCov.Blocks {
+			for l := block.StartLine; l <= block.EndLine; l++ {
+				count, ok := countByLine[l]
+				if !ok || block.Count > count {
+					countByLine[l] = block.Count
+				}
+			}
+		}
+		fileCov.Lines = make([]*LineCoverage, 0, len(countByLine))
+		for l, count := range countByLine {
+			fileCov.Lines = append(fileCov.Lines, &LineCoverage{Line: l, Count: count})
+		}
+		sort.Slice(fileCov.Lines, func(i, j int) bool {
+			return fileCov.Lines[i].Line < fileCov.Lines[j].Line
+		})
+		sort.SliceStable(fileCov.Blocks, func(i, j int) bool {
+			a, b := fileCov.Blocks[i], fileCov.Blocks[j]
+			if a.StartLine != b.StartLine {
+				return a.StartLine < b.StartLine
+			}
+			return a.StartCol < b.StartCol
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].File < files[j].File
+	})
+	return files
+}
+
+// HitLines returns the number of lines with count > 0
+func (c *FileCoverage) HitLines() int {
+	var n int
+	for _, line := range c.Lines {
+		if line.Count > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Statements returns the total and covered
+// number of statements
+func (c *FileCoverage) Statements() (total int, covered int) {
+	for _, block := range c.Blocks {
+		total += block.NumStmt
+		if block.Count > 0 {
+			covered += block.NumStmt
+		}
+	}
+	return total, covered
+}
+
+// ModuleFileMapper maps `modPath/dir/file.go` to `dir/file.go`,
+// files outside the module are kept unchanged
+func ModuleFileMapper(modPath string) func(file string) string {
+	prefix := modPath + "/"
+	return func(file string) string {
+		if modPath == "" {
+			return file
+		}
+		return strings.TrimPrefix(file, prefix)
+	}
+}
@@ -0,0 +1,94 @@
+package coverage
+
+import (
+	"strings"
+	"testing"
+)
+
+const testProfile = `mode: count
+example.com/demo/sub/sub.go:3.24,5.2 1 0
+example.com/demo/add.go:3.24,4.12 1 2
+example.com/demo/add.go:4.12,6.3 2 0
+example.com/demo/add.go:7.2,7.10 1 2
+example.com/demo/add.go:9.1,9.1 0 0
+github.com/other/lib/lib.go:3.20,5.2 1 1`
+
+func testFiles() []*FileCoverage {
+	_, lines := Parse(testProfile)
+	return GroupByFile(lines, ModuleFileMapper("example.com/demo"))
+}
+
+func TestGroupByFile(t *testing.T) {
+	files := testFiles()
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Pkg+":"+file.File)
+	}
+	expectNames := "example.com/demo:add.go,github.com/other/lib:github.com/other/lib/lib.go,example.com/demo/sub:sub/sub.go"
+	if got := strings.Join(names, ","); got != expectNames {
+		t.Fatalf("expect files %s, actual: %s", expectNames, got)
+	}
+	add := files[0]
+	if len(add.Blocks) != 3 {
+		t.Fatalf("expect 3 blocks, actual: %d", len(add.Blocks))
+	}
+	// line 4 is spanned by a covered and an uncovered block
+	var lines []string
+	for _, line := range add.Lines {
+		lines = append(lines, strings.Repeat("+", int(line.Count)))
+	}
+	expectLines := "++,++,,,++"
+	if got := strings.Join(lines, ","); got != expectLines {
+		t.Fatalf("expect lines %s, actual: %s", expectLines, got)
+	}
+	if total, covered := add.Statements(); total != 4 || covered != 2 {
+		t.Fatalf("expect statements 4/2, actual: %d/%d", total, covered)
+	}
+}
+
+func TestFormatLCOV(t *testing.T) {
+	got := FormatLCOV(testFiles()[:1])
+	expect := `TN:
+SF:add.go
+DA:3,2
+DA:4,2
+DA:5,0
+DA:6,0
+DA:7,2
+LF:5
+LH:3
+end_of_record
+`
+	if got != expect {
+		t.Fatalf("expect lcov:\n%s\nactual:\n%s", expect, got)
+	}
+}
+
+func TestFormatCobertura(t *testing.T) {
+	got, err := FormatCobertura(testFiles(), &CoberturaOptions{Source: "/src/demo", Timestamp: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expects := []string{
+		`<coverage line-rate="0.5455" branch-rate="0" lines-covered="6" lines-valid="11" branches-covered="0" branches-valid="0" complexity="0" version="xgo" timestamp="1">`,
+		`<source>/src/demo</source>`,
+		`<package name="example.com/demo" line-rate="0.6000" branch-rate="0" complexity="0">`,
+		`<class name="sub.go" filename="sub/sub.go" line-rate="0.0000" branch-rate="0" complexity="0">`,
+		`<line number="3" hits="2"></line>`,
+	}
+	for _, expect := range expects {
+		if !strings.Contains(got, expect) {
+			t.Fatalf("expect cobertura to contain %s, actual:\n%s", expect, got)
+		}
+	}
+}
+
+func TestToJSONReport(t *testing.T) {
+	report := ToJSONReport("count", testFiles())
+	if report.Statements != 6 || report.Covered != 3 {
+		t.Fatalf("expect statements 6/3, actual: %d/%d", report.Statements, report.Covered)
+	}
+	if len(report.Files) != 3 || report.Files[2].File != "sub/sub.go" || report.Files[2].Covered != 0 {
+		t.Fatalf("unexpected files: %+v", report.Files)
+	}
+}
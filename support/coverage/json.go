@@ -0,0 +1,79 @@
+package coverage
+
+import "encoding/json"
+
+type JSONReport struct {
+	Mode       string      `json:"mode"`
+	Statements int         `json:"statements"`
+	Covered    int         `json:"covered"`
+	Files      []*JSONFile `json:"files"`
+}
+
+type JSONFile struct {
+	Pkg        string       `json:"pkg"`
+	File       string       `json:"file"`
+	Statements int          `json:"statements"`
+	Covered    int          `json:"covered"`
+	Blocks     []*JSONBlock `json:"blocks"`
+	Lines      []*JSONLine  `json:"lines"`
+}
+
+type JSONBlock struct {
+	StartLine int   `json:"startLine"`
+	StartCol  int   `json:"startCol"`
+	EndLine   int   `json:"endLine"`
+	EndCol    int   `json:"endCol"`
+	NumStmt   int   `json:"numStmt"`
+	Count     int64 `json:"count"`
+}
+
+type JSONLine struct {
+	Line  int   `json:"line"`
+	Count int64 `json:"count"`
+}
+
+// ToJSONReport converts files into a report
+// with statement summaries
+func ToJSONReport(mode string, files []*FileCoverage) *JSONReport {
+	report := &JSONReport{
+		Mode:  mode,
+		Files: make([]*JSONFile, 0, len(files)),
+	}
+	for _, file := range files {
+		total, covered := file.Statements()
+		jsonFile := &JSONFile{
+			Pkg:        file.Pkg,
+			File:       file.File,
+			Statements: total,
+			Covered:    covered,
+			Blocks:     make([]*JSONBlock, 0, len(file.Blocks)),
+			Lines:      make([]*JSONLine, 0, len(file.Lines)),
+		}
+		for _, block := range file.Blocks {
+			jsonFile.Blocks = append(jsonFile.Blocks, &JSONBlock{
+				StartLine: block.StartLine,
+				StartCol:  block.StartCol,
+				EndLine:   block.EndLine,
+				EndCol:    block.EndCol,
+				NumStmt:   block.NumStmt,
+				Count:     block.Count,
+			})
+		}
+		for _, line := range file.Lines {
+			jsonFile.Lines = append(jsonFile.Lines, &JSONLine{Line: line.Line, Count: line.Count})
+		}
+		report.Statements += total
+		report.Covered += covered
+		report.Files = append(report.Files, jsonFile)
+	}
+	return report
+}
+
+// FormatJSON formats files as indented JSON, see JSONReport
+func FormatJSON(mode string, files []*FileCoverage) (string, error) {
+	data, err := json.MarshalIndent(ToJSONReport(mode, files), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
@@ -0,0 +1,36 @@
+package coverage
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FormatLCOV formats files as a LCOV tracefile, see
+// https://manpages.debian.org/unstable/lcov/geninfo.1.en.html#TRACEFILE_FORMAT
+//
+// Only line records(DA,LF,LH) are emitted since go
+// profiles do not carry function or branch info.
+func FormatLCOV(files []*FileCoverage) string {
+	var b strings.Builder
+	b.WriteString("TN:\n")
+	for _, file := range files {
+		b.WriteString("SF:")
+		b.WriteString(file.File)
+		b.WriteString("\n")
+		for _, line := range file.Lines {
+			b.WriteString("DA:")
+			b.WriteString(strconv.Itoa(line.Line))
+			b.WriteString(",")
+			b.WriteString(strconv.FormatInt(line.Count, 10))
+			b.WriteString("\n")
+		}
+		b.WriteString("LF:")
+		b.WriteString(strconv.Itoa(len(file.Lines)))
+		b.WriteString("\n")
+		b.WriteString("LH:")
+		b.WriteString(strconv.Itoa(file.HitLines()))
+		b.WriteString("\n")
+		b.WriteString("end_of_record\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,257 @@
+package coverage
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TestIndex records which blocks are covered by
+// each top-level test, it is generated by
+// `xgo test --cover-per-test <file>`
+type TestIndex struct {
+	Mode string `json:"mode"`
+	// Revision is the git commit the index is generated at,
+	// empty if unknown or .go files had uncommitted changes,
+	// line numbers of Blocks are only valid at this commit
+	Revision string `json:"revision,omitempty"`
+	// Blocks are coverage blocks without count, in the
+	// format of go coverage profiles:
+	//   pkg/file.go:startLine.startCol,endLine.endCol numberOfStatements
+	Blocks []string        `json:"blocks"`
+	Tests  []*TestCoverage `json:"tests"`
+
+	blockIndex map[string]int
+}
+
+type TestCoverage struct {
+	Pkg  string `json:"pkg"`
+	Name string `json:"name"`
+	// Failed is true if the test did not pass
+	Failed bool `json:"failed,omitempty"`
+	// Blocks are indexes of TestIndex.Blocks
+	// covered by the test, sorted
+	Blocks []int `json:"blocks"`
+}
+
+// Block is a parsed coverage block
+type Block struct {
+	File      string
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+	NumStmt   int
+}
+
+// Add records covered lines of a test
+func (c *TestIndex) Add(pkg string, name string, failed bool, lines []*CovLine) *TestCoverage {
+	if c.blockIndex == nil {
+		c.blockIndex = make(map[string]int, len(c.Blocks))
+		for i, block := range c.Blocks {
+			c.blockIndex[block] = i
+		}
+	}
+	test := &TestCoverage{
+		Pkg:    pkg,
+		Name:   name,
+		Failed: failed,
+		Blocks: []int{},
+	}
+	seen := make(map[int]bool)
+	for _, line := range lines {
+		idx, ok := c.blockIndex[line.Prefix]
+		if !ok {
+			idx = len(c.Blocks)
+			c.Blocks = append(c.Blocks, line.Prefix)
+			c.blockIndex[line.Prefix] = idx
+		}
+		if line.Count > 0 && !seen[idx] {
+			seen[idx] = true
+			test.Blocks = append(test.Blocks, idx)
+		}
+	}
+	sort.Ints(test.Blocks)
+	c.Tests = append(c.Tests, test)
+	return test
+}
+
+// FindTest finds a test by name, name can
+// be either `TestX` or `pkg.TestX`
+func (c *TestIndex) FindTest(name string) []*TestCoverage {
+	var tests []*TestCoverage
+	for _, test := range c.Tests {
+		if test.Name == name || test.Pkg+"."+test.Name == name {
+			tests = append(tests, test)
+		}
+	}
+	return tests
+}
+
+// TestsCoveringLine returns tests that cover the given line,
+// `file` matches blocks either by full name like
+// `example.com/pkg/file.go` or by a path suffix like `pkg/file.go`
+func (c *TestIndex) TestsCoveringLine(file string, line int) []*TestCoverage {
+	hitBlocks := make(map[int]bool)
+	for i, prefix := range c.Blocks {
+		block, ok := ParseBlock(prefix)
+		if !ok || !matchFile(block.File, file) {
+			continue
+		}
+		if block.StartLine <= line && line <= block.EndLine {
+			hitBlocks[i] = true
+		}
+	}
+	if len(hitBlocks) == 0 {
+		return nil
+	}
+	var tests []*TestCoverage
+	for _, test := range c.Tests {
+		for _, idx := range test.Blocks {
+			if hitBlocks[idx] {
+				tests = append(tests, test)
+				break
+			}
+		}
+	}
+	return tests
+}
+
+// CovLines returns the coverage of a test as profile
+// lines, which can be formatted by Format
+func (c *TestIndex) CovLines(test *TestCoverage) []*CovLine {
+	covered := make(map[int]bool, len(test.Blocks))
+	for _, idx := range test.Blocks {
+		covered[idx] = true
+	}
+	lines := make([]*CovLine, 0, len(c.Blocks))
+	for i, prefix := range c.Blocks {
+		var count int64
+		if covered[i] {
+			count = 1
+		}
+		lines = append(lines, &CovLine{Prefix: prefix, Count: count})
+	}
+	return lines
+}
+
+// AllCovLines returns the coverage of all tests as profile
+// lines, count is the number of tests covering the block
+func (c *TestIndex) AllCovLines() []*CovLine {
+	lines := make([]*CovLine, 0, len(c.Blocks))
+	for _, prefix := range c.Blocks {
+		lines = append(lines, &CovLine{Prefix: prefix})
+	}
+	for _, test := range c.Tests {
+		for _, idx := range test.Blocks {
+			if idx >= 0 && idx < len(lines) {
+				lines[idx].Count++
+			}
+		}
+	}
+	return lines
+}
+
+// UniqueBlocks returns the number of blocks covered
+// only by the given test
+func (c *TestIndex) UniqueBlocks(test *TestCoverage) int {
+	counts := make(map[int]int)
+	for _, t := range c.Tests {
+		for _, idx := range t.Blocks {
+			counts[idx]++
+		}
+	}
+	var n int
+	for _, idx := range test.Blocks {
+		if counts[idx] == 1 {
+			n++
+		}
+	}
+	return n
+}
+
+func matchFile(blockFile string, file string) bool {
+	file = strings.TrimPrefix(file, "./")
+	return blockFile == file || strings.HasSuffix(blockFile, "/"+file)
+}
+
+// ParseBlock parses a block prefix like
+// `pkg/file.go:34.44,37.40 3`
+func ParseBlock(prefix string) (*Block, bool) {
+	idx := strings.LastIndex(prefix, ":")
+	if idx < 0 {
+		return nil, false
+	}
+	file := prefix[:idx]
+	var pos, numStmt string
+	rest := prefix[idx+1:]
+	spaceIdx := strings.Index(rest, " ")
+	if spaceIdx < 0 {
+		pos = rest
+	} else {
+		pos = rest[:spaceIdx]
+		numStmt = strings.TrimSpace(rest[spaceIdx+1:])
+	}
+	start, end, ok := strings.Cut(pos, ",")
+	if !ok {
+		return nil, false
+	}
+	startLine, startCol, ok := parseLineCol(start)
+	if !ok {
+		return nil, false
+	}
+	endLine, endCol, ok := parseLineCol(end)
+	if !ok {
+		return nil, false
+	}
+	block := &Block{
+		File:      file,
+		StartLine: startLine,
+		StartCol:  startCol,
+		EndLine:   endLine,
+		EndCol:    endCol,
+	}
+	if numStmt != "" {
+		block.NumStmt, _ = strconv.Atoi(numStmt)
+	}
+	return block, true
+}
+
+func parseLineCol(s string) (int, int, bool) {
+	lineStr, colStr, ok := strings.Cut(s, ".")
+	if !ok {
+		return 0, 0, false
+	}
+	line, err := strconv.Atoi(lineStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	col, err := strconv.Atoi(colStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	return line, col, true
+}
+
+func ReadTestIndex(file string) (*TestIndex, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var index TestIndex
+	err = json.Unmarshal(data, &index)
+	if err != nil {
+		return nil, err
+	}
+	return &index, nil
+}
+
+func WriteTestIndex(file string, index *TestIndex) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
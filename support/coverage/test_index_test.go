@@ -0,0 +1,93 @@
+package coverage
+
+import "testing"
+
+func TestTestIndex(t *testing.T) {
+	index := &TestIndex{Mode: "set"}
+	_, linesA := Parse(`mode: set
+example.com/demo/add.go:3.24,5.2 1 1
+example.com/demo/sub.go:3.24,5.2 1 0
+example.com/demo/mul.go:3.24,7.2 2 1`)
+	_, linesB := Parse(`mode: set
+example.com/demo/add.go:3.24,5.2 1 1
+example.com/demo/sub.go:3.24,5.2 1 1
+example.com/demo/mul.go:3.24,7.2 2 0`)
+	_, linesC := Parse(`mode: set
+example.com/demo/add.go:3.24,5.2 1 1
+example.com/demo/sub.go:3.24,5.2 1 0
+example.com/demo/mul.go:3.24,7.2 2 0`)
+	index.Add("example.com/demo", "TestA", false, linesA)
+	index.Add("example.com/demo", "TestB", false, linesB)
+	testC := index.Add("example.com/demo", "TestC", true, linesC)
+
+	if len(index.Blocks) != 3 {
+		t.Fatalf("len(Blocks): %d", len(index.Blocks))
+	}
+	assertTestNames(t, index.TestsCoveringLine("add.go", 4), "TestA", "TestB", "TestC")
+	assertTestNames(t, index.TestsCoveringLine("demo/sub.go", 3), "TestB")
+	assertTestNames(t, index.TestsCoveringLine("example.com/demo/mul.go", 6), "TestA")
+	assertTestNames(t, index.TestsCoveringLine("mul.go", 8))
+	assertTestNames(t, index.TestsCoveringLine("emo/mul.go", 6))
+
+	if n := index.UniqueBlocks(index.FindTest("TestA")[0]); n != 1 {
+		t.Fatalf("UniqueBlocks(TestA): %d", n)
+	}
+	if n := index.UniqueBlocks(testC); n != 0 {
+		t.Fatalf("UniqueBlocks(TestC): %d", n)
+	}
+	if tests := index.FindTest("example.com/demo.TestC"); len(tests) != 1 || !tests[0].Failed {
+		t.Fatalf("FindTest(example.com/demo.TestC): %v", tests)
+	}
+
+	expect := `mode: set
+example.com/demo/add.go:3.24,5.2 1 1
+example.com/demo/sub.go:3.24,5.2 1 1
+example.com/demo/mul.go:3.24,7.2 2 0`
+	if got := Format("set", index.CovLines(index.FindTest("TestB")[0])); got != expect {
+		t.Fatalf("expect CovLines(TestB) to be %q, actual: %q", expect, got)
+	}
+	expectAll := `mode: count
+example.com/demo/add.go:3.24,5.2 1 3
+example.com/demo/sub.go:3.24,5.2 1 1
+example.com/demo/mul.go:3.24,7.2 2 1`
+	if got := Format("count", index.AllCovLines()); got != expectAll {
+		t.Fatalf("expect AllCovLines() to be %q, actual: %q", expectAll, got)
+	}
+}
+
+func TestParseBlock(t *testing.T) {
+	block, ok := ParseBlock("github.com/xhd2015/xgo/runtime/core/func.go:44.41,45.22 3")
+	if !ok {
+		t.Fatalf("expect parse ok")
+	}
+	expect := Block{
+		File:      "github.com/xhd2015/xgo/runtime/core/func.go",
+		StartLine: 44,
+		StartCol:  41,
+		EndLine:   45,
+		EndCol:    22,
+		NumStmt:   3,
+	}
+	if *block != expect {
+		t.Fatalf("expect block to be %+v, actual: %+v", expect, *block)
+	}
+	if _, ok := ParseBlock("func.go:44.41"); ok {
+		t.Fatalf("expect invalid block")
+	}
+}
+
+func assertTestNames(t *testing.T, tests []*TestCoverage, names ...string) {
+	t.Helper()
+	var got []string
+	for _, test := range tests {
+		got = append(got, test.Name)
+	}
+	if len(got) != len(names) {
+		t.Fatalf("expect tests %v, actual: %v", names, got)
+	}
+	for i, name := range names {
+		if got[i] != name {
+			t.Fatalf("expect tests %v, actual: %v", names, got)
+		}
+	}
+}
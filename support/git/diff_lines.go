@@ -0,0 +1,169 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xhd2015/xgo/support/cmd"
+)
+
+// FileChange describes how a file in the working
+// tree differs from a ref
+type FileChange struct {
+	// File is relative to the dir, separated by '/'
+	File string `json:"file"`
+	// Lines are changed line numbers of the new file, sorted.
+	// For deleted lines, lines around the deletion are recorded.
+	// nil if the whole file is considered changed, i.e. added,
+	// deleted, untracked or binary.
+	Lines []int `json:"lines"`
+	// OldLines are changed line numbers of the file at the
+	// ref, sorted. For inserted lines, lines around the
+	// insertion are recorded. nil if Lines is nil.
+	OldLines []int `json:"oldLines,omitempty"`
+	// Deleted is true if the file does not exist any more
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// ListChangedLines lists changes of the working tree, including
+// staged and untracked files, compared to compareRef.
+// Renames are reported as a deletion and an addition.
+func ListChangedLines(dir string, compareRef string) ([]*FileChange, error) {
+	if compareRef == "" {
+		return nil, fmt.Errorf("requires compareRef")
+	}
+	// -U0: no context lines, so hunks are exactly the changes
+	diff, err := cmd.Dir(dir).Output("git", "-c", "core.fileMode=false", "diff", "--relative", "-U0", "--no-color", "--no-ext-diff", "--no-renames", "--ignore-submodules", compareRef, "--")
+	if err != nil {
+		return nil, err
+	}
+	changes := ParseUnifiedDiff(diff)
+
+	untracked, err := cmd.Dir(dir).Output("git", "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range splitLinesFilterEmpty(untracked) {
+		changes = append(changes, &FileChange{File: file})
+	}
+	return changes, nil
+}
+
+// ParseUnifiedDiff parses output of `git diff -U0`
+func ParseUnifiedDiff(diff string) []*FileChange {
+	var changes []*FileChange
+	var cur *FileChange
+	var whole bool
+	finish := func() {
+		if cur == nil {
+			return
+		}
+		if whole || len(cur.Lines) == 0 {
+			// new, deleted, or binary file without hunks
+			cur.Lines = nil
+			cur.OldLines = nil
+		}
+		changes = append(changes, cur)
+		cur = nil
+	}
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			finish()
+			whole = false
+			cur = &FileChange{File: parseDiffGitFile(line)}
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			if line == "--- /dev/null" {
+				whole = true
+			}
+		case strings.HasPrefix(line, "+++ "):
+			if line == "+++ /dev/null" {
+				whole = true
+				cur.Deleted = true
+			} else {
+				cur.File = unquoteDiffFile(strings.TrimPrefix(line, "+++ "), "b/")
+			}
+		case strings.HasPrefix(line, "@@ "):
+			cur.Lines, cur.OldLines = appendHunkLines(cur.Lines, cur.OldLines, line)
+		}
+	}
+	finish()
+	return changes
+}
+
+// parseDiffGitFile extracts the old file from
+// `diff --git a/file b/file`, the new file is
+// read from `+++ b/file` if present
+func parseDiffGitFile(line string) string {
+	s := strings.TrimPrefix(line, "diff --git ")
+	if strings.HasPrefix(s, "a/") {
+		if idx := strings.Index(s, " b/"); idx >= 0 {
+			return s[len("a/"):idx]
+		}
+	}
+	return unquoteDiffFile(s, "a/")
+}
+
+func unquoteDiffFile(s string, prefix string) string {
+	if strings.HasPrefix(s, `"`) {
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			s = unquoted
+		}
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// appendHunkLines parses `@@ -a,b +c,d @@`, `-a,b` are
+// lines of the old file, `+c,d` are lines of the new file
+func appendHunkLines(lines []int, oldLines []int, hunk string) ([]int, []int) {
+	fields := strings.Fields(hunk)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return lines, oldLines
+	}
+	oldStart, oldCount, ok := parseHunkRange(strings.TrimPrefix(fields[1], "-"))
+	if !ok {
+		return lines, oldLines
+	}
+	start, count, ok := parseHunkRange(strings.TrimPrefix(fields[2], "+"))
+	if !ok {
+		return lines, oldLines
+	}
+	return appendRangeLines(lines, start, count), appendRangeLines(oldLines, oldStart, oldCount)
+}
+
+// parseHunkRange parses `start,count`, count defaults to 1
+func parseHunkRange(s string) (start int, count int, ok bool) {
+	startStr, countStr, hasCount := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	count = 1
+	if hasCount {
+		count, err = strconv.Atoi(countStr)
+		if err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, count, true
+}
+
+func appendRangeLines(lines []int, start int, count int) []int {
+	if count == 0 {
+		// nothing on this side, the change is after line start
+		if start > 0 {
+			lines = append(lines, start)
+		}
+		return append(lines, start+1)
+	}
+	for i := 0; i < count; i++ {
+		lines = append(lines, start+i)
+	}
+	return lines
+}
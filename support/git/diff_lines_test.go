@@ -0,0 +1,62 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseUnifiedDiff(t *testing.T) {
+	diff := `diff --git a/demo.go b/demo.go
+index 1111111..2222222 100644
+--- a/demo.go
++++ b/demo.go
+@@ -3 +3 @@ import "fmt"
+-	a := 1
++	a := 2
+@@ -10,2 +10,3 @@ func f() {
++	x()
++	y()
++	z()
+@@ -20,2 +21,0 @@ func g() {
+-	removed()
+-	removed()
+diff --git a/new.go b/new.go
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,2 @@
++package demo
++
+diff --git a/old.go b/old.go
+deleted file mode 100644
+index 4444444..0000000
+--- a/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package demo
+diff --git a/logo.png b/logo.png
+index 5555555..6666666 100644
+Binary files a/logo.png and b/logo.png differ
+`
+	changes := ParseUnifiedDiff(diff)
+	var actual []string
+	for _, c := range changes {
+		actual = append(actual, fmt.Sprintf("%s %v %v deleted=%v", c.File, c.Lines, c.OldLines, c.Deleted))
+	}
+	expect := []string{
+		"demo.go [3 10 11 12 21 22] [3 10 11 20 21] deleted=false",
+		"new.go [] [] deleted=false",
+		"old.go [] [] deleted=true",
+		"logo.png [] [] deleted=false",
+	}
+	if strings.Join(actual, "\n") != strings.Join(expect, "\n") {
+		t.Fatalf("expect:\n%s\nactual:\n%s", strings.Join(expect, "\n"), strings.Join(actual, "\n"))
+	}
+	for _, c := range changes[1:] {
+		if c.Lines != nil {
+			t.Fatalf("expect whole file change: %s", c.File)
+		}
+	}
+}
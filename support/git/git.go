@@ -21,6 +21,34 @@ func GetCurrentBranch(dir string) (string, error) {
 	return cmd.Dir(dir).Output("git", "branch", "--show-current")
 }
 
+func GetHeadCommit(dir string) (string, error) {
+	return cmd.Dir(dir).Output("git", "rev-parse", "HEAD")
+}
+
+// GetCommit resolves ref to its commit
+func GetCommit(dir string, ref string) (string, error) {
+	return cmd.Dir(dir).Output("git", "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+}
+
+// HasChanges reports whether the whole worktree of dir has
+// uncommitted changes, including untracked files, except
+// those under excludes
+func HasChanges(dir string, excludes ...string) (bool, error) {
+	args := []string{"status", "--porcelain"}
+	if len(excludes) > 0 {
+		// ":/" is the worktree root, regardless of dir
+		args = append(args, "--", ":/")
+		for _, exclude := range excludes {
+			args = append(args, ":(exclude)"+exclude)
+		}
+	}
+	output, err := cmd.Dir(dir).Output("git", args...)
+	if err != nil {
+		return false, err
+	}
+	return output != "", nil
+}
+
 func FetchRef(dir string, origin string, ref string) error {
 	if origin == "" {
 		return fmt.Errorf("requires origin")
@@ -0,0 +1,55 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestHasChangesOutsideDir(t *testing.T) {
+	root := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		c := exec.Command("git", args...)
+		c.Dir = root
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	write := func(file string, content string) {
+		t.Helper()
+		file = filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "-q")
+	write("pkg/a.go", "package pkg\n")
+	write("other/b.go", "package other\n")
+	run("add", ".")
+	run("-c", "user.name=test", "-c", "user.email=test@test", "commit", "-q", "-m", "init")
+
+	pkgDir := filepath.Join(root, "pkg")
+	excludeDir := filepath.Join(root, ".xgo")
+	write(".xgo/bench.jsonl", "{}\n")
+	dirty, err := HasChanges(pkgDir, excludeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirty {
+		t.Fatalf("expect excluded files not to make it dirty")
+	}
+
+	write("other/b.go", "package other\n\nvar X int\n")
+	dirty, err = HasChanges(pkgDir, excludeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dirty {
+		t.Fatalf("expect changes outside dir to make it dirty")
+	}
+}
@@ -23,14 +23,29 @@ type Package struct {
 	// package name of normal file is xxx
 	XTestGoFiles []string
 
-	Goroot   bool // is this package in the Go root?
-	Standard bool // is this package part of the standard Go library?
-	DepOnly  bool // package is only a dependency, not explicitly listed
+	Imports      []string // import paths used by this package
+	Deps         []string // all (recursively) imported dependencies
+	TestImports  []string // imports from TestGoFiles
+	XTestImports []string // imports from XTestGoFiles
+
+	Goroot   bool    // is this package in the Go root?
+	Standard bool    // is this package part of the standard Go library?
+	DepOnly  bool    // package is only a dependency, not explicitly listed
+	Module   *Module // info about package's containing module, if any (can be nil)
 
 	Incomplete bool          // this package or a dependency has an error
 	Error      *PackageError // error loading package
 }
 
+// check 'go help list', only a subset
+type Module struct {
+	Path    string  // module path
+	Version string  // module version
+	Replace *Module // replaced by this module
+	Main    bool    // is this the main module?
+	Dir     string  // directory holding files for this module, if any
+}
+
 type PackageError struct {
 	ImportStack []string // shortest path from package named on command line to this one
 	Pos         string   // position of error (if present, file:line:col)
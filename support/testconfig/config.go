@@ -35,6 +35,10 @@ type Config struct {
 	MockRules []string        `json:"mock_rules"`
 	Xgo       *XgoConfig      `json:"xgo,omitempty"`
 	Coverage  *CoverageConfig `json:"coverage,omitempty"`
+
+	// Patches are dirs of .xgo.patch files passed to xgo as --patch-dir,
+	// relative paths are resolved against the dir of the config file.
+	Patches []string `json:"patches"`
 }
 
 // GoConfig is the go.min / go.max constraint block.
@@ -55,6 +59,19 @@ type CoverageConfig struct {
 	Profile  string   `json:"profile"`
 	Include  []string `json:"include"`
 	Exclude  []string `json:"exclude"`
+
+	// MinTotal and MinDiff are percentages checked by
+	// `xgo tool coverage check`, 0 means no threshold.
+	MinTotal float64 `json:"min_total"`
+	MinDiff  float64 `json:"min_diff"`
+	// PerPackage also applies the thresholds to each package.
+	PerPackage bool `json:"per_package"`
+
+	// TestIndex is a per-test coverage index written by
+	// `xgo test --cover-per-test`, relative to the project dir.
+	// If present, running affected tests only selects tests
+	// covering the changed lines.
+	TestIndex string `json:"test_index"`
 }
 
 // EnvPairs returns KEY=value strings for child processes (stable key order not guaranteed).
@@ -194,6 +211,14 @@ func Parse(data []byte) (*Config, error) {
 		conf.MockRules = list
 	}
 
+	if e, ok := m["patches"]; ok && e != nil {
+		list, err := toStringList(e)
+		if err != nil {
+			return nil, fmt.Errorf("patches: %w", err)
+		}
+		conf.Patches = list
+	}
+
 	if e, ok := m["xgo"]; ok && e != nil {
 		if err := copyViaJSON(e, &conf.Xgo); err != nil {
 			return nil, fmt.Errorf("xgo: %w", err)
@@ -59,6 +59,26 @@ func TestParseMockRulesAndBypass(t *testing.T) {
 	}
 }
 
+func TestParsePatches(t *testing.T) {
+	cfg, err := Parse([]byte(`{"patches":["xgo-patches","/abs/patches"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Patches) != 2 || cfg.Patches[0] != "xgo-patches" || cfg.Patches[1] != "/abs/patches" {
+		t.Fatalf("Patches=%v", cfg.Patches)
+	}
+	cfg, err = Parse([]byte(`{"patches":"xgo-patches"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Patches) != 1 || cfg.Patches[0] != "xgo-patches" {
+		t.Fatalf("Patches=%v", cfg.Patches)
+	}
+	if _, err := Parse([]byte(`{"patches":[1]}`)); err == nil {
+		t.Fatal("expect error for non-string patches")
+	}
+}
+
 func TestLoadMissing(t *testing.T) {
 	cfg, err := Load(filepath.Join(t.TempDir(), "nope.json"))
 	if err != nil || cfg != nil {
@@ -124,3 +144,14 @@ func TestValidateGoConstraintIgnoresBadToken(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestParseCoverageThresholds(t *testing.T) {
+	cfg, err := Parse([]byte(`{"coverage":{"diff_with":"origin/main","min_total":70,"min_diff":80.5,"per_package":true}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.Coverage
+	if c == nil || c.DiffWith != "origin/main" || c.MinTotal != 70 || c.MinDiff != 80.5 || !c.PerPackage {
+		t.Fatalf("Coverage=%#v", c)
+	}
+}